@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	alertRulesStateFile     = "alert_rules.json"
+	alertRuleCheckInterval  = 30 * time.Second
+	defaultAlertRuleWindow  = 3600 // seconds
+	alertRuleStateOK        = "ok"
+	alertRuleStatePending   = "pending"
+	alertRuleStateFiring    = "firing"
+	alertRuleEventBacklog   = 1000
+	alertRuleEventQueueSize = 64
+)
+
+// alertRuleOps are the comparisons a metric rule may use.
+var alertRuleOps = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// AlertRule raises an alert on a condition over the sidecar's own metrics,
+// as served at /autoscale/metrics and /server/resources/metrics, or over
+// events on the event bus. A metric rule fires once Metric Op Threshold has
+// held for ForSeconds; an event rule fires once Count events of type Event
+// arrived within WindowSeconds. Both resolve when the condition clears.
+type AlertRule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+
+	Metric     string  `json:"metric,omitempty"`
+	Op         string  `json:"op,omitempty"`
+	Threshold  float64 `json:"threshold"`
+	ForSeconds int     `json:"for_seconds,omitempty"`
+
+	Event         string `json:"event,omitempty"`
+	Count         int    `json:"count,omitempty"`
+	WindowSeconds int    `json:"window_seconds,omitempty"`
+
+	Message string `json:"message,omitempty"`
+
+	State       string    `json:"state"`
+	Value       float64   `json:"value"`
+	ActiveSince time.Time `json:"active_since,omitempty"`
+	LastFired   time.Time `json:"last_fired,omitempty"`
+}
+
+var (
+	alertRules = make([]AlertRule, 0)
+	// alertEventTimes are when recent bus events arrived, by type.
+	alertEventTimes = map[string][]time.Time{}
+	alertRuleMutex  sync.Mutex
+)
+
+// validate checks a rule for completeness and fills in defaults.
+func (rule *AlertRule) validate() error {
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch {
+	case rule.Metric != "" && rule.Event != "":
+		return fmt.Errorf("a rule has either a metric or an event, not both")
+	case rule.Metric != "":
+		if alertRuleOps[rule.Op] == nil {
+			return fmt.Errorf("op must be one of >, >=, <, <=, == or !=")
+		}
+		if rule.ForSeconds < 0 {
+			return fmt.Errorf("for_seconds must not be negative")
+		}
+	case rule.Event != "":
+		if rule.Count == 0 {
+			rule.Count = 1
+		}
+		if rule.Count < 0 {
+			return fmt.Errorf("count must be positive")
+		}
+		if rule.WindowSeconds == 0 {
+			rule.WindowSeconds = defaultAlertRuleWindow
+		}
+		if rule.WindowSeconds < 0 {
+			return fmt.Errorf("window_seconds must be positive")
+		}
+	default:
+		return fmt.Errorf("metric or event is required")
+	}
+	return nil
+}
+
+// describe explains why the rule fires.
+func (rule *AlertRule) describe() string {
+	if rule.Message != "" {
+		return rule.Message
+	}
+	if rule.Metric != "" {
+		text := fmt.Sprintf("%s: %s is %s (%s %s", rule.Name, rule.Metric, formatMetric(rule.Value), rule.Op, formatMetric(rule.Threshold))
+		if rule.ForSeconds > 0 {
+			text += " for " + (time.Duration(rule.ForSeconds) * time.Second).String()
+		}
+		return text + ")"
+	}
+	return fmt.Sprintf("%s: %d %s events in the last %s", rule.Name, int(rule.Value), rule.Event, time.Duration(rule.WindowSeconds)*time.Second)
+}
+
+// loadAlertRules restores rules from the state directory.
+func loadAlertRules() error {
+	alertRuleMutex.Lock()
+	defer alertRuleMutex.Unlock()
+	if err := loadState(alertRulesStateFile, &alertRules); err != nil {
+		return err
+	}
+	for i := range alertRules {
+		if alertRules[i].State == "" {
+			resetAlertRule(&alertRules[i])
+		}
+	}
+	return nil
+}
+
+// saveAlertRules persists rules. Callers must hold alertRuleMutex.
+func saveAlertRules() {
+	if err := saveState(alertRulesStateFile, alertRules); err != nil {
+		log.Printf("Error saving alert rules: %v", err)
+	}
+	configChanged()
+}
+
+// resetAlertRule clears a rule's evaluation state.
+func resetAlertRule(rule *AlertRule) {
+	rule.State, rule.Value, rule.ActiveSince = alertRuleStateOK, 0, time.Time{}
+}
+
+// updateAlertRule applies one evaluation of rule i, alerting when it starts
+// or stops firing. Callers must hold alertRuleMutex.
+func updateAlertRule(i int, holds bool, value float64, now time.Time) {
+	rule := &alertRules[i]
+	if !holds {
+		firing := rule.State == alertRuleStateFiring
+		resetAlertRule(rule)
+		rule.Value = value
+		if firing {
+			fields := map[string]interface{}{"rule": rule.ID, "name": rule.Name, "value": value}
+			sendAlert("alert_rule_resolved", rule.Name+" resolved", fields)
+			saveAlertRules()
+		}
+		return
+	}
+	rule.Value = value
+	if rule.State == alertRuleStateFiring {
+		return
+	}
+	if rule.ActiveSince.IsZero() {
+		rule.ActiveSince = now
+	}
+	rule.State = alertRuleStatePending
+	if now.Sub(rule.ActiveSince) < time.Duration(rule.ForSeconds)*time.Second {
+		return
+	}
+	rule.State, rule.LastFired = alertRuleStateFiring, now
+	fields := map[string]interface{}{"rule": rule.ID, "name": rule.Name, "value": value}
+	if rule.Metric != "" {
+		fields["metric"], fields["op"], fields["threshold"] = rule.Metric, rule.Op, rule.Threshold
+	} else {
+		fields["event"], fields["count"], fields["window_seconds"] = rule.Event, rule.Count, rule.WindowSeconds
+	}
+	sendAlert("alert_rule", rule.describe(), fields)
+	saveAlertRules()
+}
+
+// evaluateAlertRules checks the enabled rules. Metric rules are skipped
+// when samples is nil.
+func evaluateAlertRules(samples map[string]float64, now time.Time) {
+	alertRuleMutex.Lock()
+	defer alertRuleMutex.Unlock()
+	// Keep event times only as long as some rule looks back.
+	windows := map[string]time.Duration{}
+	for i, rule := range alertRules {
+		if !rule.Enabled {
+			continue
+		}
+		if rule.Event != "" {
+			window := time.Duration(rule.WindowSeconds) * time.Second
+			windows[rule.Event] = max(windows[rule.Event], window)
+			n := 0
+			for _, t := range alertEventTimes[rule.Event] {
+				if now.Sub(t) <= window {
+					n++
+				}
+			}
+			updateAlertRule(i, n >= rule.Count, float64(n), now)
+		} else if samples != nil {
+			v, ok := samples[rule.Metric]
+			updateAlertRule(i, ok && alertRuleOps[rule.Op](v, rule.Threshold), v, now)
+		}
+	}
+	for event, times := range alertEventTimes {
+		kept := times[:0]
+		for _, t := range times {
+			if now.Sub(t) <= windows[event] {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			delete(alertEventTimes, event)
+		} else {
+			alertEventTimes[event] = kept
+		}
+	}
+}
+
+// alertRulesNeedMetrics reports whether an enabled rule watches a metric.
+func alertRulesNeedMetrics() bool {
+	alertRuleMutex.Lock()
+	defer alertRuleMutex.Unlock()
+	for _, rule := range alertRules {
+		if rule.Enabled && rule.Metric != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAlertEvent notes a bus event for event rules and evaluates them.
+func recordAlertEvent(ev BusEvent) {
+	alertRuleMutex.Lock()
+	watched := false
+	for _, rule := range alertRules {
+		if rule.Enabled && rule.Event == ev.Type {
+			watched = true
+			break
+		}
+	}
+	if watched {
+		times := append(alertEventTimes[ev.Type], time.Now())
+		if len(times) > alertRuleEventBacklog {
+			times = times[len(times)-alertRuleEventBacklog:]
+		}
+		alertEventTimes[ev.Type] = times
+	}
+	alertRuleMutex.Unlock()
+	if watched {
+		evaluateAlertRules(nil, time.Now())
+	}
+}
+
+// startAlertRuleEngine evaluates metric rules every thirty seconds and
+// event rules as events arrive. Alerts go to BEDROCK_API_ALERT_WEBHOOKS
+// and the event bus, like the sidecar's built-in alerts.
+func startAlertRuleEngine() {
+	ch := make(chan BusEvent, alertRuleEventQueueSize)
+	busMutex.Lock()
+	busSubscribers[ch] = struct{}{}
+	busMutex.Unlock()
+	go func() {
+		for ev := range ch {
+			recordAlertEvent(ev)
+		}
+	}()
+	go func() {
+		for range time.Tick(alertRuleCheckInterval) {
+			var samples map[string]float64
+			if alertRulesNeedMetrics() {
+				samples = map[string]float64{}
+				for _, m := range append(autoscaleMetrics(), resourceMetrics()...) {
+					samples[m.Name] = m.Value
+				}
+			}
+			evaluateAlertRules(samples, time.Now())
+		}
+	}()
+}
+
+// alertRulesHandler lists (GET) and creates (POST) alert rules.
+func alertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		alertRuleMutex.Lock()
+		defer alertRuleMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"rules": alertRules})
+	case http.MethodPost:
+		var rule AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := rule.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule.ID = newUUID()
+		resetAlertRule(&rule)
+		rule.LastFired = time.Time{}
+		alertRuleMutex.Lock()
+		alertRules = append(alertRules, rule)
+		saveAlertRules()
+		alertRuleMutex.Unlock()
+		writeJSONResponse(w, http.StatusCreated, rule)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// alertRuleHandler reads, replaces or deletes a single rule by ID.
+// Replacing a rule starts its evaluation afresh.
+func alertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/alerts/rules/")
+	alertRuleMutex.Lock()
+	defer alertRuleMutex.Unlock()
+	index := -1
+	for i, rule := range alertRules {
+		if rule.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 && r.Method != http.MethodPut {
+		writeJSONError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, alertRules[index])
+	case http.MethodPut:
+		// PUT upserts, so callers may choose stable rule IDs.
+		var rule AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := rule.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule.ID = id
+		resetAlertRule(&rule)
+		if index < 0 {
+			if !validName(id) {
+				writeJSONError(w, http.StatusBadRequest, "Invalid rule id")
+				return
+			}
+			rule.LastFired = time.Time{}
+			alertRules = append(alertRules, rule)
+			saveAlertRules()
+			writeJSONResponse(w, http.StatusCreated, rule)
+			return
+		}
+		rule.LastFired = alertRules[index].LastFired
+		alertRules[index] = rule
+		saveAlertRules()
+		writeJSONResponse(w, http.StatusOK, rule)
+	case http.MethodDelete:
+		alertRules = append(alertRules[:index], alertRules[index+1:]...)
+		saveAlertRules()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Rule deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// webhookURLs returns the comma-separated webhook URLs in an environment
+// variable.
+func webhookURLs(env string) []string {
+	var hooks []string
+	for _, hook := range strings.Split(os.Getenv(env), ",") {
+		if hook = strings.TrimSpace(hook); hook != "" {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// sendAlert posts an alert event to every webhook in
+// BEDROCK_API_ALERT_WEBHOOKS.
+func sendAlert(event, message string, fields map[string]interface{}) {
+	log.Printf("Alert %s: %s", event, message)
+	emitEvent(webhookURLs(alertWebhooksEnv), event, message, fields)
+}
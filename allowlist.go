@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	allowlistPath   = filepath.Join(dataDir, "allowlist.json")
+	permissionsPath = filepath.Join(dataDir, "permissions.json")
+	// allowlistMutex guards both allowlist.json and permissions.json.
+	allowlistMutex sync.Mutex
+
+	xuidLookupClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// AllowlistEntry is an entry of the dedicated server's allowlist.json. The
+// server fills in a missing XUID the first time the player joins.
+type AllowlistEntry struct {
+	Name               string `json:"name"`
+	XUID               string `json:"xuid,omitempty"`
+	IgnoresPlayerLimit bool   `json:"ignoresPlayerLimit"`
+}
+
+// PermissionEntry is an entry of the dedicated server's permissions.json.
+type PermissionEntry struct {
+	Permission string `json:"permission"`
+	XUID       string `json:"xuid"`
+}
+
+// importedPlayer is a player read from an import file. Permission is empty
+// unless the file grants one.
+type importedPlayer struct {
+	Name               string
+	XUID               string
+	IgnoresPlayerLimit bool
+	Permission         string
+}
+
+// AllowlistImportResult reports what happened to one imported player.
+type AllowlistImportResult struct {
+	Name       string   `json:"name"`
+	XUID       string   `json:"xuid,omitempty"`
+	Action     string   `json:"action"` // added, updated or unchanged
+	Permission string   `json:"permission,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// parseJavaPlayers reads a Java whitelist.json or ops.json, or a Bedrock
+// allowlist.json. Java UUIDs mean nothing to Bedrock and are dropped; ops
+// become operators.
+func parseJavaPlayers(data []byte) ([]importedPlayer, error) {
+	var entries []struct {
+		Name                string          `json:"name"`
+		XUID                json.RawMessage `json:"xuid"`
+		Level               *int            `json:"level"`
+		IgnoresPlayerLimit  bool            `json:"ignoresPlayerLimit"`
+		BypassesPlayerLimit bool            `json:"bypassesPlayerLimit"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	players := make([]importedPlayer, 0, len(entries))
+	for _, e := range entries {
+		p := importedPlayer{Name: e.Name, XUID: jsonXUID(e.XUID), IgnoresPlayerLimit: e.IgnoresPlayerLimit || e.BypassesPlayerLimit}
+		if e.Level != nil && *e.Level > 0 {
+			p.Permission = "operator"
+		}
+		players = append(players, p)
+	}
+	return players, nil
+}
+
+// parseCSVPlayers reads a CSV of gamertags. An optional header row names
+// the columns name (or gamertag), xuid, permission and ignores_player_limit;
+// without one the columns are taken in that order.
+func parseCSVPlayers(data []byte) ([]importedPlayer, error) {
+	rd := csv.NewReader(bytes.NewReader(data))
+	rd.FieldsPerRecord = -1
+	rd.TrimLeadingSpace = true
+	rows, err := rd.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{"name": 0, "xuid": 1, "permission": 2, "ignores_player_limit": 3}
+	if len(rows) > 0 {
+		first := strings.ToLower(strings.TrimSpace(rows[0][0]))
+		if first == "name" || first == "gamertag" {
+			cols = map[string]int{}
+			for i, h := range rows[0] {
+				h = strings.ToLower(strings.TrimSpace(h))
+				if h == "gamertag" {
+					h = "name"
+				}
+				cols[h] = i
+			}
+			rows = rows[1:]
+		}
+	}
+	field := func(row []string, col string) string {
+		if i, ok := cols[col]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	var players []importedPlayer
+	for _, row := range rows {
+		p := importedPlayer{Name: field(row, "name"), XUID: field(row, "xuid"), Permission: strings.ToLower(field(row, "permission"))}
+		p.IgnoresPlayerLimit = field(row, "ignores_player_limit") == "true"
+		if p.Name == "" && p.XUID == "" {
+			continue
+		}
+		players = append(players, p)
+	}
+	return players, nil
+}
+
+// jsonXUID accepts an XUID given as a JSON string or number.
+func jsonXUID(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
+
+// lookupXUID resolves a gamertag with the service in
+// BEDROCK_API_XUID_LOOKUP_URL, where {gamertag} is replaced by the escaped
+// gamertag and the response is a JSON object with an "xuid" field.
+func lookupXUID(gamertag string) (string, error) {
+	tmpl := os.Getenv(xuidLookupURLEnv)
+	if tmpl == "" {
+		return "", errors.New("no lookup service configured")
+	}
+	resp, err := xuidLookupClient.Get(strings.ReplaceAll(tmpl, "{gamertag}", url.PathEscape(gamertag)))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errors.New("gamertag not found")
+	} else if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("lookup returned %s", resp.Status)
+	}
+	var body struct {
+		XUID json.RawMessage `json:"xuid"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body); err != nil {
+		return "", err
+	}
+	if xuid := jsonXUID(body.XUID); xuid != "" && xuid != "0" {
+		return xuid, nil
+	}
+	return "", errors.New("gamertag not found")
+}
+
+// readJSONList reads a JSON array file, treating a missing file as empty.
+func readJSONList(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSONList writes a JSON array file in the server's indented layout.
+func writeJSONList(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// importAllowlist merges players into allowlist.json, or replaces it, and
+// grants the permissions they carry in permissions.json. Players are
+// matched by XUID, then case-insensitively by name.
+func importAllowlist(players []importedPlayer, replace bool) ([]AllowlistImportResult, error) {
+	allowlistMutex.Lock()
+	defer allowlistMutex.Unlock()
+	allowlist := []AllowlistEntry{}
+	if !replace {
+		if err := readJSONList(allowlistPath, &allowlist); err != nil {
+			return nil, fmt.Errorf("reading allowlist: %w", err)
+		}
+	}
+	perms := []PermissionEntry{}
+	if err := readJSONList(permissionsPath, &perms); err != nil {
+		return nil, fmt.Errorf("reading permissions: %w", err)
+	}
+	permsChanged := false
+
+	results := make([]AllowlistImportResult, 0, len(players))
+	for _, p := range players {
+		res := AllowlistImportResult{Name: p.Name}
+		idx := -1
+		for i, e := range allowlist {
+			if (p.XUID != "" && e.XUID == p.XUID) || (p.Name != "" && strings.EqualFold(e.Name, p.Name)) {
+				idx = i
+				break
+			}
+		}
+		if p.XUID == "" && p.Name != "" && (idx < 0 || allowlist[idx].XUID == "") {
+			if xuid, err := lookupXUID(p.Name); err != nil {
+				res.Warnings = append(res.Warnings, "XUID not resolved ("+err.Error()+"); the server fills it in on first join")
+			} else {
+				p.XUID = xuid
+			}
+		}
+		entry := AllowlistEntry{Name: p.Name, XUID: p.XUID, IgnoresPlayerLimit: p.IgnoresPlayerLimit}
+		switch {
+		case idx < 0:
+			allowlist = append(allowlist, entry)
+			res.Action = "added"
+		default:
+			if entry.Name == "" {
+				entry.Name = allowlist[idx].Name
+			}
+			if entry.XUID == "" {
+				entry.XUID = allowlist[idx].XUID
+			}
+			if entry == allowlist[idx] {
+				res.Action = "unchanged"
+			} else {
+				allowlist[idx] = entry
+				res.Action = "updated"
+			}
+		}
+
+		res.XUID = entry.XUID
+
+		switch p.Permission {
+		case "":
+		case "operator", "member", "visitor":
+			if entry.XUID == "" {
+				res.Warnings = append(res.Warnings, "permission needs an XUID and was not granted")
+				break
+			}
+			found := false
+			for i := range perms {
+				if perms[i].XUID == entry.XUID {
+					found = true
+					if perms[i].Permission != p.Permission {
+						perms[i].Permission = p.Permission
+						permsChanged = true
+					}
+				}
+			}
+			if !found {
+				perms = append(perms, PermissionEntry{Permission: p.Permission, XUID: entry.XUID})
+				permsChanged = true
+			}
+			res.Permission = p.Permission
+		default:
+			res.Warnings = append(res.Warnings, "unknown permission "+p.Permission)
+		}
+		results = append(results, res)
+	}
+
+	if err := writeJSONList(allowlistPath, allowlist); err != nil {
+		return nil, err
+	}
+	if permsChanged {
+		if err := writeJSONList(permissionsPath, perms); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// allowlistImportHandler imports players from a Java whitelist.json or
+// ops.json (a JSON array) or a CSV of gamertags into allowlist.json. The
+// format is detected from the body unless ?format=json|csv is given, and
+// ?mode=replace replaces the allowlist instead of merging into it. A
+// running server is told to reload both files.
+func allowlistImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			format = "json"
+		}
+	}
+	var players []importedPlayer
+	switch format {
+	case "json":
+		players, err = parseJavaPlayers(data)
+	case "csv":
+		players, err = parseCSVPlayers(data)
+	default:
+		writeJSONError(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid "+format+": "+err.Error())
+		return
+	}
+	if len(players) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "No players to import")
+		return
+	}
+	results, err := importAllowlist(players, r.URL.Query().Get("mode") == "replace")
+	if err != nil {
+		log.Printf("Error importing allowlist: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	reloaded := false
+	if serverUp() {
+		reloaded = sendServerCommand("allowlist reload") == nil && sendServerCommand("permission reload") == nil
+	}
+	log.Printf("Imported %d players into the allowlist", len(results))
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"imported": len(results), "reloaded": reloaded, "players": results})
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API key roles, from least to most privileged. Viewers may only read,
+// operators may also change things, and admins may use admin endpoints.
+const (
+	roleViewer   = "viewer"
+	roleOperator = "operator"
+	roleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{roleViewer: 1, roleOperator: 2, roleAdmin: 3}
+
+// APIKey is a named static key accepted in the X-API-Key header, or from
+// browser WebSockets as a subprotocol (see webSocketToken). Role defaults to
+// operator.
+type APIKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Role string `json:"role,omitempty"`
+}
+
+// apiKeysDocument is the keys file. Endpoints maps path prefixes to the
+// minimum role they require, overriding the default of viewer for reads
+// and operator for writes.
+type apiKeysDocument struct {
+	Keys      []APIKey          `json:"keys"`
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+}
+
+// apiKeyEntry is a loaded key, held only as a hash.
+type apiKeyEntry struct {
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Source string `json:"source"`
+	hash   string
+}
+
+var (
+	apiKeys          []apiKeyEntry
+	endpointRoles    map[string]string
+	apiKeysModTime   time.Time
+	apiKeysLastError string
+	apiKeysMutex     sync.RWMutex
+)
+
+// apiKeyExempt reports whether a request authenticates by other means:
+// the web UI page, the game's token-checked WebSocket, HMAC-signed webhooks, bridge
+// events with their own token and player tokens.
+func apiKeyExempt(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case path == "/" && (r.Method == http.MethodGet || isWebSocketUpgrade(r)):
+		return true
+	case path == "/mcws/connect", path == "/openapi.json":
+		return true
+	case path == "/bridge/events" && r.Method == http.MethodPost:
+		return true
+	case strings.HasPrefix(path, "/hooks/") && r.Method == http.MethodPost:
+		return true
+	case strings.HasPrefix(path, "/stream/webhook/"):
+		return true
+	case path == "/me" || strings.HasPrefix(path, "/me/"):
+		return true
+	case strings.HasPrefix(path, "/shared/") && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		return true
+	}
+	return false
+}
+
+// parseAPIKeys decodes a keys file: YAML or JSON with a "keys" list and
+// optional "endpoints" role overrides.
+func parseAPIKeys(data []byte) (apiKeysDocument, error) {
+	var parsed apiKeysDocument
+	doc, err := parseYAML(data)
+	if err != nil || doc == nil {
+		return parsed, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return parsed, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&parsed); err != nil {
+		return parsed, err
+	}
+	seen := make(map[string]bool)
+	for i := range parsed.Keys {
+		k := &parsed.Keys[i]
+		if !validName(k.Name) || seen[k.Name] {
+			return parsed, fmt.Errorf("keys[%d]: missing or duplicate name", i)
+		}
+		if len(k.Key) < 16 {
+			return parsed, fmt.Errorf("keys[%d]: key must be at least 16 characters", i)
+		}
+		if k.Role == "" {
+			k.Role = roleOperator
+		}
+		if roleRank[k.Role] == 0 {
+			return parsed, fmt.Errorf("keys[%d]: unknown role %q", i, k.Role)
+		}
+		seen[k.Name] = true
+	}
+	for prefix, role := range parsed.Endpoints {
+		if !strings.HasPrefix(prefix, "/") || roleRank[role] == 0 {
+			return parsed, fmt.Errorf("endpoints[%s]: invalid path or role", prefix)
+		}
+	}
+	return parsed, nil
+}
+
+// parseEnvAPIKey splits an environment key of the form "key" or
+// "key:role".
+func parseEnvAPIKey(v string) (key, role string) {
+	if i := strings.LastIndex(v, ":"); i > 0 && roleRank[v[i+1:]] > 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, roleOperator
+}
+
+// loadAPIKeys replaces the accepted keys with those from the environment
+// and the keys file. A broken keys file keeps the previous keys.
+func loadAPIKeys() error {
+	var entries []apiKeyEntry
+	for i, v := range strings.Split(os.Getenv(apiKeysEnv), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			key, role := parseEnvAPIKey(v)
+			entries = append(entries, apiKeyEntry{Name: fmt.Sprintf("env-%d", i+1), Role: role, Source: "env", hash: hashToken(key)})
+		}
+	}
+	var endpoints map[string]string
+	var modTime time.Time
+	if path := os.Getenv(apiKeysFileEnv); path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		parsed, err := parseAPIKeys(data)
+		apiKeysMutex.Lock()
+		apiKeysModTime = info.ModTime()
+		if err != nil {
+			apiKeysLastError = err.Error()
+			apiKeysMutex.Unlock()
+			return err
+		}
+		apiKeysMutex.Unlock()
+		for _, k := range parsed.Keys {
+			entries = append(entries, apiKeyEntry{Name: k.Name, Role: k.Role, Source: "file", hash: hashToken(k.Key)})
+		}
+		endpoints = parsed.Endpoints
+		modTime = info.ModTime()
+	}
+	apiKeysMutex.Lock()
+	defer apiKeysMutex.Unlock()
+	apiKeys = entries
+	endpointRoles = endpoints
+	apiKeysModTime = modTime
+	apiKeysLastError = ""
+	return nil
+}
+
+// startAPIKeyWatcher loads the keys and reloads the keys file whenever it
+// changes, so keys can be rotated without a restart.
+func startAPIKeyWatcher() {
+	if err := loadAPIKeys(); err != nil {
+		log.Fatalf("Error loading API keys: %v", err)
+	}
+	apiKeysMutex.RLock()
+	n := len(apiKeys)
+	apiKeysMutex.RUnlock()
+	if n == 0 {
+		log.Printf("No API keys configured; the API is open to anyone who can reach it")
+	} else {
+		log.Printf("API key authentication enabled with %d keys", n)
+	}
+	path := os.Getenv(apiKeysFileEnv)
+	if path == "" {
+		return
+	}
+	go func() {
+		for range time.Tick(configPollInterval) {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			apiKeysMutex.RLock()
+			changed := !info.ModTime().Equal(apiKeysModTime)
+			apiKeysMutex.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := loadAPIKeys(); err != nil {
+				log.Printf("Error reloading API keys from %s: %v", path, err)
+			} else {
+				log.Printf("Reloaded API keys from %s", path)
+			}
+		}
+	}()
+}
+
+// lookupAPIKey returns the loaded key matching the request's X-API-Key or
+// WebSocket token subprotocol.
+func lookupAPIKey(r *http.Request) (apiKeyEntry, bool) {
+	supplied := r.Header.Get("X-API-Key")
+	if supplied == "" {
+		supplied = webSocketToken(r)
+	}
+	if supplied == "" {
+		return apiKeyEntry{}, false
+	}
+	hash := hashToken(supplied)
+	apiKeysMutex.RLock()
+	defer apiKeysMutex.RUnlock()
+	for _, k := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(k.hash)) == 1 {
+			return k, true
+		}
+	}
+	return apiKeyEntry{}, false
+}
+
+// requiredRole returns the minimum role for a request: the longest
+// matching endpoint override, else viewer for reads and operator for
+// anything else.
+func requiredRole(r *http.Request) string {
+	apiKeysMutex.RLock()
+	defer apiKeysMutex.RUnlock()
+	role, longest := "", 0
+	for prefix, min := range endpointRoles {
+		if strings.HasPrefix(r.URL.Path, prefix) && len(prefix) > longest {
+			role, longest = min, len(prefix)
+		}
+	}
+	if role != "" {
+		return role
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return roleViewer
+	}
+	return roleOperator
+}
+
+// requireAPIKey guards every handler once any API key is configured and
+// enforces the key's role. Requests with the admin token are also accepted.
+func requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKeysMutex.RLock()
+		enabled := len(apiKeys) > 0
+		apiKeysMutex.RUnlock()
+		if !enabled || apiKeyExempt(r) || isAdminRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, ok := lookupAPIKey(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "Invalid or missing API key")
+			return
+		}
+		if need := requiredRole(r); roleRank[key.Role] < roleRank[need] {
+			writeJSONError(w, http.StatusForbidden, "Requires the "+need+" role")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKeysHandler lists the loaded key names (GET) or reloads them (POST).
+func apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := loadAPIKeys(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	apiKeysMutex.RLock()
+	defer apiKeysMutex.RUnlock()
+	keys := append([]apiKeyEntry{}, apiKeys...)
+	if keys == nil {
+		keys = []apiKeyEntry{}
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"keys":       keys,
+		"endpoints":  endpointRoles,
+		"file":       os.Getenv(apiKeysFileEnv),
+		"last_error": apiKeysLastError,
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	autoscaleSampleInterval = time.Minute
+	autoscaleSamples        = 60
+)
+
+// PlayerSample is one point of the player count history.
+type PlayerSample struct {
+	Time    time.Time `json:"time"`
+	Players int       `json:"players"`
+}
+
+// AutoscaleStatus is the machine-oriented view consumed by external autoscalers.
+type AutoscaleStatus struct {
+	ServerUp          bool           `json:"server_up"`
+	Players           int            `json:"players"`
+	Capacity          int            `json:"capacity"`
+	Utilization       float64        `json:"utilization"`
+	TrendPerMinute    float64        `json:"trend_per_minute"`
+	BackupsInProgress int            `json:"backups_in_progress"`
+	SafeToScaleDown   bool           `json:"safe_to_scale_down"`
+	Reasons           []string       `json:"reasons,omitempty"`
+	Samples           []PlayerSample `json:"samples,omitempty"`
+}
+
+var (
+	playerSamples = make([]PlayerSample, 0, autoscaleSamples)
+	samplesMutex  sync.RWMutex
+)
+
+// currentPlayerCount asks the game server for its player count, falling back
+// to the bridge's online set when the server cannot be pinged.
+func currentPlayerCount() (int, bool) {
+	if pong, err := pingServer(gameAddr(), 2*time.Second); err == nil {
+		return pong.Players, true
+	}
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	return len(onlineSet), false
+}
+
+// startPlayerSampling records the player count once a minute.
+func startPlayerSampling() {
+	go func() {
+		ticker := time.NewTicker(autoscaleSampleInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			players, up := currentPlayerCount()
+			if !up {
+				continue
+			}
+			samplesMutex.Lock()
+			playerSamples = append(playerSamples, PlayerSample{Time: time.Now(), Players: players})
+			if len(playerSamples) > autoscaleSamples {
+				playerSamples = playerSamples[len(playerSamples)-autoscaleSamples:]
+			}
+			samplesMutex.Unlock()
+		}
+	}()
+}
+
+// playerTrend returns the least-squares slope of the samples in players per minute.
+func playerTrend(samples []PlayerSample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	origin := samples[0].Time
+	for _, s := range samples {
+		x := s.Time.Sub(origin).Minutes()
+		y := float64(s.Players)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// collectAutoscaleStatus builds the current autoscaling view.
+func collectAutoscaleStatus(withSamples bool) AutoscaleStatus {
+	players, up := currentPlayerCount()
+	status := AutoscaleStatus{
+		ServerUp:          up,
+		Players:           players,
+		Capacity:          queueCapacity(),
+		BackupsInProgress: int(atomic.LoadInt32(&backupsInProgress)),
+	}
+	if status.Capacity > 0 {
+		status.Utilization = float64(players) / float64(status.Capacity)
+	}
+
+	samplesMutex.RLock()
+	samples := append([]PlayerSample(nil), playerSamples...)
+	samplesMutex.RUnlock()
+	status.TrendPerMinute = playerTrend(samples)
+	if withSamples {
+		status.Samples = samples
+	}
+
+	if players > 0 {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("%d players online", players))
+	}
+	if status.BackupsInProgress > 0 {
+		status.Reasons = append(status.Reasons, "backup in progress")
+	}
+	hibernationMutex.Lock()
+	state := hibernation.State
+	hibernationMutex.Unlock()
+	if state == hibernationStopping || state == hibernationWaking {
+		status.Reasons = append(status.Reasons, "hibernation transition in progress")
+	}
+	queueMutex.Lock()
+	if len(joinQueue) > 0 {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("%d players queued", len(joinQueue)))
+	}
+	queueMutex.Unlock()
+	status.SafeToScaleDown = len(status.Reasons) == 0
+	return status
+}
+
+// autoscaleHandler returns the autoscaling view as JSON.
+func autoscaleHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, collectAutoscaleStatus(true))
+}
+
+// autoscaleKEDAHandler returns a flat, numeric-only document suitable for
+// the KEDA metrics-api scaler (use valueLocation "players" or "utilization").
+func autoscaleKEDAHandler(w http.ResponseWriter, r *http.Request) {
+	s := collectAutoscaleStatus(false)
+	safe := 0
+	if s.SafeToScaleDown {
+		safe = 1
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"players":            s.Players,
+		"capacity":           s.Capacity,
+		"utilization":        s.Utilization,
+		"trend_per_minute":   s.TrendPerMinute,
+		"safe_to_scale_down": safe,
+	})
+}
+
+// autoscaleMetrics returns the autoscaling view as metrics.
+func autoscaleMetrics() []metricSample {
+	s := collectAutoscaleStatus(false)
+	return []metricSample{
+		{"bedrock_server_up", "Whether the game server answers pings.", "gauge", boolMetric(s.ServerUp)},
+		{"bedrock_players_online", "Players currently online.", "gauge", float64(s.Players)},
+		{"bedrock_players_capacity", "Player capacity before queueing.", "gauge", float64(s.Capacity)},
+		{"bedrock_players_trend_per_minute", "Player count slope over the last hour.", "gauge", s.TrendPerMinute},
+		{"bedrock_backups_in_progress", "Running world backups.", "gauge", float64(s.BackupsInProgress)},
+		{"bedrock_safe_to_scale_down", "Whether the server can be scaled down without disruption.", "gauge", boolMetric(s.SafeToScaleDown)},
+	}
+}
+
+// autoscaleMetricsHandler exposes the autoscaling view in Prometheus text format.
+func autoscaleMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	writePrometheus(w, autoscaleMetrics())
+}
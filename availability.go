@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	availabilityStateFile     = "availability.json"
+	availabilityProbeInterval = 30 * time.Second
+	availabilitySaveInterval  = 5 * time.Minute
+	availabilityHistory       = 400 * 24 * time.Hour
+)
+
+// Availability states. Planned downtime, such as hibernation, does not
+// count against uptime.
+const (
+	availabilityUp      = "up"
+	availabilityDown    = "down"
+	availabilityPlanned = "planned"
+)
+
+// AvailabilityInterval is a stretch of time the server spent in one state.
+type AvailabilityInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	State string    `json:"state"`
+}
+
+// AvailabilitySLO is the uptime objective and its burn-rate alert
+// thresholds, following the usual 1h/6h multi-window scheme.
+type AvailabilitySLO struct {
+	Target     float64 `json:"target"` // percent, e.g. 99.5
+	WindowDays int     `json:"window_days"`
+	FastBurn   float64 `json:"fast_burn"` // over 1h
+	SlowBurn   float64 `json:"slow_burn"` // over 6h
+}
+
+type availabilityState struct {
+	Intervals []AvailabilityInterval `json:"intervals"`
+	SLO       AvailabilitySLO        `json:"slo"`
+	// Alerting records burn-rate alerts currently firing, by window.
+	Alerting map[string]bool `json:"alerting,omitempty"`
+}
+
+var (
+	availability = availabilityState{
+		Intervals: []AvailabilityInterval{},
+		SLO:       AvailabilitySLO{Target: 99.5, WindowDays: 30, FastBurn: 14.4, SlowBurn: 6},
+	}
+	availabilitySaved time.Time
+	availabilityMutex sync.Mutex
+)
+
+func (s *AvailabilitySLO) validate() error {
+	if s.Target <= 0 || s.Target >= 100 {
+		return errors.New("target must be between 0 and 100")
+	}
+	if s.WindowDays <= 0 || s.WindowDays > 365 {
+		return errors.New("window_days must be 1-365")
+	}
+	if s.FastBurn < 0 || s.SlowBurn < 0 {
+		return errors.New("burn rate thresholds must not be negative")
+	}
+	return nil
+}
+
+// recordAvailability extends the current interval or starts a new one.
+// A gap longer than a few probes, when the sidecar itself was not running,
+// is left unmonitored. The caller holds availabilityMutex.
+func recordAvailability(state string, now time.Time) {
+	n := len(availability.Intervals)
+	if n > 0 {
+		last := &availability.Intervals[n-1]
+		if now.Sub(last.End) <= 3*availabilityProbeInterval {
+			if last.State == state {
+				last.End = now
+				return
+			}
+			// Close the previous state where this one began.
+			last.End = now
+		}
+	}
+	availability.Intervals = append(availability.Intervals, AvailabilityInterval{Start: now, End: now, State: state})
+	cutoff := now.Add(-availabilityHistory)
+	for len(availability.Intervals) > 0 && availability.Intervals[0].End.Before(cutoff) {
+		availability.Intervals = availability.Intervals[1:]
+	}
+}
+
+// availabilityTotals returns the monitored and down time between from and
+// to, excluding planned downtime from both. The caller holds
+// availabilityMutex.
+func availabilityTotals(from, to time.Time) (monitored, down time.Duration) {
+	for _, iv := range availability.Intervals {
+		start, end := iv.Start, iv.End
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) || iv.State == availabilityPlanned {
+			continue
+		}
+		monitored += end.Sub(start)
+		if iv.State == availabilityDown {
+			down += end.Sub(start)
+		}
+	}
+	return monitored, down
+}
+
+// uptimePercent is 100 when nothing was monitored.
+func uptimePercent(monitored, down time.Duration) float64 {
+	if monitored == 0 {
+		return 100
+	}
+	return 100 * float64(monitored-down) / float64(monitored)
+}
+
+// burnRate is how fast the error budget was spent over the last window:
+// 1 spends exactly the budget over the SLO window. The caller holds
+// availabilityMutex.
+func burnRate(window time.Duration, now time.Time) float64 {
+	monitored, down := availabilityTotals(now.Add(-window), now)
+	if monitored == 0 {
+		return 0
+	}
+	budget := 1 - availability.SLO.Target/100
+	return float64(down) / float64(monitored) / budget
+}
+
+// checkBurnRates fires an alert when a window's burn rate crosses its
+// threshold and another when it recovers. The caller holds
+// availabilityMutex.
+func checkBurnRates(now time.Time) {
+	if availability.Alerting == nil {
+		availability.Alerting = map[string]bool{}
+	}
+	for _, w := range []struct {
+		name      string
+		window    time.Duration
+		threshold float64
+	}{
+		{"1h", time.Hour, availability.SLO.FastBurn},
+		{"6h", 6 * time.Hour, availability.SLO.SlowBurn},
+	} {
+		if w.threshold == 0 {
+			continue
+		}
+		rate := burnRate(w.window, now)
+		fields := map[string]interface{}{"window": w.name, "burn_rate": rate, "threshold": w.threshold, "target": availability.SLO.Target}
+		switch firing := availability.Alerting[w.name]; {
+		case !firing && rate >= w.threshold:
+			availability.Alerting[w.name] = true
+			sendAlert("slo_burn_rate", fmt.Sprintf("Availability error budget burning at %.1fx over %s (threshold %.1fx, target %.2f%%)", rate, w.name, w.threshold, availability.SLO.Target), fields)
+		case firing && rate < w.threshold:
+			delete(availability.Alerting, w.name)
+			sendAlert("slo_burn_rate_resolved", fmt.Sprintf("Availability burn rate over %s back to %.1fx", w.name, rate), fields)
+		}
+	}
+}
+
+func saveAvailability() {
+	if err := saveState(availabilityStateFile, availability); err != nil {
+		log.Printf("Error saving availability: %v", err)
+	}
+	availabilitySaved = time.Now()
+}
+
+// probeAvailability pings the server once and records the result.
+func probeAvailability() {
+	state := availabilityUp
+	if !serverUp() {
+		state = availabilityDown
+		hibernationMutex.Lock()
+		if hibernation.State != hibernationAwake {
+			state = availabilityPlanned
+		}
+		hibernationMutex.Unlock()
+	}
+	now := time.Now()
+	availabilityMutex.Lock()
+	defer availabilityMutex.Unlock()
+	changed := len(availability.Intervals) == 0 || availability.Intervals[len(availability.Intervals)-1].State != state
+	recordAvailability(state, now)
+	checkBurnRates(now)
+	if changed || time.Since(availabilitySaved) >= availabilitySaveInterval {
+		saveAvailability()
+	}
+}
+
+// startAvailabilityProbe loads the history and probes the game server
+// continuously.
+func startAvailabilityProbe() {
+	availabilityMutex.Lock()
+	if err := loadState(availabilityStateFile, &availability); err != nil {
+		log.Printf("Error loading availability: %v", err)
+	}
+	availabilityMutex.Unlock()
+	go func() {
+		for {
+			probeAvailability()
+			time.Sleep(availabilityProbeInterval)
+		}
+	}()
+}
+
+// AvailabilityPeriod is the uptime of one day or month.
+type AvailabilityPeriod struct {
+	Period           string  `json:"period"`
+	UptimePercent    float64 `json:"uptime_percent"`
+	DowntimeSeconds  float64 `json:"downtime_seconds"`
+	MonitoredSeconds float64 `json:"monitored_seconds"`
+}
+
+// availabilityHandler reports uptime per day and month, incidents and the
+// SLO over the last ?days= (default 30).
+func availabilityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 400 {
+			writeJSONError(w, http.StatusBadRequest, "days must be 1-400")
+			return
+		}
+		days = n
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, 1-days)
+
+	availabilityMutex.Lock()
+	defer availabilityMutex.Unlock()
+	period := func(label string, start, end time.Time) AvailabilityPeriod {
+		monitored, down := availabilityTotals(start, end)
+		return AvailabilityPeriod{Period: label, UptimePercent: uptimePercent(monitored, down), DowntimeSeconds: down.Seconds(), MonitoredSeconds: monitored.Seconds()}
+	}
+	daily := []AvailabilityPeriod{}
+	for d := from; !d.After(today); d = d.AddDate(0, 0, 1) {
+		daily = append(daily, period(d.Format("2006-01-02"), d, d.AddDate(0, 0, 1)))
+	}
+	monthly := []AvailabilityPeriod{}
+	for m := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, now.Location()); !m.After(today); m = m.AddDate(0, 1, 0) {
+		monthly = append(monthly, period(m.Format("2006-01"), m, m.AddDate(0, 1, 0)))
+	}
+	incidents := []map[string]interface{}{}
+	for i := len(availability.Intervals) - 1; i >= 0; i-- {
+		iv := availability.Intervals[i]
+		if iv.End.Before(from) {
+			break
+		}
+		if iv.State == availabilityDown {
+			incidents = append(incidents, map[string]interface{}{"start": iv.Start, "end": iv.End, "duration_seconds": iv.End.Sub(iv.Start).Seconds()})
+		}
+	}
+
+	slo := availability.SLO
+	monitored, down := availabilityTotals(now.AddDate(0, 0, -slo.WindowDays), now)
+	budget := time.Duration(float64(monitored) * (1 - slo.Target/100))
+	remaining := 100.0
+	if budget > 0 {
+		remaining = 100 * float64(budget-down) / float64(budget)
+	}
+	current := map[string]interface{}{}
+	if n := len(availability.Intervals); n > 0 {
+		current["state"] = availability.Intervals[n-1].State
+		current["since"] = availability.Intervals[n-1].Start
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"current":   current,
+		"daily":     daily,
+		"monthly":   monthly,
+		"incidents": incidents,
+		"slo": map[string]interface{}{
+			"target":                     slo.Target,
+			"window_days":                slo.WindowDays,
+			"uptime_percent":             uptimePercent(monitored, down),
+			"error_budget_remaining_pct": remaining,
+			"burn_rate_1h":               burnRate(time.Hour, now),
+			"burn_rate_6h":               burnRate(6*time.Hour, now),
+			"alerting":                   availability.Alerting,
+			"fast_burn_threshold":        slo.FastBurn,
+			"slow_burn_threshold":        slo.SlowBurn,
+		},
+	})
+}
+
+// availabilitySLOHandler shows (GET) or replaces (PUT) the SLO.
+func availabilitySLOHandler(w http.ResponseWriter, r *http.Request) {
+	availabilityMutex.Lock()
+	defer availabilityMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, availability.SLO)
+	case http.MethodPut:
+		var slo AvailabilitySLO
+		if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := slo.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		availability.SLO = slo
+		saveAvailability()
+		writeJSONResponse(w, http.StatusOK, availability.SLO)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// backupStreamThreshold is the size above which a file is streamed by
+	// the archive writer instead of being read ahead by a worker.
+	backupStreamThreshold = 8 << 20
+)
+
+var (
+	// backupsDir holds world backup archives.
+	backupsDir = filepath.Join(dataDir, "backups")
+	// backupsInProgress counts running backups so scale-down can wait for them.
+	backupsInProgress int32
+)
+
+// archiveEntry is a file or directory to be archived.
+type archiveEntry struct {
+	path string
+	rel  string
+	info os.FileInfo
+}
+
+// loadedEntry is an archiveEntry prepared by a worker. Data holds the file
+// contents, deflated when compressed is set. Large files are left for the
+// writer to stream.
+type loadedEntry struct {
+	archiveEntry
+	data       []byte
+	compressed bool
+	crc        uint32
+	sum        string
+	err        error
+}
+
+func (e loadedEntry) streamed() bool {
+	return e.info.Mode().IsRegular() && e.data == nil && e.err == nil && e.info.Size() > 0
+}
+
+// listArchiveEntries walks srcDir in lexical order.
+func listArchiveEntries(srcDir string) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if info.IsDir() || info.Mode().IsRegular() {
+			entries = append(entries, archiveEntry{path: path, rel: filepath.ToSlash(rel), info: info})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// loadEntries reads, hashes and optionally deflates files on a worker pool
+// and delivers them in their original order, so reading and compression
+// overlap with writing the archive.
+func loadEntries(entries []archiveEntry, deflateLevel int) <-chan loadedEntry {
+	results := make([]chan loadedEntry, len(entries))
+	for i := range results {
+		results[i] = make(chan loadedEntry, 1)
+	}
+	// Bound how many files are held in memory ahead of the writer.
+	slots := make(chan struct{}, 2*runtime.NumCPU())
+	jobs := make(chan int)
+	go func() {
+		for i := range entries {
+			slots <- struct{}{}
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	for w := 0; w < runtime.NumCPU(); w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- loadEntry(entries[i], deflateLevel)
+			}
+		}()
+	}
+	out := make(chan loadedEntry)
+	go func() {
+		for i := range entries {
+			out <- <-results[i]
+			<-slots
+		}
+		close(out)
+	}()
+	return out
+}
+
+// loadEntry reads one file into memory unless it is large. A negative
+// deflateLevel leaves the data uncompressed.
+func loadEntry(e archiveEntry, deflateLevel int) loadedEntry {
+	loaded := loadedEntry{archiveEntry: e}
+	if !e.info.Mode().IsRegular() || e.info.Size() > backupStreamThreshold {
+		return loaded
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		loaded.err = err
+		return loaded
+	}
+	sum := sha256.Sum256(data)
+	loaded.sum = hex.EncodeToString(sum[:])
+	loaded.crc = crc32.ChecksumIEEE(data)
+	loaded.data = data
+	if deflateLevel >= -1 && len(data) > 0 {
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, deflateLevel)
+		if err != nil {
+			loaded.err = err
+			return loaded
+		}
+		fw.Write(data)
+		fw.Close()
+		loaded.data, loaded.compressed = buf.Bytes(), true
+	}
+	return loaded
+}
+
+// streamEntry copies a large file into w, returning its hash.
+func streamEntry(w io.Writer, e loadedEntry) (string, error) {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeZipArchive writes entries into a zip stream. Deflated files are
+// compressed by the workers and copied into the archive as raw entries.
+func writeZipArchive(w io.Writer, entries []archiveEntry, method uint16, level int) (SyncManifest, error) {
+	manifest := SyncManifest{Files: []SyncFile{}}
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+	deflateLevel := -2
+	if method == zip.Deflate {
+		deflateLevel = level
+	}
+	var firstErr error
+	for e := range loadEntries(entries, deflateLevel) {
+		if firstErr != nil {
+			continue // drain the pipeline
+		}
+		firstErr = func() error {
+			if e.err != nil {
+				return e.err
+			}
+			header, err := zip.FileInfoHeader(e.info)
+			if err != nil {
+				return err
+			}
+			header.Name = e.rel
+			if e.info.IsDir() {
+				header.Name += "/"
+				_, err = zw.CreateHeader(header)
+				return err
+			}
+			header.Method = method
+			if e.streamed() {
+				fw, err := zw.CreateHeader(header)
+				if err != nil {
+					return err
+				}
+				sum, err := streamEntry(fw, e)
+				manifest.Files = append(manifest.Files, SyncFile{Path: e.rel, Size: e.info.Size(), SHA256: sum})
+				return err
+			}
+			if !e.compressed {
+				header.Method = zip.Store
+			}
+			header.CRC32 = e.crc
+			header.UncompressedSize64 = uint64(e.info.Size())
+			header.CompressedSize64 = uint64(len(e.data))
+			fw, err := zw.CreateRaw(header)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(e.data); err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, SyncFile{Path: e.rel, Size: e.info.Size(), SHA256: e.sum})
+			return nil
+		}()
+	}
+	if firstErr != nil {
+		return manifest, firstErr
+	}
+	return manifest, zw.Close()
+}
+
+// writeTarArchive writes entries into a tar stream, with workers reading
+// files ahead of the writer.
+func writeTarArchive(w io.Writer, entries []archiveEntry) (SyncManifest, error) {
+	manifest := SyncManifest{Files: []SyncFile{}}
+	tw := tar.NewWriter(w)
+	var firstErr error
+	for e := range loadEntries(entries, -2) {
+		if firstErr != nil {
+			continue // drain the pipeline
+		}
+		firstErr = func() error {
+			if e.err != nil {
+				return e.err
+			}
+			header, err := tar.FileInfoHeader(e.info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = e.rel
+			if e.info.IsDir() {
+				header.Name += "/"
+				return tw.WriteHeader(header)
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			sum := e.sum
+			if e.streamed() {
+				sum, err = streamEntry(tw, e)
+			} else {
+				_, err = tw.Write(e.data)
+			}
+			manifest.Files = append(manifest.Files, SyncFile{Path: e.rel, Size: e.info.Size(), SHA256: sum})
+			return err
+		}()
+	}
+	if firstErr != nil {
+		return manifest, firstErr
+	}
+	return manifest, tw.Close()
+}
+
+// createWorldBackup archives the current world folder into backupsDir and
+// returns the archive path. A manifest of file hashes is written next to
+// it. Callers are responsible for quiescing the world (stopping the server
+// or issuing "save hold") beforehand.
+func createWorldBackup() (string, error) {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return "", err
+	}
+	return backupFolder(worldFolder)
+}
+
+// backupFolder archives a world folder into backupsDir, naming the archive
+// after the folder.
+func backupFolder(worldFolder string) (string, error) {
+	atomic.AddInt32(&backupsInProgress, 1)
+	defer atomic.AddInt32(&backupsInProgress, -1)
+
+	if err := os.MkdirAll(backupsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	compression := currentBackupCompression()
+	name := fmt.Sprintf("%s-%s%s", filepath.Base(worldFolder), time.Now().Format("20060102-150405"), compression.extension())
+	dst := filepath.Join(backupsDir, name)
+	start := time.Now()
+	manifest, err := archiveDirectory(worldFolder, dst, compression)
+	if err != nil {
+		return "", err
+	}
+	if data, err := json.MarshalIndent(manifest, "", "  "); err == nil {
+		if err := os.WriteFile(dst+".manifest.json", data, 0644); err != nil {
+			log.Printf("Error writing backup manifest: %v", err)
+		}
+	}
+	log.Printf("World backup written to %s (%d files in %s)", dst, len(manifest.Files), time.Since(start).Round(time.Millisecond))
+	return dst, nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupScheduleStateFile = "backup_schedule.json"
+
+// BackupSchedule runs live backups on a cron schedule and prunes old
+// archives afterwards. Zero retention limits are not enforced.
+type BackupSchedule struct {
+	Schedule   string    `json:"schedule"`
+	Enabled    bool      `json:"enabled"`
+	KeepLast   int       `json:"keep_last,omitempty"`
+	MaxAgeDays int       `json:"max_age_days,omitempty"`
+	MaxTotalMB int64     `json:"max_total_mb,omitempty"`
+	LastRun    time.Time `json:"last_run,omitempty"`
+	LastResult string    `json:"last_result,omitempty"`
+	NextRun    time.Time `json:"next_run,omitempty"`
+}
+
+// BackupArchive is one archive in the backups directory.
+type BackupArchive struct {
+	ID      string    `json:"id"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+	Files   int       `json:"files,omitempty"`
+}
+
+var (
+	backupSchedule      = BackupSchedule{}
+	backupScheduleMutex sync.Mutex
+)
+
+func (s *BackupSchedule) validate() error {
+	if s.KeepLast < 0 || s.MaxAgeDays < 0 || s.MaxTotalMB < 0 {
+		return errors.New("retention limits must not be negative")
+	}
+	if s.Schedule == "" {
+		if s.Enabled {
+			return errors.New("schedule is required")
+		}
+		s.NextRun = time.Time{}
+		return nil
+	}
+	sched, err := parseCron(s.Schedule)
+	if err != nil {
+		return err
+	}
+	s.NextRun = sched.next(time.Now())
+	return nil
+}
+
+// listBackups returns the backup archives, newest first.
+func listBackups() ([]BackupArchive, error) {
+	entries, err := os.ReadDir(backupsDir)
+	if os.IsNotExist(err) {
+		return []BackupArchive{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	backups := []BackupArchive{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".manifest.json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		b := BackupArchive{ID: name, Size: info.Size(), Created: info.ModTime()}
+		if data, err := os.ReadFile(filepath.Join(backupsDir, name+".manifest.json")); err == nil {
+			var m SyncManifest
+			if json.Unmarshal(data, &m) == nil {
+				b.Files = len(m.Files)
+			}
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Created.After(backups[j].Created) })
+	return backups, nil
+}
+
+// backupPath resolves a backup ID to its archive, rejecting anything that
+// is not an archive in the backups directory.
+func backupPath(id string) (string, error) {
+	if !validName(id) || strings.HasPrefix(id, ".") || strings.HasSuffix(id, ".manifest.json") {
+		return "", errors.New("invalid backup id")
+	}
+	path := filepath.Join(backupsDir, id)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", os.ErrNotExist
+	}
+	return path, nil
+}
+
+// deleteBackup removes an archive and its manifest.
+func deleteBackup(id string) error {
+	path, err := backupPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	os.Remove(path + ".manifest.json")
+	return nil
+}
+
+// pruneBackups enforces the retention limits, always keeping the newest
+// archive. It returns the IDs it removed.
+func pruneBackups() []string {
+	backupScheduleMutex.Lock()
+	policy := backupSchedule
+	backupScheduleMutex.Unlock()
+	backups, err := listBackups()
+	if err != nil || len(backups) <= 1 {
+		return nil
+	}
+	var removed []string
+	var total int64
+	cutoff := time.Now().AddDate(0, 0, -policy.MaxAgeDays)
+	for i, b := range backups {
+		total += b.Size
+		if i == 0 {
+			continue
+		}
+		expired := (policy.KeepLast > 0 && i >= policy.KeepLast) ||
+			(policy.MaxAgeDays > 0 && b.Created.Before(cutoff)) ||
+			(policy.MaxTotalMB > 0 && total > policy.MaxTotalMB<<20)
+		if !expired {
+			continue
+		}
+		if err := deleteBackup(b.ID); err != nil {
+			log.Printf("Error pruning backup %s: %v", b.ID, err)
+			continue
+		}
+		total -= b.Size
+		removed = append(removed, b.ID)
+	}
+	if len(removed) > 0 {
+		log.Printf("Pruned %d backups: %s", len(removed), strings.Join(removed, ", "))
+	}
+	return removed
+}
+
+// backupAndPrune takes a live backup, applies retention and ships the
+// archive to remote storage when one is configured.
+func backupAndPrune() (string, error) {
+	path, err := createHotBackup()
+	if err != nil {
+		publishEvent("backup_failed", "Backup failed: "+err.Error(), map[string]interface{}{"error": err.Error()})
+		return "", err
+	}
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	publishEvent("backup_completed", "Backup written to "+filepath.Base(path), map[string]interface{}{"backup": filepath.Base(path), "size_bytes": size})
+	pruneBackups()
+	if err := shipBackup(path); err != nil {
+		err = fmt.Errorf("backup %s written but upload failed: %w", filepath.Base(path), err)
+		publishEvent("backup_failed", "Backup failed: "+err.Error(), map[string]interface{}{"backup": filepath.Base(path), "error": err.Error()})
+		return path, err
+	}
+	return path, nil
+}
+
+// startBackupTask runs backupAndPrune as a "backup" task.
+func startBackupTask() *Task {
+	return startTask("backup", func(t *Task) error {
+		t.step(10, "Backing up the world")
+		path, err := backupAndPrune()
+		if err != nil {
+			return err
+		}
+		t.step(100, "Backup written to %s", filepath.Base(path))
+		return nil
+	})
+}
+
+// checkBackupSchedule starts the scheduled backup when it is due in the
+// minute containing tick. The cron scheduler calls it every minute.
+func checkBackupSchedule(tick time.Time) {
+	backupScheduleMutex.Lock()
+	defer backupScheduleMutex.Unlock()
+	sched, err := parseCron(backupSchedule.Schedule)
+	if err != nil || !backupSchedule.Enabled || !sched.matches(tick) {
+		return
+	}
+	backupSchedule.LastRun = tick
+	backupSchedule.NextRun = sched.next(tick)
+	if _, busy := runningTask("backup"); busy {
+		backupSchedule.LastResult = "skipped: a backup is already running"
+	} else {
+		backupSchedule.LastResult = "started task " + startBackupTask().ID
+	}
+	if err := saveState(backupScheduleStateFile, backupSchedule); err != nil {
+		log.Printf("Error saving backup schedule: %v", err)
+	}
+}
+
+// loadBackupSchedule restores the backup schedule at startup.
+func loadBackupSchedule() {
+	backupScheduleMutex.Lock()
+	defer backupScheduleMutex.Unlock()
+	if err := loadState(backupScheduleStateFile, &backupSchedule); err != nil {
+		log.Printf("Error loading backup schedule: %v", err)
+	}
+	if err := backupSchedule.validate(); err != nil {
+		log.Printf("Invalid backup schedule, disabling it: %v", err)
+		backupSchedule.Enabled = false
+	}
+}
+
+// backupScheduleHandler shows (GET) or replaces (PUT) the backup schedule
+// and retention policy.
+func backupScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		backupScheduleMutex.Lock()
+		defer backupScheduleMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, backupSchedule)
+	case http.MethodPut:
+		var s BackupSchedule
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := s.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		backupScheduleMutex.Lock()
+		s.LastRun, s.LastResult = backupSchedule.LastRun, backupSchedule.LastResult
+		backupSchedule = s
+		if err := saveState(backupScheduleStateFile, backupSchedule); err != nil {
+			log.Printf("Error saving backup schedule: %v", err)
+		}
+		backupScheduleMutex.Unlock()
+		removed := pruneBackups()
+		if removed == nil {
+			removed = []string{}
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"schedule": s, "pruned": removed})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// backupsHandler lists archives (GET) or starts a backup (POST).
+func backupsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		backups, err := listBackups()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"backups": backups})
+	case http.MethodPost:
+		if _, busy := runningTask("backup"); busy {
+			writeJSONError(w, http.StatusConflict, "A backup is already running")
+			return
+		}
+		t := startBackupTask()
+		w.Header().Set("Location", "/tasks/"+t.ID)
+		writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// backupHandler downloads (GET) or deletes (DELETE) /backups/{id}.
+func backupHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/backups/")
+	path, err := backupPath(id)
+	if os.IsNotExist(err) {
+		writeJSONError(w, http.StatusNotFound, "Backup not found")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Disposition", "attachment; filename="+id)
+		serveLimitedFile(w, r, path)
+	case http.MethodDelete:
+		if err := deleteBackup(id); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Backup deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const bansStateFile = "bans.json"
+
+// Ban keeps a player off the server until it expires, or for good when it
+// has no expiry. Bedrock has no ban command, so banned players are kicked
+// as they join. A ban matches the gamertag, case-insensitively, or the
+// XUID, which survives gamertag changes but is only known from the log.
+type Ban struct {
+	ID      string     `json:"id"`
+	Player  string     `json:"player,omitempty"`
+	XUID    string     `json:"xuid,omitempty"`
+	Reason  string     `json:"reason,omitempty"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+var (
+	bans      = []Ban{}
+	bansMutex sync.Mutex
+)
+
+func (b *Ban) matches(player, xuid string) bool {
+	return (b.Player != "" && strings.EqualFold(b.Player, player)) || (b.XUID != "" && b.XUID == xuid)
+}
+
+func (b *Ban) expired(now time.Time) bool {
+	return b.Expires != nil && now.After(*b.Expires)
+}
+
+func saveBans() {
+	if err := saveState(bansStateFile, bans); err != nil {
+		log.Printf("Error saving bans: %v", err)
+	}
+}
+
+// pruneBans drops expired bans. Callers must hold bansMutex.
+func pruneBans() {
+	now := time.Now()
+	kept := bans[:0]
+	for _, b := range bans {
+		if !b.expired(now) {
+			kept = append(kept, b)
+		}
+	}
+	if len(kept) != len(bans) {
+		bans = kept
+		saveBans()
+	}
+}
+
+// findBan returns the ban in force for a player or XUID.
+func findBan(player, xuid string) (Ban, bool) {
+	bansMutex.Lock()
+	defer bansMutex.Unlock()
+	pruneBans()
+	for _, b := range bans {
+		if b.matches(player, xuid) {
+			return b, true
+		}
+	}
+	return Ban{}, false
+}
+
+// banKickMessage is the reason a banned player sees when kicked.
+func banKickMessage(b Ban) string {
+	msg := "You are banned"
+	if b.Expires != nil {
+		msg += " until " + b.Expires.Format("2006-01-02 15:04 MST")
+	}
+	if b.Reason != "" {
+		msg += ": " + b.Reason
+	}
+	return msg
+}
+
+// enforceBans kicks a joining player who is banned, here or by a security
+// rule, reporting whether they were kicked.
+func enforceBans(player, xuid string) bool {
+	b, banned := findBan(player, xuid)
+	if !banned {
+		return enforceTempban(player)
+	}
+	if err := sendServerCommand("kick " + quotePlayer(player) + " " + banKickMessage(b)); err != nil {
+		log.Printf("Failed to kick banned player %s: %v", player, err)
+	}
+	return true
+}
+
+// BanRequest bans a player by name, XUID or both. Duration is a Go
+// duration such as "72h"; without one the ban is permanent.
+type BanRequest struct {
+	Player   string `json:"player,omitempty"`
+	XUID     string `json:"xuid,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+func (req *BanRequest) ban() (Ban, error) {
+	if req.Player == "" && req.XUID == "" {
+		return Ban{}, errors.New("player or xuid is required")
+	}
+	if (req.Player != "" && !validPlayerName(req.Player)) || strings.ContainsAny(req.Reason, "\n\r") {
+		return Ban{}, errors.New("invalid player or reason")
+	}
+	if req.XUID != "" && !xuidPattern.MatchString(req.XUID) {
+		return Ban{}, errors.New("invalid xuid")
+	}
+	b := Ban{ID: newUUID(), Player: req.Player, XUID: req.XUID, Reason: req.Reason, Created: time.Now()}
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return Ban{}, fmt.Errorf("invalid duration %q", req.Duration)
+		}
+		expires := b.Created.Add(d)
+		b.Expires = &expires
+	}
+	return b, nil
+}
+
+// bansHandler lists bans in force (GET) or adds one (POST), kicking the
+// player at once in case they are online. Bans by security rules are
+// listed under /security/bans.
+func bansHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bansMutex.Lock()
+		pruneBans()
+		out := append([]Ban{}, bans...)
+		bansMutex.Unlock()
+		sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"bans": out})
+	case http.MethodPost:
+		var req BanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		b, err := req.ban()
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		bansMutex.Lock()
+		pruneBans()
+		for _, old := range bans {
+			if (b.Player != "" && old.matches(b.Player, "")) || (b.XUID != "" && old.matches("", b.XUID)) {
+				bansMutex.Unlock()
+				writeJSONError(w, http.StatusConflict, "The player is already banned; delete that ban first")
+				return
+			}
+		}
+		bans = append(bans, b)
+		saveBans()
+		bansMutex.Unlock()
+		log.Printf("Banned player %q xuid %q: %s", b.Player, b.XUID, b.Reason)
+
+		if b.Player != "" {
+			if err := sendServerCommand("kick " + quotePlayer(b.Player) + " " + banKickMessage(b)); err != nil {
+				log.Printf("Failed to kick banned player %s: %v", b.Player, err)
+			}
+		}
+		writeJSONResponse(w, http.StatusCreated, b)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// banHandler serves DELETE /bans/{id}, which lifts a ban.
+func banHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/bans/")
+	bansMutex.Lock()
+	defer bansMutex.Unlock()
+	for i, b := range bans {
+		if b.ID == id {
+			bans = append(bans[:i], bans[i+1:]...)
+			saveBans()
+			writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Ban lifted"})
+			return
+		}
+	}
+	writeJSONError(w, http.StatusNotFound, "Ban not found")
+}
+
+// playerKickHandler serves POST /players/{name}/kick ({"reason"}). The
+// server ignores kicks of players who are not online.
+func playerKickHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+	}
+	if strings.ContainsAny(req.Reason, "\n\r") {
+		writeJSONError(w, http.StatusBadRequest, "Invalid reason")
+		return
+	}
+	if err := sendServerCommand(strings.TrimSpace("kick " + quotePlayer(player) + " " + req.Reason)); err != nil {
+		log.Printf("Error kicking %s: %v", player, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to kick player")
+		return
+	}
+	log.Printf("Kicked %s: %s", player, req.Reason)
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Kick sent", "player": player})
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Chunk record tags in the world database holding biomes: Data3D for
+// Caves & Cliffs worlds and later, Data2D for older ones.
+const (
+	chunkTagData3D = 43
+	chunkTagData2D = 45
+)
+
+// errChunkNotSaved is returned for chunks the server has not generated and
+// written to the world yet.
+var errChunkNotSaved = errors.New("chunk not saved")
+
+// biomeDimension describes a dimension's chunk keys and height range.
+type biomeDimension struct {
+	id   int32
+	minY int
+	maxY int
+}
+
+var biomeDimensions = map[string]biomeDimension{
+	"overworld": {0, -64, 320},
+	"nether":    {1, 0, 128},
+	"the_end":   {2, 0, 256},
+}
+
+// biomeNames maps Bedrock's numeric biome IDs to their identifiers.
+var biomeNames = map[int]string{
+	0: "ocean", 1: "plains", 2: "desert", 3: "extreme_hills", 4: "forest",
+	5: "taiga", 6: "swampland", 7: "river", 8: "hell", 9: "the_end",
+	10: "legacy_frozen_ocean", 11: "frozen_river", 12: "ice_plains",
+	13: "ice_mountains", 14: "mushroom_island", 15: "mushroom_island_shore",
+	16: "beach", 17: "desert_hills", 18: "forest_hills", 19: "taiga_hills",
+	20: "extreme_hills_edge", 21: "jungle", 22: "jungle_hills",
+	23: "jungle_edge", 24: "deep_ocean", 25: "stone_beach", 26: "cold_beach",
+	27: "birch_forest", 28: "birch_forest_hills", 29: "roofed_forest",
+	30: "cold_taiga", 31: "cold_taiga_hills", 32: "mega_taiga",
+	33: "mega_taiga_hills", 34: "extreme_hills_plus_trees", 35: "savanna",
+	36: "savanna_plateau", 37: "mesa", 38: "mesa_plateau_stone",
+	39: "mesa_plateau", 40: "warm_ocean", 41: "deep_warm_ocean",
+	42: "lukewarm_ocean", 43: "deep_lukewarm_ocean", 44: "cold_ocean",
+	45: "deep_cold_ocean", 46: "frozen_ocean", 47: "deep_frozen_ocean",
+	48: "bamboo_jungle", 49: "bamboo_jungle_hills",
+	129: "sunflower_plains", 130: "desert_mutated",
+	131: "extreme_hills_mutated", 132: "flower_forest", 133: "taiga_mutated",
+	134: "swampland_mutated", 140: "ice_plains_spikes",
+	149: "jungle_mutated", 151: "jungle_edge_mutated",
+	155: "birch_forest_mutated", 156: "birch_forest_hills_mutated",
+	157: "roofed_forest_mutated", 158: "cold_taiga_mutated",
+	160: "redwood_taiga_mutated", 161: "redwood_taiga_hills_mutated",
+	162: "extreme_hills_plus_trees_mutated", 163: "savanna_mutated",
+	164: "savanna_plateau_mutated", 165: "mesa_bryce",
+	166: "mesa_plateau_stone_mutated", 167: "mesa_plateau_mutated",
+	178: "soulsand_valley", 179: "crimson_forest", 180: "warped_forest",
+	181: "basalt_deltas", 182: "jagged_peaks", 183: "frozen_peaks",
+	184: "snowy_slopes", 185: "grove", 186: "meadow", 187: "lush_caves",
+	188: "dripstone_caves", 189: "stony_peaks", 190: "deep_dark",
+	191: "mangrove_swamp", 192: "cherry_grove", 193: "pale_garden",
+}
+
+// BiomeLookup is the biome at a block position.
+type BiomeLookup struct {
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Z         int    `json:"z"`
+	Dimension string `json:"dimension"`
+	BiomeID   int    `json:"biome_id"`
+	Biome     string `json:"biome"`
+}
+
+// chunkKey builds a chunk record's database key. Overworld keys omit the
+// dimension.
+func chunkKey(chunkX, chunkZ, dimension int32, tag byte) []byte {
+	key := binary.LittleEndian.AppendUint32(nil, uint32(chunkX))
+	key = binary.LittleEndian.AppendUint32(key, uint32(chunkZ))
+	if dimension != 0 {
+		key = binary.LittleEndian.AppendUint32(key, uint32(dimension))
+	}
+	return append(key, tag)
+}
+
+// biomeSection is one sub-chunk's biomes: a palette and, unless the whole
+// section is one biome, packed 4096 palette indices.
+type biomeSection struct {
+	palette []int32
+	indices []uint32
+	bits    int
+}
+
+// at returns the biome at a position within the section.
+func (s *biomeSection) at(x, y, z int) (int, error) {
+	if s.indices == nil {
+		return int(s.palette[0]), nil
+	}
+	perWord := 32 / s.bits
+	i := x<<8 | z<<4 | y
+	v := int(s.indices[i/perWord]>>(uint(i%perWord)*uint(s.bits))) & (1<<s.bits - 1)
+	if v >= len(s.palette) {
+		return 0, errors.New("biome index outside palette")
+	}
+	return int(s.palette[v]), nil
+}
+
+// chunkBiomes is a chunk's decoded biome record.
+type chunkBiomes struct {
+	dim      biomeDimension
+	heights  [256]int // surface height per column, as a block y
+	sections []biomeSection
+	columns  []byte // Data2D records: one biome per column
+}
+
+// parseData3D decodes a Data3D record: a 256-entry heightmap then one
+// paletted biome section per sub-chunk from the bottom of the dimension.
+func parseData3D(data []byte, dim biomeDimension) (*chunkBiomes, error) {
+	if len(data) < 512 {
+		return nil, errors.New("short Data3D record")
+	}
+	c := &chunkBiomes{dim: dim}
+	for i := range c.heights {
+		c.heights[i] = min(max(dim.minY+int(int16(binary.LittleEndian.Uint16(data[2*i:])))-1, dim.minY), dim.maxY-1)
+	}
+	data = data[512:]
+	for len(data) > 0 && len(c.sections) < (dim.maxY-dim.minY)/16 {
+		header := data[0]
+		data = data[1:]
+		if header == 0xff {
+			// The section repeats the one below it.
+			if len(c.sections) == 0 {
+				return nil, errors.New("invalid biome section")
+			}
+			c.sections = append(c.sections, c.sections[len(c.sections)-1])
+			continue
+		}
+		var s biomeSection
+		s.bits = int(header >> 1)
+		if s.bits == 0 {
+			if len(data) < 4 {
+				return nil, errors.New("short biome section")
+			}
+			s.palette = []int32{int32(binary.LittleEndian.Uint32(data))}
+			data = data[4:]
+			c.sections = append(c.sections, s)
+			continue
+		}
+		if s.bits > 16 {
+			return nil, fmt.Errorf("invalid biome section width %d", s.bits)
+		}
+		perWord := 32 / s.bits
+		words := (4096 + perWord - 1) / perWord
+		if len(data) < words*4+4 {
+			return nil, errors.New("short biome section")
+		}
+		s.indices = make([]uint32, words)
+		for w := range s.indices {
+			s.indices[w] = binary.LittleEndian.Uint32(data[w*4:])
+		}
+		data = data[words*4:]
+		n := int(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+		if n <= 0 || len(data) < n*4 {
+			return nil, errors.New("short biome palette")
+		}
+		s.palette = make([]int32, n)
+		for p := range s.palette {
+			s.palette[p] = int32(binary.LittleEndian.Uint32(data[p*4:]))
+		}
+		data = data[n*4:]
+		c.sections = append(c.sections, s)
+	}
+	return c, nil
+}
+
+// parseData2D decodes a Data2D record: a 256-entry heightmap then one
+// biome per column.
+func parseData2D(data []byte, dim biomeDimension) (*chunkBiomes, error) {
+	if len(data) < 768 {
+		return nil, errors.New("short Data2D record")
+	}
+	c := &chunkBiomes{dim: dim, columns: append([]byte(nil), data[512:768]...)}
+	for i := range c.heights {
+		c.heights[i] = max(int(int16(binary.LittleEndian.Uint16(data[2*i:])))-1, 0)
+	}
+	return c, nil
+}
+
+// biome returns the biome at a column and block y.
+func (c *chunkBiomes) biome(x, y, z int) (int, error) {
+	if c.columns != nil {
+		return int(c.columns[z*16+x]), nil
+	}
+	section := (y - c.dim.minY) >> 4
+	if section < 0 || section >= len(c.sections) {
+		return 0, errors.New("biome section missing")
+	}
+	return c.sections[section].at(x, (y-c.dim.minY)&15, z)
+}
+
+// surfaceBiome returns the biome at the top of a column.
+func (c *chunkBiomes) surfaceBiome(x, z int) (int, error) {
+	return c.biome(x, c.heights[z*16+x], z)
+}
+
+// decodeChunkBiomes decodes whichever biome record a chunk has, preferring
+// Data3D.
+func decodeChunkBiomes(d3, d2 []byte, dim biomeDimension) (*chunkBiomes, error) {
+	switch {
+	case d3 != nil:
+		return parseData3D(d3, dim)
+	case d2 != nil:
+		return parseData2D(d2, dim)
+	}
+	return nil, errChunkNotSaved
+}
+
+// readChunkRecord returns a chunk record's value, or nil if it is absent.
+func readChunkRecord(db *ldbDB, key []byte) ([]byte, error) {
+	var value []byte
+	err := db.scan(key, func(e ldbEntry) error {
+		if bytes.Equal(e.Key, key) {
+			value = e.Value
+		}
+		return nil
+	})
+	return value, err
+}
+
+// openWorldDB opens the active world's database and calls fn with it,
+// retrying briefly while a running server may be writing it.
+func openWorldDB(fn func(db *ldbDB) error) error {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		db, err := openLDB(filepath.Join(worldFolder, "db"))
+		if err == nil {
+			err = fn(db)
+		}
+		if err == nil || errors.Is(err, errChunkNotSaved) || attempt == 2 || !serverUp() {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// lookupBiome reads the biome at a block position from the saved world.
+// The server only saves chunks players have loaded, so positions beyond
+// them return errChunkNotSaved. With surface set, y is ignored and the
+// highest block in the column is used.
+func lookupBiome(x, y, z int, surface bool, dimension string) (*BiomeLookup, error) {
+	dim, ok := biomeDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown dimension %q", dimension)
+	}
+	chunkX, chunkZ := int32(x>>4), int32(z>>4)
+	lx, lz := x&15, z&15
+	out := &BiomeLookup{X: x, Y: y, Z: z, Dimension: dimension}
+	err := openWorldDB(func(db *ldbDB) error {
+		d3, err := readChunkRecord(db, chunkKey(chunkX, chunkZ, dim.id, chunkTagData3D))
+		var d2 []byte
+		if err == nil && d3 == nil {
+			d2, err = readChunkRecord(db, chunkKey(chunkX, chunkZ, dim.id, chunkTagData2D))
+		}
+		if err != nil {
+			return err
+		}
+		chunk, err := decodeChunkBiomes(d3, d2, dim)
+		if err != nil {
+			return err
+		}
+		if surface {
+			out.Y = chunk.heights[lz*16+lx]
+		}
+		out.BiomeID, err = chunk.biome(lx, out.Y, lz)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Biome = biomeNames[out.BiomeID]
+	if out.Biome == "" {
+		out.Biome = "unknown"
+	}
+	return out, nil
+}
+
+// biomeHandler serves GET /seed/biome?x=&z=[&y=][&dimension=]: the biome at
+// a block position, read from the chunks the server has saved, so map UIs
+// can overlay it. Without y the surface is used. Dimension is overworld
+// (default), nether or the_end.
+func biomeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	q := r.URL.Query()
+	x, errX := strconv.Atoi(q.Get("x"))
+	z, errZ := strconv.Atoi(q.Get("z"))
+	surface := q.Get("y") == ""
+	y, errY := 0, error(nil)
+	if !surface {
+		y, errY = strconv.Atoi(q.Get("y"))
+	}
+	dimension := q.Get("dimension")
+	if dimension == "" {
+		dimension = "overworld"
+	}
+	dim, ok := biomeDimensions[dimension]
+	if errX != nil || errZ != nil || errY != nil || !ok || (!surface && (y < dim.minY || y >= dim.maxY)) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid x, y, z or dimension")
+		return
+	}
+	lookup, err := lookupBiome(x, y, z, surface, dimension)
+	if errors.Is(err, errChunkNotSaved) {
+		writeJSONError(w, http.StatusNotFound, "Chunk has not been generated")
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up biome: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read biome")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, lookup)
+}
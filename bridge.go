@@ -0,0 +1,515 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The script bridge is a generated behavior pack that uses @minecraft/server-net
+// to POST batches of in-game events to the sidecar. Its UUIDs are fixed so a
+// reinstall replaces the existing pack rather than adding a second copy.
+const (
+	bridgePackUUID     = "6f1c5a3e-2b7d-4e8a-9c41-5d0b7e2a9f13"
+	bridgeModuleUUID   = "a84d2c19-7e3b-4f65-8d2a-1c9e0b4f7a52"
+	bridgePackDirName  = "sidecar_bridge"
+	bridgeEventBuffer  = 1000
+	bridgeMaxBatchSize = 1 << 20 // 1 MB
+)
+
+// BridgeLocation is a block or entity position reported by the bridge.
+type BridgeLocation struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// BridgeEvent is a structured event pushed by the bridge pack.
+type BridgeEvent struct {
+	Type       string          `json:"type"`
+	Time       int64           `json:"time"`
+	Player     string          `json:"player,omitempty"`
+	Dimension  string          `json:"dimension,omitempty"`
+	Location   *BridgeLocation `json:"location,omitempty"`
+	Block      string          `json:"block,omitempty"`
+	Entity     string          `json:"entity,omitempty"`
+	Cause      string          `json:"cause,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	ReceivedAt time.Time       `json:"received_at"`
+}
+
+// BridgeInstallRequest is the body of POST /bridge/install.
+type BridgeInstallRequest struct {
+	// SidecarURL is the base URL the game server uses to reach the sidecar.
+	SidecarURL string `json:"sidecar_url"`
+}
+
+var (
+	bridgeEvents    = make([]BridgeEvent, 0)
+	bridgePositions = make(map[string]PlayerCoords)
+	bridgeMutex     sync.RWMutex
+)
+
+// bridgeTokenPath stores the shared secret embedded in the generated pack.
+func bridgeTokenPath() string {
+	return filepath.Join(stateDir, "bridge_token")
+}
+
+// loadBridgeToken returns the persisted bridge token, or "" if none exists.
+func loadBridgeToken() string {
+	data, err := os.ReadFile(bridgeTokenPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// bridgeManifest returns the manifest.json for the bridge pack.
+func bridgeManifest() map[string]interface{} {
+	return map[string]interface{}{
+		"format_version": 2,
+		"header": map[string]interface{}{
+			"name":               "Sidecar Bridge",
+			"description":        "Pushes in-game events to the bedrock API sidecar",
+			"uuid":               bridgePackUUID,
+			"version":            []int{1, 0, 0},
+			"min_engine_version": []int{1, 20, 80},
+		},
+		"modules": []map[string]interface{}{
+			{
+				"type":     "script",
+				"language": "javascript",
+				"uuid":     bridgeModuleUUID,
+				"entry":    "scripts/main.js",
+				"version":  []int{1, 0, 0},
+			},
+		},
+		"dependencies": []map[string]string{
+			{"module_name": "@minecraft/server", "version": "1.11.0"},
+			{"module_name": "@minecraft/server-net", "version": "1.0.0-beta"},
+		},
+	}
+}
+
+// bridgeScript is the pack's entry point. It queues events and flushes them
+// to the sidecar once a second.
+const bridgeScript = `import { world, system } from "@minecraft/server";
+import { http, HttpRequest, HttpRequestMethod, HttpHeader } from "@minecraft/server-net";
+
+const ENDPOINT = %q;
+const TOKEN = %q;
+
+let queue = [];
+
+function push(type, data) {
+  queue.push(Object.assign({ type: type, time: Date.now() }, data));
+  if (queue.length > 500) queue.shift();
+}
+
+function loc(l) {
+  return { x: l.x, y: l.y, z: l.z };
+}
+
+world.afterEvents.playerSpawn.subscribe((e) => {
+  if (e.initialSpawn) push("player_join", { player: e.player.name, data: { id: e.player.id } });
+});
+
+world.afterEvents.playerLeave.subscribe((e) => {
+  push("player_leave", { player: e.playerName });
+});
+
+if (world.afterEvents.chatSend) {
+  world.afterEvents.chatSend.subscribe((e) => {
+    push("chat", { player: e.sender.name, message: e.message });
+  });
+}
+
+world.afterEvents.playerBreakBlock.subscribe((e) => {
+  push("block_broken", {
+    player: e.player.name,
+    block: e.brokenBlockPermutation.type.id,
+    dimension: e.dimension.id,
+    location: loc(e.block.location),
+  });
+});
+
+world.afterEvents.playerPlaceBlock.subscribe((e) => {
+  push("block_placed", {
+    player: e.player.name,
+    block: e.block.typeId,
+    dimension: e.dimension.id,
+    location: loc(e.block.location),
+  });
+});
+
+world.afterEvents.entityDie.subscribe((e) => {
+  try {
+    push("entity_died", {
+      entity: e.deadEntity.typeId,
+      cause: e.damageSource.cause,
+      dimension: e.deadEntity.dimension.id,
+      location: loc(e.deadEntity.location),
+    });
+  } catch (err) {}
+});
+
+system.runInterval(() => {
+  for (const p of world.getPlayers()) {
+    push("player_position", { player: p.name, dimension: p.dimension.id, location: loc(p.location) });
+  }
+}, 100);
+
+let lastTick = Date.now();
+system.runInterval(() => {
+  const now = Date.now();
+  // 20 ticks should take about a second; report anything well beyond that.
+  if (now - lastTick > 1500) push("tick_lag", { data: { ms: now - lastTick - 1000 } });
+  lastTick = now;
+}, 20);
+
+system.runInterval(() => {
+  for (const id of ["overworld", "nether", "the_end"]) {
+    let dim;
+    try {
+      dim = world.getDimension(id);
+    } catch (err) {
+      continue;
+    }
+    const counts = {};
+    const chunks = {};
+    for (const en of dim.getEntities()) {
+      counts[en.typeId] = (counts[en.typeId] || 0) + 1;
+      const key = Math.floor(en.location.x / 16) + "," + Math.floor(en.location.z / 16);
+      const c = chunks[key] || (chunks[key] = { total: 0, types: {} });
+      c.total++;
+      c.types[en.typeId] = (c.types[en.typeId] || 0) + 1;
+    }
+    const top = Object.keys(chunks)
+      .sort((a, b) => chunks[b].total - chunks[a].total)
+      .slice(0, 50)
+      .map((k) => Object.assign({ chunk: k }, chunks[k]));
+    push("entity_census", { dimension: dim.id, data: { counts: counts, chunks: top } });
+  }
+}, 600);
+
+system.runInterval(() => {
+  const objectives = {};
+  for (const obj of world.scoreboard.getObjectives()) {
+    if (obj.id.startsWith("lb_")) continue;
+    const scores = {};
+    for (const s of obj.getScores()) {
+      try {
+        if (s.participant.type === "Player") scores[s.participant.getEntity().name] = s.score;
+      } catch (err) {}
+    }
+    objectives[obj.id] = scores;
+  }
+  push("scoreboard", { data: { objectives: objectives } });
+}, 1200);
+
+function item(slot, it) {
+  if (!it) return null;
+  const out = { slot: slot, item: it.typeId, count: it.amount };
+  if (it.nameTag) out.name = it.nameTag;
+  try {
+    out.damage = it.getComponent("minecraft:durability").damage;
+  } catch (err) {}
+  try {
+    out.enchantments = it
+      .getComponent("minecraft:enchantable")
+      .getEnchantments()
+      .map((en) => ({ id: en.type.id, level: en.level }));
+  } catch (err) {}
+  return out;
+}
+
+function items(list) {
+  return list.filter((it) => it !== null);
+}
+
+// The sidecar asks for an online player's inventory with
+// "scriptevent sidecar:inventory <player>"; the answer is pushed as an event.
+system.afterEvents.scriptEventReceive.subscribe((e) => {
+  if (e.id !== "sidecar:inventory") return;
+  const p = world.getPlayers({ name: e.message })[0];
+  if (!p) {
+    push("inventory", { player: e.message, data: null });
+    return;
+  }
+  const container = p.getComponent("minecraft:inventory").container;
+  const inventory = [];
+  for (let i = 0; i < container.size; i++) inventory.push(item(i, container.getItem(i)));
+  let armor = [];
+  let offhand = [];
+  try {
+    const eq = p.getComponent("minecraft:equippable");
+    armor = ["Head", "Chest", "Legs", "Feet"].map((s, i) => item(i, eq.getEquipment(s)));
+    offhand = [item(0, eq.getEquipment("Offhand"))];
+  } catch (err) {}
+  push("inventory", { player: e.message, data: { inventory: items(inventory), armor: items(armor), offhand: items(offhand) } });
+});
+
+system.runInterval(() => {
+  if (queue.length === 0) return;
+  const batch = queue;
+  queue = [];
+  const req = new HttpRequest(ENDPOINT);
+  req.method = HttpRequestMethod.Post;
+  req.body = JSON.stringify(batch);
+  req.headers = [new HttpHeader("Content-Type", "application/json"), new HttpHeader("X-Bridge-Token", TOKEN)];
+  http.request(req).catch(() => {});
+}, 20);
+`
+
+// allowServerNetModule adds @minecraft/server-net to the server's script
+// module allowlist, which is required for the bridge to make HTTP requests.
+func allowServerNetModule() error {
+	permsPath := filepath.Join(dataDir, "config", "default", "permissions.json")
+	perms := map[string]interface{}{}
+	if data, err := os.ReadFile(permsPath); err == nil {
+		if err := json.Unmarshal(data, &perms); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", permsPath, err)
+		}
+	}
+	modules := []interface{}{"@minecraft/server", "@minecraft/server-ui", "@minecraft/server-admin"}
+	if existing, ok := perms["allowed_modules"].([]interface{}); ok {
+		modules = existing
+	}
+	for _, m := range modules {
+		if m == "@minecraft/server-net" {
+			return nil
+		}
+	}
+	perms["allowed_modules"] = append(modules, "@minecraft/server-net")
+	data, err := json.MarshalIndent(perms, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(permsPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(permsPath, data, 0644)
+}
+
+// activateBehaviorPack appends a pack to the world's behavior pack list if it
+// is not already present.
+func activateBehaviorPack(uuid string, version []int) error {
+	worldPacksMutex.Lock()
+	defer worldPacksMutex.Unlock()
+	addons, err := readWorldPacks("behavior")
+	if err != nil {
+		return err
+	}
+	for _, a := range addons {
+		if a.PackID == uuid {
+			return nil
+		}
+	}
+	return writeWorldPacks("behavior", append(addons, ActiveAddon{PackID: uuid, Version: version}))
+}
+
+// bridgeInstallHandler generates the bridge pack, installs it into the
+// behavior packs directory and activates it on the current world.
+func bridgeInstallHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req BridgeInstallRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+	}
+	if req.SidecarURL == "" {
+		req.SidecarURL = "http://127.0.0.1:" + listenPort
+	}
+	req.SidecarURL = strings.TrimRight(req.SidecarURL, "/")
+
+	token := loadBridgeToken()
+	if token == "" {
+		var b [24]byte
+		rand.Read(b[:])
+		token = hex.EncodeToString(b[:])
+		if err := os.MkdirAll(stateDir, 0755); err != nil {
+			log.Printf("Error creating state directory: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if err := os.WriteFile(bridgeTokenPath(), []byte(token), 0600); err != nil {
+			log.Printf("Error saving bridge token: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+	}
+
+	packDir := filepath.Join(behaviorPacksDir, bridgePackDirName)
+	if err := os.MkdirAll(filepath.Join(packDir, "scripts"), 0755); err != nil {
+		log.Printf("Error creating bridge pack directory: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to install bridge pack")
+		return
+	}
+	manifest, _ := json.MarshalIndent(bridgeManifest(), "", "  ")
+	if err := os.WriteFile(filepath.Join(packDir, "manifest.json"), manifest, 0644); err != nil {
+		log.Printf("Error writing bridge manifest: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to install bridge pack")
+		return
+	}
+	script := fmt.Sprintf(bridgeScript, req.SidecarURL+"/bridge/events", token)
+	if err := os.WriteFile(filepath.Join(packDir, "scripts", "main.js"), []byte(script), 0644); err != nil {
+		log.Printf("Error writing bridge script: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to install bridge pack")
+		return
+	}
+	if err := allowServerNetModule(); err != nil {
+		log.Printf("Error updating script module permissions: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to allow @minecraft/server-net")
+		return
+	}
+	if err := activateBehaviorPack(bridgePackUUID, []int{1, 0, 0}); err != nil {
+		log.Printf("Error activating bridge pack: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Bridge installed but could not be activated")
+		return
+	}
+
+	log.Printf("Installed script bridge pack at %s", packDir)
+	writeJSONResponse(w, http.StatusOK, map[string]string{
+		"message":  "Bridge pack installed; restart the server to load it",
+		"pack_id":  bridgePackUUID,
+		"endpoint": req.SidecarURL + "/bridge/events",
+	})
+}
+
+// recordBridgeEvent stores an event and updates derived state.
+func recordBridgeEvent(ev BridgeEvent) {
+	bridgeMutex.Lock()
+	defer bridgeMutex.Unlock()
+	switch ev.Type {
+	case "player_position":
+		if ev.Player != "" && ev.Location != nil {
+			bridgePositions[ev.Player] = PlayerCoords{Name: ev.Player, X: ev.Location.X, Y: ev.Location.Y, Z: ev.Location.Z, Dimension: ev.Dimension}
+		}
+		// Positions are high volume; keep only the latest per player.
+		return
+	case "player_leave":
+		delete(bridgePositions, ev.Player)
+	case "entity_census":
+		recordEntityCensus(ev)
+		return
+	case "scoreboard", "inventory":
+		return
+	}
+	bridgeEvents = append(bridgeEvents, ev)
+	if len(bridgeEvents) > bridgeEventBuffer {
+		bridgeEvents = bridgeEvents[len(bridgeEvents)-bridgeEventBuffer:]
+	}
+}
+
+// dispatchBridgeEvent runs the side effects of an event in order of arrival.
+// It must be called without holding bridgeMutex.
+func dispatchBridgeEvent(ev BridgeEvent) {
+	switch ev.Type {
+	case "player_join":
+		observeActivity(ev.Player, activityJoin)
+		recordPlayerEntityID(ev)
+		if enforceBans(ev.Player, "") {
+			break
+		}
+		if queueOnJoin(ev.Player) {
+			syncPlayerTags(ev.Player)
+			effectsOnJoin(ev.Player)
+			recordPlayerJoin(ev.Player)
+		}
+	case "player_leave":
+		if queueOnLeave(ev.Player) {
+			recordPlayerLeave(ev.Player)
+		}
+	case "scoreboard":
+		recordScoreboardSample(ev.Data)
+	case "inventory":
+		deliverInventory(ev)
+	case "chat":
+		publishChat(ChatMessage{Direction: "inbound", Source: "game", Sender: ev.Player, Message: ev.Message, Time: ev.ReceivedAt})
+	case "tick_lag":
+		var lag struct {
+			MS int `json:"ms"`
+		}
+		if json.Unmarshal(ev.Data, &lag) == nil {
+			checkMitigationTickLag(lag.MS)
+		}
+	}
+	if ev.Player != "" && ev.Type != "player_position" {
+		questOnEvent(ev)
+	}
+}
+
+// bridgeEventsHandler accepts event batches from the bridge pack (POST) and
+// lists recent events (GET, optionally filtered by type).
+func bridgeEventsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		token := loadBridgeToken()
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Bridge-Token")), []byte(token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, bridgeMaxBatchSize)
+		var batch []BridgeEvent
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid event batch")
+			return
+		}
+		now := time.Now()
+		for _, ev := range batch {
+			ev.ReceivedAt = now
+			recordBridgeEvent(ev)
+			dispatchBridgeEvent(ev)
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]int{"accepted": len(batch)})
+	case http.MethodGet:
+		limit := 100
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				writeJSONError(w, http.StatusBadRequest, "Invalid limit")
+				return
+			}
+			limit = min(n, bridgeEventBuffer)
+		}
+		typ := r.URL.Query().Get("type")
+		bridgeMutex.RLock()
+		events := []BridgeEvent{}
+		for i := len(bridgeEvents) - 1; i >= 0 && len(events) < limit; i-- {
+			if typ == "" || bridgeEvents[i].Type == typ {
+				events = append(events, bridgeEvents[i])
+			}
+		}
+		bridgeMutex.RUnlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"events": events})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// bridgePlayerPositions returns the latest positions reported by the bridge.
+func bridgePlayerPositions() []PlayerCoords {
+	bridgeMutex.RLock()
+	defer bridgeMutex.RUnlock()
+	players := make([]PlayerCoords, 0, len(bridgePositions))
+	for _, p := range bridgePositions {
+		players = append(players, p)
+	}
+	return players
+}
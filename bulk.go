@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxBulkTargets caps how many players one bulk request may address.
+const maxBulkTargets = 200
+
+var selectorPattern = regexp.MustCompile(`^@[aprse](\[[^\]]*\])?$`)
+
+// BulkPlayerRequest applies one operation to a list of players or a target
+// selector. Operation is message, kick, tag_add, tag_remove or gamemode.
+// The selector @a is expanded to the online players so each gets its own
+// result; any other selector is sent as a single target.
+type BulkPlayerRequest struct {
+	Operation string   `json:"operation"`
+	Players   []string `json:"players,omitempty"`
+	Selector  string   `json:"selector,omitempty"`
+	Message   string   `json:"message,omitempty"` // message text, or the kick reason
+	Tag       string   `json:"tag,omitempty"`
+	GameMode  string   `json:"gamemode,omitempty"`
+}
+
+// BulkPlayerResult is the outcome for one player or selector.
+type BulkPlayerResult struct {
+	Target  string `json:"target"`
+	Command string `json:"command"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+var bulkGameModes = map[string]bool{"survival": true, "creative": true, "adventure": true, "spectator": true}
+
+// command builds the console command applying the operation to target.
+func (req *BulkPlayerRequest) command(target string) string {
+	switch req.Operation {
+	case "message":
+		return tellrawCommand(target, req.Message)
+	case "kick":
+		return strings.TrimSpace("kick " + target + " " + req.Message)
+	case "tag_add":
+		return "tag " + target + " add " + req.Tag
+	case "tag_remove":
+		return "tag " + target + " remove " + req.Tag
+	case "gamemode":
+		return "gamemode " + req.GameMode + " " + target
+	}
+	return ""
+}
+
+func (req *BulkPlayerRequest) validate() error {
+	switch req.Operation {
+	case "message":
+		if strings.TrimSpace(req.Message) == "" {
+			return errors.New("message is required")
+		}
+	case "kick":
+		if strings.ContainsAny(req.Message, "\n\r") {
+			return errors.New("invalid kick reason")
+		}
+	case "tag_add", "tag_remove":
+		if req.Tag == "" || strings.ContainsAny(req.Tag, " \"\n\r") {
+			return errors.New("invalid tag")
+		}
+	case "gamemode":
+		req.GameMode = strings.ToLower(req.GameMode)
+		if !bulkGameModes[req.GameMode] {
+			return errors.New("gamemode must be survival, creative, adventure or spectator")
+		}
+	default:
+		return errors.New("operation must be message, kick, tag_add, tag_remove or gamemode")
+	}
+	if (len(req.Players) == 0) == (req.Selector == "") {
+		return errors.New("give either players or a selector")
+	}
+	if req.Selector != "" && !selectorPattern.MatchString(req.Selector) {
+		return errors.New("invalid selector")
+	}
+	for _, p := range req.Players {
+		if !validPlayerName(p) {
+			return errors.New("invalid player name")
+		}
+	}
+	return nil
+}
+
+// bulkTargets returns the command targets: quoted player names, the online
+// players for @a, or the selector itself.
+func (req *BulkPlayerRequest) bulkTargets() (names, targets []string) {
+	players := req.Players
+	if req.Selector == "@a" {
+		queueMutex.Lock()
+		for p := range onlineSet {
+			players = append(players, p)
+		}
+		queueMutex.Unlock()
+		sort.Strings(players)
+	} else if req.Selector != "" {
+		return []string{req.Selector}, []string{req.Selector}
+	}
+	for _, p := range players {
+		names = append(names, p)
+		targets = append(targets, quotePlayer(p))
+	}
+	return names, targets
+}
+
+// playersBulkHandler applies an operation to many players in one call,
+// sending the commands one after another and reporting each result. Unless
+// the transport reports command results, ok only means the command was
+// delivered.
+func playersBulkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req BulkPlayerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	names, targets := req.bulkTargets()
+	if len(targets) > maxBulkTargets {
+		writeJSONError(w, http.StatusBadRequest, "Too many players")
+		return
+	}
+
+	results := make([]BulkPlayerResult, 0, len(targets))
+	failed := 0
+	for i, target := range targets {
+		res := BulkPlayerResult{Target: names[i], Command: req.command(target), OK: true}
+		if err := sendServerCommand(res.Command); err != nil {
+			res.OK, res.Error = false, err.Error()
+			failed++
+		}
+		results = append(results, res)
+	}
+	rr, ok := commandTransport.(commandResultReporter)
+	log.Printf("Bulk %s applied to %d targets, %d failed", req.Operation, len(results), failed)
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"operation": req.Operation,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+		"confirmed": ok && rr.ReportsCommandResults(),
+		"results":   results,
+	})
+}
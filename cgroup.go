@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCgroupDir = "/sys/fs/cgroup"
+
+// CgroupUsage is the memory and CPU state of the cgroup the bedrock process
+// runs in. Memory is the working set, usage less inactive page cache, which
+// is what the OOM killer and kubelet weigh against the limit. Throttling is
+// the share of scheduler periods throttled since the previous sample.
+type CgroupUsage struct {
+	Version          int     `json:"version"`
+	Path             string  `json:"path"`
+	MemoryBytes      uint64  `json:"memory_working_set_bytes"`
+	MemoryLimit      uint64  `json:"memory_limit_bytes,omitempty"`
+	MemoryPercent    float64 `json:"memory_percent,omitempty"`
+	CPULimit         float64 `json:"cpu_limit_cores,omitempty"`
+	Periods          uint64  `json:"cpu_periods_total"`
+	ThrottledPeriods uint64  `json:"cpu_throttled_periods_total"`
+	ThrottledPercent float64 `json:"cpu_throttled_percent"`
+	OOMKills         uint64  `json:"oom_kills_total"`
+}
+
+var (
+	// resourceWarnings are the limits currently being approached, by name,
+	// as reported by /healthz.
+	resourceWarnings      = map[string]string{}
+	resourceWarningsMutex sync.Mutex
+)
+
+// cgroupThresholds returns the memory and CPU throttling percentages above
+// which the sidecar warns.
+func cgroupThresholds() (memoryPercent, throttledPercent float64) {
+	memoryPercent, _ = strconv.ParseFloat(envOrDefault(memoryWarnPercentEnv, "90"), 64)
+	throttledPercent, _ = strconv.ParseFloat(envOrDefault(cpuThrottleWarnEnv, "25"), 64)
+	return memoryPercent, throttledPercent
+}
+
+// readCgroupFile returns the trimmed contents of a cgroup control file.
+func readCgroupFile(dir, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	return strings.TrimSpace(string(data)), err
+}
+
+// readCgroupKeyValues reads a flat-keyed cgroup file such as cpu.stat.
+func readCgroupKeyValues(dir, name string) map[string]uint64 {
+	values := map[string]uint64{}
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), " "); ok {
+			values[k], _ = strconv.ParseUint(v, 10, 64)
+		}
+	}
+	return values
+}
+
+// serverCgroupDir finds the cgroup of the bedrock process. With a private
+// cgroup namespace the process sees its own cgroup as the root, so the
+// path is looked up under the mounted hierarchy and the root is used when
+// it is not there, which is right whenever the server shares the sidecar's
+// container.
+func serverCgroupDir(pid int, controller string) string {
+	root := envOrDefault(cgroupDirEnv, defaultCgroupDir)
+	if controller != "" {
+		root = filepath.Join(root, controller)
+	}
+	if pid <= 0 {
+		return root
+	}
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return root
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Lines are hierarchy-ID:controllers:path; cgroup v2 has no
+		// controllers.
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if controller == "" && parts[1] != "" {
+			continue
+		}
+		if controller != "" && !strings.Contains(","+parts[1]+",", ","+controller+",") {
+			continue
+		}
+		dir := filepath.Join(root, parts[2])
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return root
+}
+
+// parseCgroupLimit parses a limit, where "max" and the near-2^63 values
+// cgroup v1 uses both mean unlimited.
+func parseCgroupLimit(s string) uint64 {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n >= 1<<62 {
+		return 0
+	}
+	return n
+}
+
+// sampleCgroup reads the cgroup v2 or v1 state of the bedrock process's
+// cgroup, deriving throttling from the previous sample.
+func sampleCgroup(pid int, prev *CgroupUsage) (*CgroupUsage, error) {
+	var u CgroupUsage
+	dir := serverCgroupDir(pid, "")
+	if current, err := readCgroupFile(dir, "memory.current"); err == nil {
+		u.Version, u.Path = 2, dir
+		usage, _ := strconv.ParseUint(current, 10, 64)
+		limit, _ := readCgroupFile(dir, "memory.max")
+		u.MemoryLimit = parseCgroupLimit(limit)
+		inactive := readCgroupKeyValues(dir, "memory.stat")["inactive_file"]
+		if inactive < usage {
+			u.MemoryBytes = usage - inactive
+		}
+		u.OOMKills = readCgroupKeyValues(dir, "memory.events")["oom_kill"]
+		if cpuMax, err := readCgroupFile(dir, "cpu.max"); err == nil {
+			quota, period, _ := strings.Cut(cpuMax, " ")
+			q, qerr := strconv.ParseFloat(quota, 64)
+			p, perr := strconv.ParseFloat(period, 64)
+			if qerr == nil && perr == nil && p > 0 {
+				u.CPULimit = q / p
+			}
+		}
+		stat := readCgroupKeyValues(dir, "cpu.stat")
+		u.Periods, u.ThrottledPeriods = stat["nr_periods"], stat["nr_throttled"]
+	} else {
+		memDir := serverCgroupDir(pid, "memory")
+		usage, err := readCgroupFile(memDir, "memory.usage_in_bytes")
+		if err != nil {
+			return nil, fmt.Errorf("no cgroup memory accounting under %s", envOrDefault(cgroupDirEnv, defaultCgroupDir))
+		}
+		u.Version, u.Path = 1, memDir
+		n, _ := strconv.ParseUint(usage, 10, 64)
+		limit, _ := readCgroupFile(memDir, "memory.limit_in_bytes")
+		u.MemoryLimit = parseCgroupLimit(limit)
+		inactive := readCgroupKeyValues(memDir, "memory.stat")["total_inactive_file"]
+		if inactive < n {
+			u.MemoryBytes = n - inactive
+		}
+		u.OOMKills = readCgroupKeyValues(memDir, "memory.oom_control")["oom_kill"]
+		cpuDir := serverCgroupDir(pid, "cpu")
+		quota, qerr := readCgroupFile(cpuDir, "cpu.cfs_quota_us")
+		period, perr := readCgroupFile(cpuDir, "cpu.cfs_period_us")
+		q, _ := strconv.ParseFloat(quota, 64)
+		p, _ := strconv.ParseFloat(period, 64)
+		if qerr == nil && perr == nil && q > 0 && p > 0 {
+			u.CPULimit = q / p
+		}
+		stat := readCgroupKeyValues(cpuDir, "cpu.stat")
+		u.Periods, u.ThrottledPeriods = stat["nr_periods"], stat["nr_throttled"]
+	}
+	if u.MemoryLimit > 0 {
+		u.MemoryPercent = float64(u.MemoryBytes) / float64(u.MemoryLimit) * 100
+	}
+	if prev != nil && prev.Path == u.Path && u.Periods > prev.Periods && u.ThrottledPeriods >= prev.ThrottledPeriods {
+		u.ThrottledPercent = float64(u.ThrottledPeriods-prev.ThrottledPeriods) / float64(u.Periods-prev.Periods) * 100
+	}
+	return &u, nil
+}
+
+// checkCgroupLimits warns when memory nears the limit or the CPU is being
+// throttled, again when that clears, and whenever the OOM killer has run.
+func checkCgroupLimits(u, prev *CgroupUsage) {
+	maxMemory, maxThrottled := cgroupThresholds()
+	resourceWarningsMutex.Lock()
+	defer resourceWarningsMutex.Unlock()
+	if prev != nil && prev.Path == u.Path && u.OOMKills > prev.OOMKills {
+		sendAlert("resource_oom_kill", fmt.Sprintf("The OOM killer ended %d process(es) in the server's cgroup (limit %d MiB)", u.OOMKills-prev.OOMKills, u.MemoryLimit>>20),
+			map[string]interface{}{"oom_kills": u.OOMKills, "limit_bytes": u.MemoryLimit})
+	}
+	for _, c := range []struct {
+		name, message, resolved string
+		value, threshold        float64
+		breached                bool
+	}{
+		{"memory_limit", fmt.Sprintf("Server memory at %.1f%% of its %d MiB limit", u.MemoryPercent, u.MemoryLimit>>20),
+			fmt.Sprintf("Server memory back to %.1f%% of its limit", u.MemoryPercent),
+			u.MemoryPercent, maxMemory, u.MemoryLimit > 0 && maxMemory > 0 && u.MemoryPercent >= maxMemory},
+		{"cpu_throttled", fmt.Sprintf("Server CPU throttled in %.1f%% of periods (limit %.2f cores)", u.ThrottledPercent, u.CPULimit),
+			fmt.Sprintf("Server CPU throttling back to %.1f%% of periods", u.ThrottledPercent),
+			u.ThrottledPercent, maxThrottled, maxThrottled > 0 && u.ThrottledPercent >= maxThrottled},
+	} {
+		fields := map[string]interface{}{"metric": c.name, "value": c.value, "threshold": c.threshold}
+		_, warned := resourceWarnings[c.name]
+		switch {
+		case c.breached:
+			if !warned {
+				sendAlert("resource_"+c.name, c.message, fields)
+			}
+			resourceWarnings[c.name] = c.message
+		case warned:
+			delete(resourceWarnings, c.name)
+			sendAlert("resource_"+c.name+"_resolved", c.resolved, fields)
+		}
+	}
+}
+
+// healthzHandler reports the sidecar as ok, or degraded while the server
+// is close to a resource limit. It always answers 200 because restarting
+// the sidecar would not help; monitors should read the status.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	resourceWarningsMutex.Lock()
+	warnings := make([]string, 0, len(resourceWarnings))
+	for _, name := range []string{"memory_limit", "cpu_throttled"} {
+		if msg, ok := resourceWarnings[name]; ok {
+			warnings = append(warnings, msg)
+		}
+	}
+	resourceWarningsMutex.Unlock()
+	status := "ok"
+	if len(warnings) > 0 {
+		status = "degraded"
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"status": status, "warnings": warnings, "time": time.Now()})
+}
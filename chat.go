@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	chatBufferLen     = 500
+	maxChatMessageLen = 512
+)
+
+// ChatMessage is an entry in the chat event stream. Inbound messages come
+// from players in game; outbound messages were posted via /chat/send.
+type ChatMessage struct {
+	Seq       int64     `json:"seq"`
+	Direction string    `json:"direction"` // "inbound" or "outbound"
+	Source    string    `json:"source"`    // "game" or the external platform name
+	Sender    string    `json:"sender"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+}
+
+// ChatSendRequest is the body of POST /chat/send.
+type ChatSendRequest struct {
+	Source  string `json:"source"` // platform name shown as prefix, e.g. "matrix"
+	Sender  string `json:"sender"`
+	Message string `json:"message"`
+	Prefix  string `json:"prefix,omitempty"` // overrides the default "[source]" prefix
+}
+
+var (
+	chatLog         = make([]ChatMessage, 0)
+	chatSeq         int64
+	chatSubscribers = make(map[chan ChatMessage]struct{})
+	chatMutex       sync.Mutex
+)
+
+// publishChat appends a message to the stream and fans it out to subscribers.
+func publishChat(msg ChatMessage) {
+	chatMutex.Lock()
+	defer chatMutex.Unlock()
+	chatSeq++
+	msg.Seq = chatSeq
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	chatLog = append(chatLog, msg)
+	if len(chatLog) > chatBufferLen {
+		chatLog = chatLog[len(chatLog)-chatBufferLen:]
+	}
+	if msg.Direction == "inbound" && strings.HasPrefix(msg.Message, "!") {
+		go handleChatCommand(msg.Sender, msg.Message)
+	}
+	if msg.Direction == "inbound" && msg.Source == "game" && msg.Sender != "" {
+		go questOnChat(msg.Sender, msg.Message)
+		kind := activityChat
+		if strings.HasPrefix(msg.Message, "!") {
+			kind = activityCommand
+		}
+		go observeActivity(msg.Sender, kind)
+	}
+	for ch := range chatSubscribers {
+		select {
+		case ch <- msg:
+		default:
+			// Slow subscribers miss messages rather than block the game.
+		}
+	}
+}
+
+// handleChatCommand dispatches "!" chat triggers typed by players.
+func handleChatCommand(player, message string) {
+	if player == "" {
+		return
+	}
+	if handleHomeChatCommand(player, message) {
+		return
+	}
+	handleShopChatCommand(player, message)
+}
+
+// tellrawCommand builds a tellraw command broadcasting text to all players.
+func tellrawCommand(target, text string) string {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"rawtext": []map[string]string{{"text": text}},
+	})
+	return "tellraw " + target + " " + string(raw)
+}
+
+// chatSendHandler posts a message from an external platform into game chat.
+func chatSendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req ChatSendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Message = strings.TrimSpace(strings.ReplaceAll(req.Message, "\n", " "))
+	if req.Message == "" || len(req.Message) > maxChatMessageLen {
+		writeJSONError(w, http.StatusBadRequest, "Message must be between 1 and 512 characters")
+		return
+	}
+	if req.Source == "" {
+		req.Source = "api"
+	}
+	prefix := req.Prefix
+	if prefix == "" {
+		prefix = "[" + req.Source + "]"
+	}
+	text := prefix + " " + req.Message
+	if req.Sender != "" {
+		text = fmt.Sprintf("%s <%s> %s", prefix, req.Sender, req.Message)
+	}
+	if err := sendServerCommand(tellrawCommand("@a", text)); err != nil {
+		log.Printf("Error sending chat message: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to send message")
+		return
+	}
+	publishChat(ChatMessage{Direction: "outbound", Source: req.Source, Sender: req.Sender, Message: req.Message})
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Chat message sent"})
+}
+
+// chatEventsHandler returns chat messages with a sequence number greater
+// than ?since= (default 0), so pollers can resume where they left off.
+func chatEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid since")
+			return
+		}
+		since = n
+	}
+	chatMutex.Lock()
+	messages := []ChatMessage{}
+	for _, m := range chatLog {
+		if m.Seq > since {
+			messages = append(messages, m)
+		}
+	}
+	latest := chatSeq
+	chatMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"messages": messages, "latest_seq": latest})
+}
+
+// chatStreamHandler streams chat messages as server-sent events.
+func chatStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	ch := make(chan ChatMessage, 64)
+	chatMutex.Lock()
+	chatSubscribers[ch] = struct{}{}
+	chatMutex.Unlock()
+	defer func() {
+		chatMutex.Lock()
+		delete(chatSubscribers, ch)
+		chatMutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case msg := <-ch:
+			data, _ := json.Marshal(msg)
+			fmt.Fprintf(w, "id: %d\nevent: chat\ndata: %s\n\n", msg.Seq, data)
+			flusher.Flush()
+		}
+	}
+}
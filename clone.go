@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cloneLocalProperties are kept from the local server.properties when
+// cloning so a copy can run next to its source on the same host.
+var cloneLocalProperties = []string{"server-port", "server-portv6"}
+
+// ClonePack is an archived pack offered by a source sidecar.
+type ClonePack struct {
+	Type string `json:"type"`
+	UUID string `json:"uuid"`
+	File string `json:"file"`
+}
+
+// CloneBundle is everything a sidecar needs to replicate another, apart
+// from the world itself.
+type CloneBundle struct {
+	Config      string                   `json:"config"`
+	Properties  map[string]string        `json:"properties"`
+	Packs       []ClonePack              `json:"packs"`
+	Activations map[string][]ActiveAddon `json:"activations"`
+}
+
+// CloneRequest asks this sidecar to replicate a remote one.
+type CloneRequest struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	World bool   `json:"world"`
+}
+
+// remoteSidecar is another sidecar reached with its admin token.
+type remoteSidecar struct {
+	base  string
+	token string
+}
+
+func newRemoteSidecar(rawURL, token string) (*remoteSidecar, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("invalid sidecar URL")
+	}
+	return &remoteSidecar{base: strings.TrimSuffix(rawURL, "/"), token: token}, nil
+}
+
+// get issues an authenticated GET and fails on any non-200 status.
+func (s *remoteSidecar) get(path string) (*http.Response, error) {
+	return s.do(http.MethodGet, path)
+}
+
+// do issues an authenticated request and fails on any non-200 status.
+func (s *remoteSidecar) do(method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (s *remoteSidecar) getJSON(path string, v interface{}) error {
+	return s.decode(http.MethodGet, path, v)
+}
+
+func (s *remoteSidecar) postJSON(path string, v interface{}) error {
+	return s.decode(http.MethodPost, path, v)
+}
+
+func (s *remoteSidecar) decode(method, path string, v interface{}) error {
+	resp, err := s.do(method, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// download saves a remote file to a temporary path.
+func (s *remoteSidecar) download(path, pattern string) (string, error) {
+	resp, err := s.get(path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, limitedReader{io.LimitReader(resp.Body, maxDownloadSize+1), downloadLimiter})
+	f.Close()
+	if err == nil && n > maxDownloadSize {
+		err = errors.New("download exceeds size limit")
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// archivedPacks lists the pack archives kept under pack_archives.
+func archivedPacks() []ClonePack {
+	packs := []ClonePack{}
+	for kind, dir := range map[string]string{"behavior": behaviorPackArchiveDir, "resource": resourcePackArchiveDir} {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			files, _ := os.ReadDir(filepath.Join(dir, e.Name()))
+			for _, f := range files {
+				if !f.IsDir() {
+					packs = append(packs, ClonePack{Type: kind, UUID: e.Name(), File: f.Name()})
+					break
+				}
+			}
+		}
+	}
+	return packs
+}
+
+// buildCloneBundle collects this sidecar's configuration for a clone.
+func buildCloneBundle() (CloneBundle, error) {
+	config, err := exportConfig()
+	if err != nil {
+		return CloneBundle{}, err
+	}
+	props, err := readServerProperties()
+	if err != nil {
+		return CloneBundle{}, err
+	}
+	bundle := CloneBundle{Config: string(config), Properties: props, Packs: archivedPacks(), Activations: map[string][]ActiveAddon{}}
+	worldPacksMutex.Lock()
+	defer worldPacksMutex.Unlock()
+	for _, kind := range []string{"behavior", "resource"} {
+		if bundle.Activations[kind], err = readWorldPacks(kind); err != nil {
+			return CloneBundle{}, err
+		}
+	}
+	return bundle, nil
+}
+
+// serverUp reports whether the dedicated server answers pings.
+func serverUp() bool {
+	_, err := pingServer(gameAddr(), time.Second)
+	return err == nil
+}
+
+// runClone replicates the remote sidecar's configuration, packs and,
+// optionally, world.
+func runClone(t *Task, remote *remoteSidecar, withWorld bool) error {
+	t.step(5, "Fetching configuration bundle from %s", remote.base)
+	var bundle CloneBundle
+	if err := remote.getJSON("/clone/bundle", &bundle); err != nil {
+		return fmt.Errorf("fetching bundle: %w", err)
+	}
+
+	t.step(10, "Applying configuration")
+	cfg, err := decodeConfig([]byte(bundle.Config))
+	if err == nil {
+		err = validateConfig(&cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("remote configuration: %w", err)
+	}
+	applyConfig(cfg)
+	configChanged()
+
+	t.step(15, "Writing server.properties")
+	if _, err := os.Stat(serverPropsPath); os.IsNotExist(err) {
+		if err := os.WriteFile(serverPropsPath, nil, 0644); err != nil {
+			return err
+		}
+	}
+	props := make(map[string]string, len(bundle.Properties))
+	for k, v := range bundle.Properties {
+		if propertyKeyPattern.MatchString(k) && !strings.ContainsAny(v, "\n\r") {
+			props[k] = v
+		}
+	}
+	for _, k := range cloneLocalProperties {
+		delete(props, k)
+	}
+	if err := setServerProperties(props); err != nil {
+		return fmt.Errorf("writing server.properties: %w", err)
+	}
+
+	for i, p := range bundle.Packs {
+		if (p.Type != "behavior" && p.Type != "resource") || !validName(p.UUID) || !validName(p.File) {
+			return fmt.Errorf("invalid pack entry %q", p.UUID)
+		}
+		t.step(20+40*i/len(bundle.Packs), "Installing %s pack %s", p.Type, p.UUID)
+		path, err := remote.download("/clone/packs/"+p.Type+"/"+url.PathEscape(p.UUID), "clone-*.mcpack")
+		if err != nil {
+			return fmt.Errorf("downloading pack %s: %w", p.UUID, err)
+		}
+		// Keep the source's file name so restores find the same archive.
+		named := filepath.Join(filepath.Dir(path), p.File)
+		if err := os.Rename(path, named); err != nil {
+			os.Remove(path)
+			return err
+		}
+		err = installMcpackFile(named, p.Type)
+		os.Remove(named)
+		if err != nil {
+			return err
+		}
+	}
+
+	var archive string
+	if withWorld {
+		t.step(60, "Downloading world")
+		if archive, err = remote.download("/clone/world", "clone-world-*"); err != nil {
+			return fmt.Errorf("downloading world: %w", err)
+		}
+		defer os.Remove(archive)
+	}
+
+	apply := func() error {
+		if archive != "" {
+			t.step(85, "Restoring world")
+			if err := restoreWorldArchive(archive); err != nil {
+				return fmt.Errorf("restoring world: %w", err)
+			}
+		}
+		worldFolder, err := getWorldFolder()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(worldFolder, 0755); err != nil {
+			return err
+		}
+		t.step(95, "Activating packs")
+		worldPacksMutex.Lock()
+		defer worldPacksMutex.Unlock()
+		for kind, addons := range bundle.Activations {
+			if kind != "behavior" && kind != "resource" {
+				continue
+			}
+			if err := writeWorldPacks(kind, addons); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if archive != "" && serverUp() {
+		t.step(80, "Stopping server to replace the world")
+		err = withServerStopped(apply)
+	} else {
+		err = apply()
+	}
+	if err != nil {
+		return err
+	}
+	t.step(100, "Clone complete")
+	log.Printf("Cloned configuration from %s", remote.base)
+	return nil
+}
+
+// cloneFromHandler starts replicating another sidecar into this one.
+func cloneFromHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req CloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	remote, err := newRemoteSidecar(req.URL, req.Token)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, busy := runningTask("clone"); busy {
+		writeJSONError(w, http.StatusConflict, "A clone is already running")
+		return
+	}
+	t := startTask("clone", func(t *Task) error { return runClone(t, remote, req.World) })
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+}
+
+// cloneSourceHandler serves this sidecar's bundle, pack archives and world
+// to another sidecar cloning it.
+func cloneSourceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/clone/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "bundle":
+		bundle, err := buildCloneBundle()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, bundle)
+	case len(parts) == 3 && parts[0] == "packs":
+		for _, p := range archivedPacks() {
+			if p.Type == parts[1] && p.UUID == parts[2] {
+				w.Header().Set("Content-Type", "application/zip")
+				dir := behaviorPackArchiveDir
+				if p.Type == "resource" {
+					dir = resourcePackArchiveDir
+				}
+				serveLimitedFile(w, r, filepath.Join(dir, p.UUID, p.File))
+				return
+			}
+		}
+		writeJSONError(w, http.StatusNotFound, "Pack not found")
+	case len(parts) == 1 && parts[0] == "world":
+		archive, err := createHotBackup()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer os.Remove(archive)
+		defer os.Remove(archive + ".manifest.json")
+		w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(archive))
+		serveLimitedFile(w, r, archive)
+	default:
+		writeJSONError(w, http.StatusNotFound, "Not found")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const commandPolicyStateFile = "command_policy.json"
+
+// Command policy rule actions.
+const (
+	commandRuleDeny  = "deny"
+	commandRuleAllow = "allow"
+)
+
+// CommandRule restricts the commands /send-command passes to the server.
+// Commands lists command prefixes matched on whole words, case-insensitively
+// and with or without a leading slash, so "op" matches "op Steve" but not
+// "ops" and "*" matches everything. A rule applies to requests made with one
+// of its Keys (API key names) or Roles; with neither it applies to all. A
+// command matching any applicable deny rule is refused; if allow rules
+// apply, the command must also match one of them. An execute command is
+// checked along with each command it runs, so "execute as @a run op Steve"
+// is caught by a rule on "op" and passes an allowlist only if both
+// "execute" and "op" are allowed.
+//
+// Custom commands are checked both when they are saved and when they are
+// run, and /console WebSocket commands as they arrive. Commands configured
+// in scheduled jobs, inbound hooks, stream mappings and mitigation rules,
+// and the fixed commands behind player endpoints, are not checked; restrict
+// who may change those with endpoint roles instead.
+type CommandRule struct {
+	Name     string   `json:"name"`
+	Action   string   `json:"action"`
+	Commands []string `json:"commands"`
+	Keys     []string `json:"keys,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+}
+
+var (
+	commandRules       = make([]CommandRule, 0)
+	commandPolicyMutex sync.Mutex
+)
+
+// validate checks a rule for completeness.
+func (c *CommandRule) validate() error {
+	if !validName(c.Name) {
+		return errors.New("name must be a valid name")
+	}
+	if c.Action != commandRuleDeny && c.Action != commandRuleAllow {
+		return fmt.Errorf("action must be %s or %s", commandRuleDeny, commandRuleAllow)
+	}
+	if len(c.Commands) == 0 {
+		return errors.New("commands is required")
+	}
+	for _, p := range c.Commands {
+		if normalizeCommand(p) == "" {
+			return errors.New("commands must not be empty")
+		}
+	}
+	for _, role := range c.Roles {
+		if roleRank[role] == 0 {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule covers a request by the key and role.
+func (c *CommandRule) appliesTo(key, role string) bool {
+	if len(c.Keys) == 0 && len(c.Roles) == 0 {
+		return true
+	}
+	for _, k := range c.Keys {
+		if key != "" && k == key {
+			return true
+		}
+	}
+	for _, r := range c.Roles {
+		if role != "" && r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the command starts with one of the rule's prefixes.
+func (c *CommandRule) matches(command string) bool {
+	for _, p := range c.Commands {
+		p = normalizeCommand(p)
+		if p == "*" || command == p || strings.HasPrefix(command, p+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// commandChain returns a normalized command followed by the commands an
+// execute chain runs, each the text after a "run" subcommand.
+func commandChain(command string) []string {
+	chain := []string{command}
+	for strings.HasPrefix(command, "execute ") {
+		i := strings.Index(command, " run ")
+		if i < 0 {
+			break
+		}
+		command = normalizeCommand(command[i+len(" run "):])
+		chain = append(chain, command)
+	}
+	return chain
+}
+
+// normalizeCommand lowercases a command, drops a leading slash and collapses
+// whitespace, for matching.
+func normalizeCommand(command string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(command), "/")), " "))
+}
+
+// commandRequester returns the API key name and role a request was made
+// with. The admin token counts as the admin role.
+func commandRequester(r *http.Request) (key, role string) {
+	if k, ok := lookupAPIKey(r); ok {
+		return k.Name, k.Role
+	}
+	if isAdminRequest(r) {
+		return "", roleAdmin
+	}
+	return "", ""
+}
+
+// checkCommandPolicy returns the rule refusing a command, if any, and why.
+func checkCommandPolicy(command, key, role string) (rule, reason string) {
+	chain := commandChain(normalizeCommand(command))
+	commandPolicyMutex.Lock()
+	defer commandPolicyMutex.Unlock()
+	var allowlists []string
+	allowed := make([]bool, len(chain))
+	for _, c := range commandRules {
+		if !c.appliesTo(key, role) {
+			continue
+		}
+		for i, part := range chain {
+			switch c.Action {
+			case commandRuleDeny:
+				if c.matches(part) {
+					return c.Name, fmt.Sprintf("Command blocked by rule %q", c.Name)
+				}
+			case commandRuleAllow:
+				allowed[i] = allowed[i] || c.matches(part)
+			}
+		}
+		if c.Action == commandRuleAllow {
+			allowlists = append(allowlists, c.Name)
+		}
+	}
+	refused := false
+	for _, ok := range allowed {
+		refused = refused || !ok
+	}
+	if len(allowlists) > 0 && refused {
+		if len(allowlists) == 1 {
+			return allowlists[0], fmt.Sprintf("Command not allowed by rule %q", allowlists[0])
+		}
+		return allowlists[0], fmt.Sprintf("Command not allowed by any of rules %q", allowlists)
+	}
+	return "", ""
+}
+
+// refuseCommand checks a command sent or saved by a request, returning the
+// status and reason to refuse it with, and the rule for policy refusals, or
+// zero if it may run. Multi-line commands are rejected outright: the pipe
+// transports would run each line, so only the first would have been checked.
+// A policy refusal publishes a command_blocked event.
+func refuseCommand(r *http.Request, command string) (status int, reason, rule string) {
+	if strings.ContainsAny(command, "\r\n") {
+		return http.StatusBadRequest, "Command must be a single line", ""
+	}
+	key, role := commandRequester(r)
+	rule, reason = checkCommandPolicy(command, key, role)
+	if rule == "" {
+		return 0, "", ""
+	}
+	log.Printf("Command refused by policy rule %s: %s", rule, command)
+	publishEvent("command_blocked", reason+": "+command, map[string]interface{}{"command": command, "rule": rule, "key": key, "role": role})
+	return http.StatusForbidden, reason, rule
+}
+
+// allowCommand applies refuseCommand to a request, writing the refusal: a
+// 403 naming the rule for the policy, or a 400.
+func allowCommand(w http.ResponseWriter, r *http.Request, command string) bool {
+	status, reason, rule := refuseCommand(r, command)
+	switch {
+	case status == 0:
+		return true
+	case rule != "":
+		writeJSONResponse(w, status, map[string]string{"error": reason, "rule": rule})
+	default:
+		writeJSONError(w, status, reason)
+	}
+	return false
+}
+
+// commandPolicyHandler serves the /send-command policy: GET lists the rules
+// and PUT replaces them all.
+func commandPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		commandPolicyMutex.Lock()
+		defer commandPolicyMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"rules": commandRules})
+	case http.MethodPut:
+		var body struct {
+			Rules []CommandRule `json:"rules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		seen := map[string]bool{}
+		for i := range body.Rules {
+			c := &body.Rules[i]
+			if err := c.validate(); err != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("rules[%d]: %v", i, err))
+				return
+			}
+			if seen[c.Name] {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("rules[%d]: duplicate name %q", i, c.Name))
+				return
+			}
+			seen[c.Name] = true
+		}
+		if body.Rules == nil {
+			body.Rules = []CommandRule{}
+		}
+		commandPolicyMutex.Lock()
+		commandRules = body.Rules
+		if err := saveState(commandPolicyStateFile, commandRules); err != nil {
+			log.Printf("Error saving command policy: %v", err)
+		}
+		commandPolicyMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"rules": body.Rules})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
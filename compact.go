@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CompactResult reports a world database compaction. Sizes are in bytes.
+type CompactResult struct {
+	World      string  `json:"world"`
+	SizeBefore int64   `json:"size_before"`
+	SizeAfter  int64   `json:"size_after"`
+	Keys       int     `json:"keys"`
+	Tables     int     `json:"tables"`
+	Seconds    float64 `json:"seconds"`
+	Restarted  bool    `json:"restarted"`
+}
+
+// dbSize is the total size of the files in a database folder.
+func dbSize(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
+
+// compactWorld rewrites a world's database without deleted keys and
+// superseded versions. The new database is written next to the old one and
+// read back before the two are swapped; the old one goes to the trash.
+func compactWorld(name string) (CompactResult, error) {
+	result := CompactResult{World: name}
+	dir := filepath.Join(worldsDir, name, "db")
+	tmp := dir + ".compact"
+	start := time.Now()
+	var err error
+	if result.SizeBefore, err = dbSize(dir); err != nil {
+		return result, err
+	}
+	os.RemoveAll(tmp)
+	if result.Keys, result.Tables, err = compactLDB(dir, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return result, err
+	}
+	check, err := openLDB(tmp)
+	if err == nil {
+		keys := 0
+		err = check.scan(nil, func(ldbEntry) error { keys++; return nil })
+		if err == nil && keys != result.Keys {
+			err = fmt.Errorf("compacted database has %d keys, expected %d", keys, result.Keys)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return result, fmt.Errorf("verifying compacted database: %w", err)
+	}
+	if err := moveToTrash("world-db", dir, "compacted world "+name); err != nil {
+		os.RemoveAll(tmp)
+		return result, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return result, fmt.Errorf("installing compacted database (the old one is in the trash): %w", err)
+	}
+	result.SizeAfter, _ = dbSize(dir)
+	result.Seconds = time.Since(start).Seconds()
+	return result, nil
+}
+
+// compactWorldHandler serves POST /worlds/{name}/compact. The server keeps
+// the active world's database open, so it is compacted with the server
+// stopped, which takes ?restart=true while the server is running.
+func compactWorldHandler(w http.ResponseWriter, r *http.Request, name string) {
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	if _, err := os.Stat(filepath.Join(worldsDir, name, "db", "CURRENT")); err != nil {
+		writeJSONError(w, http.StatusConflict, "The world has no database yet")
+		return
+	}
+	var result CompactResult
+	compact := func() error {
+		var err error
+		result, err = compactWorld(name)
+		return err
+	}
+	active, _ := currentLevelName()
+	restart := name == active && serverUp()
+	if restart && r.URL.Query().Get("restart") != "true" {
+		writeJSONError(w, http.StatusConflict, "The world is in use; pass restart=true to stop the server while it is compacted")
+		return
+	}
+	var err error
+	if restart {
+		kickAllPlayers("Compacting the world, back shortly")
+		err = withServerStopped(compact)
+	} else {
+		err = compact()
+	}
+	result.Restarted = restart
+	if err != nil {
+		log.Printf("Error compacting world %s: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to compact world: "+err.Error())
+		return
+	}
+	log.Printf("Compacted world %s from %d to %d bytes (%d keys)", name, result.SizeBefore, result.SizeAfter, result.Keys)
+	writeJSONResponse(w, http.StatusOK, result)
+}
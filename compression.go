@@ -0,0 +1,293 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const backupCompressionStateFile = "backup_compression.json"
+
+// Archive formats for backups and world exports.
+const (
+	formatZip    = "zip"
+	formatTarGz  = "tar.gz"
+	formatTarZst = "tar.zst"
+)
+
+// BackupCompression selects how world backups and exports are archived.
+// Method applies to zip only. Level 0 uses the format's default.
+type BackupCompression struct {
+	Format string `json:"format"`
+	Method string `json:"method,omitempty"` // deflate or store
+	Level  int    `json:"level,omitempty"`
+}
+
+var (
+	backupCompression      = BackupCompression{Format: formatZip, Method: "deflate"}
+	backupCompressionMutex sync.Mutex
+)
+
+func (c *BackupCompression) validate() error {
+	switch c.Format {
+	case formatZip:
+		if c.Method == "" {
+			c.Method = "deflate"
+		}
+		if c.Method != "deflate" && c.Method != "store" {
+			return errors.New("method must be deflate or store")
+		}
+		if c.Level < 0 || c.Level > 9 {
+			return errors.New("zip level must be 1-9")
+		}
+	case formatTarGz:
+		c.Method = ""
+		if c.Level < 0 || c.Level > 9 {
+			return errors.New("gzip level must be 1-9")
+		}
+	case formatTarZst:
+		c.Method = ""
+		if c.Level < 0 || c.Level > 19 {
+			return errors.New("zstd level must be 1-19")
+		}
+		if _, err := exec.LookPath("zstd"); err != nil {
+			return errors.New("zstd is not installed")
+		}
+	default:
+		return fmt.Errorf("unknown format %q", c.Format)
+	}
+	return nil
+}
+
+// extension returns the file name suffix for archives in this format.
+func (c BackupCompression) extension() string {
+	return "." + c.Format
+}
+
+// currentBackupCompression returns the configured compression.
+func currentBackupCompression() BackupCompression {
+	backupCompressionMutex.Lock()
+	defer backupCompressionMutex.Unlock()
+	return backupCompression
+}
+
+// archiveDirectory writes srcDir into dst using the given compression and
+// returns the hashes of the archived files.
+func archiveDirectory(srcDir, dst string, c BackupCompression) (manifest SyncManifest, err error) {
+	out, err := os.Create(dst)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to create archive: %w", err)
+	}
+	buffered := bufio.NewWriterSize(out, 1<<20)
+	manifest, err = writeArchive(buffered, srcDir, c)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return manifest, fmt.Errorf("failed to archive %s: %w", srcDir, err)
+	}
+	return manifest, nil
+}
+
+// writeArchive streams srcDir to w in the given format.
+func writeArchive(w io.Writer, srcDir string, c BackupCompression) (SyncManifest, error) {
+	entries, err := listArchiveEntries(srcDir)
+	if err != nil {
+		return SyncManifest{}, err
+	}
+	if c.Format == formatZip {
+		method, level := zip.Deflate, c.Level
+		if c.Method == "store" {
+			method = zip.Store
+		}
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		return writeZipArchive(w, entries, method, level)
+	}
+
+	var compressed io.WriteCloser
+	var wait func() error
+	if c.Format == formatTarGz {
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		if compressed, err = gzip.NewWriterLevel(w, level); err != nil {
+			return SyncManifest{}, err
+		}
+	} else {
+		args := []string{"-q", "-c", "-T0"}
+		if c.Level > 0 {
+			args = append(args, "-"+strconv.Itoa(c.Level))
+		}
+		cmd := exec.Command("zstd", args...)
+		cmd.Stdout = w
+		if compressed, err = cmd.StdinPipe(); err != nil {
+			return SyncManifest{}, err
+		}
+		if err = cmd.Start(); err != nil {
+			return SyncManifest{}, fmt.Errorf("failed to start zstd: %w", err)
+		}
+		wait = cmd.Wait
+	}
+	buffered := bufio.NewWriterSize(compressed, 1<<20)
+	manifest, err := writeTarArchive(buffered, entries)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if cerr := compressed.Close(); err == nil {
+		err = cerr
+	}
+	if wait != nil {
+		if werr := wait(); werr != nil && err == nil {
+			err = fmt.Errorf("zstd failed: %w", werr)
+		}
+	}
+	return manifest, err
+}
+
+// extractArchive extracts a zip, gzip or zstd tarball into dst, detecting
+// the format from the file's magic bytes.
+func extractArchive(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return errors.New("unrecognised archive format")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK")):
+		return unzipArchive(src, dst)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return untar(gz, dst)
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		cmd := exec.Command("zstd", "-q", "-d", "-c")
+		cmd.Stdin = f
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start zstd: %w", err)
+		}
+		untarErr := untar(stdout, dst)
+		if untarErr != nil {
+			cmd.Process.Kill()
+		}
+		if err := cmd.Wait(); err != nil && untarErr == nil {
+			return fmt.Errorf("zstd failed: %w", err)
+		}
+		return untarErr
+	}
+	return errors.New("unrecognised archive format")
+}
+
+// untar extracts a tar stream into dst, refusing entries that escape dst.
+func untar(r io.Reader, dst string) error {
+	root := filepath.Clean(dst) + string(os.PathSeparator)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dst, header.Name)
+		if !strings.HasPrefix(path, root) {
+			return fmt.Errorf("illegal path in archive: %s", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode).Perm()|0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// loadBackupCompression restores the configured compression at startup.
+func loadBackupCompression() {
+	backupCompressionMutex.Lock()
+	defer backupCompressionMutex.Unlock()
+	if err := loadState(backupCompressionStateFile, &backupCompression); err != nil {
+		log.Printf("Error loading backup compression: %v", err)
+	}
+	if err := backupCompression.validate(); err != nil {
+		log.Printf("Invalid backup compression %+v, using zip: %v", backupCompression, err)
+		backupCompression = BackupCompression{Format: formatZip, Method: "deflate"}
+	}
+}
+
+// backupCompressionHandler shows (GET) or replaces (PUT) the archive format
+// used for world backups and exports.
+func backupCompressionHandler(w http.ResponseWriter, r *http.Request) {
+	backupCompressionMutex.Lock()
+	defer backupCompressionMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, backupCompression)
+	case http.MethodPut:
+		var c BackupCompression
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := c.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		backupCompression = c
+		if err := saveState(backupCompressionStateFile, backupCompression); err != nil {
+			log.Printf("Error saving backup compression: %v", err)
+		}
+		writeJSONResponse(w, http.StatusOK, backupCompression)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
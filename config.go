@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables recognised by the sidecar.
+const (
+	dataDirEnv            = "BEDROCK_API_DATA_DIR"
+	commandPipeEnv        = "BEDROCK_API_COMMAND_PIPE"
+	transportEnv          = "BEDROCK_API_TRANSPORT"
+	rconAddrEnv           = "BEDROCK_API_RCON_ADDR"
+	rconPasswordEnv       = "BEDROCK_API_RCON_PASSWORD"
+	wsTransportURLEnv     = "BEDROCK_API_WS_URL"
+	mcwsEnabledEnv        = "BEDROCK_API_MCWS_ENABLED"
+	gameAddrEnv           = "BEDROCK_API_GAME_ADDR"
+	startCommandEnv       = "BEDROCK_API_START_COMMAND"
+	hibernateAfterEnv     = "BEDROCK_API_HIBERNATE_AFTER"
+	queueCapacityEnv      = "BEDROCK_API_QUEUE_CAPACITY"
+	queueWebhookEnv       = "BEDROCK_API_QUEUE_WEBHOOK"
+	twitchSecretEnv       = "BEDROCK_API_TWITCH_SECRET"
+	streamSecretEnv       = "BEDROCK_API_STREAM_SECRET"
+	enablePprofEnv        = "BEDROCK_API_ENABLE_PPROF"
+	adminTokenEnv         = "BEDROCK_API_ADMIN_TOKEN"
+	configFileEnv         = "BEDROCK_API_CONFIG_FILE"
+	configWritebackEnv    = "BEDROCK_API_CONFIG_WRITEBACK"
+	propertiesTemplateEnv = "BEDROCK_API_PROPERTIES_TEMPLATE"
+	provisionSpecEnv      = "BEDROCK_API_PROVISION_SPEC"
+	standbyPrimaryEnv     = "BEDROCK_API_STANDBY_PRIMARY"
+	standbyTokenEnv       = "BEDROCK_API_STANDBY_TOKEN"
+	standbyIntervalEnv    = "BEDROCK_API_STANDBY_INTERVAL"
+	serverLogEnv          = "BEDROCK_API_SERVER_LOG"
+	restartCountdownEnv   = "BEDROCK_API_RESTART_COUNTDOWN"
+	apiKeysEnv            = "BEDROCK_API_KEYS"
+	apiKeysFileEnv        = "BEDROCK_API_KEYS_FILE"
+	gitBuildStepsEnv      = "BEDROCK_API_GIT_BUILD_STEPS"
+	devPacksDirEnv        = "BEDROCK_API_DEV_PACKS_DIR"
+	tlsCertEnv            = "BEDROCK_API_TLS_CERT"
+	tlsKeyEnv             = "BEDROCK_API_TLS_KEY"
+	tlsClientCAEnv        = "BEDROCK_API_TLS_CLIENT_CA"
+	tlsClientAuthEnv      = "BEDROCK_API_TLS_CLIENT_AUTH"
+	plainHTTPAddrEnv      = "BEDROCK_API_HTTP_ADDR"
+	contentLogEnv         = "BEDROCK_API_CONTENT_LOG"
+	s3EndpointEnv         = "BEDROCK_API_S3_ENDPOINT"
+	s3RegionEnv           = "BEDROCK_API_S3_REGION"
+	s3BucketEnv           = "BEDROCK_API_S3_BUCKET"
+	s3PrefixEnv           = "BEDROCK_API_S3_PREFIX"
+	s3AccessKeyEnv        = "BEDROCK_API_S3_ACCESS_KEY_ID"
+	s3SecretKeyEnv        = "BEDROCK_API_S3_SECRET_ACCESS_KEY"
+	s3PathStyleEnv        = "BEDROCK_API_S3_PATH_STYLE"
+	s3StorageClassEnv     = "BEDROCK_API_S3_STORAGE_CLASS"
+	s3KeepLastEnv         = "BEDROCK_API_S3_KEEP_LAST"
+	s3MaxAgeDaysEnv       = "BEDROCK_API_S3_MAX_AGE_DAYS"
+	alertWebhooksEnv      = "BEDROCK_API_ALERT_WEBHOOKS"
+	latencyAlertMSEnv     = "BEDROCK_API_LATENCY_ALERT_MS"
+	packetLossAlertEnv    = "BEDROCK_API_PACKET_LOSS_ALERT_PERCENT"
+	publicAddrEnv         = "BEDROCK_API_PUBLIC_ADDR"
+	publicCacheTTLEnv     = "BEDROCK_API_PUBLIC_CACHE_TTL"
+	eventSchemaVersionEnv = "BEDROCK_API_EVENT_SCHEMA_VERSION"
+	trashRetentionEnv     = "BEDROCK_API_TRASH_RETENTION"
+	xuidLookupURLEnv      = "BEDROCK_API_XUID_LOOKUP_URL"
+	serverPIDFileEnv      = "BEDROCK_API_SERVER_PID_FILE"
+	cgroupDirEnv          = "BEDROCK_API_CGROUP_DIR"
+	memoryWarnPercentEnv  = "BEDROCK_API_MEMORY_WARN_PERCENT"
+	cpuThrottleWarnEnv    = "BEDROCK_API_CPU_THROTTLE_WARN_PERCENT"
+	playerWebhooksEnv     = "BEDROCK_API_PLAYER_WEBHOOKS"
+	eventWebhooksEnv      = "BEDROCK_API_EVENT_WEBHOOKS"
+	mqttURLEnv            = "BEDROCK_API_MQTT_URL"
+	influxURLEnv          = "BEDROCK_API_INFLUX_URL"
+	influxTokenEnv        = "BEDROCK_API_INFLUX_TOKEN"
+	graphiteAddrEnv       = "BEDROCK_API_GRAPHITE_ADDR"
+	metricsPrefixEnv      = "BEDROCK_API_METRICS_PREFIX"
+	metricsTagsEnv        = "BEDROCK_API_METRICS_TAGS"
+	metricsIntervalEnv    = "BEDROCK_API_METRICS_INTERVAL"
+	superviseEnv          = "BEDROCK_API_SUPERVISE"
+	superviseDirEnv       = "BEDROCK_API_SUPERVISE_DIR"
+	logBufferLinesEnv     = "BEDROCK_API_LOG_BUFFER_LINES"
+	logBufferBytesEnv     = "BEDROCK_API_LOG_BUFFER_BYTES"
+	watchdogTimeoutEnv    = "BEDROCK_API_WATCHDOG_TIMEOUT"
+	watchdogPolicyEnv     = "BEDROCK_API_WATCHDOG_POLICY"
+	watchdogRestartsEnv   = "BEDROCK_API_WATCHDOG_MAX_RESTARTS"
+	smtpAddrEnv           = "BEDROCK_API_SMTP_ADDR"
+	smtpUserEnv           = "BEDROCK_API_SMTP_USER"
+	smtpPasswordEnv       = "BEDROCK_API_SMTP_PASSWORD"
+	smtpFromEnv           = "BEDROCK_API_SMTP_FROM"
+	signingSecretEnv      = "BEDROCK_API_SIGNING_SECRET"
+	signingWindowEnv      = "BEDROCK_API_SIGNING_WINDOW"
+	settingsFileEnv       = "BEDROCK_API_SETTINGS"
+	behaviorPacksDirEnv   = "BEDROCK_API_BEHAVIOR_PACKS_DIR"
+	resourcePacksDirEnv   = "BEDROCK_API_RESOURCE_PACKS_DIR"
+	serverPropsEnv        = "BEDROCK_API_SERVER_PROPERTIES"
+	stateDirEnv           = "BEDROCK_API_STATE_DIR"
+	listenPortEnv         = "BEDROCK_API_PORT"
+	mcwsTokenEnv          = "BEDROCK_API_MCWS_TOKEN"
+)
+
+// envOrDefault returns the trimmed value of key, or def when it is unset or empty.
+func envOrDefault(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// envEnabled reports whether the given environment variable is set to a truthy value.
+func envEnabled(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
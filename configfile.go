@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	configPollInterval = 5 * time.Second
+	configWriteDelay   = 2 * time.Second
+)
+
+// ConfigFile is the declarative automation config. Each section that is
+// present replaces the corresponding runtime state; absent sections are left
+// to the API. Values may reference environment variables as ${NAME}, which is
+// the recommended way to supply hook secrets.
+type ConfigFile struct {
+	Jobs        *[]CronJob        `json:"jobs,omitempty"`
+	Macros      *[]CustomCommand  `json:"macros,omitempty"`
+	Webhooks    *[]InboundHook    `json:"webhooks,omitempty"`
+	Mitigations *[]MitigationRule `json:"mitigations,omitempty"`
+	AlertRules  *[]AlertRule      `json:"alert_rules,omitempty"`
+	Process     *ProcessTuning    `json:"process,omitempty"`
+}
+
+// configRuntimeKeys are runtime fields never written back to the file.
+var configRuntimeKeys = map[string][]string{
+	"jobs":        {"last_run", "last_result", "next_run"},
+	"macros":      {"created_at", "executed_at"},
+	"webhooks":    {"last_triggered", "secret"},
+	"mitigations": {"last_triggered"},
+	"alert_rules": {"state", "value", "active_since", "last_fired"},
+}
+
+var (
+	configPath      string
+	configModTime   time.Time
+	configWritten   []byte
+	configMutex     sync.Mutex
+	configApplying  int32
+	configDirty     = make(chan struct{}, 1)
+	configLastError string
+)
+
+// parseConfigFile decodes YAML (or JSON) config after expanding ${ENV}
+// references, rejecting unknown keys.
+func parseConfigFile(data []byte) (ConfigFile, error) {
+	return decodeConfig([]byte(os.ExpandEnv(string(data))))
+}
+
+// decodeConfig decodes YAML (or JSON) config, rejecting unknown keys.
+func decodeConfig(data []byte) (ConfigFile, error) {
+	var cfg ConfigFile
+	doc, err := parseYAML(data)
+	if err != nil {
+		return cfg, err
+	}
+	if doc == nil {
+		return cfg, nil
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return cfg, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// validateConfig checks every entry before anything is applied.
+func validateConfig(cfg *ConfigFile) error {
+	if cfg.Jobs != nil {
+		for i := range *cfg.Jobs {
+			if err := (*cfg.Jobs)[i].validate(); err != nil {
+				return fmt.Errorf("jobs[%d]: %v", i, err)
+			}
+		}
+	}
+	if cfg.Macros != nil {
+		for i, m := range *cfg.Macros {
+			if m.Name == "" || strings.TrimSpace(m.Command) == "" {
+				return fmt.Errorf("macros[%d]: name and command are required", i)
+			}
+		}
+	}
+	if cfg.Webhooks != nil {
+		hooksMutex.Lock()
+		for i := range *cfg.Webhooks {
+			h := &(*cfg.Webhooks)[i]
+			// Keep the secret already issued for this hook if the file has none.
+			if j := findHook(h.Name); h.Secret == "" && j >= 0 {
+				h.Secret = inboundHooks[j].Secret
+			}
+		}
+		hooksMutex.Unlock()
+		for i := range *cfg.Webhooks {
+			if err := (*cfg.Webhooks)[i].validate(); err != nil {
+				return fmt.Errorf("webhooks[%d]: %v", i, err)
+			}
+		}
+	}
+	if cfg.Mitigations != nil {
+		for i := range *cfg.Mitigations {
+			rule := &(*cfg.Mitigations)[i]
+			if rule.ID == "" {
+				rule.ID = rule.Name
+			}
+			if err := rule.validate(); err != nil {
+				return fmt.Errorf("mitigations[%d]: %v", i, err)
+			}
+		}
+	}
+	if cfg.AlertRules != nil {
+		for i := range *cfg.AlertRules {
+			rule := &(*cfg.AlertRules)[i]
+			if rule.ID == "" {
+				rule.ID = rule.Name
+			}
+			if err := rule.validate(); err != nil {
+				return fmt.Errorf("alert_rules[%d]: %v", i, err)
+			}
+		}
+	}
+	if cfg.Process != nil {
+		if err := cfg.Process.validate(); err != nil {
+			return fmt.Errorf("process: %v", err)
+		}
+	}
+	return nil
+}
+
+// applyConfig replaces runtime state with the sections present in cfg.
+func applyConfig(cfg ConfigFile) {
+	atomic.StoreInt32(&configApplying, 1)
+	defer atomic.StoreInt32(&configApplying, 0)
+
+	if cfg.Jobs != nil {
+		cronJobsMutex.Lock()
+		jobs := *cfg.Jobs
+		for i := range jobs {
+			if j := findCronJob(jobs[i].Name); j >= 0 {
+				jobs[i].LastRun, jobs[i].LastResult = cronJobs[j].LastRun, cronJobs[j].LastResult
+			}
+		}
+		cronJobs = append([]CronJob{}, jobs...)
+		saveCronJobs()
+		cronJobsMutex.Unlock()
+	}
+	if cfg.Macros != nil {
+		commandsMutex.Lock()
+		macros := *cfg.Macros
+		for i := range macros {
+			for _, c := range customCommands {
+				if c.Name == macros[i].Name {
+					macros[i].CreatedAt, macros[i].ExecutedAt = c.CreatedAt, c.ExecutedAt
+				}
+			}
+			if macros[i].CreatedAt.IsZero() {
+				macros[i].CreatedAt = time.Now()
+			}
+		}
+		customCommands = append([]CustomCommand{}, macros...)
+		saveMacros()
+		commandsMutex.Unlock()
+	}
+	if cfg.Webhooks != nil {
+		hooksMutex.Lock()
+		hooks := *cfg.Webhooks
+		for i := range hooks {
+			if j := findHook(hooks[i].Name); j >= 0 {
+				hooks[i].LastTriggered = inboundHooks[j].LastTriggered
+			}
+		}
+		inboundHooks = append([]InboundHook{}, hooks...)
+		saveHooks()
+		hooksMutex.Unlock()
+	}
+	if cfg.Mitigations != nil {
+		mitigationMutex.Lock()
+		rules := *cfg.Mitigations
+		for i := range rules {
+			for _, old := range mitigationRules {
+				if old.ID == rules[i].ID {
+					rules[i].LastTriggered = old.LastTriggered
+				}
+			}
+		}
+		mitigationRules = append([]MitigationRule{}, rules...)
+		saveMitigations()
+		mitigationMutex.Unlock()
+	}
+	if cfg.AlertRules != nil {
+		alertRuleMutex.Lock()
+		rules := *cfg.AlertRules
+		for i := range rules {
+			resetAlertRule(&rules[i])
+			rules[i].LastFired = time.Time{}
+			for _, old := range alertRules {
+				if old.ID == rules[i].ID {
+					rules[i].State, rules[i].Value = old.State, old.Value
+					rules[i].ActiveSince, rules[i].LastFired = old.ActiveSince, old.LastFired
+				}
+			}
+		}
+		alertRules = append([]AlertRule{}, rules...)
+		saveAlertRules()
+		alertRuleMutex.Unlock()
+	}
+	if cfg.Process != nil {
+		processTuningMutex.Lock()
+		processTuning = *cfg.Process
+		saveProcessTuning()
+		// Re-tune the running process on the next sample.
+		processTuningStatus.PID = 0
+		processTuningMutex.Unlock()
+	}
+}
+
+// loadConfigFile reads, validates and applies the config file.
+func loadConfigFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := parseConfigFile(data)
+	if err == nil {
+		err = validateConfig(&cfg)
+	}
+	configMutex.Lock()
+	configPath = path
+	configModTime = info.ModTime()
+	if err != nil {
+		configLastError = err.Error()
+		configMutex.Unlock()
+		return err
+	}
+	configLastError = ""
+	configMutex.Unlock()
+	applyConfig(cfg)
+	log.Printf("Applied config file %s", path)
+	return nil
+}
+
+// startConfigWatcher reloads the file when it changes and, if write-back is
+// enabled, writes API-made changes back to it.
+func startConfigWatcher(path string) {
+	go func() {
+		for range time.Tick(configPollInterval) {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			configMutex.Lock()
+			changed := !info.ModTime().Equal(configModTime)
+			configMutex.Unlock()
+			if changed {
+				// A broken edit keeps the previous config running.
+				if err := loadConfigFile(path); err != nil {
+					log.Printf("Error reloading config file %s: %v", path, err)
+				}
+			}
+		}
+	}()
+	if envEnabled(configWritebackEnv) {
+		go func() {
+			for range configDirty {
+				time.Sleep(configWriteDelay)
+				if err := writeConfigFile(path); err != nil {
+					log.Printf("Error writing config file %s: %v", path, err)
+				}
+			}
+		}()
+	}
+}
+
+// configChanged notes that API-managed state changed. It never blocks and
+// is safe to call while holding other locks.
+func configChanged() {
+	if atomic.LoadInt32(&configApplying) == 1 {
+		return
+	}
+	select {
+	case configDirty <- struct{}{}:
+	default:
+	}
+}
+
+// exportConfig renders the current runtime state as a config document.
+func exportConfig() ([]byte, error) {
+	cronJobsMutex.Lock()
+	jobs := append([]CronJob{}, cronJobs...)
+	cronJobsMutex.Unlock()
+	commandsMutex.RLock()
+	macros := append([]CustomCommand{}, customCommands...)
+	commandsMutex.RUnlock()
+	hooksMutex.Lock()
+	hooks := append([]InboundHook{}, inboundHooks...)
+	hooksMutex.Unlock()
+	mitigationMutex.Lock()
+	rules := append([]MitigationRule{}, mitigationRules...)
+	mitigationMutex.Unlock()
+	alertRuleMutex.Lock()
+	alerts := append([]AlertRule{}, alertRules...)
+	alertRuleMutex.Unlock()
+	cfg := ConfigFile{Jobs: &jobs, Macros: &macros, Webhooks: &hooks, Mitigations: &rules, AlertRules: &alerts}
+	processTuningMutex.Lock()
+	if tuning := processTuning; !tuning.isZero() {
+		cfg.Process = &tuning
+	}
+	processTuningMutex.Unlock()
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	for section, keys := range configRuntimeKeys {
+		list, _ := doc[section].([]interface{})
+		for _, item := range list {
+			if m, ok := item.(map[string]interface{}); ok {
+				for _, k := range keys {
+					delete(m, k)
+				}
+			}
+		}
+	}
+	header := "# Managed by the sidecar; API changes are written back to this file.\n"
+	return append([]byte(header), encodeYAML(doc)...), nil
+}
+
+// writeConfigFile atomically rewrites the config file if its content changed.
+func writeConfigFile(path string) error {
+	data, err := exportConfig()
+	if err != nil {
+		return err
+	}
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if bytes.Equal(data, configWritten) {
+		return nil
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	configWritten = data
+	if info, err := os.Stat(path); err == nil {
+		configModTime = info.ModTime()
+	}
+	log.Printf("Wrote API changes back to %s", path)
+	return nil
+}
+
+// configFileHandler shows the config file status (GET), reloads it (POST),
+// or renders the current state as YAML (GET ?export=1).
+func configFileHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("export") != "" {
+			data, err := exportConfig()
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "application/yaml")
+			w.Write(data)
+			return
+		}
+		configMutex.Lock()
+		defer configMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"path":       configPath,
+			"modified":   configModTime,
+			"writeback":  envEnabled(configWritebackEnv),
+			"last_error": configLastError,
+		})
+	case http.MethodPost:
+		configMutex.Lock()
+		path := configPath
+		configMutex.Unlock()
+		if path == "" {
+			writeJSONError(w, http.StatusNotFound, "No config file configured")
+			return
+		}
+		if err := loadConfigFile(path); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Config file reloaded"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
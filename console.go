@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	consolePollInterval = 500 * time.Millisecond
+	consoleReplayBytes  = 64 << 10
+)
+
+// ConsoleMessage is one frame on the /console WebSocket. Log lines and
+// command echoes go to the client; clients send commands as plain text.
+type ConsoleMessage struct {
+	Type    string    `json:"type"`          // log, command, error or info
+	Seq     int64     `json:"seq,omitempty"` // numbers log lines
+	Line    string    `json:"line,omitempty"`
+	Command string    `json:"command,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+var (
+	consoleLog         = newLogRing()
+	consoleSubscribers = make(map[chan ConsoleMessage]struct{})
+	consoleMutex       sync.Mutex
+)
+
+// publishConsoleLine buffers a server log line, fans it out and checks it
+// against the mitigation rules.
+func publishConsoleLine(line string) {
+	queueMitigationLogLine(line)
+	msg := ConsoleMessage{Type: "log", Line: line, Time: time.Now()}
+	consoleMutex.Lock()
+	defer consoleMutex.Unlock()
+	msg = consoleLog.add(msg)
+	for ch := range consoleSubscribers {
+		select {
+		case ch <- msg:
+		default:
+			// Slow consoles miss lines rather than block the tail.
+		}
+	}
+}
+
+// startConsoleTail follows the server's output log, reopening it when it is
+// truncated or rotated. A supervised server's output is captured directly.
+func startConsoleTail() {
+	path := os.Getenv(serverLogEnv)
+	if path == "" || supervisor != nil {
+		return
+	}
+	log.Printf("Streaming server output from %s", path)
+	go func() {
+		var f *os.File
+		var reader *bufio.Reader
+		var offset int64
+		var partial string
+		first := true
+		for {
+			if f == nil {
+				var err error
+				if f, err = os.Open(path); err != nil {
+					time.Sleep(5 * time.Second)
+					continue
+				}
+				offset, partial = 0, ""
+				if first {
+					// Only replay the tail of a log that predates the sidecar.
+					if info, err := f.Stat(); err == nil && info.Size() > consoleReplayBytes {
+						offset, _ = f.Seek(info.Size()-consoleReplayBytes, io.SeekStart)
+					}
+					first = false
+				}
+				reader = bufio.NewReader(f)
+			}
+			line, err := reader.ReadString('\n')
+			offset += int64(len(line))
+			if err == nil {
+				publishConsoleLine(strings.TrimRight(partial+line, "\r\n"))
+				partial = ""
+				continue
+			}
+			partial += line
+			if err != io.EOF {
+				log.Printf("Error reading server log: %v", err)
+			}
+			time.Sleep(consolePollInterval)
+			// Reopen when the file was replaced or truncated.
+			current, statErr := os.Stat(path)
+			opened, _ := f.Stat()
+			if statErr != nil || opened == nil || !os.SameFile(current, opened) || current.Size() < offset {
+				f.Close()
+				f = nil
+			}
+		}
+	}()
+}
+
+// consoleHandler upgrades to a WebSocket that streams server output and
+// runs each text message received as a console command, subject to the
+// command policy. Every session is recorded (see consolesessions.go).
+// Browsers pass the admin token or an admin API key as a subprotocol.
+func consoleHandler(w http.ResponseWriter, r *http.Request) {
+	backlog := 100
+	if v := r.URL.Query().Get("backlog"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid backlog")
+			return
+		}
+		backlog = n
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		log.Printf("Console WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	recorder := startConsoleRecording(r)
+	defer recorder.finish()
+
+	ch := make(chan ConsoleMessage, 256)
+	consoleMutex.Lock()
+	history := consoleLog.tail(backlog, func(ConsoleMessage) bool { return true })
+	consoleSubscribers[ch] = struct{}{}
+	consoleMutex.Unlock()
+	defer func() {
+		consoleMutex.Lock()
+		delete(consoleSubscribers, ch)
+		consoleMutex.Unlock()
+	}()
+
+	send := func(msg ConsoleMessage) error {
+		recorder.record(msg)
+		data, _ := json.Marshal(msg)
+		return conn.WriteText(data)
+	}
+	if !serverOutputAvailable() {
+		send(ConsoleMessage{Type: "info", Message: "Server output is not available: set " + serverLogEnv, Time: time.Now()})
+	}
+	for _, msg := range history {
+		if send(msg) != nil {
+			return
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			command := strings.TrimSpace(string(data))
+			if command == "" {
+				continue
+			}
+			reply := ConsoleMessage{Type: "command", Command: command, Time: time.Now()}
+			if _, reason, _ := refuseCommand(r, command); reason != "" {
+				reply = ConsoleMessage{Type: "error", Command: command, Error: reason, Time: time.Now()}
+			} else if err := sendServerCommand(command); err != nil {
+				reply = ConsoleMessage{Type: "error", Command: command, Error: err.Error(), Time: time.Now()}
+			}
+			log.Printf("Console command from %s: %s", r.RemoteAddr, command)
+			if reply.Type == "command" {
+				publishEvent("command_executed", "Command sent: "+command, map[string]interface{}{"command": command, "source": "console"})
+			}
+			if send(reply) != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-ch:
+			if send(msg) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	consoleSessionsStateFile = "console_sessions.json"
+	consoleSessionsDir       = "console_sessions"
+	maxConsoleSessions       = 200
+	// consoleTranscriptMaxBytes caps a transcript; past it, server output is
+	// no longer recorded but commands still are.
+	consoleTranscriptMaxBytes = 16 << 20
+)
+
+// ConsoleSession describes a recorded /console WebSocket session: who
+// connected, when, and how much was typed and seen. Its transcript holds
+// every frame sent to the client, including command echoes and errors, so
+// it can be replayed in order.
+type ConsoleSession struct {
+	ID         string    `json:"id"`
+	Key        string    `json:"key,omitempty"` // API key name
+	Role       string    `json:"role,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at,omitempty"`
+	Active     bool      `json:"active"`
+	Commands   int       `json:"commands"`
+	Lines      int       `json:"lines"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+var (
+	consoleSessions      = make([]*ConsoleSession, 0)
+	consoleSessionsMutex sync.Mutex
+)
+
+// consoleRecorder appends one session's frames to its transcript.
+type consoleRecorder struct {
+	session *ConsoleSession
+	file    *os.File
+	bytes   int
+}
+
+func consoleTranscriptPath(id string) string {
+	return filepath.Join(stateDir, consoleSessionsDir, id+".jsonl")
+}
+
+// loadConsoleSessions restores the session index. Sessions cut short by a
+// restart are no longer active but keep no end time.
+func loadConsoleSessions() {
+	if err := loadState(consoleSessionsStateFile, &consoleSessions); err != nil {
+		log.Printf("Error loading console sessions: %v", err)
+	}
+	if consoleSessions == nil {
+		consoleSessions = []*ConsoleSession{}
+	}
+	for _, s := range consoleSessions {
+		s.Active = false
+	}
+}
+
+// saveConsoleSessionsLocked persists the index. Callers hold
+// consoleSessionsMutex.
+func saveConsoleSessionsLocked() {
+	if err := saveState(consoleSessionsStateFile, consoleSessions); err != nil {
+		log.Printf("Error saving console sessions: %v", err)
+	}
+}
+
+// startConsoleRecording opens a transcript for a console connection and
+// drops the oldest finished sessions beyond maxConsoleSessions. It returns
+// nil, and the session goes unrecorded, if the transcript cannot be created.
+func startConsoleRecording(r *http.Request) *consoleRecorder {
+	key, role := commandRequester(r)
+	session := &ConsoleSession{
+		ID:         newUUID(),
+		Key:        key,
+		Role:       role,
+		RemoteAddr: r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+		StartedAt:  time.Now(),
+		Active:     true,
+	}
+	path := consoleTranscriptPath(session.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("Error recording console session: %v", err)
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("Error recording console session: %v", err)
+		return nil
+	}
+
+	consoleSessionsMutex.Lock()
+	defer consoleSessionsMutex.Unlock()
+	consoleSessions = append(consoleSessions, session)
+	for excess := len(consoleSessions) - maxConsoleSessions; excess > 0; excess-- {
+		oldest := -1
+		for i, s := range consoleSessions {
+			if !s.Active {
+				oldest = i
+				break
+			}
+		}
+		if oldest < 0 {
+			break
+		}
+		os.Remove(consoleTranscriptPath(consoleSessions[oldest].ID))
+		consoleSessions = append(consoleSessions[:oldest], consoleSessions[oldest+1:]...)
+	}
+	saveConsoleSessionsLocked()
+	log.Printf("Recording console session %s from %s", session.ID, r.RemoteAddr)
+	return &consoleRecorder{session: session, file: f}
+}
+
+// record appends a frame sent to the client. It is safe on a nil recorder.
+func (c *consoleRecorder) record(msg ConsoleMessage) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	consoleSessionsMutex.Lock()
+	defer consoleSessionsMutex.Unlock()
+	if msg.Type == "log" && c.bytes+len(data) > consoleTranscriptMaxBytes {
+		c.session.Truncated = true
+		return
+	}
+	if _, err := c.file.Write(append(data, '\n')); err != nil {
+		return
+	}
+	c.bytes += len(data) + 1
+	switch msg.Type {
+	case "log":
+		c.session.Lines++
+	case "command", "error":
+		c.session.Commands++
+	}
+}
+
+// finish closes the transcript and marks the session ended.
+func (c *consoleRecorder) finish() {
+	if c == nil {
+		return
+	}
+	consoleSessionsMutex.Lock()
+	defer consoleSessionsMutex.Unlock()
+	c.file.Close()
+	c.session.Active = false
+	c.session.EndedAt = time.Now()
+	saveConsoleSessionsLocked()
+}
+
+// readConsoleTranscript returns a session's recorded frames in order.
+func readConsoleTranscript(id string) ([]ConsoleMessage, error) {
+	f, err := os.Open(consoleTranscriptPath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	transcript := []ConsoleMessage{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), consoleTranscriptMaxBytes)
+	for scanner.Scan() {
+		var msg ConsoleMessage
+		if json.Unmarshal(scanner.Bytes(), &msg) == nil {
+			transcript = append(transcript, msg)
+		}
+	}
+	return transcript, scanner.Err()
+}
+
+// consoleSessionsHandler serves GET /console/sessions, the recorded console
+// sessions newest first, optionally only those made with ?key=.
+func consoleSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	key := r.URL.Query().Get("key")
+	consoleSessionsMutex.Lock()
+	sessions := []ConsoleSession{}
+	for _, s := range consoleSessions {
+		if key == "" || s.Key == key {
+			sessions = append(sessions, *s)
+		}
+	}
+	consoleSessionsMutex.Unlock()
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].StartedAt.After(sessions[j].StartedAt) })
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"sessions": sessions})
+}
+
+// consoleSessionHandler serves GET /console/sessions/{id}: the session and
+// its transcript, or with ?format=text the transcript as timestamped text,
+// commands prefixed "> " and errors "! ". Sessions cannot be deleted
+// through the API, so the record holds admins to account.
+func consoleSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/console/sessions/")
+	var session ConsoleSession
+	found := false
+	consoleSessionsMutex.Lock()
+	for _, s := range consoleSessions {
+		if s.ID == id {
+			session, found = *s, true
+			break
+		}
+	}
+	consoleSessionsMutex.Unlock()
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	transcript, err := readConsoleTranscript(id)
+	if err != nil {
+		log.Printf("Error reading console session %s: %v", id, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read transcript")
+		return
+	}
+	if r.URL.Query().Get("format") != "text" {
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"session": session, "transcript": transcript})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var b strings.Builder
+	who := session.Key
+	if who == "" {
+		who = session.Role
+	}
+	if who == "" {
+		who = "anonymous"
+	}
+	fmt.Fprintf(&b, "# Console session %s by %s from %s, started %s\n", session.ID, who, session.RemoteAddr, session.StartedAt.Format(time.RFC3339))
+	for _, msg := range transcript {
+		ts := msg.Time.Format("15:04:05")
+		switch msg.Type {
+		case "command":
+			fmt.Fprintf(&b, "[%s] > %s\n", ts, msg.Command)
+		case "error":
+			fmt.Fprintf(&b, "[%s] ! %s: %s\n", ts, msg.Command, msg.Error)
+		case "info":
+			fmt.Fprintf(&b, "[%s] # %s\n", ts, msg.Message)
+		default:
+			fmt.Fprintf(&b, "[%s] %s\n", ts, msg.Line)
+		}
+	}
+	if !session.EndedAt.IsZero() {
+		fmt.Fprintf(&b, "# Ended %s\n", session.EndedAt.Format(time.RFC3339))
+	}
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	contentLogDir = filepath.Join(dataDir, "logs")
+	// contentLogLinePattern matches "12:00:00[Json][error]-message".
+	contentLogLinePattern = regexp.MustCompile(`^\s*(\d{1,2}:\d{2}:\d{2})?\s*\[([^\]]+)\]\[(\w+)\]-\s*(.*)$`)
+	// contentLogPackPattern finds a pack folder in a path mentioned by a warning.
+	contentLogPackPattern = regexp.MustCompile(`(?:development_)?(?:behavior|resource)_packs[/\\]([^/\\|]+)`)
+)
+
+// ContentWarning is one distinct content log entry for a pack.
+type ContentWarning struct {
+	Area     string `json:"area"`
+	Level    string `json:"level"`
+	Message  string `json:"message"`
+	Count    int    `json:"count"`
+	LastTime string `json:"last_time,omitempty"`
+}
+
+// contentLogPack identifies an installed pack in content log messages.
+type contentLogPack struct {
+	UUID   string
+	Folder string
+	Name   string
+}
+
+// enableContentLog turns on Bedrock's content log file when
+// BEDROCK_API_CONTENT_LOG is set, so pack warnings can be surfaced.
+func enableContentLog() {
+	if !envEnabled(contentLogEnv) {
+		return
+	}
+	if v, _ := getServerProperty("content-log-file-enabled", "false"); v == "true" {
+		return
+	}
+	if err := setServerProperty("content-log-file-enabled", "true"); err != nil {
+		log.Printf("Error enabling the content log: %v", err)
+		return
+	}
+	log.Printf("Enabled content-log-file-enabled; it applies from the next server start")
+}
+
+// latestContentLog returns the newest ContentLog file, which covers the
+// current server session.
+func latestContentLog() (string, error) {
+	matches, err := filepath.Glob(filepath.Join(contentLogDir, "ContentLog*.txt"))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+	var latest string
+	var latestMod int64
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.ModTime().UnixNano() > latestMod {
+			latest, latestMod = m, info.ModTime().UnixNano()
+		}
+	}
+	return latest, nil
+}
+
+// installedContentLogPacks lists installed packs with their folder and
+// manifest name, which is how content log messages refer to them.
+func installedContentLogPacks() []contentLogPack {
+	var packs []contentLogPack
+	for _, root := range []string{behaviorPacksDir, resourcePacksDir} {
+		entries, _ := os.ReadDir(root)
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(root, e.Name(), "manifest.json"))
+			if err != nil {
+				continue
+			}
+			var m struct {
+				Header struct {
+					UUID string `json:"uuid"`
+					Name string `json:"name"`
+				} `json:"header"`
+			}
+			if json.Unmarshal(data, &m) != nil || m.Header.UUID == "" {
+				continue
+			}
+			packs = append(packs, contentLogPack{UUID: m.Header.UUID, Folder: e.Name(), Name: m.Header.Name})
+		}
+	}
+	return packs
+}
+
+// contentWarningPack attributes a content log message to a pack by the
+// pack folder in a path, or else by a "|"-separated field naming the pack.
+func contentWarningPack(message string, packs []contentLogPack) string {
+	if m := contentLogPackPattern.FindStringSubmatch(message); m != nil {
+		for _, p := range packs {
+			if p.Folder == strings.TrimSpace(m[1]) {
+				return p.UUID
+			}
+		}
+	}
+	for _, field := range strings.Split(message, "|") {
+		field = strings.TrimSpace(field)
+		for _, p := range packs {
+			if field != "" && (field == p.Name || field == p.Folder) {
+				return p.UUID
+			}
+		}
+	}
+	return ""
+}
+
+// parseContentLog reads a content log and returns the distinct warnings
+// for each pack UUID, most frequent first.
+func parseContentLog(path string) (map[string][]ContentWarning, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	packs := installedContentLogPacks()
+	byPack := make(map[string][]ContentWarning)
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		m := contentLogLinePattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		uuid := contentWarningPack(m[4], packs)
+		if uuid == "" {
+			continue
+		}
+		key := uuid + "\x00" + m[2] + "\x00" + m[3] + "\x00" + m[4]
+		if i, ok := index[key]; ok {
+			byPack[uuid][i].Count++
+			byPack[uuid][i].LastTime = m[1]
+			continue
+		}
+		index[key] = len(byPack[uuid])
+		byPack[uuid] = append(byPack[uuid], ContentWarning{Area: m[2], Level: strings.ToLower(m[3]), Message: m[4], Count: 1, LastTime: m[1]})
+	}
+	for _, warnings := range byPack {
+		sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Count > warnings[j].Count })
+	}
+	return byPack, scanner.Err()
+}
+
+// contentWarningsHandler serves /addons/{uuid}/content-warnings with the
+// warnings the current content log holds for that pack.
+func contentWarningsHandler(w http.ResponseWriter, r *http.Request) {
+	uuid, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/addons/"), "/")
+	if rest != "content-warnings" {
+		writeJSONError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if !packUUIDPattern.MatchString(uuid) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid pack UUID")
+		return
+	}
+	resp := map[string]interface{}{"uuid": uuid, "warnings": []ContentWarning{}}
+	if v, _ := getServerProperty("content-log-file-enabled", "false"); v != "true" {
+		resp["message"] = "content-log-file-enabled is off: set " + contentLogEnv + " or enable it in server.properties"
+	}
+	path, err := latestContentLog()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if path != "" {
+		byPack, err := parseContentLog(path)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if warnings := byPack[uuid]; warnings != nil {
+			resp["warnings"] = warnings
+		}
+		resp["file"] = filepath.Base(path)
+	}
+	writeJSONResponse(w, http.StatusOK, resp)
+}
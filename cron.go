@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cronJobsStateFile = "cron_jobs.json"
+
+// cronSchedule is a parsed five-field cron expression (minute hour
+// day-of-month month day-of-week), each field a bitset of allowed values.
+type cronSchedule struct {
+	fields           [5]uint64
+	domStar, dowStar bool
+}
+
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a cron expression supporting *, lists, ranges and steps.
+func parseCron(expr string) (cronSchedule, error) {
+	var s cronSchedule
+	if m, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		expr = m
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return s, errors.New("cron expression needs five fields")
+	}
+	for i, part := range parts {
+		lo, hi := cronBounds[i][0], cronBounds[i][1]
+		for _, item := range strings.Split(part, ",") {
+			rng, stepStr, hasStep := strings.Cut(item, "/")
+			step := 1
+			if hasStep {
+				n, err := strconv.Atoi(stepStr)
+				if err != nil || n <= 0 {
+					return s, fmt.Errorf("invalid step in %q", item)
+				}
+				step = n
+			}
+			start, end := lo, hi
+			if rng != "*" {
+				a, b, isRange := strings.Cut(rng, "-")
+				var err error
+				if start, err = strconv.Atoi(a); err != nil {
+					return s, fmt.Errorf("invalid value in %q", item)
+				}
+				end = start
+				if isRange {
+					if end, err = strconv.Atoi(b); err != nil {
+						return s, fmt.Errorf("invalid range in %q", item)
+					}
+				} else if hasStep {
+					end = hi
+				}
+			}
+			if start < lo || end > hi || start > end {
+				return s, fmt.Errorf("%q is out of range", item)
+			}
+			for v := start; v <= end; v += step {
+				s.fields[i] |= 1 << uint(v)
+			}
+		}
+	}
+	// Sunday may be written as 0 or 7.
+	if s.fields[4]&(1<<7) != 0 {
+		s.fields[4] |= 1
+	}
+	s.domStar = parts[2] == "*"
+	s.dowStar = parts[4] == "*"
+	return s, nil
+}
+
+// matches reports whether the schedule fires in the minute containing t.
+// As in classic cron, a restricted day-of-month and day-of-week are ORed.
+func (s cronSchedule) matches(t time.Time) bool {
+	has := func(i, v int) bool { return s.fields[i]&(1<<uint(v)) != 0 }
+	if !has(0, t.Minute()) || !has(1, t.Hour()) || !has(3, int(t.Month())) {
+		return false
+	}
+	dom, dow := has(2, t.Day()), has(4, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first matching minute after t, searching up to a year.
+func (s cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(1, 0, 0); t.Before(limit); t = t.Add(time.Minute) {
+		if s.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// CronJob runs actions on a cron schedule. Actions use the same types as
+// inbound hooks.
+type CronJob struct {
+	Name       string       `json:"name"`
+	Schedule   string       `json:"schedule"`
+	Enabled    bool         `json:"enabled"`
+	Actions    []HookAction `json:"actions"`
+	LastRun    time.Time    `json:"last_run,omitempty"`
+	LastResult []string     `json:"last_result,omitempty"`
+	NextRun    time.Time    `json:"next_run,omitempty"`
+}
+
+var (
+	cronJobs      = make([]CronJob, 0)
+	cronJobsMutex sync.Mutex
+)
+
+func (j *CronJob) validate() error {
+	if !validName(j.Name) || strings.Contains(j.Name, " ") {
+		return errors.New("invalid name")
+	}
+	sched, err := parseCron(j.Schedule)
+	if err != nil {
+		return err
+	}
+	if err := validateHookActions(j.Actions); err != nil {
+		return err
+	}
+	j.NextRun = sched.next(time.Now())
+	return nil
+}
+
+func saveCronJobs() {
+	if err := saveState(cronJobsStateFile, cronJobs); err != nil {
+		log.Printf("Error saving cron jobs: %v", err)
+	}
+	configChanged()
+}
+
+// findCronJob returns the index of the named job, or -1. Callers must hold cronJobsMutex.
+func findCronJob(name string) int {
+	for i, j := range cronJobs {
+		if j.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// runCronJob executes a job and records the outcome.
+func runCronJob(name string) ([]string, error) {
+	cronJobsMutex.Lock()
+	i := findCronJob(name)
+	if i < 0 {
+		cronJobsMutex.Unlock()
+		return nil, errors.New("job not found")
+	}
+	job := cronJobs[i]
+	cronJobsMutex.Unlock()
+
+	results := runHookActions(InboundHook{Name: job.Name, Actions: job.Actions}, nil)
+	log.Printf("Cron job %s ran: %v", job.Name, results)
+
+	cronJobsMutex.Lock()
+	if i := findCronJob(name); i >= 0 {
+		cronJobs[i].LastRun = time.Now()
+		cronJobs[i].LastResult = results
+		if sched, err := parseCron(cronJobs[i].Schedule); err == nil {
+			cronJobs[i].NextRun = sched.next(time.Now())
+		}
+		if err := saveState(cronJobsStateFile, cronJobs); err != nil {
+			log.Printf("Error saving cron jobs: %v", err)
+		}
+	}
+	cronJobsMutex.Unlock()
+	return results, nil
+}
+
+// startCronScheduler checks the jobs at the start of every minute.
+func startCronScheduler() {
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			tick := time.Now()
+			cronJobsMutex.Lock()
+			var due []string
+			for _, j := range cronJobs {
+				sched, err := parseCron(j.Schedule)
+				if err == nil && j.Enabled && sched.matches(tick) {
+					due = append(due, j.Name)
+				}
+			}
+			cronJobsMutex.Unlock()
+			for _, name := range due {
+				go runCronJob(name)
+			}
+			checkBackupSchedule(tick)
+		}
+	}()
+}
+
+// cronJobsHandler lists jobs (GET) or creates one (POST).
+func cronJobsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cronJobsMutex.Lock()
+		defer cronJobsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"jobs": cronJobs})
+	case http.MethodPost:
+		var j CronJob
+		if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := j.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cronJobsMutex.Lock()
+		defer cronJobsMutex.Unlock()
+		if findCronJob(j.Name) >= 0 {
+			writeJSONError(w, http.StatusConflict, "Job already exists")
+			return
+		}
+		cronJobs = append(cronJobs, j)
+		saveCronJobs()
+		writeJSONResponse(w, http.StatusCreated, j)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// cronJobHandler serves GET, PUT (upsert) and DELETE /jobs/{name} and
+// POST /jobs/{name}/run.
+func cronJobHandler(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	if action == "run" {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		results, err := runCronJob(name)
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "Job not found")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"job": name, "results": results})
+		return
+	}
+
+	cronJobsMutex.Lock()
+	defer cronJobsMutex.Unlock()
+	i := findCronJob(name)
+	switch r.Method {
+	case http.MethodGet:
+		if i < 0 {
+			writeJSONError(w, http.StatusNotFound, "Job not found")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, cronJobs[i])
+	case http.MethodPut:
+		var j CronJob
+		if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		j.Name = name
+		if err := j.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		status := http.StatusOK
+		if i < 0 {
+			cronJobs = append(cronJobs, j)
+			status = http.StatusCreated
+		} else {
+			j.LastRun, j.LastResult = cronJobs[i].LastRun, cronJobs[i].LastResult
+			cronJobs[i] = j
+		}
+		saveCronJobs()
+		writeJSONResponse(w, status, j)
+	case http.MethodDelete:
+		if i < 0 {
+			writeJSONError(w, http.StatusNotFound, "Job not found")
+			return
+		}
+		cronJobs = append(cronJobs[:i], cronJobs[i+1:]...)
+		saveCronJobs()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Job deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
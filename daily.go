@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dailyRewardsStateFile = "daily_rewards.json"
+
+// DailyReward is granted on the first join of a day. Day 0 applies every
+// day; Day N applies only when the player's streak is exactly N, unless
+// Repeat is set, in which case it applies every N days of the streak.
+type DailyReward struct {
+	Day      int          `json:"day"`
+	Repeat   bool         `json:"repeat,omitempty"`
+	Points   int64        `json:"points,omitempty"`
+	Items    []RewardItem `json:"items,omitempty"`
+	Commands []string     `json:"commands,omitempty"`
+}
+
+// RewardItem is an item handed out with give.
+type RewardItem struct {
+	Item  string `json:"item"`
+	Count int    `json:"count"`
+}
+
+// DailyRewardsConfig holds the configured rewards.
+type DailyRewardsConfig struct {
+	Enabled bool          `json:"enabled"`
+	Rewards []DailyReward `json:"rewards"`
+}
+
+var (
+	dailyRewards      = DailyRewardsConfig{Rewards: []DailyReward{}}
+	dailyRewardsMutex sync.RWMutex
+)
+
+func (r DailyReward) appliesTo(streak int) bool {
+	switch {
+	case r.Day <= 0:
+		return true
+	case r.Repeat:
+		return streak%r.Day == 0
+	default:
+		return streak == r.Day
+	}
+}
+
+// grantReward gives points and items and runs commands with {player} and
+// any vars substituted.
+func grantReward(player, reason string, pts int64, items []RewardItem, commands []string, vars map[string]string) {
+	if pts != 0 {
+		if _, err := adjustPoints(player, pts, reason); err != nil {
+			log.Printf("Failed to grant %d points to %s: %v", pts, player, err)
+		}
+	}
+	for _, it := range items {
+		count := it.Count
+		if count <= 0 {
+			count = 1
+		}
+		if err := sendServerCommand(fmt.Sprintf("give %s %s %d", quotePlayer(player), it.Item, count)); err != nil {
+			log.Printf("Failed to give %s to %s: %v", it.Item, player, err)
+		}
+	}
+	for _, c := range commands {
+		c = strings.ReplaceAll(c, "{player}", quotePlayer(player))
+		for k, v := range vars {
+			c = strings.ReplaceAll(c, "{"+k+"}", v)
+		}
+		if err := sendServerCommand(c); err != nil {
+			log.Printf("Failed to run reward command for %s: %v", player, err)
+		}
+	}
+}
+
+// grantDailyReward updates the streak and grants rewards on the player's
+// first join of the day.
+func grantDailyReward(player string) {
+	dailyRewardsMutex.RLock()
+	cfg := dailyRewards
+	dailyRewardsMutex.RUnlock()
+	if !cfg.Enabled {
+		return
+	}
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+
+	playerStatsMutex.Lock()
+	st := statsFor(player)
+	if st.LastDailyReward == today {
+		playerStatsMutex.Unlock()
+		return
+	}
+	if st.LastDailyReward == yesterday {
+		st.DailyStreak++
+	} else {
+		st.DailyStreak = 1
+	}
+	if st.DailyStreak > st.LongestStreak {
+		st.LongestStreak = st.DailyStreak
+	}
+	st.LastDailyReward = today
+	streak := st.DailyStreak
+	savePlayerStats()
+	playerStatsMutex.Unlock()
+
+	vars := map[string]string{"streak": strconv.Itoa(streak)}
+	for _, r := range cfg.Rewards {
+		if r.appliesTo(streak) {
+			grantReward(player, fmt.Sprintf("daily reward (day %d)", streak), r.Points, r.Items, r.Commands, vars)
+		}
+	}
+	tellPlayer(player, fmt.Sprintf("Daily reward claimed - %d day streak", streak))
+}
+
+// dailyRewardsHandler returns (GET) or replaces (PUT) the reward config.
+func dailyRewardsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		dailyRewardsMutex.RLock()
+		defer dailyRewardsMutex.RUnlock()
+		writeJSONResponse(w, http.StatusOK, dailyRewards)
+	case http.MethodPut:
+		var cfg DailyRewardsConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		for _, rw := range cfg.Rewards {
+			for _, it := range rw.Items {
+				if !itemIDPattern.MatchString(it.Item) {
+					writeJSONError(w, http.StatusBadRequest, "Invalid item "+it.Item)
+					return
+				}
+			}
+		}
+		if cfg.Rewards == nil {
+			cfg.Rewards = []DailyReward{}
+		}
+		dailyRewardsMutex.Lock()
+		dailyRewards = cfg
+		err := saveState(dailyRewardsStateFile, dailyRewards)
+		dailyRewardsMutex.Unlock()
+		if err != nil {
+			log.Printf("Error saving daily rewards: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save daily rewards")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, cfg)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
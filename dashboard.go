@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// dashboardUnit picks a Grafana unit from a metric's name, for the value
+// the panel plots: counters are plotted as rates.
+func dashboardUnit(m metricSample) string {
+	switch {
+	case strings.HasSuffix(m.Name, "_bytes_total"):
+		return "Bps"
+	case strings.HasSuffix(m.Name, "_bytes"):
+		return "bytes"
+	case strings.HasSuffix(m.Name, "_percent"):
+		return "percent"
+	}
+	return "short"
+}
+
+// grafanaDashboard builds a dashboard with a panel for each metric. Yes/no
+// gauges are stat panels; counters are graphed as per-second rates. The
+// datasource, job and instance are dashboard variables, so one dashboard
+// covers several servers scraped into the same Prometheus.
+func grafanaDashboard(title string, samples []metricSample) map[string]interface{} {
+	datasource := map[string]string{"type": "prometheus", "uid": "${datasource}"}
+	selector := `{job=~"$job",instance=~"$instance"}`
+	// Stat panels go first, in a strip along the top.
+	sort.SliceStable(samples, func(i, j int) bool {
+		return strings.HasPrefix(samples[i].Help, "Whether") && !strings.HasPrefix(samples[j].Help, "Whether")
+	})
+	var panels []map[string]interface{}
+	x, y, rowHeight := 0, 0, 0
+	place := func(w, h int) map[string]int {
+		if x+w > 24 || (x > 0 && h != rowHeight) {
+			x, y = 0, y+rowHeight
+		}
+		rowHeight = h
+		pos := map[string]int{"x": x, "y": y, "w": w, "h": h}
+		x += w
+		return pos
+	}
+	for i, m := range samples {
+		expr := m.Name + selector
+		if m.Type == "counter" {
+			expr = "rate(" + expr + "[$__rate_interval])"
+		}
+		defaults := map[string]interface{}{"unit": dashboardUnit(m)}
+		panel := map[string]interface{}{
+			"id":          i + 1,
+			"title":       strings.TrimSuffix(m.Help, "."),
+			"description": m.Name,
+			"datasource":  datasource,
+			"targets": []map[string]interface{}{
+				{"refId": "A", "datasource": datasource, "expr": expr, "legendFormat": "{{instance}}"},
+			},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  defaults,
+				"overrides": []interface{}{},
+			},
+		}
+		if strings.HasPrefix(m.Help, "Whether") {
+			panel["type"] = "stat"
+			panel["gridPos"] = place(6, 4)
+			defaults["mappings"] = []map[string]interface{}{
+				{"type": "value", "options": map[string]interface{}{
+					"0": map[string]string{"text": "No", "color": "red"},
+					"1": map[string]string{"text": "Yes", "color": "green"},
+				}},
+			}
+			panel["options"] = map[string]interface{}{"colorMode": "background", "graphMode": "none"}
+		} else {
+			panel["type"] = "timeseries"
+			panel["gridPos"] = place(12, 8)
+		}
+		panels = append(panels, panel)
+	}
+	labelVariable := func(name, query string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":       name,
+			"type":       "query",
+			"datasource": datasource,
+			"query":      query,
+			"refresh":    2,
+			"includeAll": true,
+			"multi":      true,
+			"current":    map[string]interface{}{"text": "All", "value": "$__all"},
+		}
+	}
+	return map[string]interface{}{
+		"title":         title,
+		"uid":           "bedrock-sidecar",
+		"tags":          []string{"minecraft", "bedrock"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"panels":        panels,
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus"},
+				labelVariable("job", "label_values(bedrock_server_up, job)"),
+				labelVariable("instance", `label_values(bedrock_server_up{job=~"$job"}, instance)`),
+			},
+		},
+	}
+}
+
+// metricsDashboardHandler serves GET /metrics/dashboard, a Grafana
+// dashboard for the metrics at /autoscale/metrics and
+// /server/resources/metrics, ready to import. It covers the metrics the
+// sidecar exposes now: cgroup and process metrics that cannot be sampled
+// yet are left out until they can. ?title= names the dashboard.
+func metricsDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	title := r.URL.Query().Get("title")
+	if title == "" {
+		title = "Bedrock server"
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="bedrock-dashboard.json"`)
+	writeJSONResponse(w, http.StatusOK, grafanaDashboard(title, append(autoscaleMetrics(), resourceMetrics()...)))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RuntimeStats is a snapshot of the sidecar's own Go runtime state.
+type RuntimeStats struct {
+	Goroutines    int       `json:"goroutines"`
+	HeapAlloc     uint64    `json:"heap_alloc_bytes"`
+	HeapSys       uint64    `json:"heap_sys_bytes"`
+	HeapObjects   uint64    `json:"heap_objects"`
+	TotalAlloc    uint64    `json:"total_alloc_bytes"`
+	Sys           uint64    `json:"sys_bytes"`
+	NumGC         uint32    `json:"num_gc"`
+	PauseTotalNs  uint64    `json:"gc_pause_total_ns"`
+	LastGC        time.Time `json:"last_gc,omitempty"`
+	NextGC        uint64    `json:"next_gc_bytes"`
+	OpenFDs       int       `json:"open_fds"`
+	GoVersion     string    `json:"go_version"`
+	NumCPU        int       `json:"num_cpu"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+}
+
+var startTime = time.Now()
+
+// requireAdmin wraps a handler so it is only reachable with the admin token,
+// supplied either as "Authorization: Bearer <token>" or "X-Admin-Token", or
+// with an API key holding the admin role.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := lookupAPIKey(r); ok && key.Role == roleAdmin {
+			next(w, r)
+			return
+		}
+		if os.Getenv(adminTokenEnv) == "" {
+			writeJSONError(w, http.StatusForbidden, "Admin token not configured")
+			return
+		}
+		if !isAdminRequest(r) {
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isAdminRequest reports whether the request carries the admin token, in a
+// header or, for browser WebSockets, a subprotocol (see webSocketToken).
+func isAdminRequest(r *http.Request) bool {
+	token := os.Getenv(adminTokenEnv)
+	if token == "" {
+		return false
+	}
+	supplied := r.Header.Get("X-Admin-Token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		supplied = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if supplied == "" {
+		supplied = webSocketToken(r)
+	}
+	return subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) == 1
+}
+
+// countOpenFDs returns the number of open file descriptors, or -1 if unknown.
+func countOpenFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// collectRuntimeStats gathers goroutine, heap and GC statistics.
+func collectRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := RuntimeStats{
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     m.HeapAlloc,
+		HeapSys:       m.HeapSys,
+		HeapObjects:   m.HeapObjects,
+		TotalAlloc:    m.TotalAlloc,
+		Sys:           m.Sys,
+		NumGC:         m.NumGC,
+		PauseTotalNs:  m.PauseTotalNs,
+		NextGC:        m.NextGC,
+		OpenFDs:       countOpenFDs(),
+		GoVersion:     runtime.Version(),
+		NumCPU:        runtime.NumCPU(),
+		UptimeSeconds: time.Since(startTime).Seconds(),
+	}
+	if m.LastGC != 0 {
+		stats.LastGC = time.Unix(0, int64(m.LastGC))
+	}
+	return stats
+}
+
+// debugRuntimeHandler returns a snapshot of the sidecar's runtime statistics.
+func debugRuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, collectRuntimeStats())
+}
+
+// registerDebugHandlers mounts pprof and /debug/runtime on mux when the
+// feature flag is enabled. All routes require the admin token.
+func registerDebugHandlers(mux *http.ServeMux) {
+	if !envEnabled(enablePprofEnv) {
+		return
+	}
+	mux.HandleFunc("/debug/pprof/", requireAdmin(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", requireAdmin(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", requireAdmin(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", requireAdmin(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", requireAdmin(pprof.Trace))
+	mux.HandleFunc("/debug/runtime", requireAdmin(debugRuntimeHandler))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	debugLoggingStateFile   = "debug_logging.json"
+	defaultDebugLogDuration = 30 * time.Minute
+	maxDebugLogDuration     = 24 * time.Hour
+)
+
+// debugServerProperties are the server.properties switched on while debug
+// logging is active. They apply from the next server start.
+var debugServerProperties = map[string]string{
+	"content-log-file-enabled":           "true",
+	"content-log-console-output-enabled": "true",
+	"emit-server-telemetry":              "true",
+}
+
+// DebugLoggingRequest turns debug logging on for a limited time.
+type DebugLoggingRequest struct {
+	Duration   string `json:"duration,omitempty"` // e.g. "30m", at most 24h
+	Properties *bool  `json:"properties,omitempty"`
+	Sidecar    *bool  `json:"sidecar,omitempty"`
+	Restart    bool   `json:"restart,omitempty"`
+}
+
+// DebugLoggingState is the active debug logging session, persisted so the
+// revert still happens after a sidecar restart.
+type DebugLoggingState struct {
+	Enabled    bool              `json:"enabled"`
+	Until      time.Time         `json:"until,omitempty"`
+	Sidecar    bool              `json:"sidecar"`
+	Properties map[string]string `json:"properties,omitempty"`
+	// Previous holds the property values to restore; an empty value means
+	// the key was absent and is restored as its server default.
+	Previous map[string]string `json:"previous,omitempty"`
+}
+
+var (
+	debugLogging      DebugLoggingState
+	debugLoggingTimer *time.Timer
+	debugLoggingMutex sync.Mutex
+	// verboseLogging enables debugf output.
+	verboseLogging int32
+)
+
+// debugf logs only while sidecar debug logging is on.
+func debugf(format string, args ...interface{}) {
+	if atomic.LoadInt32(&verboseLogging) == 1 {
+		log.Printf("[debug] "+format, args...)
+	}
+}
+
+// logRequests logs every API request while sidecar debug logging is on.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&verboseLogging) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		debugf("%s %s from %s in %s", r.Method, r.URL.Path, r.RemoteAddr, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+// enableDebugLogging applies a request. Extending an active session keeps
+// the originally saved property values. The caller holds debugLoggingMutex.
+func enableDebugLogging(req DebugLoggingRequest) error {
+	duration := defaultDebugLogDuration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || d > maxDebugLogDuration {
+			return fmt.Errorf("duration must be between 0 and %s", maxDebugLogDuration)
+		}
+		duration = d
+	}
+	withProps := req.Properties == nil || *req.Properties
+	withSidecar := req.Sidecar == nil || *req.Sidecar
+	if !withProps && !withSidecar {
+		return errors.New("nothing to enable")
+	}
+
+	state := debugLogging
+	if !state.Enabled {
+		state = DebugLoggingState{Previous: map[string]string{}}
+	}
+	if withProps && state.Properties == nil {
+		current, err := readServerProperties()
+		if err != nil {
+			return err
+		}
+		for k := range debugServerProperties {
+			state.Previous[k] = current[k]
+		}
+		if err := setServerProperties(debugServerProperties); err != nil {
+			return err
+		}
+		state.Properties = debugServerProperties
+	}
+	if withSidecar {
+		state.Sidecar = true
+		atomic.StoreInt32(&verboseLogging, 1)
+	}
+	state.Enabled = true
+	state.Until = time.Now().Add(duration)
+	debugLogging = state
+	saveDebugLogging()
+	scheduleDebugLoggingRevert()
+	log.Printf("Debug logging enabled until %s", state.Until.Format(time.RFC3339))
+	return nil
+}
+
+// revertDebugLogging restores the saved properties and quiets the sidecar.
+// The caller holds debugLoggingMutex.
+func revertDebugLogging() error {
+	if debugLoggingTimer != nil {
+		debugLoggingTimer.Stop()
+		debugLoggingTimer = nil
+	}
+	atomic.StoreInt32(&verboseLogging, 0)
+	if !debugLogging.Enabled {
+		return nil
+	}
+	if debugLogging.Properties != nil {
+		restore := make(map[string]string, len(debugLogging.Previous))
+		for k, v := range debugLogging.Previous {
+			if v == "" {
+				v = "false"
+			}
+			restore[k] = v
+		}
+		if err := setServerProperties(restore); err != nil {
+			return err
+		}
+	}
+	debugLogging = DebugLoggingState{}
+	saveDebugLogging()
+	log.Printf("Debug logging reverted")
+	return nil
+}
+
+// scheduleDebugLoggingRevert arms the revert timer for the active session.
+// The caller holds debugLoggingMutex.
+func scheduleDebugLoggingRevert() {
+	if debugLoggingTimer != nil {
+		debugLoggingTimer.Stop()
+	}
+	debugLoggingTimer = time.AfterFunc(time.Until(debugLogging.Until), func() {
+		debugLoggingMutex.Lock()
+		defer debugLoggingMutex.Unlock()
+		if err := revertDebugLogging(); err != nil {
+			log.Printf("Error reverting debug logging, retrying in a minute: %v", err)
+			debugLoggingTimer = time.AfterFunc(time.Minute, func() {
+				debugLoggingMutex.Lock()
+				defer debugLoggingMutex.Unlock()
+				if err := revertDebugLogging(); err != nil {
+					log.Printf("Error reverting debug logging: %v", err)
+				}
+			})
+		}
+	})
+}
+
+func saveDebugLogging() {
+	if err := saveState(debugLoggingStateFile, debugLogging); err != nil {
+		log.Printf("Error saving debug logging state: %v", err)
+	}
+}
+
+// loadDebugLogging resumes a session left by a previous run, reverting it
+// straight away if it has already expired.
+func loadDebugLogging() {
+	debugLoggingMutex.Lock()
+	defer debugLoggingMutex.Unlock()
+	if err := loadState(debugLoggingStateFile, &debugLogging); err != nil {
+		log.Printf("Error loading debug logging state: %v", err)
+	}
+	if !debugLogging.Enabled {
+		return
+	}
+	if debugLogging.Sidecar {
+		atomic.StoreInt32(&verboseLogging, 1)
+	}
+	scheduleDebugLoggingRevert()
+}
+
+// debugLoggingHandler shows (GET), enables or extends (PUT) and reverts
+// (DELETE) debug logging. With "restart" the server is restarted so the
+// property changes take effect.
+func debugLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	debugLoggingMutex.Lock()
+	defer debugLoggingMutex.Unlock()
+	restart := false
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req DebugLoggingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := enableDebugLogging(req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		restart = req.Restart
+	case http.MethodDelete:
+		restart = debugLogging.Properties != nil && r.URL.Query().Get("restart") == "true"
+		if err := revertDebugLogging(); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if restart {
+		go func() {
+			if err := restartServer(); err != nil {
+				log.Printf("Error restarting server for debug logging: %v", err)
+			}
+		}()
+	}
+	writeJSONResponse(w, http.StatusOK, debugLogging)
+}
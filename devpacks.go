@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	devPackPollInterval = time.Second
+	devPackEventBacklog = 200
+)
+
+// DevPackEvent is one entry on the pack development event stream.
+type DevPackEvent struct {
+	Seq     int64     `json:"seq"`
+	Type    string    `json:"type"` // sync, reload, script-error or error
+	Pack    string    `json:"pack,omitempty"`
+	Changed []string  `json:"changed,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+	Line    string    `json:"line,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// DevPack is a pack found in the development source directory.
+type DevPack struct {
+	UUID      string `json:"uuid"`
+	Type      string `json:"type"`
+	Source    string `json:"source"`
+	Installed string `json:"installed,omitempty"`
+}
+
+var (
+	devPackEvents      []DevPackEvent
+	devPackSeq         int64
+	devPackSubscribers = make(map[chan DevPackEvent]struct{})
+	devPackLastSync    time.Time
+	devPackMutex       sync.Mutex
+	// devPackSyncMutex serialises syncs from the watcher and the API.
+	devPackSyncMutex sync.Mutex
+)
+
+// publishDevPackEvent records an event and fans it out to subscribers.
+func publishDevPackEvent(e DevPackEvent) {
+	devPackMutex.Lock()
+	defer devPackMutex.Unlock()
+	devPackSeq++
+	e.Seq = devPackSeq
+	e.Time = time.Now()
+	devPackEvents = append(devPackEvents, e)
+	if len(devPackEvents) > devPackEventBacklog {
+		devPackEvents = devPackEvents[len(devPackEvents)-devPackEventBacklog:]
+	}
+	for ch := range devPackSubscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// devPackFingerprint summarises the names, sizes and modification times
+// below dir so the watcher only hashes files when something changed.
+func devPackFingerprint(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)), err
+}
+
+// listDevPacks returns the packs in the source directory and where each is
+// installed.
+func listDevPacks(root string) ([]DevPack, error) {
+	dirs, err := findPackDirs(root)
+	if err != nil {
+		return nil, err
+	}
+	packs := []DevPack{}
+	for _, dir := range dirs {
+		m, kind, err := readPackManifest(dir)
+		if err != nil {
+			return nil, err
+		}
+		if kind == "" {
+			return nil, fmt.Errorf("%s: cannot tell the pack type from its modules", dir)
+		}
+		installRoot := behaviorPacksDir
+		if kind == "resource" {
+			installRoot = resourcePacksDir
+		}
+		installed, _ := findPackByUUID(installRoot, m.Header.UUID)
+		packs = append(packs, DevPack{UUID: m.Header.UUID, Type: kind, Source: dir, Installed: installed})
+	}
+	return packs, nil
+}
+
+// mirrorPackDir makes target an exact copy of src, touching only files
+// whose contents differ.
+func mirrorPackDir(src, target string) (changed, removed []string, err error) {
+	want, err := buildSyncManifest(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	have, err := buildSyncManifest(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	fetch, remove := diffSyncManifests(have, want)
+	for _, f := range fetch {
+		dst, err := syncTargetPath(target, f.Path)
+		if err != nil {
+			return changed, removed, err
+		}
+		if err := copyTruncated(filepath.Join(src, filepath.FromSlash(f.Path)), dst, f.Size); err != nil {
+			return changed, removed, err
+		}
+		changed = append(changed, f.Path)
+	}
+	for _, rel := range remove {
+		path, err := syncTargetPath(target, rel)
+		if err != nil {
+			return changed, removed, err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return changed, removed, err
+		}
+		removed = append(removed, rel)
+	}
+	return changed, removed, nil
+}
+
+// syncDevPacks copies changes from the source directory into the installed
+// packs and reloads the server when anything changed. Packs that are not
+// installed yet are installed and archived like an upload.
+func syncDevPacks(root string) error {
+	devPackSyncMutex.Lock()
+	defer devPackSyncMutex.Unlock()
+	packs, err := listDevPacks(root)
+	if err != nil {
+		return err
+	}
+	dirty := false
+	for _, p := range packs {
+		if p.Installed == "" {
+			if _, err := installPackDir(p.Source, p.Type, p.UUID); err != nil {
+				return fmt.Errorf("installing %s: %w", p.UUID, err)
+			}
+			publishDevPackEvent(DevPackEvent{Type: "sync", Pack: p.UUID, Changed: []string{"*"}})
+			dirty = true
+			continue
+		}
+		changed, removed, err := mirrorPackDir(p.Source, p.Installed)
+		if len(changed) > 0 || len(removed) > 0 {
+			publishDevPackEvent(DevPackEvent{Type: "sync", Pack: p.UUID, Changed: changed, Removed: removed})
+			dirty = true
+		}
+		if err != nil {
+			return fmt.Errorf("syncing %s: %w", p.UUID, err)
+		}
+	}
+	devPackMutex.Lock()
+	devPackLastSync = time.Now()
+	devPackMutex.Unlock()
+	if !dirty || !serverUp() {
+		return nil
+	}
+	if err := sendServerCommand("reload"); err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+	publishDevPackEvent(DevPackEvent{Type: "reload"})
+	return nil
+}
+
+// watchScriptErrors forwards Scripting API errors and their stack frames
+// from the server log to the development event stream.
+func watchScriptErrors() {
+	ch := make(chan ConsoleMessage, 256)
+	consoleMutex.Lock()
+	consoleSubscribers[ch] = struct{}{}
+	consoleMutex.Unlock()
+	inTrace := false
+	for msg := range ch {
+		switch {
+		case scriptErrorPattern.MatchString(msg.Line):
+			inTrace = true
+		case inTrace && scriptStackPattern.MatchString(msg.Line):
+		default:
+			inTrace = false
+			continue
+		}
+		publishDevPackEvent(DevPackEvent{Type: "script-error", Line: msg.Line})
+	}
+}
+
+// startDevPackWatcher polls the development source directory and syncs it
+// into the installed packs once a burst of edits has settled.
+func startDevPackWatcher() {
+	root := os.Getenv(devPacksDirEnv)
+	if root == "" {
+		return
+	}
+	log.Printf("Pack development mode: watching %s", root)
+	if os.Getenv(serverLogEnv) != "" {
+		go watchScriptErrors()
+	}
+	go func() {
+		var synced, pending string
+		for range time.Tick(devPackPollInterval) {
+			fp, err := devPackFingerprint(root)
+			if err != nil || fp == synced {
+				continue
+			}
+			if fp != pending {
+				// Wait for one quiet interval so half-saved files are not copied.
+				pending = fp
+				continue
+			}
+			synced = fp
+			if err := syncDevPacks(root); err != nil {
+				log.Printf("Error syncing development packs: %v", err)
+				publishDevPackEvent(DevPackEvent{Type: "error", Error: err.Error()})
+			}
+		}
+	}()
+}
+
+// devPacksHandler shows the development packs and recent events (GET) or
+// forces a sync and reload (POST).
+func devPacksHandler(w http.ResponseWriter, r *http.Request) {
+	root := os.Getenv(devPacksDirEnv)
+	if root == "" {
+		writeJSONError(w, http.StatusNotFound, "Pack development mode is off: set "+devPacksDirEnv)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := syncDevPacks(root); err != nil {
+			publishDevPackEvent(DevPackEvent{Type: "error", Error: err.Error()})
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	packs, err := listDevPacks(root)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	devPackMutex.Lock()
+	defer devPackMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"source":    root,
+		"packs":     packs,
+		"last_sync": devPackLastSync,
+		"events":    append([]DevPackEvent{}, devPackEvents...),
+	})
+}
+
+// devPackEventsHandler streams sync, reload and script error events as
+// server-sent events.
+func devPackEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	ch := make(chan DevPackEvent, 64)
+	devPackMutex.Lock()
+	devPackSubscribers[ch] = struct{}{}
+	devPackMutex.Unlock()
+	defer func() {
+		devPackMutex.Lock()
+		delete(devPackSubscribers, ch)
+		devPackMutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case e := <-ch:
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
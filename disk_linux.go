@@ -0,0 +1,15 @@
+package main
+
+import "syscall"
+
+// diskUsage reports the size, used and available bytes of the filesystem
+// holding path. Used and available exclude the blocks reserved for root,
+// as df does.
+func diskUsage(path string) (size, used, avail uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, 0, err
+	}
+	bsize := uint64(st.Bsize)
+	return st.Blocks * bsize, (st.Blocks - st.Bfree) * bsize, st.Bavail * bsize, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func diskUsage(path string) (size, used, avail uint64, err error) {
+	return 0, 0, 0, errors.New("disk usage is only reported on Linux")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// APIExample is a request/response sample for one endpoint. Request and
+// Response hold values of the handlers' own types, so the samples follow
+// the models as they change.
+type APIExample struct {
+	Name        string      `json:"name"`
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	Description string      `json:"description,omitempty"`
+	Role        string      `json:"role"`
+	Request     interface{} `json:"request,omitempty"`
+	RawRequest  string      `json:"raw_request,omitempty"`
+	Status      int         `json:"status"`
+	Response    interface{} `json:"response,omitempty"`
+}
+
+// exampleTime keeps the samples stable between requests.
+var exampleTime = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	examplePackUUID = "5f1c9f2e-2d4b-4c8a-9b1e-0a7d3c6e8f42"
+	exampleTaskID   = "0b9e4c7a-61d2-4f3e-8a5b-2c7d9e1f3a60"
+)
+
+// apiExamples returns the documented samples.
+func apiExamples() []APIExample {
+	countdown := 30
+	examples := []APIExample{
+		{Name: "Send a console command", Method: http.MethodPost, Path: "/send-command",
+			Description: "The body is the raw command without a leading slash.",
+			RawRequest:  "say Hello from the API", Status: http.StatusOK,
+			Response: map[string]string{"message": "Command sent successfully"}},
+		{Name: "Server status", Method: http.MethodGet, Path: "/status", Status: http.StatusOK,
+			Response: map[string]interface{}{"online": true, "motd": "Dedicated Server", "version": "1.21.0", "players": 3, "max_players": 10,
+				"game_mode": "Survival", "level_name": "Bedrock level", "latency_ms": 0.4, "uptime_30d_percent": 99.93, "time": exampleTime}},
+		{Name: "Latency percentiles", Method: http.MethodGet, Path: "/status/latency?window=1h", Status: http.StatusOK,
+			Response: map[string]interface{}{"window": "1h0m0s", "stats": LatencyStats{Sent: 720, Lost: 2, LossPercent: 0.28, MinMS: 0.3, AvgMS: 0.6, MaxMS: 12, P50MS: 0.5, P90MS: 0.9, P95MS: 1.4, P99MS: 6.2}}},
+		{Name: "Restart with countdown", Method: http.MethodPost, Path: "/server/restart", Role: roleAdmin,
+			Request: ServerActionRequest{Countdown: &countdown, Message: "Restarting for updates"},
+			Status:  http.StatusAccepted, Response: map[string]string{"task_id": exampleTaskID}},
+		{Name: "Hibernation status", Method: http.MethodGet, Path: "/hibernation", Status: http.StatusOK,
+			Response: HibernationStatus{Enabled: true, State: hibernationAwake, IdleAfter: "15m", Players: 2}},
+		{Name: "Relay a chat message", Method: http.MethodPost, Path: "/chat/send",
+			Request: ChatSendRequest{Source: "discord", Sender: "Alex", Message: "hi everyone"},
+			Status:  http.StatusOK, Response: map[string]string{"message": "Chat message sent"}},
+		{Name: "Create a warp", Method: http.MethodPost, Path: "/warps",
+			Request: Warp{Name: "spawn", X: 0, Y: 64, Z: 0, Dimension: "overworld"},
+			Status:  http.StatusCreated, Response: Warp{Name: "spawn", X: 0, Y: 64, Z: 0, Dimension: "overworld"}},
+		{Name: "Schedule a job", Method: http.MethodPut, Path: "/jobs/nightly-say",
+			Request: CronJob{Name: "nightly-say", Schedule: "0 22 * * *", Enabled: true, Actions: []HookAction{{Type: "command", Command: "say Nightly restart in 2 hours"}}},
+			Status:  http.StatusCreated, Response: CronJob{Name: "nightly-say", Schedule: "0 22 * * *", Enabled: true, Actions: []HookAction{{Type: "command", Command: "say Nightly restart in 2 hours"}}, NextRun: exampleTime.Add(10 * time.Hour)}},
+		{Name: "Create a leaderboard", Method: http.MethodPost, Path: "/leaderboards",
+			Request: Leaderboard{Name: "playtime", Source: "playtime"},
+			Status:  http.StatusOK, Response: Leaderboard{Name: "playtime", Source: "playtime"}},
+		{Name: "Read a leaderboard", Method: http.MethodGet, Path: "/leaderboards/playtime?limit=3", Status: http.StatusOK,
+			Response: map[string]interface{}{"leaderboard": Leaderboard{Name: "playtime", Source: "playtime"}, "total": 2, "offset": 0, "limit": 3,
+				"entries": []LeaderboardEntry{{Rank: 1, Player: "Steve", Score: 86400}, {Rank: 2, Player: "Alex", Score: 43200}}}},
+		{Name: "Player stats", Method: http.MethodGet, Path: "/players/Steve/stats", Status: http.StatusOK,
+			Response: PlayerStats{Player: "Steve", FirstSeen: exampleTime.AddDate(0, -1, 0), LastSeen: exampleTime, Joins: 42, PlaytimeSeconds: 86400, DailyStreak: 3, LongestStreak: 9}},
+		{Name: "Activate an installed pack", Method: http.MethodPost, Path: "/activate-addon",
+			Request: ActivateAddonRequest{UUID: examplePackUUID},
+			Status:  http.StatusCreated, Response: map[string]interface{}{"type": "behavior", "pack_id": examplePackUUID, "version": []int{1, 0, 0}}},
+		{Name: "Reorder resource packs", Method: http.MethodPost, Path: "/reorder-addons",
+			Description: "Packs earlier in the list take priority.",
+			Request:     map[string]interface{}{"type": "resource", "order": []string{examplePackUUID}},
+			Status:      http.StatusOK, Response: map[string]interface{}{"type": "resource", "activations": []ActiveAddon{{PackID: examplePackUUID, Version: []int{1, 0, 0}}}}},
+		{Name: "Set the backup schedule", Method: http.MethodPut, Path: "/backups/schedule", Role: roleAdmin,
+			Request: BackupSchedule{Schedule: "0 4 * * *", Enabled: true, KeepLast: 7},
+			Status:  http.StatusOK, Response: map[string]interface{}{"schedule": BackupSchedule{Schedule: "0 4 * * *", Enabled: true, KeepLast: 7, NextRun: exampleTime.Add(16 * time.Hour)}, "pruned": []string{}}},
+		{Name: "Restore a backup", Method: http.MethodPost, Path: "/restore", Role: roleAdmin,
+			Request: RestoreRequest{BackupID: "backup-20240601-040000.zip"},
+			Status:  http.StatusAccepted, Response: map[string]string{"task_id": exampleTaskID}},
+		{Name: "Set the availability SLO", Method: http.MethodPut, Path: "/availability/slo", Role: roleAdmin,
+			Request: AvailabilitySLO{Target: 99.5, WindowDays: 30, FastBurn: 14.4, SlowBurn: 6},
+			Status:  http.StatusOK, Response: AvailabilitySLO{Target: 99.5, WindowDays: 30, FastBurn: 14.4, SlowBurn: 6}},
+		{Name: "Turn on debug logging", Method: http.MethodPut, Path: "/debug/logging", Role: roleAdmin,
+			Request: DebugLoggingRequest{Duration: "30m"},
+			Status:  http.StatusOK, Response: DebugLoggingState{Enabled: true, Until: exampleTime.Add(30 * time.Minute), Sidecar: true,
+				Properties: map[string]string{"content-log-file-enabled": "true", "content-log-console-output-enabled": "true", "emit-server-telemetry": "true"}}},
+	}
+	for i := range examples {
+		if examples[i].Role == "" {
+			r, _ := http.NewRequest(examples[i].Method, examples[i].Path, nil)
+			examples[i].Role = requiredRole(r)
+		}
+	}
+	return examples
+}
+
+// apiVersion returns the version in the embedded OpenAPI description.
+func apiVersion() string {
+	var spec struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	json.Unmarshal(openAPISpec, &spec)
+	return spec.Info.Version
+}
+
+// docsExamplesHandler serves the samples, optionally only those whose path
+// starts with ?path=.
+func docsExamplesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	prefix := r.URL.Query().Get("path")
+	examples := []APIExample{}
+	for _, e := range apiExamples() {
+		if strings.HasPrefix(e.Path, prefix) {
+			examples = append(examples, e)
+		}
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"version": apiVersion(), "examples": examples})
+}
+
+// postmanRequest builds a Postman v2.1 request from an example.
+func postmanRequest(e APIExample) map[string]interface{} {
+	path, query, _ := strings.Cut(e.Path, "?")
+	url := map[string]interface{}{
+		"raw":  "{{baseUrl}}" + e.Path,
+		"host": []string{"{{baseUrl}}"},
+		"path": strings.Split(strings.TrimPrefix(path, "/"), "/"),
+	}
+	if query != "" {
+		var params []map[string]string
+		for _, kv := range strings.Split(query, "&") {
+			k, v, _ := strings.Cut(kv, "=")
+			params = append(params, map[string]string{"key": k, "value": v})
+		}
+		url["query"] = params
+	}
+	req := map[string]interface{}{"method": e.Method, "url": url, "description": e.Description}
+	switch {
+	case e.Request != nil:
+		body, _ := json.MarshalIndent(e.Request, "", "  ")
+		req["header"] = []map[string]string{{"key": "Content-Type", "value": "application/json"}}
+		req["body"] = map[string]interface{}{"mode": "raw", "raw": string(body), "options": map[string]interface{}{"raw": map[string]string{"language": "json"}}}
+	case e.RawRequest != "":
+		req["header"] = []map[string]string{{"key": "Content-Type", "value": "text/plain"}}
+		req["body"] = map[string]interface{}{"mode": "raw", "raw": e.RawRequest}
+	}
+	return req
+}
+
+// docsPostmanHandler exports the samples as a Postman collection, one
+// folder per top-level path. Set the baseUrl and apiKey variables after
+// importing it.
+func docsPostmanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	folders := map[string][]interface{}{}
+	for _, e := range apiExamples() {
+		folder := strings.SplitN(strings.TrimPrefix(e.Path, "/"), "/", 2)[0]
+		folder = strings.SplitN(folder, "?", 2)[0]
+		item := map[string]interface{}{"name": e.Name, "request": postmanRequest(e)}
+		if e.Response != nil {
+			body, _ := json.MarshalIndent(e.Response, "", "  ")
+			item["response"] = []map[string]interface{}{{
+				"name": e.Name, "originalRequest": postmanRequest(e), "code": e.Status, "status": http.StatusText(e.Status),
+				"header": []map[string]string{{"key": "Content-Type", "value": "application/json"}}, "body": string(body),
+				"_postman_previewlanguage": "json",
+			}}
+		}
+		folders[folder] = append(folders[folder], item)
+	}
+	names := make([]string, 0, len(folders))
+	for name := range folders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := []map[string]interface{}{}
+	for _, name := range names {
+		items = append(items, map[string]interface{}{"name": name, "item": folders[name]})
+	}
+	version := apiVersion()
+	w.Header().Set("Content-Disposition", "attachment; filename=go-bedrock-api-"+version+".postman_collection.json")
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"info": map[string]string{
+			"name":    "go-bedrock-api " + version,
+			"version": version,
+			"schema":  "https://schema.getpostman.com/json/collection/v2.1.0/collection.json",
+		},
+		"auth": map[string]interface{}{"type": "apikey", "apikey": []map[string]string{
+			{"key": "key", "value": "X-API-Key"}, {"key": "value", "value": "{{apiKey}}"}, {"key": "in", "value": "header"},
+		}},
+		"variable": []map[string]string{{"key": "baseUrl", "value": "http://localhost:" + listenPort}, {"key": "apiKey", "value": ""}},
+		"item":     items,
+	})
+}
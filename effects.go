@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	effectsStateFile = "effects.json"
+	effectTick       = 30 * time.Second
+	// effectRenewal is how long each renewal lasts in game. It spans a few
+	// ticks so an effect never lapses between renewals.
+	effectRenewal = 3 * effectTick
+	maxEffectTime = 30 * 24 * time.Hour
+)
+
+var (
+	effectNamePattern = regexp.MustCompile(`^(minecraft:)?[a-z_]+$`)
+	// Abilities are granted with /ability, which needs Education Edition
+	// features enabled in the world.
+	effectAbilities = map[string]bool{"mayfly": true, "mute": true, "worldbuilder": true}
+)
+
+// PlayerEffect is a status effect or ability granted to a player for an
+// amount of their playtime. The sidecar renews effects while the player is
+// online, so they outlast deaths and the raw command's own duration, and
+// removes them once the playtime is used up.
+type PlayerEffect struct {
+	ID            string    `json:"id"`
+	Player        string    `json:"player"`
+	Type          string    `json:"type"` // effect or ability
+	Name          string    `json:"name"`
+	Amplifier     int       `json:"amplifier,omitempty"`
+	HideParticles bool      `json:"hide_particles,omitempty"`
+	Duration      string    `json:"duration"`
+	Remaining     int64     `json:"remaining_seconds"`
+	Removing      bool      `json:"removing,omitempty"`
+	Created       time.Time `json:"created"`
+}
+
+var (
+	playerEffects      = []*PlayerEffect{}
+	playerEffectsMutex sync.Mutex
+)
+
+func (e *PlayerEffect) validate() error {
+	if !validPlayerName(e.Player) {
+		return errors.New("invalid player")
+	}
+	if e.Type == "" {
+		e.Type = "effect"
+	}
+	switch e.Type {
+	case "effect":
+		if !effectNamePattern.MatchString(e.Name) {
+			return fmt.Errorf("invalid effect %q", e.Name)
+		}
+		if e.Amplifier < 0 || e.Amplifier > 255 {
+			return errors.New("amplifier must be between 0 and 255")
+		}
+	case "ability":
+		if !effectAbilities[e.Name] {
+			return errors.New("ability must be mayfly, mute or worldbuilder")
+		}
+	default:
+		return errors.New("type must be effect or ability")
+	}
+	d, err := time.ParseDuration(e.Duration)
+	if err != nil || d < time.Minute || d > maxEffectTime {
+		return errors.New("duration must be between 1m and 720h")
+	}
+	e.Remaining = int64(d / time.Second)
+	return nil
+}
+
+// applyCommand returns the command granting the effect for its next
+// renewal, and removeCommand the one taking it away.
+func (e *PlayerEffect) applyCommand() string {
+	target := quotePlayer(e.Player)
+	if e.Type == "ability" {
+		return "ability " + target + " " + e.Name + " true"
+	}
+	secs := int64(effectRenewal / time.Second)
+	if e.Remaining < secs {
+		secs = e.Remaining
+	}
+	return fmt.Sprintf("effect %s %s %d %d %t", target, e.Name, secs, e.Amplifier, e.HideParticles)
+}
+
+func (e *PlayerEffect) removeCommand() string {
+	target := quotePlayer(e.Player)
+	if e.Type == "ability" {
+		return "ability " + target + " " + e.Name + " false"
+	}
+	// A duration of 0 clears the effect.
+	return "effect " + target + " " + e.Name + " 0"
+}
+
+func savePlayerEffects() {
+	if err := saveState(effectsStateFile, playerEffects); err != nil {
+		log.Printf("Error saving player effects: %v", err)
+	}
+}
+
+// refreshPlayerEffects removes the player's used-up effects and renews the
+// rest. elapsed is the playtime since the last refresh. Callers must hold
+// playerEffectsMutex.
+func refreshPlayerEffects(player string, elapsed time.Duration) {
+	kept := playerEffects[:0]
+	for _, e := range playerEffects {
+		if e.Player != player {
+			kept = append(kept, e)
+			continue
+		}
+		e.Remaining -= int64(elapsed / time.Second)
+		if e.Remaining <= 0 || e.Removing {
+			if err := sendServerCommand(e.removeCommand()); err != nil {
+				log.Printf("Failed to remove %s from %s: %v", e.Name, player, err)
+				e.Remaining, e.Removing = 0, true
+				kept = append(kept, e)
+				continue
+			}
+			log.Printf("Removed %s %s from %s", e.Type, e.Name, player)
+			continue
+		}
+		// Abilities stay with the player, so they only need granting again
+		// when the player joins.
+		if e.Type == "effect" || elapsed == 0 {
+			if err := sendServerCommand(e.applyCommand()); err != nil {
+				log.Printf("Failed to renew %s for %s: %v", e.Name, player, err)
+			}
+		}
+		kept = append(kept, e)
+	}
+	playerEffects = kept
+}
+
+// effectsOnJoin grants a joining player's effects again.
+func effectsOnJoin(player string) {
+	playerEffectsMutex.Lock()
+	defer playerEffectsMutex.Unlock()
+	refreshPlayerEffects(player, 0)
+	savePlayerEffects()
+}
+
+// startEffectLoop counts down effects while their players are online and
+// renews them.
+func startEffectLoop() {
+	go func() {
+		for range time.Tick(effectTick) {
+			queueMutex.Lock()
+			online := make([]string, 0, len(onlineSet))
+			for p := range onlineSet {
+				online = append(online, p)
+			}
+			queueMutex.Unlock()
+			playerEffectsMutex.Lock()
+			if len(playerEffects) > 0 {
+				for _, p := range online {
+					refreshPlayerEffects(p, effectTick)
+				}
+				savePlayerEffects()
+			}
+			playerEffectsMutex.Unlock()
+		}
+	}()
+}
+
+// effectsHandler lists effects, optionally for ?player= (GET), or grants
+// one (POST). A player who is online gets it at once.
+func effectsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		player := r.URL.Query().Get("player")
+		playerEffectsMutex.Lock()
+		defer playerEffectsMutex.Unlock()
+		out := []PlayerEffect{}
+		for _, e := range playerEffects {
+			if player == "" || e.Player == player {
+				out = append(out, *e)
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"effects": out})
+	case http.MethodPost:
+		var e PlayerEffect
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := e.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		e.ID, e.Created, e.Removing = newUUID(), time.Now(), false
+		queueMutex.Lock()
+		_, online := onlineSet[e.Player]
+		queueMutex.Unlock()
+		playerEffectsMutex.Lock()
+		defer playerEffectsMutex.Unlock()
+		// A player holds at most one grant of each effect or ability.
+		for _, old := range playerEffects {
+			if old.Player == e.Player && old.Type == e.Type && old.Name == e.Name && !old.Removing {
+				writeJSONError(w, http.StatusConflict, "The player already has "+e.Name+"; delete it first")
+				return
+			}
+		}
+		playerEffects = append(playerEffects, &e)
+		if online {
+			if err := sendServerCommand(e.applyCommand()); err != nil {
+				log.Printf("Failed to apply %s to %s: %v", e.Name, e.Player, err)
+			}
+		}
+		savePlayerEffects()
+		writeJSONResponse(w, http.StatusCreated, e)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// effectHandler serves DELETE /effects/{id}. The effect is taken away at
+// once if the player is online, otherwise when they next join.
+func effectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/effects/")
+	playerEffectsMutex.Lock()
+	defer playerEffectsMutex.Unlock()
+	for _, e := range playerEffects {
+		if e.ID != id {
+			continue
+		}
+		e.Removing = true
+		queueMutex.Lock()
+		_, online := onlineSet[e.Player]
+		queueMutex.Unlock()
+		if online {
+			refreshPlayerEffects(e.Player, 0)
+		}
+		savePlayerEffects()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "Effect removed", "applied": online})
+		return
+	}
+	writeJSONError(w, http.StatusNotFound, "Effect not found")
+}
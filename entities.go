@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EntityChunk is the entity population of one 16x16 column as reported by
+// the bridge census.
+type EntityChunk struct {
+	Chunk string         `json:"chunk"`
+	Total int            `json:"total"`
+	Types map[string]int `json:"types"`
+}
+
+// DimensionCensus is the latest entity census for one dimension.
+type DimensionCensus struct {
+	Counts    map[string]int `json:"counts"`
+	Chunks    []EntityChunk  `json:"chunks"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// DenseArea is an entry in the densest-areas list of the summary.
+type DenseArea struct {
+	Dimension string         `json:"dimension"`
+	ChunkX    int            `json:"chunk_x"`
+	ChunkZ    int            `json:"chunk_z"`
+	BlockX    int            `json:"block_x"`
+	BlockZ    int            `json:"block_z"`
+	Total     int            `json:"total"`
+	TopType   string         `json:"top_type"`
+	Types     map[string]int `json:"types"`
+}
+
+// entityCensus holds the latest census per dimension, guarded by bridgeMutex.
+var entityCensus = make(map[string]DimensionCensus)
+
+// recordEntityCensus stores a census event. Callers must hold bridgeMutex.
+func recordEntityCensus(ev BridgeEvent) {
+	var census DimensionCensus
+	if err := json.Unmarshal(ev.Data, &census); err != nil {
+		log.Printf("Invalid entity census from bridge: %v", err)
+		return
+	}
+	census.UpdatedAt = ev.ReceivedAt
+	entityCensus[ev.Dimension] = census
+}
+
+// parseChunkKey splits a "x,z" chunk key.
+func parseChunkKey(key string) (int, int, error) {
+	parts := strings.SplitN(key, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid chunk key %q", key)
+	}
+	x, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	z, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, z, nil
+}
+
+// entitiesSummaryHandler reports entity counts by type and dimension and the
+// top-N densest chunks across all dimensions.
+func entitiesSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	top := 10
+	if t := r.URL.Query().Get("top"); t != "" {
+		n, err := strconv.Atoi(t)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid top")
+			return
+		}
+		top = n
+	}
+
+	bridgeMutex.RLock()
+	defer bridgeMutex.RUnlock()
+	if len(entityCensus) == 0 {
+		writeJSONError(w, http.StatusServiceUnavailable, "No entity census yet: install the script bridge via POST /bridge/install")
+		return
+	}
+
+	byDimension := make(map[string]map[string]int)
+	byType := make(map[string]int)
+	totals := make(map[string]int)
+	var updatedAt time.Time
+	areas := []DenseArea{}
+	for dim, census := range entityCensus {
+		byDimension[dim] = census.Counts
+		for typ, n := range census.Counts {
+			byType[typ] += n
+			totals[dim] += n
+		}
+		if census.UpdatedAt.After(updatedAt) {
+			updatedAt = census.UpdatedAt
+		}
+		for _, c := range census.Chunks {
+			x, z, err := parseChunkKey(c.Chunk)
+			if err != nil {
+				continue
+			}
+			area := DenseArea{Dimension: dim, ChunkX: x, ChunkZ: z, BlockX: x * 16, BlockZ: z * 16, Total: c.Total, Types: c.Types}
+			for typ, n := range c.Types {
+				if n > area.Types[area.TopType] || area.TopType == "" {
+					area.TopType = typ
+				}
+			}
+			areas = append(areas, area)
+		}
+	}
+	sort.Slice(areas, func(i, j int) bool { return areas[i].Total > areas[j].Total })
+	if len(areas) > top {
+		areas = areas[:top]
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"by_dimension":    byDimension,
+		"by_type":         byType,
+		"dimension_total": totals,
+		"densest_areas":   areas,
+		"updated_at":      updatedAt,
+	})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	environmentStateFile = "environment_locks.json"
+	environmentInterval  = time.Minute
+)
+
+// timeOfDayNames are the names /time set accepts, in ticks.
+var timeOfDayNames = map[string]int{
+	"sunrise":  23000,
+	"day":      1000,
+	"noon":     6000,
+	"sunset":   12000,
+	"night":    13000,
+	"midnight": 18000,
+}
+
+var weatherTypes = map[string]bool{"clear": true, "rain": true, "thunder": true}
+
+// EnvironmentLocks is the weather and time of day the sidecar holds. A
+// lock turns the matching game rule cycle off and is re-applied every
+// minute, so it also survives restarts and world switches.
+type EnvironmentLocks struct {
+	Weather string    `json:"weather,omitempty"`
+	Time    *int      `json:"time,omitempty"`
+	Applied time.Time `json:"applied"`
+}
+
+var (
+	environmentLocks      EnvironmentLocks
+	environmentLocksMutex sync.Mutex
+)
+
+// EnvironmentRequest sets the weather or the time. Duration is how many
+// seconds unlocked weather lasts; Time is ticks or a name such as noon.
+type EnvironmentRequest struct {
+	Weather  string          `json:"weather,omitempty"`
+	Duration int             `json:"duration,omitempty"`
+	Time     json.RawMessage `json:"time,omitempty"`
+	Lock     bool            `json:"lock"`
+}
+
+// parseTimeOfDay accepts ticks (0-23999) or a named time of day.
+func parseTimeOfDay(raw json.RawMessage) (int, error) {
+	var ticks int
+	if err := json.Unmarshal(raw, &ticks); err == nil {
+		if ticks < 0 || ticks >= 24000 {
+			return 0, fmt.Errorf("time must be between 0 and 23999 ticks")
+		}
+		return ticks, nil
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		if t, ok := timeOfDayNames[name]; ok {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("time must be ticks or one of sunrise, day, noon, sunset, night, midnight")
+}
+
+// applyEnvironmentLocks sends the commands holding the locked weather and
+// time. Callers must hold environmentLocksMutex.
+func applyEnvironmentLocks() error {
+	var cmds []string
+	if environmentLocks.Weather != "" {
+		cmds = append(cmds, "gamerule doweathercycle false", "weather "+environmentLocks.Weather)
+	}
+	if environmentLocks.Time != nil {
+		cmds = append(cmds, "gamerule dodaylightcycle false", "time set "+strconv.Itoa(*environmentLocks.Time))
+	}
+	for _, cmd := range cmds {
+		if err := sendServerCommand(cmd); err != nil {
+			return err
+		}
+	}
+	if len(cmds) > 0 {
+		environmentLocks.Applied = time.Now()
+	}
+	return nil
+}
+
+func saveEnvironmentLocks() {
+	if err := saveState(environmentStateFile, environmentLocks); err != nil {
+		log.Printf("Error saving environment locks: %v", err)
+	}
+}
+
+// releaseWeatherLock drops the weather lock and turns the weather cycle
+// back on. Callers must hold environmentLocksMutex.
+func releaseWeatherLock() error {
+	if environmentLocks.Weather == "" {
+		return nil
+	}
+	environmentLocks.Weather = ""
+	saveEnvironmentLocks()
+	return sendServerCommand("gamerule doweathercycle true")
+}
+
+// releaseTimeLock drops the time lock and turns the daylight cycle back
+// on. Callers must hold environmentLocksMutex.
+func releaseTimeLock() error {
+	if environmentLocks.Time == nil {
+		return nil
+	}
+	environmentLocks.Time = nil
+	saveEnvironmentLocks()
+	return sendServerCommand("gamerule dodaylightcycle true")
+}
+
+// startEnvironmentLockLoop re-applies the locks while the server is up.
+func startEnvironmentLockLoop() {
+	go func() {
+		for range time.Tick(environmentInterval) {
+			environmentLocksMutex.Lock()
+			if (environmentLocks.Weather != "" || environmentLocks.Time != nil) && serverUp() {
+				if err := applyEnvironmentLocks(); err != nil {
+					log.Printf("Error re-applying environment locks: %v", err)
+				}
+			}
+			environmentLocksMutex.Unlock()
+		}
+	}()
+}
+
+// weatherHandler shows the weather lock (GET), sets the weather (POST
+// {"weather","duration","lock"}) or releases the lock (DELETE).
+func weatherHandler(w http.ResponseWriter, r *http.Request) {
+	environmentLocksMutex.Lock()
+	defer environmentLocksMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req EnvironmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if !weatherTypes[req.Weather] {
+			writeJSONError(w, http.StatusBadRequest, "weather must be clear, rain or thunder")
+			return
+		}
+		if req.Duration < 0 || req.Duration > 1000000 {
+			writeJSONError(w, http.StatusBadRequest, "duration must be between 0 and 1000000 seconds")
+			return
+		}
+		var err error
+		if req.Lock {
+			environmentLocks.Weather = req.Weather
+			err = applyEnvironmentLocks()
+			saveEnvironmentLocks()
+		} else {
+			cmd := "weather " + req.Weather
+			if req.Duration > 0 {
+				cmd += " " + strconv.Itoa(req.Duration)
+			}
+			if err = releaseWeatherLock(); err == nil {
+				err = sendServerCommand(cmd)
+			}
+		}
+		if err != nil {
+			log.Printf("Error setting weather: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to set weather")
+			return
+		}
+	case http.MethodDelete:
+		if err := releaseWeatherLock(); err != nil {
+			log.Printf("Error releasing weather lock: %v", err)
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"locked":  environmentLocks.Weather != "",
+		"weather": environmentLocks.Weather,
+		"applied": environmentLocks.Applied,
+	})
+}
+
+// timeHandler shows the time lock (GET), sets the time of day (POST
+// {"time","lock"}) or releases the lock (DELETE).
+func timeHandler(w http.ResponseWriter, r *http.Request) {
+	environmentLocksMutex.Lock()
+	defer environmentLocksMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req EnvironmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		ticks, err := parseTimeOfDay(req.Time)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Lock {
+			environmentLocks.Time = &ticks
+			err = applyEnvironmentLocks()
+			saveEnvironmentLocks()
+		} else if err = releaseTimeLock(); err == nil {
+			err = sendServerCommand("time set " + strconv.Itoa(ticks))
+		}
+		if err != nil {
+			log.Printf("Error setting time: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to set time")
+			return
+		}
+	case http.MethodDelete:
+		if err := releaseTimeLock(); err != nil {
+			log.Printf("Error releasing time lock: %v", err)
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"locked":  environmentLocks.Time != nil,
+		"time":    environmentLocks.Time,
+		"applied": environmentLocks.Applied,
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	busBacklog   = 200
+	busQueueSize = 256
+)
+
+// BusEvent is an event as it goes through the event bus: the current
+// schema's payload with a sequence number for SSE resumption.
+type BusEvent struct {
+	Seq     int64
+	Type    string
+	Payload map[string]interface{}
+}
+
+// eventSink receives every event published on the bus.
+type eventSink interface {
+	Name() string
+	Publish(ev BusEvent) error
+}
+
+// webhookSink posts events to the URLs in BEDROCK_API_EVENT_WEBHOOKS in the
+// schema version webhooks are configured for.
+type webhookSink struct{ urls []string }
+
+func (s webhookSink) Name() string { return "webhook" }
+
+func (s webhookSink) Publish(ev BusEvent) error {
+	payload := ev.Payload
+	if v := emittedEventSchema(); v != eventSchemaVersion {
+		data, _ := payload["data"].(map[string]interface{})
+		payload = eventPayload(v, ev.Type, fmt.Sprint(payload["content"]), data)
+	}
+	var errs []string
+	for _, u := range s.urls {
+		if err := postWebhook(u, payload); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// mqttSink publishes each event as JSON to <prefix>/<event type>.
+type mqttSink struct{ client *mqttClient }
+
+func (s mqttSink) Name() string { return "mqtt" }
+
+func (s mqttSink) Publish(ev BusEvent) error {
+	data, err := json.Marshal(ev.Payload)
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(ev.Type, data)
+}
+
+var (
+	busSeq         int64
+	busEvents      []BusEvent
+	busSubscribers = make(map[chan BusEvent]struct{})
+	// busQueues feed each sink from its own goroutine, so a slow sink
+	// neither blocks publishers nor holds up the others.
+	busQueues []chan BusEvent
+	busMutex  sync.Mutex
+)
+
+// startEventBus starts the configured sinks. The SSE stream at /events and
+// the notification router are always available.
+func startEventBus() {
+	sinks := []eventSink{notificationRouter{}}
+	if urls := webhookURLs(eventWebhooksEnv); len(urls) > 0 {
+		sinks = append(sinks, webhookSink{urls: urls})
+	}
+	if raw := os.Getenv(mqttURLEnv); raw != "" {
+		client, err := newMQTTClient(raw)
+		if err != nil {
+			log.Printf("Error configuring MQTT sink: %v", err)
+		} else {
+			go client.keepAlive()
+			sinks = append(sinks, mqttSink{client: client})
+			log.Printf("Publishing events to MQTT broker %s under %s/", client.addr, client.prefix)
+		}
+	}
+	for _, sink := range sinks {
+		ch := make(chan BusEvent, busQueueSize)
+		busMutex.Lock()
+		busQueues = append(busQueues, ch)
+		busMutex.Unlock()
+		go func(sink eventSink) {
+			for ev := range ch {
+				if err := sink.Publish(ev); err != nil {
+					log.Printf("Event sink %s failed for %s: %v", sink.Name(), ev.Type, err)
+				}
+			}
+		}(sink)
+	}
+}
+
+// publishEvent puts an event on the bus for the SSE stream and every sink.
+func publishEvent(event, content string, data map[string]interface{}) {
+	busMutex.Lock()
+	defer busMutex.Unlock()
+	busSeq++
+	ev := BusEvent{Seq: busSeq, Type: event, Payload: eventPayload(eventSchemaVersion, event, content, data)}
+	busEvents = append(busEvents, ev)
+	if len(busEvents) > busBacklog {
+		busEvents = busEvents[len(busEvents)-busBacklog:]
+	}
+	for ch := range busSubscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Slow streams miss events rather than block publishers.
+		}
+	}
+	for _, ch := range busQueues {
+		select {
+		case ch <- ev:
+		default:
+			log.Printf("Event sink queue full, dropping %s", event)
+		}
+	}
+}
+
+// eventsStreamHandler streams bus events as server-sent events, optionally
+// only the comma-separated ?type= list. A reconnecting client's
+// Last-Event-ID replays what it missed from the recent backlog.
+func eventsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	var types map[string]bool
+	if v := r.URL.Query().Get("type"); v != "" {
+		types = map[string]bool{}
+		for _, t := range strings.Split(v, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	ch := make(chan BusEvent, 64)
+	busMutex.Lock()
+	var replay []BusEvent
+	if lastID > 0 {
+		for _, ev := range busEvents {
+			if ev.Seq > lastID {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	busSubscribers[ch] = struct{}{}
+	busMutex.Unlock()
+	defer func() {
+		busMutex.Lock()
+		delete(busSubscribers, ch)
+		busMutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(ev BusEvent) {
+		if types != nil && !types[ev.Type] {
+			return
+		}
+		data, _ := json.Marshal(ev.Payload)
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data)
+	}
+	for _, ev := range replay {
+		send(ev)
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case ev := <-ch:
+			send(ev)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Webhook event payloads are versioned. Version 1 is the original flat
+// payload with the event's fields at the top level; version 2 moves them
+// under "data". The previous version can still be selected with
+// BEDROCK_API_EVENT_SCHEMA_VERSION until its sunset date.
+const (
+	eventSchemaVersion    = 2
+	eventSchemaPrevious   = 1
+	eventSchemaV1SunsetAt = "2027-04-16"
+)
+
+// EventType describes one webhook event and the fields of its data.
+type EventType struct {
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	Fields      map[string]string `json:"fields"`
+}
+
+var eventTypes = []EventType{
+	{"queue_slot_open", "A slot opened for the first queued player, who has a reservation to join.",
+		map[string]string{"player": "string", "reserved_until": "RFC 3339 time"}},
+	{"slo_burn_rate", "The availability error budget is burning faster than a window's threshold.",
+		map[string]string{"window": "string, 1h or 6h", "burn_rate": "number", "threshold": "number", "target": "number, percent"}},
+	{"slo_burn_rate_resolved", "A window's burn rate dropped back below its threshold.",
+		map[string]string{"window": "string, 1h or 6h", "burn_rate": "number", "threshold": "number", "target": "number, percent"}},
+	{"game_latency", "p95 ping latency to the game server crossed its threshold.",
+		map[string]string{"metric": "string", "value": "number, ms", "threshold": "number, ms", "window": "duration"}},
+	{"game_latency_resolved", "p95 ping latency dropped back below its threshold.",
+		map[string]string{"metric": "string", "value": "number, ms", "threshold": "number, ms", "window": "duration"}},
+	{"game_packet_loss", "Ping packet loss to the game server crossed its threshold.",
+		map[string]string{"metric": "string", "value": "number, percent", "threshold": "number, percent", "window": "duration"}},
+	{"game_packet_loss_resolved", "Ping packet loss dropped back below its threshold.",
+		map[string]string{"metric": "string", "value": "number, percent", "threshold": "number, percent", "window": "duration"}},
+	{"resource_memory_limit", "The server's cgroup working set crossed its share of the memory limit.",
+		map[string]string{"metric": "string", "value": "number, percent", "threshold": "number, percent"}},
+	{"resource_memory_limit_resolved", "The server's memory dropped back below its share of the limit.",
+		map[string]string{"metric": "string", "value": "number, percent", "threshold": "number, percent"}},
+	{"resource_cpu_throttled", "The server's cgroup was CPU-throttled in more than its share of scheduler periods.",
+		map[string]string{"metric": "string", "value": "number, percent", "threshold": "number, percent"}},
+	{"resource_cpu_throttled_resolved", "CPU throttling of the server dropped back below its threshold.",
+		map[string]string{"metric": "string", "value": "number, percent", "threshold": "number, percent"}},
+	{"server_start", "The game server finished starting, as seen in the server log.",
+		map[string]string{}},
+	{"server_stop", "The game server shut down cleanly, as seen in the server log.",
+		map[string]string{}},
+	{"command_executed", "A console command was sent through the API or the console WebSocket.",
+		map[string]string{"command": "string", "source": "string, api or console"}},
+	{"addon_installed", "A behavior or resource pack was installed or upgraded.",
+		map[string]string{"uuid": "string", "type": "string, behavior or resource", "version": "array of numbers", "folder": "string", "action": "string"}},
+	{"backup_completed", "A world backup was written.",
+		map[string]string{"backup": "string", "size_bytes": "number"}},
+	{"backup_failed", "A world backup could not be written or uploaded.",
+		map[string]string{"backup": "string, when the archive was written", "error": "string"}},
+	{"player_join", "A player joined, as seen in the server log.",
+		map[string]string{"player": "string", "xuid": "string"}},
+	{"player_leave", "A player left, as seen in the server log.",
+		map[string]string{"player": "string", "xuid": "string", "session_seconds": "number"}},
+	{"resource_oom_kill", "The OOM killer ended a process in the server's cgroup.",
+		map[string]string{"oom_kills": "number, total", "limit_bytes": "number"}},
+	{"server_crash", "The server exited unexpectedly or stopped answering pings.",
+		map[string]string{"reason": "string", "action": "string, what the sidecar did about it", "log_tail": "array of strings, the last lines of server output when captured"}},
+	{"server_hang", "The server answers pings but printed nothing, even in answer to a command.",
+		map[string]string{"reason": "string", "action": "string, what the sidecar did about it", "log_tail": "array of strings, the last lines of server output when captured"}},
+	{"command_blocked", "The command policy refused a command sent to /send-command or a custom command being saved or run.",
+		map[string]string{"command": "string", "rule": "string", "key": "string, the API key name", "role": "string"}},
+	{"alert_rule", "An alert rule's condition started to hold.",
+		map[string]string{"rule": "string", "name": "string", "value": "number", "metric": "string, metric rules", "op": "string, metric rules", "threshold": "number, metric rules", "event": "string, event rules", "count": "number, event rules", "window_seconds": "number, event rules"}},
+	{"alert_rule_resolved", "An alert rule's condition stopped holding.",
+		map[string]string{"rule": "string", "name": "string", "value": "number"}},
+}
+
+// eventEnvelopes documents the top-level fields of each schema version.
+var eventEnvelopes = map[int]map[string]string{
+	1: {
+		"schema_version": "1",
+		"event":          "event type",
+		"content":        "human-readable message, shown as-is by Discord",
+		"text":           "the same message, shown as-is by Slack",
+		"time":           "RFC 3339 time the event was emitted",
+		"deprecated":     "true; version 1 stops being emitted at its sunset",
+		"<field>":        "each of the event's fields at the top level",
+	},
+	2: {
+		"schema_version": "2",
+		"event":          "event type",
+		"content":        "human-readable message, shown as-is by Discord",
+		"text":           "the same message, shown as-is by Slack",
+		"time":           "RFC 3339 time the event was emitted",
+		"data":           "object holding the event's fields",
+	},
+}
+
+var sunsetWarning sync.Once
+
+// emittedEventSchema returns the schema version webhooks receive. Version
+// 1 is honoured until its sunset, then the current version is sent.
+func emittedEventSchema() int {
+	v, err := strconv.Atoi(envOrDefault(eventSchemaVersionEnv, strconv.Itoa(eventSchemaVersion)))
+	if err != nil || v != eventSchemaPrevious {
+		return eventSchemaVersion
+	}
+	sunset, _ := time.Parse("2006-01-02", eventSchemaV1SunsetAt)
+	if !time.Now().Before(sunset) {
+		sunsetWarning.Do(func() {
+			log.Printf("Event schema version 1 was retired on %s; sending version %d", eventSchemaV1SunsetAt, eventSchemaVersion)
+		})
+		return eventSchemaVersion
+	}
+	return v
+}
+
+// eventPayload builds an event in the given schema version.
+func eventPayload(version int, event, content string, data map[string]interface{}) map[string]interface{} {
+	payload := map[string]interface{}{}
+	if version == 1 {
+		for k, v := range data {
+			payload[k] = v
+		}
+		payload["deprecated"] = true
+	} else {
+		payload["data"] = data
+	}
+	payload["schema_version"] = version
+	payload["event"] = event
+	payload["content"] = content
+	payload["text"] = content
+	payload["time"] = time.Now()
+	return payload
+}
+
+// emitEvent posts an event to each webhook in the background and
+// publishes it on the event bus.
+func emitEvent(hooks []string, event, content string, data map[string]interface{}) {
+	payload := eventPayload(emittedEventSchema(), event, content, data)
+	for _, hook := range hooks {
+		go func(hook string) {
+			if err := postWebhook(hook, payload); err != nil {
+				log.Printf("Webhook for %s failed: %v", event, err)
+			}
+		}(hook)
+	}
+	publishEvent(event, content, data)
+}
+
+// eventsSchemaHandler describes the webhook event types and the payload
+// envelope of each supported schema version.
+func eventsSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	events := append([]EventType(nil), eventTypes...)
+	sort.Slice(events, func(i, j int) bool { return events[i].Type < events[j].Type })
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"current_version":    eventSchemaVersion,
+		"emitted_version":    emittedEventSchema(),
+		"supported_versions": []int{eventSchemaPrevious, eventSchemaVersion},
+		"deprecations":       []map[string]interface{}{{"version": eventSchemaPrevious, "sunset": eventSchemaV1SunsetAt}},
+		"envelopes":          eventEnvelopes,
+		"events":             events,
+	})
+}
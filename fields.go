@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// selectFields keeps only the given paths of a decoded JSON value. Paths
+// apply to every element of an array, so "packs.name" picks the name of
+// each pack in a "packs" list.
+func selectFields(v interface{}, paths [][]string) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = selectFields(e, paths)
+		}
+		return out
+	case map[string]interface{}:
+		nested := map[string][][]string{}
+		whole := map[string]bool{}
+		for _, p := range paths {
+			if len(p) == 1 {
+				whole[p[0]] = true
+			} else {
+				nested[p[0]] = append(nested[p[0]], p[1:])
+			}
+		}
+		out := map[string]interface{}{}
+		for k, val := range v {
+			if whole[k] {
+				out[k] = val
+			} else if sub, ok := nested[k]; ok {
+				out[k] = selectFields(val, sub)
+			}
+		}
+		return out
+	}
+	return v
+}
+
+// sparseFields trims a handler's JSON response to ?fields=a,b.c so small
+// clients such as phone widgets only download what they show. Errors and
+// non-JSON responses pass through unchanged.
+func sparseFields(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		spec := r.URL.Query().Get("fields")
+		if spec == "" {
+			next(w, r)
+			return
+		}
+		var paths [][]string
+		for _, f := range strings.Split(spec, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				paths = append(paths, strings.Split(f, "."))
+			}
+		}
+		rec := &cacheRecorder{header: http.Header{}}
+		next(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		body := rec.body.Bytes()
+		var v interface{}
+		if rec.status/100 == 2 && strings.HasPrefix(rec.header.Get("Content-Type"), "application/json") && json.Unmarshal(body, &v) == nil {
+			if filtered, err := json.Marshal(selectFields(v, paths)); err == nil {
+				body = append(filtered, '\n')
+			}
+		}
+		for k, vals := range rec.header {
+			w.Header()[k] = vals
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	}
+}
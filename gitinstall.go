@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const gitInstallTimeout = 10 * time.Minute
+
+var (
+	gitRefPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+	packFolderUnsafe = regexp.MustCompile(`[^A-Za-z0-9 ._-]+`)
+)
+
+// GitPack declares a pack folder inside the repository.
+type GitPack struct {
+	Path string `json:"path"`
+	Type string `json:"type,omitempty"` // behavior or resource; detected when empty
+}
+
+// GitBuildStep is one whitelisted build step. "copy" copies From to To
+// inside the checkout; "regolith" runs "regolith run [Profile]" and must be
+// allowed by the operator in BEDROCK_API_GIT_BUILD_STEPS.
+type GitBuildStep struct {
+	Type    string `json:"type"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Profile string `json:"profile,omitempty"`
+}
+
+// GitInstallRequest installs packs straight from a Git repository.
+type GitInstallRequest struct {
+	URL      string         `json:"url"`
+	Ref      string         `json:"ref,omitempty"`
+	Build    []GitBuildStep `json:"build,omitempty"`
+	Packs    []GitPack      `json:"packs,omitempty"`
+	Activate bool           `json:"activate,omitempty"`
+}
+
+// packManifest is the part of manifest.json needed to classify a pack.
+type packManifest struct {
+	Header  ManifestHeader `json:"header"`
+	Modules []struct {
+		Type string `json:"type"`
+	} `json:"modules"`
+}
+
+func (req *GitInstallRequest) validate() error {
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("url must be an https Git URL")
+	}
+	if req.Ref != "" && (!gitRefPattern.MatchString(req.Ref) || strings.Contains(req.Ref, "..")) {
+		return errors.New("invalid ref")
+	}
+	allowed := strings.Split(os.Getenv(gitBuildStepsEnv), ",")
+	for i, step := range req.Build {
+		switch step.Type {
+		case "copy":
+			if step.From == "" || step.To == "" {
+				return fmt.Errorf("build[%d]: copy needs from and to", i)
+			}
+		case "regolith":
+			if !contains(allowed, "regolith") {
+				return fmt.Errorf("build[%d]: regolith is not allowed; set %s", i, gitBuildStepsEnv)
+			}
+			if step.Profile != "" && !validName(step.Profile) {
+				return fmt.Errorf("build[%d]: invalid profile", i)
+			}
+		default:
+			return fmt.Errorf("build[%d]: unknown step type %q", i, step.Type)
+		}
+	}
+	for i, p := range req.Packs {
+		if p.Path == "" || (p.Type != "" && p.Type != "behavior" && p.Type != "resource") {
+			return fmt.Errorf("packs[%d]: invalid pack", i)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.TrimSpace(v) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// runInDir runs a program in dir with Git prompts and non-https transports
+// disabled.
+func runInDir(ctx context.Context, dir, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL=https")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// readPackManifest reads a pack folder's manifest and works out its type.
+func readPackManifest(dir string) (packManifest, string, error) {
+	var m packManifest
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return m, "", err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, "", fmt.Errorf("%s: %w", dir, err)
+	}
+	if !packUUIDPattern.MatchString(m.Header.UUID) {
+		return m, "", fmt.Errorf("%s: manifest has no valid uuid", dir)
+	}
+	kind := ""
+	for _, mod := range m.Modules {
+		switch mod.Type {
+		case "resources":
+			kind = "resource"
+		case "data", "script", "javascript", "client_data":
+			if kind == "" {
+				kind = "behavior"
+			}
+		}
+	}
+	return m, kind, nil
+}
+
+// findPackDirs returns every folder below root holding a manifest.json.
+func findPackDirs(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && d.Name() == "manifest.json" {
+			dirs = append(dirs, filepath.Dir(path))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// packFolderName turns a source folder name into a safe installed folder
+// name, falling back to the pack UUID.
+func packFolderName(name, uuid string) string {
+	name = strings.Trim(packFolderUnsafe.ReplaceAllString(name, "_"), " ._")
+	if name == "" {
+		return uuid
+	}
+	return name
+}
+
+// installPackDir installs an unpacked pack folder, replacing any installed
+// pack with the same UUID, and archives it so it survives volume resets.
+// A different pack already using the folder name is left alone and the
+// UUID is appended instead. It returns the installed folder's name.
+func installPackDir(srcDir, packType, uuid string) (string, error) {
+	targetRoot := behaviorPacksDir
+	if packType == "resource" {
+		targetRoot = resourcePacksDir
+	}
+	name := packFolderName(filepath.Base(srcDir), uuid)
+	tmp, err := os.MkdirTemp("", "pack-build")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	mcpack := filepath.Join(tmp, name+".mcpack")
+	if _, err := archiveDirectory(srcDir, mcpack, BackupCompression{Format: formatZip, Method: "deflate"}); err != nil {
+		return "", err
+	}
+	if _, _, err := saveMcpackToArchive(mcpack, packType); err != nil {
+		return "", err
+	}
+	target := filepath.Join(targetRoot, name)
+	if existing, err := findPackByUUID(targetRoot, uuid); err == nil && existing != "" {
+		target = existing
+	} else if _, err := os.Stat(target); err == nil {
+		target += "_" + uuid[:8]
+	}
+	if err := moveToTrash(packType+"-pack", target, "replaced by a new install"); err != nil {
+		return "", err
+	}
+	return filepath.Base(target), copyDir(srcDir, target)
+}
+
+// rejectSymlinks fails if a checkout contains a symbolic link, which could
+// point a build step or an installed pack at files outside it.
+func rejectSymlinks(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			rel, _ := filepath.Rel(dir, path)
+			return fmt.Errorf("repository contains a symlink: %s", filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+// runGitInstall clones the repository, runs the build steps and installs
+// the resulting packs.
+func runGitInstall(t *Task, req GitInstallRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), gitInstallTimeout)
+	defer cancel()
+	repo, err := os.MkdirTemp("", "git-install")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(repo)
+
+	ref := req.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	t.step(5, "Fetching %s at %s", req.URL, ref)
+	if err := runInDir(ctx, repo, "git", "init", "-q"); err != nil {
+		return err
+	}
+	if err := runInDir(ctx, repo, "git", "fetch", "-q", "--depth", "1", "--", req.URL, ref); err != nil {
+		return err
+	}
+	if err := runInDir(ctx, repo, "git", "checkout", "-q", "FETCH_HEAD"); err != nil {
+		return err
+	}
+	if err := rejectSymlinks(repo); err != nil {
+		return err
+	}
+
+	for i, step := range req.Build {
+		t.step(30+20*i/len(req.Build), "Build step %d: %s", i+1, step.Type)
+		switch step.Type {
+		case "copy":
+			from, err := syncTargetPath(repo, step.From)
+			if err != nil {
+				return err
+			}
+			to, err := syncTargetPath(repo, step.To)
+			if err != nil {
+				return err
+			}
+			if err := copyDir(from, to); err != nil {
+				return fmt.Errorf("copy %s: %w", step.From, err)
+			}
+		case "regolith":
+			args := []string{"run"}
+			if step.Profile != "" {
+				args = append(args, step.Profile)
+			}
+			if err := runInDir(ctx, repo, "regolith", args...); err != nil {
+				return err
+			}
+		}
+	}
+
+	packs := req.Packs
+	if len(packs) == 0 {
+		dirs, err := findPackDirs(repo)
+		if err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			rel, _ := filepath.Rel(repo, dir)
+			packs = append(packs, GitPack{Path: filepath.ToSlash(rel)})
+		}
+	}
+	if len(packs) == 0 {
+		return errors.New("no packs found in repository")
+	}
+	for i, p := range packs {
+		dir := repo
+		if p.Path != "." {
+			if dir, err = syncTargetPath(repo, p.Path); err != nil {
+				return err
+			}
+		}
+		m, kind, err := readPackManifest(dir)
+		if err != nil {
+			return err
+		}
+		if p.Type != "" {
+			kind = p.Type
+		}
+		if kind == "" {
+			return fmt.Errorf("%s: cannot tell behavior from resource pack; declare its type", p.Path)
+		}
+		t.step(60+35*i/len(packs), "Installing %s pack %s (%s)", kind, p.Path, m.Header.UUID)
+		if _, err := installPackDir(dir, kind, m.Header.UUID); err != nil {
+			return fmt.Errorf("installing %s: %w", p.Path, err)
+		}
+		if req.Activate {
+			if _, err := setPackActivation(kind, m.Header.UUID, m.Header.Version); err != nil {
+				return fmt.Errorf("activating %s: %w", p.Path, err)
+			}
+		}
+	}
+	t.step(100, "Installed %d packs", len(packs))
+	return nil
+}
+
+// installFromGitHandler starts installing packs from a Git repository.
+func installFromGitHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req GitInstallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "git is not installed")
+		return
+	}
+	t := startTask("git-install", func(t *Task) error { return runGitInstall(t, req) })
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const groupsStateFile = "groups.json"
+
+// PermissionGroup maps a role to the API macros its members may run and the
+// in-game tags they carry.
+type PermissionGroup struct {
+	Name          string   `json:"name"`
+	Tags          []string `json:"tags"`
+	AllowedMacros []string `json:"allowed_macros"` // custom command names; "*" allows all
+}
+
+// groupState is the persisted group configuration.
+type groupState struct {
+	Groups  []PermissionGroup   `json:"groups"`
+	Members map[string][]string `json:"members"` // player -> group names
+	// Tags are set on players directly rather than through a group, and
+	// TagRemovals are removed tags not yet taken off an offline player.
+	Tags        map[string][]string `json:"tags,omitempty"`
+	TagRemovals map[string][]string `json:"tag_removals,omitempty"`
+}
+
+var (
+	groups      = groupState{Groups: []PermissionGroup{}, Members: map[string][]string{}, Tags: map[string][]string{}, TagRemovals: map[string][]string{}}
+	groupsMutex sync.RWMutex
+)
+
+func (g *PermissionGroup) validate() error {
+	if !validName(g.Name) || strings.Contains(g.Name, " ") {
+		return errors.New("invalid group name")
+	}
+	for _, t := range g.Tags {
+		if t == "" || strings.ContainsAny(t, " \"") {
+			return errors.New("invalid tag " + t)
+		}
+	}
+	if g.Tags == nil {
+		g.Tags = []string{}
+	}
+	if g.AllowedMacros == nil {
+		g.AllowedMacros = []string{}
+	}
+	return nil
+}
+
+func saveGroups() {
+	if err := saveState(groupsStateFile, groups); err != nil {
+		log.Printf("Error saving permission groups: %v", err)
+	}
+}
+
+// findGroup returns the index of the named group, or -1. Callers must hold groupsMutex.
+func findGroup(name string) int {
+	for i, g := range groups.Groups {
+		if g.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// playerMayRunMacro reports whether any of the player's groups allows the macro.
+func playerMayRunMacro(player, macro string) bool {
+	groupsMutex.RLock()
+	defer groupsMutex.RUnlock()
+	for _, name := range groups.Members[player] {
+		i := findGroup(name)
+		if i < 0 {
+			continue
+		}
+		for _, m := range groups.Groups[i].AllowedMacros {
+			if m == "*" || m == macro {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wantedTags returns the tags the player should carry, from their groups
+// and set directly. Callers must hold groupsMutex.
+func wantedTags(player string) map[string]bool {
+	want := map[string]bool{}
+	for _, name := range groups.Members[player] {
+		if i := findGroup(name); i >= 0 {
+			for _, t := range groups.Groups[i].Tags {
+				want[t] = true
+			}
+		}
+	}
+	for _, t := range groups.Tags[player] {
+		want[t] = true
+	}
+	return want
+}
+
+// syncPlayerTags adds the tags of the player's groups and their own tags,
+// and removes managed tags they should no longer carry.
+func syncPlayerTags(player string) {
+	groupsMutex.Lock()
+	want := wantedTags(player)
+	managed := map[string]bool{}
+	for _, g := range groups.Groups {
+		for _, t := range g.Tags {
+			managed[t] = true
+		}
+	}
+	for _, tags := range groups.Tags {
+		for _, t := range tags {
+			managed[t] = true
+		}
+	}
+	removals := groups.TagRemovals[player]
+	for _, t := range removals {
+		managed[t] = true
+	}
+	groupsMutex.Unlock()
+
+	target := quotePlayer(player)
+	for t := range managed {
+		op := "remove"
+		if want[t] {
+			op = "add"
+		}
+		if err := sendServerCommand("tag " + target + " " + op + " " + t); err != nil {
+			log.Printf("Failed to sync tag %s for %s: %v", t, player, err)
+			return
+		}
+	}
+	if len(removals) > 0 {
+		groupsMutex.Lock()
+		left := groups.TagRemovals[player]
+		for _, t := range removals {
+			left = removeString(left, t)
+		}
+		if len(left) == 0 {
+			delete(groups.TagRemovals, player)
+		} else {
+			groups.TagRemovals[player] = left
+		}
+		saveGroups()
+		groupsMutex.Unlock()
+	}
+}
+
+// syncOnlinePlayerTags syncs tags for every known online player.
+func syncOnlinePlayerTags() int {
+	queueMutex.Lock()
+	players := make([]string, 0, len(onlineSet))
+	for p := range onlineSet {
+		players = append(players, p)
+	}
+	queueMutex.Unlock()
+	for _, p := range players {
+		syncPlayerTags(p)
+	}
+	return len(players)
+}
+
+// groupsHandler lists (GET) or creates (POST) groups.
+func groupsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		groupsMutex.RLock()
+		defer groupsMutex.RUnlock()
+		writeJSONResponse(w, http.StatusOK, groups)
+	case http.MethodPost:
+		var g PermissionGroup
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := g.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		groupsMutex.Lock()
+		defer groupsMutex.Unlock()
+		if findGroup(g.Name) >= 0 {
+			writeJSONError(w, http.StatusConflict, "Group already exists")
+			return
+		}
+		groups.Groups = append(groups.Groups, g)
+		saveGroups()
+		writeJSONResponse(w, http.StatusCreated, g)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// groupHandler serves /groups/{name}, /groups/{name}/members/{player} and
+// POST /groups/sync.
+func groupHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/groups/"), "/", 3)
+	name := parts[0]
+
+	if name == "sync" && len(parts) == 1 {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		n := syncOnlinePlayerTags()
+		writeJSONResponse(w, http.StatusOK, map[string]int{"synced_players": n})
+		return
+	}
+
+	if len(parts) == 3 && parts[1] == "members" {
+		player, err := url.PathUnescape(parts[2])
+		if err != nil || !validPlayerName(player) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid player")
+			return
+		}
+		groupMemberHandler(w, r, name, player)
+		return
+	}
+	if len(parts) != 1 {
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	groupsMutex.Lock()
+	defer groupsMutex.Unlock()
+	i := findGroup(name)
+	if i < 0 {
+		writeJSONError(w, http.StatusNotFound, "Group not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, groups.Groups[i])
+	case http.MethodPut:
+		var g PermissionGroup
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		g.Name = name
+		if err := g.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		groups.Groups[i] = g
+		saveGroups()
+		writeJSONResponse(w, http.StatusOK, g)
+	case http.MethodDelete:
+		groups.Groups = append(groups.Groups[:i], groups.Groups[i+1:]...)
+		for player, names := range groups.Members {
+			groups.Members[player] = removeString(names, name)
+		}
+		saveGroups()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Group deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// groupMemberHandler adds (PUT) or removes (DELETE) a player from a group and
+// syncs their tags.
+func groupMemberHandler(w http.ResponseWriter, r *http.Request, name, player string) {
+	groupsMutex.Lock()
+	if findGroup(name) < 0 {
+		groupsMutex.Unlock()
+		writeJSONError(w, http.StatusNotFound, "Group not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		groups.Members[player] = append(removeString(groups.Members[player], name), name)
+	case http.MethodDelete:
+		groups.Members[player] = removeString(groups.Members[player], name)
+		if len(groups.Members[player]) == 0 {
+			delete(groups.Members, player)
+		}
+	default:
+		groupsMutex.Unlock()
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	saveGroups()
+	groupsMutex.Unlock()
+
+	queueMutex.Lock()
+	_, online := onlineSet[player]
+	queueMutex.Unlock()
+	if online {
+		syncPlayerTags(player)
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Membership updated"})
+}
+
+// removeString returns list without any occurrence of s.
+func removeString(list []string, s string) []string {
+	out := list[:0:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
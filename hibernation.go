@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const hibernationCheckInterval = 30 * time.Second
+
+// Hibernation states.
+const (
+	hibernationAwake    = "awake"
+	hibernationStopping = "stopping"
+	hibernationAsleep   = "asleep"
+	hibernationWaking   = "waking"
+)
+
+// HibernationStatus describes the idle hibernation state machine.
+type HibernationStatus struct {
+	Enabled     bool      `json:"enabled"`
+	State       string    `json:"state"`
+	IdleAfter   string    `json:"idle_after,omitempty"`
+	IdleSince   time.Time `json:"idle_since,omitempty"`
+	Players     int       `json:"players"`
+	LastBackup  string    `json:"last_backup,omitempty"`
+	AsleepSince time.Time `json:"asleep_since,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+var (
+	hibernation      = HibernationStatus{State: hibernationAwake}
+	hibernationMutex sync.Mutex
+	// hibernationListener holds the game port while the server sleeps.
+	hibernationListener net.PacketConn
+)
+
+// hibernateAfter returns the configured idle period, or 0 when disabled.
+func hibernateAfter() time.Duration {
+	v := os.Getenv(hibernateAfterEnv)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, hibernation disabled", hibernateAfterEnv, v)
+		return 0
+	}
+	return d
+}
+
+// startHibernationLoop watches the player count and puts the server to sleep
+// after the configured idle period.
+func startHibernationLoop() {
+	after := hibernateAfter()
+	if after == 0 {
+		return
+	}
+	if !canStartServer() {
+		log.Printf("Hibernation disabled: %s is required to wake the server", startCommandEnv)
+		return
+	}
+	hibernationMutex.Lock()
+	hibernation.Enabled = true
+	hibernation.IdleAfter = after.String()
+	hibernationMutex.Unlock()
+	log.Printf("Hibernation enabled after %s without players", after)
+
+	go func() {
+		ticker := time.NewTicker(hibernationCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkHibernation(after)
+		}
+	}()
+}
+
+// checkHibernation samples the player count and hibernates when idle long enough.
+func checkHibernation(after time.Duration) {
+	hibernationMutex.Lock()
+	if hibernation.State != hibernationAwake {
+		hibernationMutex.Unlock()
+		return
+	}
+	pong, err := pingServer(gameAddr(), 2*time.Second)
+	if err != nil {
+		// The server may be restarting; do not count this as idle time.
+		hibernation.IdleSince = time.Time{}
+		hibernationMutex.Unlock()
+		return
+	}
+	hibernation.Players = pong.Players
+	if pong.Players > 0 {
+		hibernation.IdleSince = time.Time{}
+		hibernationMutex.Unlock()
+		return
+	}
+	if hibernation.IdleSince.IsZero() {
+		hibernation.IdleSince = time.Now()
+	}
+	idle := time.Since(hibernation.IdleSince)
+	if idle < after {
+		hibernationMutex.Unlock()
+		return
+	}
+	hibernation.State = hibernationStopping
+	hibernationMutex.Unlock()
+
+	log.Printf("No players for %s, hibernating server", idle.Round(time.Second))
+	if err := hibernate(pong.MOTD); err != nil {
+		log.Printf("Hibernation failed: %v", err)
+		hibernationMutex.Lock()
+		hibernation.State = hibernationAwake
+		hibernation.LastError = err.Error()
+		hibernationMutex.Unlock()
+	}
+}
+
+// hibernate stops the server, takes a final backup and holds the game port.
+func hibernate(motd string) error {
+	if err := stopServer(); err != nil {
+		return fmt.Errorf("failed to stop server: %w", err)
+	}
+	if err := waitForServerDown(2 * time.Minute); err != nil {
+		return err
+	}
+	backup, err := createWorldBackup()
+	if err != nil {
+		log.Printf("Final backup before hibernation failed: %v", err)
+	}
+
+	// Listen on all interfaces so pings from players reach us.
+	_, port, err := net.SplitHostPort(gameAddr())
+	if err != nil {
+		return fmt.Errorf("invalid game address: %w", err)
+	}
+	conn, err := net.ListenPacket("udp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to hold game port: %w", err)
+	}
+	hibernationMutex.Lock()
+	hibernation.State = hibernationAsleep
+	hibernation.AsleepSince = time.Now()
+	hibernation.LastBackup = backup
+	hibernation.LastError = ""
+	hibernationListener = conn
+	hibernationMutex.Unlock()
+	log.Printf("Server hibernating; holding UDP port %s until the next ping", port)
+
+	go holdGamePort(conn, motd)
+	return nil
+}
+
+// holdGamePort answers server-list pings with a "sleeping" advertisement and
+// wakes the server on the first ping.
+func holdGamePort(conn net.PacketConn, motd string) {
+	if motd == "" {
+		motd = "Bedrock Server"
+	}
+	guid := rand.Int63()
+	advert := fmt.Sprintf("MCPE;%s (sleeping - refresh to wake);0;;0;0;%d;Sleeping;Survival;1;;;", motd, guid)
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		pingTime, ok := isUnconnectedPing(buf[:n])
+		if !ok {
+			continue
+		}
+		conn.WriteTo(encodePong(pingTime, guid, advert), addr)
+		log.Printf("Wake ping from %s", addr)
+		go wakeServer()
+		return
+	}
+}
+
+// wakeServer releases the game port and starts the server.
+func wakeServer() error {
+	hibernationMutex.Lock()
+	if hibernation.State != hibernationAsleep {
+		hibernationMutex.Unlock()
+		return nil
+	}
+	hibernation.State = hibernationWaking
+	if hibernationListener != nil {
+		hibernationListener.Close()
+		hibernationListener = nil
+	}
+	hibernationMutex.Unlock()
+
+	err := startServer()
+	hibernationMutex.Lock()
+	defer hibernationMutex.Unlock()
+	hibernation.IdleSince = time.Time{}
+	hibernation.AsleepSince = time.Time{}
+	if err != nil {
+		log.Printf("Failed to wake server: %v", err)
+		hibernation.LastError = err.Error()
+		hibernation.State = hibernationAwake
+		return err
+	}
+	hibernation.State = hibernationAwake
+	log.Printf("Server woken from hibernation")
+	return nil
+}
+
+// hibernationHandler reports the hibernation state.
+func hibernationHandler(w http.ResponseWriter, r *http.Request) {
+	hibernationMutex.Lock()
+	defer hibernationMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, hibernation)
+}
+
+// hibernationWakeHandler wakes a hibernating server on demand.
+func hibernationWakeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if err := wakeServer(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to wake server")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Server awake"})
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const homesStateFile = "homes.json"
+
+// Scopes for player tokens managing their own homes.
+const (
+	scopeHomesRead  = "homes:read"
+	scopeHomesWrite = "homes:write"
+)
+
+func init() {
+	playerScopes[scopeHomesRead] = true
+	playerScopes[scopeHomesWrite] = true
+}
+
+// Home is a saved teleport destination.
+type Home struct {
+	Name      string    `json:"name"`
+	X         float64   `json:"x"`
+	Y         float64   `json:"y"`
+	Z         float64   `json:"z"`
+	Dimension string    `json:"dimension,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HomesConfig sets home limits and the teleport cooldown.
+type HomesConfig struct {
+	DefaultLimit    int            `json:"default_limit"`
+	GroupLimits     map[string]int `json:"group_limits"`
+	CooldownSeconds int            `json:"cooldown_seconds"`
+}
+
+type homesState struct {
+	Config HomesConfig                `json:"config"`
+	Homes  map[string]map[string]Home `json:"homes"` // player -> name -> home
+}
+
+var (
+	homes = homesState{
+		Config: HomesConfig{DefaultLimit: 3, GroupLimits: map[string]int{}, CooldownSeconds: 60},
+		Homes:  map[string]map[string]Home{},
+	}
+	homeCooldowns = make(map[string]time.Time)
+	homesMutex    sync.Mutex
+)
+
+func saveHomes() {
+	if err := saveState(homesStateFile, homes); err != nil {
+		log.Printf("Error saving homes: %v", err)
+	}
+}
+
+// homeLimit returns the largest limit among the player's groups, or the default.
+func homeLimit(player string) int {
+	limit := homes.Config.DefaultLimit
+	groupsMutex.RLock()
+	defer groupsMutex.RUnlock()
+	for _, g := range groups.Members[player] {
+		if l, ok := homes.Config.GroupLimits[g]; ok && l > limit {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// setHome saves a home at pos, or at the player's current bridge position when pos is nil.
+func setHome(player, name string, pos *Home) (Home, error) {
+	if !validName(name) || strings.Contains(name, " ") {
+		return Home{}, errors.New("invalid home name")
+	}
+	if pos == nil {
+		bridgeMutex.RLock()
+		cur, ok := bridgePositions[player]
+		bridgeMutex.RUnlock()
+		if !ok {
+			return Home{}, errors.New("player position unknown: the script bridge must be installed")
+		}
+		pos = &Home{X: cur.X, Y: cur.Y, Z: cur.Z, Dimension: cur.Dimension}
+	}
+	home := Home{Name: name, X: pos.X, Y: pos.Y, Z: pos.Z, Dimension: pos.Dimension, CreatedAt: time.Now()}
+
+	homesMutex.Lock()
+	defer homesMutex.Unlock()
+	list := homes.Homes[player]
+	if list == nil {
+		list = map[string]Home{}
+		homes.Homes[player] = list
+	}
+	if _, exists := list[name]; !exists && len(list) >= homeLimit(player) {
+		return Home{}, fmt.Errorf("home limit of %d reached", homeLimit(player))
+	}
+	list[name] = home
+	saveHomes()
+	return home, nil
+}
+
+// deleteHome removes a player's home.
+func deleteHome(player, name string) error {
+	homesMutex.Lock()
+	defer homesMutex.Unlock()
+	if _, ok := homes.Homes[player][name]; !ok {
+		return errors.New("home not found")
+	}
+	delete(homes.Homes[player], name)
+	if len(homes.Homes[player]) == 0 {
+		delete(homes.Homes, player)
+	}
+	saveHomes()
+	return nil
+}
+
+// teleportHome teleports the player to a home, enforcing the cooldown.
+func teleportHome(player, name string) error {
+	homesMutex.Lock()
+	home, ok := homes.Homes[player][name]
+	if !ok {
+		homesMutex.Unlock()
+		return errors.New("home not found")
+	}
+	cooldown := time.Duration(homes.Config.CooldownSeconds) * time.Second
+	if wait := cooldown - time.Since(homeCooldowns[player]); wait > 0 {
+		homesMutex.Unlock()
+		return fmt.Errorf("teleport on cooldown for %d more seconds", int(wait.Seconds())+1)
+	}
+	homeCooldowns[player] = time.Now()
+	homesMutex.Unlock()
+
+	cmd := fmt.Sprintf("tp %s %.2f %.2f %.2f", quotePlayer(player), home.X, home.Y, home.Z)
+	if dim := strings.TrimPrefix(home.Dimension, "minecraft:"); dim != "" {
+		cmd = "execute in " + dim + " run " + cmd
+	}
+	return sendServerCommand(cmd)
+}
+
+// listHomes returns a player's homes.
+func listHomes(player string) []Home {
+	homesMutex.Lock()
+	defer homesMutex.Unlock()
+	list := []Home{}
+	for _, h := range homes.Homes[player] {
+		list = append(list, h)
+	}
+	return list
+}
+
+// tellPlayer sends a private message to a player.
+func tellPlayer(player, text string) {
+	if err := sendServerCommand(tellrawCommand(quotePlayer(player), text)); err != nil {
+		log.Printf("Failed to message %s: %v", player, err)
+	}
+}
+
+// handleHomeChatCommand handles !sethome, !home, !delhome and !homes.
+// It reports whether the message was a home command.
+func handleHomeChatCommand(player, message string) bool {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return false
+	}
+	name := "home"
+	if len(fields) > 1 {
+		name = fields[1]
+	}
+	switch fields[0] {
+	case "!sethome":
+		if _, err := setHome(player, name, nil); err != nil {
+			tellPlayer(player, "Could not set home: "+err.Error())
+		} else {
+			tellPlayer(player, "Home "+name+" set")
+		}
+	case "!home":
+		if err := teleportHome(player, name); err != nil {
+			tellPlayer(player, "Could not teleport: "+err.Error())
+		}
+	case "!delhome":
+		if err := deleteHome(player, name); err != nil {
+			tellPlayer(player, "Could not delete home: "+err.Error())
+		} else {
+			tellPlayer(player, "Home "+name+" deleted")
+		}
+	case "!homes":
+		names := []string{}
+		for _, h := range listHomes(player) {
+			names = append(names, h.Name)
+		}
+		tellPlayer(player, fmt.Sprintf("Homes (%d/%d): %s", len(names), homeLimit(player), strings.Join(names, ", ")))
+	default:
+		return false
+	}
+	return true
+}
+
+// serveHomes implements the home routes for a resolved player. rest is the
+// path after the player segment: "", "{name}" or "{name}/teleport".
+func serveHomes(w http.ResponseWriter, r *http.Request, player, rest string) {
+	name, action, _ := strings.Cut(rest, "/")
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"player": player, "homes": listHomes(player), "limit": homeLimit(player)})
+	case name != "" && action == "" && r.Method == http.MethodPut:
+		var pos *Home
+		if r.ContentLength != 0 {
+			pos = &Home{}
+			if err := json.NewDecoder(r.Body).Decode(pos); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "Invalid request")
+				return
+			}
+		}
+		home, err := setHome(player, name, pos)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, home)
+	case name != "" && action == "" && r.Method == http.MethodDelete:
+		if err := deleteHome(player, name); err != nil {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Home deleted"})
+	case name != "" && action == "teleport" && r.Method == http.MethodPost:
+		if err := teleportHome(player, name); err != nil {
+			writeJSONError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Teleported"})
+	default:
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// homesHandler serves /homes/{player}[/{name}[/teleport]] for admins.
+func homesHandler(w http.ResponseWriter, r *http.Request) {
+	rawPlayer, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/homes/"), "/")
+	player, err := url.PathUnescape(rawPlayer)
+	if err != nil || !validPlayerName(player) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid player")
+		return
+	}
+	serveHomes(w, r, player, rest)
+}
+
+// meHomesHandler serves /me/homes[/{name}[/teleport]] for player tokens.
+func meHomesHandler(w http.ResponseWriter, r *http.Request) {
+	scope := scopeHomesWrite
+	if r.Method == http.MethodGet {
+		scope = scopeHomesRead
+	}
+	requirePlayerToken(scope, func(w http.ResponseWriter, r *http.Request, tok PlayerToken) {
+		rest := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/me/homes"), "/")
+		serveHomes(w, r, tok.Player, rest)
+	})(w, r)
+}
+
+// homesConfigHandler returns (GET) or replaces (PUT) the homes configuration.
+func homesConfigHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		homesMutex.Lock()
+		defer homesMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, homes.Config)
+	case http.MethodPut:
+		var cfg HomesConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil || cfg.DefaultLimit < 0 || cfg.CooldownSeconds < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if cfg.GroupLimits == nil {
+			cfg.GroupLimits = map[string]int{}
+		}
+		homesMutex.Lock()
+		homes.Config = cfg
+		saveHomes()
+		homesMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, cfg)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hooksStateFile  = "hooks.json"
+	hookHistoryLen  = 200
+	maxHookBodySize = 1 << 20
+)
+
+// Inbound hook action types.
+const (
+	hookActionMacro      = "macro"
+	hookActionCommand    = "command"
+	hookActionBackup     = "backup"
+	hookActionRestart    = "restart"
+	hookActionWorldReset = "world_reset"
+)
+
+var hookPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// HookAction is one thing an inbound hook does. Command templates may use
+// {path.to.field} placeholders filled from the JSON payload.
+type HookAction struct {
+	Type    string `json:"type"`
+	Macro   string `json:"macro,omitempty"`
+	Command string `json:"command,omitempty"`
+	Job     string `json:"job,omitempty"` // world reset job id
+}
+
+// InboundHook maps an external event to actions. Match lists payload fields
+// that must equal the given values for the hook to fire.
+type InboundHook struct {
+	Name          string            `json:"name"`
+	Secret        string            `json:"secret,omitempty"`
+	Match         map[string]string `json:"match,omitempty"`
+	Actions       []HookAction      `json:"actions"`
+	LastTriggered time.Time         `json:"last_triggered,omitempty"`
+}
+
+// HookInvocation records one delivery to an inbound hook.
+type HookInvocation struct {
+	Hook    string    `json:"hook"`
+	Fired   bool      `json:"fired"`
+	Results []string  `json:"results"`
+	Time    time.Time `json:"time"`
+}
+
+var (
+	inboundHooks = make([]InboundHook, 0)
+	hookHistory  = make([]HookInvocation, 0)
+	hooksMutex   sync.Mutex
+)
+
+func (h *InboundHook) validate() error {
+	if !validName(h.Name) || strings.Contains(h.Name, " ") {
+		return errors.New("invalid name")
+	}
+	if err := validateHookActions(h.Actions); err != nil {
+		return err
+	}
+	if h.Secret == "" {
+		buf := make([]byte, 24)
+		rand.Read(buf)
+		h.Secret = hex.EncodeToString(buf)
+	}
+	return nil
+}
+
+// validateHookActions checks a list of actions shared by hooks and jobs.
+func validateHookActions(actions []HookAction) error {
+	if len(actions) == 0 {
+		return errors.New("at least one action is required")
+	}
+	for _, a := range actions {
+		switch a.Type {
+		case hookActionMacro:
+			if a.Macro == "" {
+				return errors.New("macro actions need a macro name")
+			}
+		case hookActionCommand:
+			if strings.TrimSpace(a.Command) == "" {
+				return errors.New("command actions need a command")
+			}
+		case hookActionWorldReset:
+			if a.Job == "" {
+				return errors.New("world_reset actions need a job id")
+			}
+		case hookActionBackup, hookActionRestart:
+		default:
+			return fmt.Errorf("unknown action %q", a.Type)
+		}
+	}
+	return nil
+}
+
+// redacted hides the secret for listing.
+func (h InboundHook) redacted() InboundHook {
+	h.Secret = ""
+	return h
+}
+
+func saveHooks() {
+	if err := saveState(hooksStateFile, inboundHooks); err != nil {
+		log.Printf("Error saving inbound hooks: %v", err)
+	}
+	configChanged()
+}
+
+// findHook returns the index of the named hook, or -1. Callers must hold hooksMutex.
+func findHook(name string) int {
+	for i, h := range inboundHooks {
+		if h.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// verifyHookSignature checks an HMAC-SHA256 of the body, accepted either as
+// X-Hub-Signature-256 ("sha256=<hex>", as GitHub and others send it) or as
+// a bare hex X-Signature.
+func verifyHookSignature(r *http.Request, body []byte, secret string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	sig := r.Header.Get("X-Hub-Signature-256")
+	if sig == "" {
+		sig = r.Header.Get("X-Signature")
+	}
+	sig = strings.TrimPrefix(sig, "sha256=")
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(sig)))
+}
+
+// lookupJSONPath follows a dotted path into a decoded JSON value.
+func lookupJSONPath(v interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = m[key]; !ok {
+			return "", false
+		}
+	}
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case nil:
+		return "", true
+	default:
+		b, _ := json.Marshal(t)
+		return string(b), true
+	}
+}
+
+// runHookActions performs a hook's actions and describes each outcome.
+func runHookActions(h InboundHook, payload interface{}) []string {
+	var results []string
+	for _, a := range h.Actions {
+		var err error
+		switch a.Type {
+		case hookActionMacro:
+			cmd, ok := customCommandByName(a.Macro)
+			if !ok {
+				err = fmt.Errorf("macro %q not found", a.Macro)
+				break
+			}
+			err = sendServerCommand(cmd)
+		case hookActionCommand:
+			cmd := hookPlaceholder.ReplaceAllStringFunc(a.Command, func(m string) string {
+				v, _ := lookupJSONPath(payload, m[1:len(m)-1])
+				return sanitizeStreamText(v)
+			})
+			err = sendServerCommand(cmd)
+		case hookActionBackup:
+			var path string
+			if path, err = backupAndPrune(); err == nil {
+				results = append(results, "backup: "+path)
+				continue
+			}
+		case hookActionRestart:
+			err = restartServer()
+		case hookActionWorldReset:
+			err = executeWorldResetJob(a.Job)
+		}
+		if err != nil {
+			results = append(results, a.Type+": "+err.Error())
+		} else {
+			results = append(results, a.Type+": ok")
+		}
+	}
+	return results
+}
+
+// hooksHandler lists hooks (GET) or creates one (POST). The secret is only
+// returned on creation.
+func hooksHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		hooksMutex.Lock()
+		defer hooksMutex.Unlock()
+		list := make([]InboundHook, 0, len(inboundHooks))
+		for _, h := range inboundHooks {
+			list = append(list, h.redacted())
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"hooks": list, "history": hookHistory})
+	case http.MethodPost:
+		var h InboundHook
+		if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := h.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		hooksMutex.Lock()
+		defer hooksMutex.Unlock()
+		if findHook(h.Name) >= 0 {
+			writeJSONError(w, http.StatusConflict, "Hook already exists")
+			return
+		}
+		inboundHooks = append(inboundHooks, h)
+		saveHooks()
+		writeJSONResponse(w, http.StatusCreated, h)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// hookHandler serves /hooks/{name}: POST delivers an event (HMAC-verified),
+// while GET, PUT and DELETE manage the hook and require admin credentials.
+func hookHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/hooks/")
+	if r.Method != http.MethodPost {
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { manageHook(w, r, name) })(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHookBodySize))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to read body")
+		return
+	}
+	hooksMutex.Lock()
+	i := findHook(name)
+	if i < 0 {
+		hooksMutex.Unlock()
+		writeJSONError(w, http.StatusNotFound, "Hook not found")
+		return
+	}
+	h := inboundHooks[i]
+	hooksMutex.Unlock()
+	if !verifyHookSignature(r, body, h.Secret) {
+		writeJSONError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	var payload interface{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Payload must be JSON")
+			return
+		}
+	}
+	inv := HookInvocation{Hook: name, Results: []string{}, Time: time.Now()}
+	for path, want := range h.Match {
+		if got, ok := lookupJSONPath(payload, path); !ok || got != want {
+			inv.Results = append(inv.Results, fmt.Sprintf("skipped: %s does not match", path))
+		}
+	}
+	if len(inv.Results) == 0 {
+		inv.Fired = true
+		inv.Results = runHookActions(h, payload)
+		log.Printf("Inbound hook %s fired: %v", name, inv.Results)
+	}
+
+	hooksMutex.Lock()
+	if i := findHook(name); i >= 0 && inv.Fired {
+		inboundHooks[i].LastTriggered = inv.Time
+		saveHooks()
+	}
+	hookHistory = append(hookHistory, inv)
+	if len(hookHistory) > hookHistoryLen {
+		hookHistory = hookHistory[len(hookHistory)-hookHistoryLen:]
+	}
+	hooksMutex.Unlock()
+
+	status := http.StatusOK
+	if !inv.Fired {
+		status = http.StatusAccepted
+	}
+	writeJSONResponse(w, status, inv)
+}
+
+// manageHook serves GET, PUT (upsert) and DELETE for one hook.
+func manageHook(w http.ResponseWriter, r *http.Request, name string) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	i := findHook(name)
+	switch r.Method {
+	case http.MethodGet:
+		if i < 0 {
+			writeJSONError(w, http.StatusNotFound, "Hook not found")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, inboundHooks[i].redacted())
+	case http.MethodPut:
+		var h InboundHook
+		if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		h.Name = name
+		if h.Secret == "" && i >= 0 {
+			h.Secret = inboundHooks[i].Secret
+		}
+		if err := h.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		status := http.StatusOK
+		if i < 0 {
+			inboundHooks = append(inboundHooks, h)
+			status = http.StatusCreated
+		} else {
+			h.LastTriggered = inboundHooks[i].LastTriggered
+			inboundHooks[i] = h
+		}
+		saveHooks()
+		writeJSONResponse(w, status, h)
+	case http.MethodDelete:
+		if i < 0 {
+			writeJSONError(w, http.StatusNotFound, "Hook not found")
+			return
+		}
+		inboundHooks = append(inboundHooks[:i], inboundHooks[i+1:]...)
+		saveHooks()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Hook deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
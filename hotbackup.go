@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	saveQueryTimeout = time.Minute
+	saveQueryReady   = "Files are now ready to be copied"
+)
+
+// saveQueryEntry matches one "path:length" item of the save query list.
+var saveQueryEntry = regexp.MustCompile(`(.+?):(\d+)(?:, |$)`)
+
+// saveQueryFile is a file the server reported as safe to copy, and how
+// many bytes of it belong to the snapshot.
+type saveQueryFile struct {
+	Path   string
+	Length int64
+}
+
+// parseSaveQueryFiles parses the file list printed after "save query".
+func parseSaveQueryFiles(line string) ([]saveQueryFile, error) {
+	line = strings.TrimSpace(line)
+	matches := saveQueryEntry.FindAllStringSubmatch(line, -1)
+	if len(matches) == 0 {
+		return nil, errors.New("empty save query file list")
+	}
+	files := make([]saveQueryFile, 0, len(matches))
+	for _, m := range matches {
+		n, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, saveQueryFile{Path: strings.TrimSpace(m[1]), Length: n})
+	}
+	return files, nil
+}
+
+// querySaveFiles polls "save query" after "save hold" until the server
+// reports the files to copy, reading the answer from the server log.
+func querySaveFiles() ([]saveQueryFile, error) {
+	ch := make(chan ConsoleMessage, 256)
+	consoleMutex.Lock()
+	consoleSubscribers[ch] = struct{}{}
+	consoleMutex.Unlock()
+	defer func() {
+		consoleMutex.Lock()
+		delete(consoleSubscribers, ch)
+		consoleMutex.Unlock()
+	}()
+
+	deadline := time.After(saveQueryTimeout)
+	for {
+		if err := sendServerCommand("save query"); err != nil {
+			return nil, err
+		}
+		retry := time.After(time.Second)
+		ready := false
+	wait:
+		for {
+			select {
+			case msg := <-ch:
+				if ready {
+					return parseSaveQueryFiles(msg.Line)
+				}
+				if i := strings.Index(msg.Line, saveQueryReady); i >= 0 {
+					ready = true
+					// Some builds print the list on the same line.
+					if rest := strings.TrimLeft(msg.Line[i+len(saveQueryReady):], ". "); rest != "" {
+						return parseSaveQueryFiles(rest)
+					}
+				}
+			case <-retry:
+				if !ready {
+					break wait
+				}
+			case <-deadline:
+				return nil, errors.New("timed out waiting for save query")
+			}
+		}
+	}
+}
+
+// copyTruncated copies the first length bytes of src to dst.
+func copyTruncated(src, dst string, length int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(out, in, length); err != nil {
+		out.Close()
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// copyWorldConsistent copies the world into dst. While the server runs it
+// holds saves and copies exactly the files and lengths "save query"
+// reports, which needs the server log; without it, it falls back to a
+// plain copy under "save hold".
+func copyWorldConsistent(worldFolder, dst string) error {
+	if !serverUp() {
+		return copyDir(worldFolder, dst)
+	}
+	if err := sendServerCommand("save hold"); err != nil {
+		return fmt.Errorf("save hold: %w", err)
+	}
+	defer sendServerCommand("save resume")
+	if os.Getenv(serverLogEnv) == "" {
+		log.Printf("Copying the live world without save query: set %s for consistent hot copies", serverLogEnv)
+		return copyDir(worldFolder, dst)
+	}
+	files, err := querySaveFiles()
+	if err != nil {
+		return err
+	}
+	level := filepath.Base(worldFolder)
+	listed := make(map[string]bool, len(files))
+	for _, f := range files {
+		rel, ok := strings.CutPrefix(filepath.ToSlash(f.Path), level+"/")
+		if !ok {
+			return fmt.Errorf("save query listed %s outside %s", f.Path, level)
+		}
+		listed[rel] = true
+		src, err := syncTargetPath(worldFolder, rel)
+		if err != nil {
+			return err
+		}
+		target, err := syncTargetPath(dst, rel)
+		if err != nil {
+			return err
+		}
+		if err := copyTruncated(src, target, f.Length); err != nil {
+			return err
+		}
+	}
+	// Pack lists and other metadata outside the database are not part of
+	// the save and are copied whole.
+	return filepath.Walk(worldFolder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(worldFolder, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if rel == "db" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if listed[rel] || !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyTruncated(path, filepath.Join(dst, rel), info.Size())
+	})
+}
+
+// createHotBackup backs up the world, taking a consistent copy first when
+// the server is running.
+func createHotBackup() (string, error) {
+	if !serverUp() {
+		return createWorldBackup()
+	}
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return "", err
+	}
+	staging := filepath.Join(backupsDir, ".staging", filepath.Base(worldFolder))
+	os.RemoveAll(staging)
+	defer os.RemoveAll(filepath.Dir(staging))
+	if err := copyWorldConsistent(worldFolder, staging); err != nil {
+		return "", err
+	}
+	return backupFolder(staging)
+}
@@ -9,4 +9,4 @@ git clone https://github.com/sordfish/go-bedrock-api.git /app
 cd /app
 
 echo "Running application..."
-go run main.go
+go run .
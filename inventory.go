@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	playerEntityIDsStateFile = "player_entity_ids.json"
+	inventoryQueryTimeout    = 5 * time.Second
+	// playerDataKeyPrefix starts the LevelDB keys of saved player data.
+	playerDataKeyPrefix = "player_server_"
+)
+
+// errStopScan ends a database scan early.
+var errStopScan = errors.New("stop scan")
+
+// enchantmentNames maps Bedrock's numeric enchantment IDs, as saved in
+// item NBT, to the names the script API uses.
+var enchantmentNames = []string{
+	"protection", "fire_protection", "feather_falling", "blast_protection", "projectile_protection",
+	"thorns", "respiration", "depth_strider", "aqua_affinity", "sharpness", "smite",
+	"bane_of_arthropods", "knockback", "fire_aspect", "looting", "efficiency", "silk_touch",
+	"unbreaking", "fortune", "power", "punch", "flame", "infinity", "luck_of_the_sea", "lure",
+	"frost_walker", "mending", "binding", "vanishing", "impaling", "riptide", "loyalty",
+	"channeling", "multishot", "piercing", "quick_charge", "soul_speed", "swift_sneak",
+	"wind_burst", "density", "breach",
+}
+
+// ItemEnchantment is an enchantment on an item.
+type ItemEnchantment struct {
+	ID    string `json:"id"`
+	Level int    `json:"level"`
+}
+
+// InventoryItem is a stack in one slot of a container.
+type InventoryItem struct {
+	Slot         int               `json:"slot"`
+	Item         string            `json:"item"`
+	Count        int               `json:"count"`
+	Damage       int               `json:"damage,omitempty"`
+	Name         string            `json:"name,omitempty"` // custom name
+	Enchantments []ItemEnchantment `json:"enchantments,omitempty"`
+}
+
+// PlayerInventory is a player's items. Source is "bridge" for a live read
+// of an online player, or "world" for the player data the server last
+// saved. The script API cannot read ender chests, so EnderChest always
+// comes from the world and is null when the player has no saved data.
+type PlayerInventory struct {
+	Player     string          `json:"player"`
+	Source     string          `json:"source"`
+	Inventory  []InventoryItem `json:"inventory"`
+	Armor      []InventoryItem `json:"armor"`
+	Offhand    []InventoryItem `json:"offhand"`
+	EnderChest []InventoryItem `json:"enderchest"`
+}
+
+var (
+	// playerEntityIDs maps players to the entity IDs the bridge reports on
+	// join, which is how their saved data is found in the world.
+	playerEntityIDs = map[string]string{}
+	// inventoryWaiters are the requests waiting for the bridge to answer
+	// an inventory query, by lower-cased player name.
+	inventoryWaiters = map[string][]chan json.RawMessage{}
+	inventoryMutex   sync.Mutex
+)
+
+// recordPlayerEntityID remembers the entity ID in a bridge join event.
+func recordPlayerEntityID(ev BridgeEvent) {
+	var data struct {
+		ID string `json:"id"`
+	}
+	if ev.Player == "" || json.Unmarshal(ev.Data, &data) != nil || data.ID == "" {
+		return
+	}
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+	if playerEntityIDs[ev.Player] == data.ID {
+		return
+	}
+	playerEntityIDs[ev.Player] = data.ID
+	if err := saveState(playerEntityIDsStateFile, playerEntityIDs); err != nil {
+		log.Printf("Error saving player entity IDs: %v", err)
+	}
+}
+
+// deliverInventory hands a bridge inventory event to the waiting requests.
+func deliverInventory(ev BridgeEvent) {
+	key := strings.ToLower(ev.Player)
+	inventoryMutex.Lock()
+	waiters := inventoryWaiters[key]
+	delete(inventoryWaiters, key)
+	inventoryMutex.Unlock()
+	for _, ch := range waiters {
+		ch <- ev.Data
+	}
+}
+
+// queryBridgeInventory asks the bridge pack for an online player's
+// inventory through a script event and waits for its answer, which comes
+// with the next event batch.
+func queryBridgeInventory(player string) (*PlayerInventory, error) {
+	key := strings.ToLower(player)
+	ch := make(chan json.RawMessage, 1)
+	inventoryMutex.Lock()
+	inventoryWaiters[key] = append(inventoryWaiters[key], ch)
+	inventoryMutex.Unlock()
+	defer func() {
+		inventoryMutex.Lock()
+		waiters := inventoryWaiters[key]
+		for i, w := range waiters {
+			if w == ch {
+				inventoryWaiters[key] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(inventoryWaiters[key]) == 0 {
+			delete(inventoryWaiters, key)
+		}
+		inventoryMutex.Unlock()
+	}()
+	if err := sendServerCommand("scriptevent sidecar:inventory " + player); err != nil {
+		return nil, err
+	}
+	select {
+	case data := <-ch:
+		if len(data) == 0 || string(data) == "null" {
+			return nil, nil
+		}
+		inv := &PlayerInventory{Player: player, Source: "bridge"}
+		if err := json.Unmarshal(data, inv); err != nil {
+			return nil, err
+		}
+		return inv, nil
+	case <-time.After(inventoryQueryTimeout):
+		return nil, errors.New("timed out waiting for the bridge")
+	}
+}
+
+// nbtItems converts a list of item compounds, leaving out empty slots.
+func nbtItems(v interface{}) []InventoryItem {
+	list, _ := v.([]interface{})
+	items := []InventoryItem{}
+	for _, raw := range list {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["Name"].(string)
+		count, _ := levelInt(m, "Count")
+		if name == "" || count == 0 {
+			continue
+		}
+		item := InventoryItem{Item: name, Count: count}
+		item.Slot, _ = levelInt(m, "Slot")
+		item.Damage, _ = levelInt(m, "Damage")
+		if tag, ok := m["tag"].(map[string]interface{}); ok {
+			// Tools and armour keep their wear in the tag.
+			if d, ok := levelInt(tag, "Damage"); ok {
+				item.Damage = d
+			}
+			if display, ok := tag["display"].(map[string]interface{}); ok {
+				item.Name, _ = display["Name"].(string)
+			}
+			ench, _ := tag["ench"].([]interface{})
+			for _, e := range ench {
+				em, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				id, _ := levelInt(em, "id")
+				lvl, _ := levelInt(em, "lvl")
+				name := strconv.Itoa(id)
+				if id >= 0 && id < len(enchantmentNames) {
+					name = enchantmentNames[id]
+				}
+				item.Enchantments = append(item.Enchantments, ItemEnchantment{ID: name, Level: lvl})
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// findPlayerData returns the saved player data with an entity ID, or an
+// entry with a nil key when there is none.
+func findPlayerData(db *ldbDB, id string) (ldbEntry, map[string]interface{}, error) {
+	var found ldbEntry
+	var root map[string]interface{}
+	err := db.scan([]byte(playerDataKeyPrefix), func(e ldbEntry) error {
+		_, v, err := decodeNBT(bytes.NewReader(e.Value))
+		if err != nil {
+			return nil
+		}
+		m, _ := v.(map[string]interface{})
+		if uid, ok := m["UniqueID"].(int64); ok && strconv.FormatInt(uid, 10) == id {
+			found, root = e, m
+			return errStopScan
+		}
+		return nil
+	})
+	if errors.Is(err, errStopScan) {
+		err = nil
+	}
+	return found, root, err
+}
+
+// playerEntityID returns the entity ID the bridge reported for a player.
+func playerEntityID(player string) (string, bool) {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+	id, ok := playerEntityIDs[player]
+	return id, ok
+}
+
+// readWorldInventory finds a player's saved data in the active world by
+// the entity ID the bridge reported, returning nil when there is none.
+// While the server runs it may compact the database under the reader, so
+// a failed read is retried.
+func readWorldInventory(player string) (*PlayerInventory, error) {
+	id, ok := playerEntityID(player)
+	if !ok {
+		return nil, nil
+	}
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		var found map[string]interface{}
+		db, err := openLDB(filepath.Join(worldFolder, "db"))
+		if err == nil {
+			_, found, err = findPlayerData(db, id)
+		}
+		if err == nil {
+			if found == nil {
+				return nil, nil
+			}
+			return &PlayerInventory{
+				Player:     player,
+				Source:     "world",
+				Inventory:  nbtItems(found["Inventory"]),
+				Armor:      nbtItems(found["Armor"]),
+				Offhand:    nbtItems(found["Offhand"]),
+				EnderChest: nbtItems(found["EnderChestInventory"]),
+			}, nil
+		}
+		if attempt == 2 || !serverUp() {
+			return nil, err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// playerOnline reports whether the bridge or the server log saw the player
+// join and not leave.
+func playerOnline(player string) bool {
+	queueMutex.Lock()
+	_, online := onlineSet[player]
+	queueMutex.Unlock()
+	if online {
+		return true
+	}
+	logSessionsMutex.Lock()
+	defer logSessionsMutex.Unlock()
+	_, online = logSessions[player]
+	return online
+}
+
+// playerInventoryHandler serves GET /players/{name}/inventory. Online
+// players are read live through the bridge, with the ender chest from the
+// world; offline players, or online ones the bridge cannot reach, from the
+// world as the server last saved it. Saved data is found by entity ID, so
+// only players who joined with the bridge installed can be read offline.
+func playerInventoryHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if strings.ContainsAny(player, "\n\r") {
+		writeJSONError(w, http.StatusBadRequest, "Invalid player")
+		return
+	}
+	saved, err := readWorldInventory(player)
+	if err != nil {
+		log.Printf("Error reading %s's saved inventory: %v", player, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read the world's player data")
+		return
+	}
+	if loadBridgeToken() != "" && playerOnline(player) {
+		live, err := queryBridgeInventory(player)
+		if err != nil {
+			log.Printf("Error querying %s's inventory through the bridge: %v", player, err)
+		}
+		if live != nil {
+			if saved != nil {
+				live.EnderChest = saved.EnderChest
+			}
+			writeJSONResponse(w, http.StatusOK, live)
+			return
+		}
+	}
+	if saved == nil {
+		writeJSONError(w, http.StatusNotFound, "No inventory found; offline players must have joined with the bridge installed")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, saved)
+}
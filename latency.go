@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	latencyProbeInterval = 5 * time.Second
+	latencyProbeTimeout  = 2 * time.Second
+	latencyHistory       = 24 * time.Hour
+	// latencyAlertWindow is the span alert thresholds are checked over.
+	latencyAlertWindow = 5 * time.Minute
+)
+
+// LatencySample is one RakNet ping from the sidecar to the game server.
+type LatencySample struct {
+	Time      time.Time `json:"time"`
+	LatencyMS float64   `json:"latency_ms,omitempty"`
+	Lost      bool      `json:"lost,omitempty"`
+}
+
+// LatencyStats summarises the samples in a window.
+type LatencyStats struct {
+	Sent        int     `json:"sent"`
+	Lost        int     `json:"lost"`
+	LossPercent float64 `json:"loss_percent"`
+	MinMS       float64 `json:"min_ms"`
+	AvgMS       float64 `json:"avg_ms"`
+	MaxMS       float64 `json:"max_ms"`
+	P50MS       float64 `json:"p50_ms"`
+	P90MS       float64 `json:"p90_ms"`
+	P95MS       float64 `json:"p95_ms"`
+	P99MS       float64 `json:"p99_ms"`
+}
+
+// LatencyBucket is the history for one minute.
+type LatencyBucket struct {
+	Time time.Time `json:"time"`
+	LatencyStats
+}
+
+var (
+	latencySamples  []LatencySample
+	latencyAlerting = map[string]bool{}
+	latencyMutex    sync.Mutex
+)
+
+// latencyThresholds returns the p95 latency and packet loss alert
+// thresholds; zero disables an alert.
+func latencyThresholds() (latencyMS, lossPercent float64) {
+	latencyMS, _ = strconv.ParseFloat(envOrDefault(latencyAlertMSEnv, "150"), 64)
+	lossPercent, _ = strconv.ParseFloat(envOrDefault(packetLossAlertEnv, "5"), 64)
+	return latencyMS, lossPercent
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// latencyStats summarises samples.
+func latencyStats(samples []LatencySample) LatencyStats {
+	var s LatencyStats
+	var rtts []float64
+	for _, sample := range samples {
+		s.Sent++
+		if sample.Lost {
+			s.Lost++
+			continue
+		}
+		rtts = append(rtts, sample.LatencyMS)
+		s.AvgMS += sample.LatencyMS
+	}
+	if s.Sent > 0 {
+		s.LossPercent = 100 * float64(s.Lost) / float64(s.Sent)
+	}
+	if len(rtts) == 0 {
+		return s
+	}
+	sort.Float64s(rtts)
+	s.AvgMS /= float64(len(rtts))
+	s.MinMS, s.MaxMS = rtts[0], rtts[len(rtts)-1]
+	s.P50MS, s.P90MS = percentile(rtts, 50), percentile(rtts, 90)
+	s.P95MS, s.P99MS = percentile(rtts, 95), percentile(rtts, 99)
+	return s
+}
+
+// latencySince returns the samples from the last window. The caller holds
+// latencyMutex.
+func latencySince(window time.Duration, now time.Time) []LatencySample {
+	cutoff := now.Add(-window)
+	i := sort.Search(len(latencySamples), func(i int) bool { return !latencySamples[i].Time.Before(cutoff) })
+	return latencySamples[i:]
+}
+
+// checkLatencyAlerts alerts when p95 latency or packet loss over the alert
+// window crosses its threshold, and again when it recovers. Total loss is
+// an outage rather than lag and is left to the availability alerts. The
+// caller holds latencyMutex.
+func checkLatencyAlerts(now time.Time) {
+	maxLatency, maxLoss := latencyThresholds()
+	stats := latencyStats(latencySince(latencyAlertWindow, now))
+	if stats.Sent == 0 {
+		return
+	}
+	for _, c := range []struct {
+		name, label, unit string
+		value, threshold  float64
+	}{
+		{"latency", "p95 latency", "ms", stats.P95MS, maxLatency},
+		{"packet_loss", "packet loss", "%", stats.LossPercent, maxLoss},
+	} {
+		breached := c.threshold > 0 && c.value >= c.threshold && stats.Lost < stats.Sent
+		fields := map[string]interface{}{"metric": c.name, "value": c.value, "threshold": c.threshold, "window": latencyAlertWindow.String()}
+		switch {
+		case breached && !latencyAlerting[c.name]:
+			latencyAlerting[c.name] = true
+			sendAlert("game_"+c.name, fmt.Sprintf("Game server %s is %.1f%s over %s (threshold %.1f%s)", c.label, c.value, c.unit, latencyAlertWindow, c.threshold, c.unit), fields)
+		case !breached && latencyAlerting[c.name]:
+			delete(latencyAlerting, c.name)
+			sendAlert("game_"+c.name+"_resolved", fmt.Sprintf("Game server %s back to %.1f%s", c.label, c.value, c.unit), fields)
+		}
+	}
+}
+
+// probeLatency pings the game server once. Nothing is recorded while the
+// server hibernates.
+func probeLatency() {
+	hibernationMutex.Lock()
+	asleep := hibernation.State != hibernationAwake
+	hibernationMutex.Unlock()
+	if asleep {
+		return
+	}
+	sample := LatencySample{Time: time.Now()}
+	if pong, err := pingServer(gameAddr(), latencyProbeTimeout); err != nil {
+		sample.Lost = true
+	} else {
+		sample.LatencyMS = pong.LatencyMS
+	}
+	latencyMutex.Lock()
+	defer latencyMutex.Unlock()
+	latencySamples = append(latencySince(latencyHistory, sample.Time), sample)
+	checkLatencyAlerts(sample.Time)
+}
+
+// startLatencyProbe pings the game server continuously.
+func startLatencyProbe() {
+	go func() {
+		for {
+			probeLatency()
+			time.Sleep(latencyProbeInterval)
+		}
+	}()
+}
+
+// latencyHandler reports latency percentiles and packet loss over
+// ?window= (default 1h, at most 24h) with a per-minute history.
+func latencyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	window := time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > latencyHistory {
+			writeJSONError(w, http.StatusBadRequest, "window must be a duration up to 24h")
+			return
+		}
+		window = d
+	}
+	now := time.Now()
+	latencyMutex.Lock()
+	samples := append([]LatencySample(nil), latencySince(window, now)...)
+	alerting := make([]string, 0, len(latencyAlerting))
+	for name := range latencyAlerting {
+		alerting = append(alerting, name)
+	}
+	latencyMutex.Unlock()
+	sort.Strings(alerting)
+
+	history := []LatencyBucket{}
+	for start := 0; start < len(samples); {
+		minute := samples[start].Time.Truncate(time.Minute)
+		end := start
+		for end < len(samples) && samples[end].Time.Truncate(time.Minute).Equal(minute) {
+			end++
+		}
+		history = append(history, LatencyBucket{Time: minute, LatencyStats: latencyStats(samples[start:end])})
+		start = end
+	}
+	maxLatency, maxLoss := latencyThresholds()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"window":  window.String(),
+		"stats":   latencyStats(samples),
+		"history": history,
+		"alerts": map[string]interface{}{
+			"window":              latencyAlertWindow.String(),
+			"latency_p95_ms":      maxLatency,
+			"packet_loss_percent": maxLoss,
+			"firing":              alerting,
+		},
+	})
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	leaderboardsStateFile  = "leaderboards.json"
+	leaderboardMirrorEvery = 5 * time.Minute
+	mirrorObjectivePrefix  = "lb_"
+)
+
+// Leaderboard sources.
+const (
+	boardPlaytime   = "playtime"
+	boardPoints     = "points"
+	boardScoreboard = "scoreboard"
+)
+
+var objectivePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,32}$`)
+
+// Leaderboard ranks players by a source. Scoreboard boards use the latest
+// sampled value of Objective. Mirror shows the top MirrorSize entries on the
+// in-game sidebar.
+type Leaderboard struct {
+	Name       string `json:"name"`
+	Source     string `json:"source"`
+	Objective  string `json:"objective,omitempty"`
+	Ascending  bool   `json:"ascending,omitempty"`
+	Mirror     bool   `json:"mirror,omitempty"`
+	MirrorSize int    `json:"mirror_size,omitempty"`
+}
+
+// LeaderboardEntry is one ranked player.
+type LeaderboardEntry struct {
+	Rank   int    `json:"rank"`
+	Player string `json:"player"`
+	Score  int64  `json:"score"`
+}
+
+type leaderboardState struct {
+	Boards []Leaderboard               `json:"boards"`
+	Scores map[string]map[string]int64 `json:"scores"` // objective -> player -> score
+}
+
+var (
+	leaderboards      = leaderboardState{Boards: []Leaderboard{}, Scores: map[string]map[string]int64{}}
+	leaderboardsMutex sync.Mutex
+)
+
+func (b *Leaderboard) validate() error {
+	if !validName(b.Name) {
+		return errors.New("invalid name")
+	}
+	switch b.Source {
+	case boardPlaytime, boardPoints:
+	case boardScoreboard:
+		if !objectivePattern.MatchString(b.Objective) {
+			return errors.New("invalid objective")
+		}
+	default:
+		return fmt.Errorf("unknown source %q", b.Source)
+	}
+	if b.MirrorSize <= 0 {
+		b.MirrorSize = 10
+	}
+	if b.Mirror && len(mirrorObjectivePrefix+b.Name) > 32 {
+		return errors.New("name too long to mirror")
+	}
+	return nil
+}
+
+// recordScoreboardSample stores the objective scores pushed by the bridge.
+// Players missing from a sample keep their last known score.
+func recordScoreboardSample(data json.RawMessage) {
+	var sample struct {
+		Objectives map[string]map[string]int64 `json:"objectives"`
+	}
+	if err := json.Unmarshal(data, &sample); err != nil {
+		return
+	}
+	leaderboardsMutex.Lock()
+	defer leaderboardsMutex.Unlock()
+	for obj, scores := range sample.Objectives {
+		if strings.HasPrefix(obj, mirrorObjectivePrefix) {
+			continue
+		}
+		m, ok := leaderboards.Scores[obj]
+		if !ok {
+			m = map[string]int64{}
+			leaderboards.Scores[obj] = m
+		}
+		for p, s := range scores {
+			m[p] = s
+		}
+	}
+	if err := saveState(leaderboardsStateFile, leaderboards); err != nil {
+		log.Printf("Error saving leaderboards: %v", err)
+	}
+}
+
+// rankLeaderboard computes the full ranking for a board. Callers must hold
+// leaderboardsMutex.
+func rankLeaderboard(b Leaderboard) []LeaderboardEntry {
+	var entries []LeaderboardEntry
+	switch b.Source {
+	case boardPlaytime:
+		playerStatsMutex.Lock()
+		for p, st := range playerStats {
+			entries = append(entries, LeaderboardEntry{Player: p, Score: st.livePlaytime()})
+		}
+		playerStatsMutex.Unlock()
+	case boardPoints:
+		pointsMutex.Lock()
+		for p, bal := range points.Balances {
+			entries = append(entries, LeaderboardEntry{Player: p, Score: bal})
+		}
+		pointsMutex.Unlock()
+	case boardScoreboard:
+		for p, s := range leaderboards.Scores[b.Objective] {
+			entries = append(entries, LeaderboardEntry{Player: p, Score: s})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			if b.Ascending {
+				return entries[i].Score < entries[j].Score
+			}
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].Player < entries[j].Player
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+// mirrorLeaderboards writes the top entries of mirrored boards to in-game
+// scoreboard objectives shown on the sidebar.
+func mirrorLeaderboards() {
+	leaderboardsMutex.Lock()
+	type mirror struct {
+		obj, title string
+		top        []LeaderboardEntry
+	}
+	var mirrors []mirror
+	for _, b := range leaderboards.Boards {
+		if !b.Mirror {
+			continue
+		}
+		top := rankLeaderboard(b)
+		if len(top) > b.MirrorSize {
+			top = top[:b.MirrorSize]
+		}
+		mirrors = append(mirrors, mirror{obj: mirrorObjectivePrefix + b.Name, title: b.Name, top: top})
+	}
+	leaderboardsMutex.Unlock()
+
+	for _, m := range mirrors {
+		cmds := []string{
+			fmt.Sprintf("scoreboard objectives add %s dummy %s", m.obj, quotePlayer(m.title)),
+			fmt.Sprintf("scoreboard players reset * %s", m.obj),
+		}
+		for _, e := range m.top {
+			cmds = append(cmds, fmt.Sprintf("scoreboard players set %s %s %d", quotePlayer(e.Player), m.obj, e.Score))
+		}
+		cmds = append(cmds, "scoreboard objectives setdisplay sidebar "+m.obj)
+		for _, c := range cmds {
+			// "objectives add" fails once the objective exists; that's fine.
+			if err := sendServerCommand(c); err != nil && !strings.HasPrefix(c, "scoreboard objectives add") {
+				log.Printf("Failed to mirror leaderboard %s: %v", m.title, err)
+				break
+			}
+		}
+	}
+}
+
+// startLeaderboardMirror refreshes mirrored boards periodically.
+func startLeaderboardMirror() {
+	go func() {
+		for range time.Tick(leaderboardMirrorEvery) {
+			mirrorLeaderboards()
+		}
+	}()
+}
+
+// leaderboardsHandler lists boards (GET) or creates or replaces one (POST).
+func leaderboardsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		leaderboardsMutex.Lock()
+		defer leaderboardsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"leaderboards": leaderboards.Boards})
+	case http.MethodPost:
+		var b Leaderboard
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := b.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		leaderboardsMutex.Lock()
+		replaced := false
+		for i := range leaderboards.Boards {
+			if leaderboards.Boards[i].Name == b.Name {
+				leaderboards.Boards[i] = b
+				replaced = true
+			}
+		}
+		if !replaced {
+			leaderboards.Boards = append(leaderboards.Boards, b)
+		}
+		err := saveState(leaderboardsStateFile, leaderboards)
+		leaderboardsMutex.Unlock()
+		if err != nil {
+			log.Printf("Error saving leaderboards: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save leaderboard")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, b)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// leaderboardHandler serves GET /leaderboards/{name}?offset=&limit= and
+// DELETE /leaderboards/{name}.
+func leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/leaderboards/")
+	leaderboardsMutex.Lock()
+	defer leaderboardsMutex.Unlock()
+	idx := -1
+	for i, b := range leaderboards.Boards {
+		if b.Name == name {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		writeJSONError(w, http.StatusNotFound, "Leaderboard not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if offset < 0 {
+			offset = 0
+		}
+		if limit <= 0 || limit > 100 {
+			limit = 25
+		}
+		entries := rankLeaderboard(leaderboards.Boards[idx])
+		total := len(entries)
+		if offset > total {
+			offset = total
+		}
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		page := entries[offset:end]
+		if page == nil {
+			page = []LeaderboardEntry{}
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"leaderboard": leaderboards.Boards[idx],
+			"total":       total,
+			"offset":      offset,
+			"limit":       limit,
+			"entries":     page,
+		})
+	case http.MethodDelete:
+		b := leaderboards.Boards[idx]
+		leaderboards.Boards = append(leaderboards.Boards[:idx], leaderboards.Boards[idx+1:]...)
+		if err := saveState(leaderboardsStateFile, leaderboards); err != nil {
+			log.Printf("Error saving leaderboards: %v", err)
+		}
+		if b.Mirror {
+			go sendServerCommand("scoreboard objectives remove " + mirrorObjectivePrefix + b.Name)
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Leaderboard deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,938 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A small reader and writer for the LevelDB database in a world's db
+// folder: enough to read every live key and to write the database out
+// again compacted. Mojang's fork compresses table blocks with zlib (2) or
+// raw deflate (4); snappy (1), which Bedrock does not write, is not
+// supported.
+
+const (
+	ldbNumLevels       = 7
+	ldbBlockSize       = 4 << 10
+	ldbTableSize       = 2 << 20
+	ldbRestartInterval = 16
+	ldbLogBlockSize    = 32 << 10
+	ldbLogHeaderLen    = 7
+	ldbFooterLen       = 48
+	ldbTableMagic      = 0xdb4775248b80fb57
+	ldbComparator      = "leveldb.BytewiseComparator"
+)
+
+// Log record types.
+const (
+	ldbFullRecord byte = iota + 1
+	ldbFirstRecord
+	ldbMiddleRecord
+	ldbLastRecord
+)
+
+// Table block compression types.
+const (
+	ldbNoCompression      byte = 0
+	ldbZlibCompression    byte = 2
+	ldbZlibRawCompression byte = 4
+)
+
+// Version edit tags in the MANIFEST.
+const (
+	ldbTagComparator     = 1
+	ldbTagLogNumber      = 2
+	ldbTagNextFile       = 3
+	ldbTagLastSequence   = 4
+	ldbTagCompactPointer = 5
+	ldbTagDeletedFile    = 6
+	ldbTagNewFile        = 7
+	ldbTagPrevLogNumber  = 9
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ldbChecksum is LevelDB's masked CRC-32C of data.
+func ldbChecksum(data ...[]byte) uint32 {
+	var c uint32
+	for _, d := range data {
+		c = crc32.Update(c, crc32c, d)
+	}
+	return (c>>15 | c<<17) + 0xa282ead8
+}
+
+// ldbCursor decodes varints and length-prefixed byte strings, remembering
+// the first error.
+type ldbCursor struct {
+	p   []byte
+	err error
+}
+
+func (c *ldbCursor) uvarint() uint64 {
+	if c.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(c.p)
+	if n <= 0 {
+		c.err = errors.New("leveldb: bad varint")
+		return 0
+	}
+	c.p = c.p[n:]
+	return v
+}
+
+func (c *ldbCursor) take(n uint64) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if n > uint64(len(c.p)) {
+		c.err = errors.New("leveldb: truncated data")
+		return nil
+	}
+	b := c.p[:n:n]
+	c.p = c.p[n:]
+	return b
+}
+
+func (c *ldbCursor) bytes() []byte {
+	return c.take(c.uvarint())
+}
+
+// ldbEntry is one version of a key. Deleted entries are tombstones.
+type ldbEntry struct {
+	Key     []byte
+	Seq     uint64
+	Deleted bool
+	Value   []byte
+}
+
+// parseInternalKey splits a table key into the user key and its sequence
+// number and type.
+func parseInternalKey(ikey []byte) (ldbEntry, error) {
+	if len(ikey) < 8 {
+		return ldbEntry{}, errors.New("leveldb: short internal key")
+	}
+	n := len(ikey) - 8
+	tag := binary.LittleEndian.Uint64(ikey[n:])
+	if tag&0xff > 1 {
+		return ldbEntry{}, fmt.Errorf("leveldb: unknown value type %d", tag&0xff)
+	}
+	return ldbEntry{Key: ikey[:n:n], Seq: tag >> 8, Deleted: tag&0xff == 0}, nil
+}
+
+func (e ldbEntry) internalKey() []byte {
+	tag := e.Seq << 8
+	if !e.Deleted {
+		tag |= 1
+	}
+	return binary.LittleEndian.AppendUint64(append([]byte{}, e.Key...), tag)
+}
+
+// ldbLess orders entries as LevelDB does: by key, newest version first.
+func ldbLess(a, b ldbEntry) bool {
+	if c := bytes.Compare(a.Key, b.Key); c != 0 {
+		return c < 0
+	}
+	return a.Seq > b.Seq
+}
+
+// readLDBLog returns the records of a log file, which is also the format
+// of the MANIFEST. A record torn by a crash mid-write ends the log, as it
+// does for LevelDB.
+func readLDBLog(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records [][]byte
+	var rec []byte
+	inRecord := false
+	for start := 0; start < len(data); start += ldbLogBlockSize {
+		blockEnd := min(start+ldbLogBlockSize, len(data))
+		block := data[start:blockEnd]
+		for len(block) >= ldbLogHeaderLen {
+			length := int(binary.LittleEndian.Uint16(block[4:6]))
+			typ := block[6]
+			if typ == 0 && length == 0 {
+				// Zeroed trailer or preallocated space.
+				break
+			}
+			end := ldbLogHeaderLen + length
+			if end > len(block) {
+				return records, nil
+			}
+			if ldbChecksum(block[6:end]) != binary.LittleEndian.Uint32(block[:4]) {
+				if allZero(data[blockEnd-len(block)+end:]) {
+					return records, nil
+				}
+				return nil, fmt.Errorf("leveldb: corrupt record in %s", filepath.Base(path))
+			}
+			payload := block[ldbLogHeaderLen:end]
+			switch typ {
+			case ldbFullRecord:
+				records = append(records, append([]byte{}, payload...))
+				inRecord = false
+			case ldbFirstRecord:
+				rec = append([]byte{}, payload...)
+				inRecord = true
+			case ldbMiddleRecord:
+				if inRecord {
+					rec = append(rec, payload...)
+				}
+			case ldbLastRecord:
+				if inRecord {
+					records = append(records, append(rec, payload...))
+				}
+				inRecord = false
+			default:
+				return nil, fmt.Errorf("leveldb: unknown record type %d in %s", typ, filepath.Base(path))
+			}
+			block = block[end:]
+		}
+	}
+	return records, nil
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// appendLDBLog appends records in log format, fragmenting them across
+// blocks.
+func appendLDBLog(out []byte, records ...[]byte) []byte {
+	offset := len(out) % ldbLogBlockSize
+	for _, rec := range records {
+		first := true
+		for {
+			if left := ldbLogBlockSize - offset; left < ldbLogHeaderLen {
+				out = append(out, make([]byte, left)...)
+				offset = 0
+			}
+			n := min(len(rec), ldbLogBlockSize-offset-ldbLogHeaderLen)
+			last := n == len(rec)
+			typ := ldbMiddleRecord
+			switch {
+			case first && last:
+				typ = ldbFullRecord
+			case first:
+				typ = ldbFirstRecord
+			case last:
+				typ = ldbLastRecord
+			}
+			out = binary.LittleEndian.AppendUint32(out, ldbChecksum([]byte{typ}, rec[:n]))
+			out = binary.LittleEndian.AppendUint16(out, uint16(n))
+			out = append(append(out, typ), rec[:n]...)
+			offset += ldbLogHeaderLen + n
+			rec = rec[n:]
+			first = false
+			if last {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// decodeLDBBatch calls fn for each put and delete in a log's write batch.
+func decodeLDBBatch(rec []byte, fn func(ldbEntry)) error {
+	if len(rec) < 12 {
+		return errors.New("leveldb: short write batch")
+	}
+	seq := binary.LittleEndian.Uint64(rec)
+	count := binary.LittleEndian.Uint32(rec[8:])
+	c := ldbCursor{p: rec[12:]}
+	for i := uint64(0); i < uint64(count); i++ {
+		if len(c.p) == 0 {
+			return errors.New("leveldb: truncated write batch")
+		}
+		kind := c.p[0]
+		c.p = c.p[1:]
+		e := ldbEntry{Key: c.bytes(), Seq: seq + i, Deleted: kind == 0}
+		switch kind {
+		case 0:
+		case 1:
+			e.Value = c.bytes()
+		default:
+			return fmt.Errorf("leveldb: unknown batch entry type %d", kind)
+		}
+		if c.err != nil {
+			return c.err
+		}
+		fn(e)
+	}
+	return nil
+}
+
+// ldbFile is a table file as recorded in the MANIFEST. Smallest and
+// Largest are internal keys.
+type ldbFile struct {
+	Level    int
+	Number   uint64
+	Size     uint64
+	Smallest []byte
+	Largest  []byte
+}
+
+// ldbVersion is the state of a database after replaying its MANIFEST.
+type ldbVersion struct {
+	Comparator    string
+	LogNumber     uint64
+	PrevLogNumber uint64
+	NextFile      uint64
+	LastSequence  uint64
+	Files         []ldbFile
+}
+
+// readLDBManifest replays the MANIFEST named in dir/CURRENT.
+func readLDBManifest(dir string) (*ldbVersion, error) {
+	current, err := os.ReadFile(filepath.Join(dir, "CURRENT"))
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(string(current))
+	if !strings.HasPrefix(name, "MANIFEST-") || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("leveldb: bad CURRENT file %q", name)
+	}
+	records, err := readLDBLog(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	v := &ldbVersion{Comparator: ldbComparator}
+	type fileID struct {
+		level  int
+		number uint64
+	}
+	live := map[fileID]ldbFile{}
+	for _, rec := range records {
+		c := ldbCursor{p: rec}
+		for len(c.p) > 0 && c.err == nil {
+			switch tag := c.uvarint(); tag {
+			case ldbTagComparator:
+				v.Comparator = string(c.bytes())
+			case ldbTagLogNumber:
+				v.LogNumber = c.uvarint()
+			case ldbTagPrevLogNumber:
+				v.PrevLogNumber = c.uvarint()
+			case ldbTagNextFile:
+				v.NextFile = c.uvarint()
+			case ldbTagLastSequence:
+				v.LastSequence = c.uvarint()
+			case ldbTagCompactPointer:
+				c.uvarint()
+				c.bytes()
+			case ldbTagDeletedFile:
+				level := int(c.uvarint())
+				delete(live, fileID{level, c.uvarint()})
+			case ldbTagNewFile:
+				f := ldbFile{Level: int(c.uvarint()), Number: c.uvarint(), Size: c.uvarint()}
+				f.Smallest, f.Largest = c.bytes(), c.bytes()
+				if f.Level < 0 || f.Level >= ldbNumLevels {
+					return nil, fmt.Errorf("leveldb: bad level %d in %s", f.Level, name)
+				}
+				live[fileID{f.Level, f.Number}] = f
+			default:
+				if c.err == nil {
+					return nil, fmt.Errorf("leveldb: unknown manifest tag %d in %s", tag, name)
+				}
+			}
+		}
+		if c.err != nil {
+			return nil, fmt.Errorf("%w in %s", c.err, name)
+		}
+	}
+	for _, f := range live {
+		v.Files = append(v.Files, f)
+	}
+	sort.Slice(v.Files, func(i, j int) bool {
+		a, b := v.Files[i], v.Files[j]
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		ka, _ := parseInternalKey(a.Smallest)
+		kb, _ := parseInternalKey(b.Smallest)
+		return ldbLess(ka, kb)
+	})
+	return v, nil
+}
+
+// encodeEdit encodes the version as a single edit that creates it from
+// nothing.
+func (v *ldbVersion) encodeEdit() []byte {
+	var b []byte
+	str := func(s []byte) {
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	b = binary.AppendUvarint(b, ldbTagComparator)
+	str([]byte(v.Comparator))
+	b = binary.AppendUvarint(b, ldbTagLogNumber)
+	b = binary.AppendUvarint(b, v.LogNumber)
+	b = binary.AppendUvarint(b, ldbTagNextFile)
+	b = binary.AppendUvarint(b, v.NextFile)
+	b = binary.AppendUvarint(b, ldbTagLastSequence)
+	b = binary.AppendUvarint(b, v.LastSequence)
+	for _, f := range v.Files {
+		b = binary.AppendUvarint(b, ldbTagNewFile)
+		b = binary.AppendUvarint(b, uint64(f.Level))
+		b = binary.AppendUvarint(b, f.Number)
+		b = binary.AppendUvarint(b, f.Size)
+		str(f.Smallest)
+		str(f.Largest)
+	}
+	return b
+}
+
+// readLDBBlock reads, checks and decompresses the block at a handle.
+func readLDBBlock(table []byte, offset, size uint64) ([]byte, error) {
+	if offset+size+5 > uint64(len(table)) || offset+size < offset {
+		return nil, errors.New("leveldb: block handle out of range")
+	}
+	raw := table[offset : offset+size]
+	typ := table[offset+size]
+	if ldbChecksum(table[offset:offset+size+1]) != binary.LittleEndian.Uint32(table[offset+size+1:]) {
+		return nil, errors.New("leveldb: block checksum mismatch")
+	}
+	var r io.ReadCloser
+	switch typ {
+	case ldbNoCompression:
+		return raw, nil
+	case ldbZlibCompression:
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	case ldbZlibRawCompression:
+		r = flate.NewReader(bytes.NewReader(raw))
+	default:
+		return nil, fmt.Errorf("leveldb: unsupported block compression %d", typ)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// ldbBlockEntries decodes the key/value pairs of a block.
+func ldbBlockEntries(block []byte) ([][2][]byte, error) {
+	if len(block) < 4 {
+		return nil, errors.New("leveldb: short block")
+	}
+	restarts := uint64(binary.LittleEndian.Uint32(block[len(block)-4:]))
+	if restarts*4+4 > uint64(len(block)) {
+		return nil, errors.New("leveldb: bad block restart count")
+	}
+	c := ldbCursor{p: block[:uint64(len(block))-restarts*4-4]}
+	var out [][2][]byte
+	var key []byte
+	for len(c.p) > 0 && c.err == nil {
+		shared, unshared, valueLen := c.uvarint(), c.uvarint(), c.uvarint()
+		if shared > uint64(len(key)) {
+			return nil, errors.New("leveldb: bad shared key length")
+		}
+		k := append(append([]byte{}, key[:shared]...), c.take(unshared)...)
+		v := c.take(valueLen)
+		out = append(out, [2][]byte{k, v})
+		key = k
+	}
+	return out, c.err
+}
+
+// ldbBlockHandle locates a data block. Last is the user key of its index
+// entry, which is at or after the block's last key.
+type ldbBlockHandle struct {
+	Last   []byte
+	Offset uint64
+	Size   uint64
+}
+
+// ldbTableBlocks returns the handles of a table's data blocks from its
+// index.
+func ldbTableBlocks(table []byte) ([]ldbBlockHandle, error) {
+	if len(table) < ldbFooterLen {
+		return nil, errors.New("leveldb: table too short")
+	}
+	footer := table[len(table)-ldbFooterLen:]
+	if binary.LittleEndian.Uint64(footer[40:]) != ldbTableMagic {
+		return nil, errors.New("leveldb: bad table magic")
+	}
+	c := ldbCursor{p: footer}
+	c.uvarint() // metaindex, for the filter block
+	c.uvarint()
+	indexOffset, indexSize := c.uvarint(), c.uvarint()
+	if c.err != nil {
+		return nil, c.err
+	}
+	index, err := readLDBBlock(table, indexOffset, indexSize)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ldbBlockEntries(index)
+	if err != nil {
+		return nil, err
+	}
+	handles := make([]ldbBlockHandle, 0, len(entries))
+	for _, e := range entries {
+		last, err := parseInternalKey(e[0])
+		if err != nil {
+			return nil, err
+		}
+		hc := ldbCursor{p: e[1]}
+		h := ldbBlockHandle{Last: last.Key, Offset: hc.uvarint(), Size: hc.uvarint()}
+		if hc.err != nil {
+			return nil, hc.err
+		}
+		handles = append(handles, h)
+	}
+	return handles, nil
+}
+
+// ldbIterator walks entries in LevelDB order.
+type ldbIterator interface {
+	Next() bool
+	Entry() ldbEntry
+	Err() error
+}
+
+// ldbSliceIterator iterates sorted entries in memory, such as the logs.
+type ldbSliceIterator struct {
+	entries []ldbEntry
+	pos     int
+}
+
+func (it *ldbSliceIterator) Next() bool {
+	it.pos++
+	return it.pos <= len(it.entries)
+}
+
+func (it *ldbSliceIterator) Entry() ldbEntry { return it.entries[it.pos-1] }
+func (it *ldbSliceIterator) Err() error      { return nil }
+
+// ldbTableIterator iterates a run of tables whose key ranges do not
+// overlap, in order, holding one table in memory at a time. Blocks before
+// the first key with prefix are skipped.
+type ldbTableIterator struct {
+	dir     string
+	files   []ldbFile
+	prefix  []byte
+	table   []byte
+	blocks  []ldbBlockHandle
+	entries [][2][]byte
+	entry   ldbEntry
+	err     error
+}
+
+func (it *ldbTableIterator) Next() bool {
+	for it.err == nil {
+		if len(it.entries) > 0 {
+			it.entry, it.err = parseInternalKey(it.entries[0][0])
+			it.entry.Value = it.entries[0][1]
+			it.entries = it.entries[1:]
+			return it.err == nil
+		}
+		if len(it.blocks) > 0 {
+			var block []byte
+			if block, it.err = readLDBBlock(it.table, it.blocks[0].Offset, it.blocks[0].Size); it.err == nil {
+				it.entries, it.err = ldbBlockEntries(block)
+			}
+			it.blocks = it.blocks[1:]
+			continue
+		}
+		if len(it.files) == 0 {
+			it.table = nil
+			return false
+		}
+		if it.table, it.err = readLDBTable(it.dir, it.files[0].Number); it.err == nil {
+			it.blocks, it.err = ldbTableBlocks(it.table)
+			for len(it.blocks) > 0 && bytes.Compare(it.blocks[0].Last, it.prefix) < 0 {
+				it.blocks = it.blocks[1:]
+			}
+		}
+		if it.err != nil {
+			it.err = fmt.Errorf("table %06d: %w", it.files[0].Number, it.err)
+		}
+		it.files = it.files[1:]
+	}
+	return false
+}
+
+func (it *ldbTableIterator) Entry() ldbEntry { return it.entry }
+func (it *ldbTableIterator) Err() error      { return it.err }
+
+// readLDBTable reads a table file, which older versions named .sst.
+func readLDBTable(dir string, number uint64) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%06d.ldb", number)))
+	if os.IsNotExist(err) {
+		data, err = os.ReadFile(filepath.Join(dir, fmt.Sprintf("%06d.sst", number)))
+	}
+	return data, err
+}
+
+// ldbMergeHeap orders iterators by their current entry.
+type ldbMergeHeap []ldbIterator
+
+func (h ldbMergeHeap) Len() int            { return len(h) }
+func (h ldbMergeHeap) Less(i, j int) bool  { return ldbLess(h[i].Entry(), h[j].Entry()) }
+func (h ldbMergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *ldbMergeHeap) Push(x interface{}) { *h = append(*h, x.(ldbIterator)) }
+func (h *ldbMergeHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// ldbDB is a database opened read-only: its MANIFEST and the unflushed
+// writes in its logs.
+type ldbDB struct {
+	dir      string
+	version  *ldbVersion
+	memtable []ldbEntry
+}
+
+// openLDB reads a database. The server must not be writing to it, or the
+// files read may no longer be current.
+func openLDB(dir string) (*ldbDB, error) {
+	v, err := readLDBManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if v.Comparator != ldbComparator {
+		return nil, fmt.Errorf("leveldb: unsupported comparator %q", v.Comparator)
+	}
+	db := &ldbDB{dir: dir, version: v}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var logs []uint64
+	for _, e := range entries {
+		n, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), ".log"), 10, 64)
+		if err != nil || !strings.HasSuffix(e.Name(), ".log") {
+			continue
+		}
+		if n >= v.LogNumber || n == v.PrevLogNumber {
+			logs = append(logs, n)
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i] < logs[j] })
+	for _, n := range logs {
+		records, err := readLDBLog(filepath.Join(dir, fmt.Sprintf("%06d.log", n)))
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			if err := decodeLDBBatch(rec, func(e ldbEntry) { db.memtable = append(db.memtable, e) }); err != nil {
+				return nil, fmt.Errorf("%w in %06d.log", err, n)
+			}
+		}
+	}
+	sort.SliceStable(db.memtable, func(i, j int) bool { return ldbLess(db.memtable[i], db.memtable[j]) })
+	return db, nil
+}
+
+// lastSequence is the newest sequence number in the database.
+func (db *ldbDB) lastSequence() uint64 {
+	seq := db.version.LastSequence
+	for _, e := range db.memtable {
+		seq = max(seq, e.Seq)
+	}
+	return seq
+}
+
+// scan calls fn with the newest version of every live key starting with
+// prefix, in key order.
+func (db *ldbDB) scan(prefix []byte, fn func(ldbEntry) error) error {
+	var mem []ldbEntry
+	for _, e := range db.memtable {
+		if bytes.HasPrefix(e.Key, prefix) {
+			mem = append(mem, e)
+		}
+	}
+	iters := []ldbIterator{&ldbSliceIterator{entries: mem}}
+	levels := make([][]ldbFile, ldbNumLevels)
+	for _, f := range db.version.Files {
+		smallest, err1 := parseInternalKey(f.Smallest)
+		largest, err2 := parseInternalKey(f.Largest)
+		if err1 == nil && err2 == nil && (bytes.Compare(largest.Key, prefix) < 0 ||
+			(bytes.Compare(smallest.Key, prefix) > 0 && !bytes.HasPrefix(smallest.Key, prefix))) {
+			continue
+		}
+		if f.Level == 0 {
+			// Level 0 tables overlap, so each is merged separately.
+			iters = append(iters, &ldbTableIterator{dir: db.dir, files: []ldbFile{f}, prefix: prefix})
+		} else {
+			levels[f.Level] = append(levels[f.Level], f)
+		}
+	}
+	for _, files := range levels {
+		if len(files) > 0 {
+			iters = append(iters, &ldbTableIterator{dir: db.dir, files: files, prefix: prefix})
+		}
+	}
+	h := ldbMergeHeap{}
+	for _, it := range iters {
+		if it.Next() {
+			h = append(h, it)
+		} else if err := it.Err(); err != nil {
+			return err
+		}
+	}
+	heap.Init(&h)
+	var last []byte
+	first := true
+	for h.Len() > 0 {
+		it := h[0]
+		e := it.Entry()
+		if !bytes.HasPrefix(e.Key, prefix) && bytes.Compare(e.Key, prefix) > 0 {
+			break
+		}
+		if first || !bytes.Equal(e.Key, last) {
+			first = false
+			last = append(last[:0], e.Key...)
+			if !e.Deleted && bytes.HasPrefix(e.Key, prefix) {
+				if err := fn(e); err != nil {
+					return err
+				}
+			}
+		}
+		if it.Next() {
+			heap.Fix(&h, 0)
+		} else if err := it.Err(); err != nil {
+			return err
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
+
+// ldbBlockBuilder builds a block with prefix-compressed keys.
+type ldbBlockBuilder struct {
+	buf      []byte
+	restarts []uint32
+	counter  int
+	lastKey  []byte
+}
+
+func (b *ldbBlockBuilder) reset() {
+	b.buf, b.restarts, b.counter, b.lastKey = b.buf[:0], append(b.restarts[:0], 0), 0, b.lastKey[:0]
+}
+
+func (b *ldbBlockBuilder) add(key, value []byte) {
+	if b.restarts == nil {
+		b.reset()
+	}
+	shared := 0
+	if b.counter < ldbRestartInterval {
+		for shared < min(len(key), len(b.lastKey)) && key[shared] == b.lastKey[shared] {
+			shared++
+		}
+	} else {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+		b.counter = 0
+	}
+	b.buf = binary.AppendUvarint(b.buf, uint64(shared))
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(key)-shared))
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(value)))
+	b.buf = append(append(b.buf, key[shared:]...), value...)
+	b.lastKey = append(b.lastKey[:0], key...)
+	b.counter++
+}
+
+func (b *ldbBlockBuilder) finish() []byte {
+	if b.restarts == nil {
+		b.reset()
+	}
+	for _, r := range b.restarts {
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, r)
+	}
+	return binary.LittleEndian.AppendUint32(b.buf, uint32(len(b.restarts)))
+}
+
+// ldbTableWriter builds a table in memory. Blocks are raw deflate
+// compressed, as Bedrock writes them, when that saves at least an eighth.
+// No filter block is written; LevelDB reads tables without one.
+type ldbTableWriter struct {
+	buf      bytes.Buffer
+	data     ldbBlockBuilder
+	index    ldbBlockBuilder
+	smallest []byte
+	largest  []byte
+}
+
+func (t *ldbTableWriter) add(ikey, value []byte) {
+	if t.smallest == nil {
+		t.smallest = ikey
+	}
+	t.largest = ikey
+	t.data.add(ikey, value)
+	if len(t.data.buf) >= ldbBlockSize {
+		t.flush()
+	}
+}
+
+func (t *ldbTableWriter) size() int {
+	return t.buf.Len() + len(t.data.buf)
+}
+
+// flush writes the pending data block and indexes it under its last key.
+func (t *ldbTableWriter) flush() {
+	if len(t.data.buf) == 0 {
+		return
+	}
+	handle := t.writeBlock(t.data.finish())
+	t.index.add(t.largest, handle)
+	t.data.reset()
+}
+
+func (t *ldbTableWriter) writeBlock(raw []byte) []byte {
+	out, typ := raw, ldbNoCompression
+	var z bytes.Buffer
+	zw, _ := flate.NewWriter(&z, flate.DefaultCompression)
+	zw.Write(raw)
+	zw.Close()
+	if z.Len() < len(raw)-len(raw)/8 {
+		out, typ = z.Bytes(), ldbZlibRawCompression
+	}
+	handle := binary.AppendUvarint(nil, uint64(t.buf.Len()))
+	handle = binary.AppendUvarint(handle, uint64(len(out)))
+	t.buf.Write(out)
+	t.buf.WriteByte(typ)
+	binary.Write(&t.buf, binary.LittleEndian, ldbChecksum(out, []byte{typ}))
+	return handle
+}
+
+// finish writes the index and footer and returns the table.
+func (t *ldbTableWriter) finish() []byte {
+	t.flush()
+	var meta ldbBlockBuilder
+	footer := t.writeBlock(meta.finish())
+	footer = append(footer, t.writeBlock(t.index.finish())...)
+	footer = append(footer, make([]byte, 40-len(footer))...)
+	footer = binary.LittleEndian.AppendUint64(footer, ldbTableMagic)
+	t.buf.Write(footer)
+	return t.buf.Bytes()
+}
+
+// writeSynced writes a file and flushes it to disk.
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeLDBBatch writes puts to a database that is not open as a log file
+// after every existing file, which LevelDB replays when it next opens the
+// database, as it would its own unflushed writes.
+func writeLDBBatch(dir string, puts []ldbEntry) error {
+	db, err := openLDB(dir)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	number := db.version.NextFile
+	for _, e := range entries {
+		name := strings.TrimPrefix(e.Name(), "MANIFEST-")
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		}
+		if n, err := strconv.ParseUint(name, 10, 64); err == nil && n >= number {
+			number = n + 1
+		}
+	}
+	batch := binary.LittleEndian.AppendUint64(nil, db.lastSequence()+1)
+	batch = binary.LittleEndian.AppendUint32(batch, uint32(len(puts)))
+	for _, e := range puts {
+		batch = append(batch, 1)
+		batch = binary.AppendUvarint(batch, uint64(len(e.Key)))
+		batch = append(batch, e.Key...)
+		batch = binary.AppendUvarint(batch, uint64(len(e.Value)))
+		batch = append(batch, e.Value...)
+	}
+	return writeSynced(filepath.Join(dir, fmt.Sprintf("%06d.log", number)), appendLDBLog(nil, batch))
+}
+
+// compactLDB writes the live contents of the database in src to a new
+// database in dst: the newest version of every key that is not deleted,
+// in non-overlapping tables on the bottom level, with empty logs. It
+// returns the number of keys and tables written.
+func compactLDB(src, dst string) (keys, tables int, err error) {
+	db, err := openLDB(src)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return 0, 0, err
+	}
+	// File 1 is the MANIFEST; tables follow it.
+	v := &ldbVersion{Comparator: db.version.Comparator, LastSequence: db.lastSequence(), NextFile: 2}
+	var tw *ldbTableWriter
+	finishTable := func() error {
+		data := tw.finish()
+		f := ldbFile{Level: ldbNumLevels - 1, Number: v.NextFile, Size: uint64(len(data)), Smallest: tw.smallest, Largest: tw.largest}
+		if err := writeSynced(filepath.Join(dst, fmt.Sprintf("%06d.ldb", f.Number)), data); err != nil {
+			return err
+		}
+		v.Files = append(v.Files, f)
+		v.NextFile++
+		tw = nil
+		return nil
+	}
+	err = db.scan(nil, func(e ldbEntry) error {
+		if tw == nil {
+			tw = &ldbTableWriter{}
+		}
+		tw.add(e.internalKey(), e.Value)
+		keys++
+		if tw.size() >= ldbTableSize {
+			return finishTable()
+		}
+		return nil
+	})
+	if err == nil && tw != nil {
+		err = finishTable()
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	// The log number names a log that does not exist yet, so LevelDB
+	// starts with nothing to replay.
+	v.LogNumber = v.NextFile
+	v.NextFile++
+	if err := writeSynced(filepath.Join(dst, "MANIFEST-000001"), appendLDBLog(nil, v.encodeEdit())); err != nil {
+		return 0, 0, err
+	}
+	if err := writeSynced(filepath.Join(dst, "CURRENT"), []byte("MANIFEST-000001\n")); err != nil {
+		return 0, 0, err
+	}
+	return keys, len(v.Files), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gameAddr returns the UDP address of the game server's IPv4 port.
+func gameAddr() string {
+	if addr := os.Getenv(gameAddrEnv); addr != "" {
+		return addr
+	}
+	port, _ := getServerProperty("server-port", "19132")
+	return "127.0.0.1:" + port
+}
+
+// runHostCommand executes an operator-supplied shell command.
+func runHostCommand(command string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%q failed: %w: %s", command, err, out)
+	}
+	return nil
+}
+
+// canStartServer reports whether the sidecar knows how to start the server.
+func canStartServer() bool {
+	return supervisor != nil || os.Getenv(startCommandEnv) != ""
+}
+
+// startServer starts the dedicated server using the configured start command.
+var startServer = func() error {
+	command := os.Getenv(startCommandEnv)
+	if command == "" {
+		return errors.New("no start command configured: set " + startCommandEnv)
+	}
+	log.Printf("Starting server: %s", command)
+	expectServer(true)
+	return runHostCommand(command)
+}
+
+// stopServer asks the dedicated server to shut down.
+var stopServer = func() error {
+	expectServer(false)
+	return sendServerCommand("stop")
+}
+
+// waitForServerDown polls the game port until the server stops answering.
+func waitForServerDown(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := pingServer(gameAddr(), time.Second); err != nil {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return errors.New("server did not stop in time")
+}
+
+// restartServer restarts the dedicated server. Without a start command it
+// only stops the server and relies on the container restart policy.
+var restartServer = func() error {
+	if err := stopServer(); err != nil {
+		return err
+	}
+	if !canStartServer() {
+		return nil
+	}
+	if err := waitForServerDown(time.Minute); err != nil {
+		return err
+	}
+	return startServer()
+}
+
+// withServerStopped stops the server, runs fn while the world files are
+// quiescent and starts the server again, even if fn fails. It requires a
+// start command because a container restart policy would race with fn.
+func withServerStopped(fn func() error) error {
+	if !canStartServer() {
+		return errors.New("a start command is required: set " + startCommandEnv)
+	}
+	if err := stopServer(); err != nil {
+		return fmt.Errorf("failed to stop server: %w", err)
+	}
+	if err := waitForServerDown(2 * time.Minute); err != nil {
+		return err
+	}
+	fnErr := fn()
+	if err := startServer(); err != nil {
+		if fnErr != nil {
+			return fmt.Errorf("%v; additionally failed to start server: %w", fnErr, err)
+		}
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return fnErr
+}
+
+// countdownWarnings are the remaining seconds at which players are warned.
+var countdownWarnings = []int{600, 300, 120, 60, 30, 10, 5, 4, 3, 2, 1}
+
+// ServerActionRequest configures a stop or restart. Countdown is in
+// seconds; Message replaces the default warning text and may contain %d
+// for the seconds remaining.
+type ServerActionRequest struct {
+	Countdown *int   `json:"countdown,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+var (
+	serverActionCancel chan struct{}
+	serverActionMutex  sync.Mutex
+)
+
+// defaultCountdown returns the configured countdown before stops and restarts.
+func defaultCountdown() int {
+	n, err := strconv.Atoi(envOrDefault(restartCountdownEnv, "0"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// formatSeconds renders seconds as players expect to read them.
+func formatSeconds(n int) string {
+	switch {
+	case n >= 60 && n%60 == 0 && n != 60:
+		return fmt.Sprintf("%d minutes", n/60)
+	case n == 60:
+		return "1 minute"
+	case n == 1:
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", n)
+}
+
+// runCountdown warns players with "say" until the countdown expires. It
+// returns an error if the countdown is cancelled.
+func runCountdown(t *Task, seconds int, verb, message string, cancel <-chan struct{}) error {
+	deadline := time.Now().Add(time.Duration(seconds) * time.Second)
+	for _, at := range countdownWarnings {
+		if at > seconds {
+			continue
+		}
+		select {
+		case <-time.After(time.Until(deadline.Add(-time.Duration(at) * time.Second))):
+		case <-cancel:
+			sendServerCommand("say Server " + verb + " cancelled")
+			return errors.New("cancelled")
+		}
+		text := fmt.Sprintf("Server %s in %s", verb, formatSeconds(at))
+		if message != "" {
+			text = strings.ReplaceAll(message, "%d", strconv.Itoa(at))
+		}
+		t.step(100*(seconds-at)/seconds, "%s", text)
+		sendServerCommand("say " + text)
+	}
+	select {
+	case <-time.After(time.Until(deadline)):
+		return nil
+	case <-cancel:
+		sendServerCommand("say Server " + verb + " cancelled")
+		return errors.New("cancelled")
+	}
+}
+
+// serverHandler serves POST /server/{start,stop,restart,cancel}. Stops and
+// restarts run as tasks after an optional countdown.
+func serverHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	action := strings.TrimPrefix(r.URL.Path, "/server/")
+	serverActionMutex.Lock()
+	defer serverActionMutex.Unlock()
+	pending, busy := runningTask("server")
+
+	switch action {
+	case "start":
+		if busy {
+			writeJSONError(w, http.StatusConflict, "A server action is in progress")
+			return
+		}
+		if serverUp() {
+			writeJSONError(w, http.StatusConflict, "Server is already running")
+			return
+		}
+		if err := startServer(); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Server starting"})
+		return
+	case "cancel":
+		if !busy || serverActionCancel == nil {
+			writeJSONError(w, http.StatusNotFound, "No countdown in progress")
+			return
+		}
+		close(serverActionCancel)
+		serverActionCancel = nil
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Cancelled", "task_id": pending.ID})
+		return
+	case "stop", "restart":
+	default:
+		writeJSONError(w, http.StatusNotFound, "Unknown server action")
+		return
+	}
+
+	if busy {
+		writeJSONError(w, http.StatusConflict, "A server action is already in progress")
+		return
+	}
+	req := ServerActionRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+	}
+	seconds := defaultCountdown()
+	if req.Countdown != nil {
+		seconds = *req.Countdown
+	}
+	if seconds < 0 || seconds > 3600 {
+		writeJSONError(w, http.StatusBadRequest, "Countdown must be 0-3600 seconds")
+		return
+	}
+	verb, run := "stopping", stopServer
+	if action == "restart" {
+		verb, run = "restarting", restartServer
+	}
+	cancel := make(chan struct{})
+	serverActionCancel = cancel
+	t := startTask("server", func(t *Task) error {
+		if seconds > 0 {
+			if err := runCountdown(t, seconds, verb, req.Message, cancel); err != nil {
+				return err
+			}
+		}
+		serverActionMutex.Lock()
+		if serverActionCancel == cancel {
+			serverActionCancel = nil
+		}
+		serverActionMutex.Unlock()
+		t.step(100, "Server %s", verb)
+		return run()
+	})
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+}
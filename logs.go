@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLogBufferLines = 5000
+	defaultLogBufferBytes = 4 << 20
+	defaultLogLines       = 100
+)
+
+// logRing keeps the most recent server output lines within a line and a
+// byte budget, overwriting the oldest. Callers must hold consoleMutex.
+type logRing struct {
+	buf      []ConsoleMessage
+	start, n int
+	bytes    int
+	maxBytes int
+	seq      int64
+}
+
+// newLogRing sizes the buffer from BEDROCK_API_LOG_BUFFER_LINES and
+// BEDROCK_API_LOG_BUFFER_BYTES.
+func newLogRing() *logRing {
+	lines, err := strconv.Atoi(envOrDefault(logBufferLinesEnv, strconv.Itoa(defaultLogBufferLines)))
+	if err != nil || lines < 1 {
+		log.Printf("Invalid %s, using %d", logBufferLinesEnv, defaultLogBufferLines)
+		lines = defaultLogBufferLines
+	}
+	size, err := strconv.Atoi(envOrDefault(logBufferBytesEnv, strconv.Itoa(defaultLogBufferBytes)))
+	if err != nil || size < 1 {
+		log.Printf("Invalid %s, using %d", logBufferBytesEnv, defaultLogBufferBytes)
+		size = defaultLogBufferBytes
+	}
+	return &logRing{buf: make([]ConsoleMessage, lines), maxBytes: size}
+}
+
+// add appends a line, numbering it, and evicts the oldest lines over budget.
+func (r *logRing) add(msg ConsoleMessage) ConsoleMessage {
+	r.seq++
+	msg.Seq = r.seq
+	if r.n == len(r.buf) {
+		r.evict()
+	}
+	r.buf[(r.start+r.n)%len(r.buf)] = msg
+	r.n++
+	r.bytes += len(msg.Line)
+	for r.bytes > r.maxBytes && r.n > 1 {
+		r.evict()
+	}
+	return msg
+}
+
+func (r *logRing) evict() {
+	r.bytes -= len(r.buf[r.start].Line)
+	r.buf[r.start] = ConsoleMessage{}
+	r.start = (r.start + 1) % len(r.buf)
+	r.n--
+}
+
+// tail returns up to the last n lines that pass keep, oldest first.
+func (r *logRing) tail(n int, keep func(ConsoleMessage) bool) []ConsoleMessage {
+	var out []ConsoleMessage
+	for i := r.n - 1; i >= 0 && len(out) < n; i-- {
+		if msg := r.buf[(r.start+i)%len(r.buf)]; keep(msg) {
+			out = append(out, msg)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// serverOutputAvailable reports whether server output reaches the buffer.
+func serverOutputAvailable() bool {
+	return supervisor != nil || os.Getenv(serverLogEnv) != ""
+}
+
+// parseLogSince accepts an RFC 3339 time or a duration back from now.
+func parseLogSince(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("since must be an RFC 3339 time or a duration")
+	}
+	return time.Now().Add(-d), nil
+}
+
+// logsHandler serves GET /logs, the server output kept in memory, as
+// plain text: the last ?lines= lines (100 by default), only those after
+// ?since=, an RFC 3339 time or a duration such as 10m. With ?follow=true
+// the lines are followed by new output as server-sent events, each line's
+// sequence number as its ID so a reconnecting client's Last-Event-ID
+// resumes where it left off.
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if !serverOutputAvailable() {
+		writeJSONError(w, http.StatusNotFound, "Server output is not captured: set "+serverLogEnv+" or "+superviseEnv)
+		return
+	}
+	q := r.URL.Query()
+	lines := defaultLogLines
+	if v := q.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid lines")
+			return
+		}
+		lines = n
+	}
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		t, err := parseLogSince(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		since = t
+	}
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	keep := func(msg ConsoleMessage) bool {
+		return msg.Time.After(since) && msg.Seq > lastID
+	}
+	if lastID > 0 {
+		// A resuming client wants everything it missed.
+		lines = len(consoleLog.buf)
+	}
+	follow := q.Get("follow") == "true"
+	if !follow {
+		consoleMutex.Lock()
+		backlog := consoleLog.tail(lines, keep)
+		consoleMutex.Unlock()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		var b strings.Builder
+		for _, msg := range backlog {
+			b.WriteString(msg.Line)
+			b.WriteByte('\n')
+		}
+		w.Write([]byte(b.String()))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	ch := make(chan ConsoleMessage, 256)
+	consoleMutex.Lock()
+	backlog := consoleLog.tail(lines, keep)
+	consoleSubscribers[ch] = struct{}{}
+	consoleMutex.Unlock()
+	defer func() {
+		consoleMutex.Lock()
+		delete(consoleSubscribers, ch)
+		consoleMutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(msg ConsoleMessage) {
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", msg.Seq, msg.Line)
+	}
+	for _, msg := range backlog {
+		send(msg)
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case msg := <-ch:
+			send(msg)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const macrosStateFile = "macros.json"
+
+// saveMacros persists custom commands. Callers must hold commandsMutex.
+func saveMacros() {
+	if err := saveState(macrosStateFile, customCommands); err != nil {
+		log.Printf("Error saving custom commands: %v", err)
+	}
+}
+
+// findMacro returns the index of the named custom command, or -1. Callers
+// must hold commandsMutex.
+func findMacro(name string) int {
+	for i, c := range customCommands {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// macrosHandler lists custom commands (GET) or creates one (POST, 409 if the
+// name is taken).
+func macrosHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getCustomCommandsHandler(w, r)
+	case http.MethodPost:
+		var m CustomCommand
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil || !validName(m.Name) || strings.TrimSpace(m.Command) == "" {
+			writeJSONError(w, http.StatusBadRequest, "name and command are required")
+			return
+		}
+		if !allowCommand(w, r, m.Command) {
+			return
+		}
+		commandsMutex.Lock()
+		if findMacro(m.Name) >= 0 {
+			commandsMutex.Unlock()
+			writeJSONError(w, http.StatusConflict, "Custom command already exists")
+			return
+		}
+		m.CreatedAt = time.Now()
+		m.ExecutedAt = time.Time{}
+		customCommands = append(customCommands, m)
+		saveMacros()
+		commandsMutex.Unlock()
+		configChanged()
+		writeJSONResponse(w, http.StatusCreated, m)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// macroHandler serves GET, PUT (upsert) and DELETE /macros/{name}.
+func macroHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/macros/")
+	commandsMutex.Lock()
+	i := findMacro(name)
+	switch r.Method {
+	case http.MethodGet:
+		defer commandsMutex.Unlock()
+		if i < 0 {
+			writeJSONError(w, http.StatusNotFound, "Custom command not found")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, customCommands[i])
+	case http.MethodPut:
+		var m CustomCommand
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil || !validName(name) || strings.TrimSpace(m.Command) == "" {
+			commandsMutex.Unlock()
+			writeJSONError(w, http.StatusBadRequest, "command is required")
+			return
+		}
+		if !allowCommand(w, r, m.Command) {
+			commandsMutex.Unlock()
+			return
+		}
+		m.Name = name
+		status := http.StatusOK
+		if i < 0 {
+			m.CreatedAt = time.Now()
+			customCommands = append(customCommands, m)
+			status = http.StatusCreated
+		} else {
+			m.CreatedAt, m.ExecutedAt = customCommands[i].CreatedAt, customCommands[i].ExecutedAt
+			customCommands[i] = m
+		}
+		saveMacros()
+		commandsMutex.Unlock()
+		configChanged()
+		writeJSONResponse(w, status, m)
+	case http.MethodDelete:
+		if i < 0 {
+			commandsMutex.Unlock()
+			writeJSONError(w, http.StatusNotFound, "Custom command not found")
+			return
+		}
+		customCommands = append(customCommands[:i], customCommands[i+1:]...)
+		saveMacros()
+		commandsMutex.Unlock()
+		configChanged()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Custom command deleted"})
+	default:
+		commandsMutex.Unlock()
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -3,26 +3,37 @@ package main
 import (
 	"archive/zip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
-const (
-	fifoPath                     = "/shared/command_fifo"
-	behaviorPacksDir             = "/data/behavior_packs"
-	resourcePacksDir             = "/data/resource_packs"
-	serverPropsPath              = "/data/server.properties"
-	behaviorPackArchiveDir       = "/data/pack_archives/behavior"
-	resourcePackArchiveDir       = "/data/pack_archives/resource"
-	maxUploadSize          int64 = 10 << 20 // 10 MB
+const maxUploadSize int64 = 10 << 20 // 10 MB
+
+// Filesystem layout. Paths derive from the data root unless set on their
+// own (see settings.go), so the sidecar can run outside the Linux container
+// layout (for example next to a Windows dedicated server install) and
+// several sidecars can share a host.
+var (
+	fifoPath               = settingOrDefault("command-pipe", defaultCommandPipe())
+	dataDir                = settingOrDefault("data-dir", "/data")
+	behaviorPacksDir       = settingOrDefault("behavior-packs-dir", filepath.Join(dataDir, "behavior_packs"))
+	resourcePacksDir       = settingOrDefault("resource-packs-dir", filepath.Join(dataDir, "resource_packs"))
+	serverPropsPath        = settingOrDefault("server-properties", filepath.Join(dataDir, "server.properties"))
+	behaviorPackArchiveDir = filepath.Join(dataDir, "pack_archives", "behavior")
+	resourcePackArchiveDir = filepath.Join(dataDir, "pack_archives", "resource")
+	stateDir               = settingOrDefault("state-dir", filepath.Join(dataDir, "sidecar"))
+	listenPort             = settingOrDefault("port", "8080")
 )
 
 // ActiveAddon represents an entry in the world JSON files.
@@ -52,10 +63,11 @@ type CustomCommand struct {
 
 // PlayerCoords represents a player's current coordinates
 type PlayerCoords struct {
-	Name string  `json:"name"`
-	X    float64 `json:"x"`
-	Y    float64 `json:"y"`
-	Z    float64 `json:"z"`
+	Name      string  `json:"name"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Z         float64 `json:"z"`
+	Dimension string  `json:"dimension,omitempty"`
 }
 
 // SpawnPoint represents a predefined spawn location
@@ -110,13 +122,103 @@ func getWorldFolder() (string, error) {
 				if levelName == "" {
 					return "", fmt.Errorf("level-name is empty in server.properties")
 				}
-				return filepath.Join("/data/worlds", levelName), nil
+				return filepath.Join(dataDir, "worlds", levelName), nil
 			}
 		}
 	}
 	return "", fmt.Errorf("level-name not found in %s", serverPropsPath)
 }
 
+// getServerProperty returns the value of key from server.properties, or def
+// when the key is absent.
+func getServerProperty(key, def string) (string, error) {
+	data, err := os.ReadFile(serverPropsPath)
+	if err != nil {
+		return def, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return strings.TrimSpace(parts[1]), nil
+		}
+	}
+	return def, nil
+}
+
+// readServerProperties returns every key in server.properties.
+func readServerProperties() (map[string]string, error) {
+	data, err := os.ReadFile(serverPropsPath)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return props, nil
+}
+
+// setServerProperty sets key to value in server.properties, preserving all
+// other lines, and appends the key when it is not present.
+func setServerProperty(key, value string) error {
+	return setServerProperties(map[string]string{key: value})
+}
+
+// setServerProperties applies several properties in one atomic write.
+// Existing keys are updated in place and new keys are appended in sorted
+// order.
+func setServerProperties(values map[string]string) error {
+	data, err := os.ReadFile(serverPropsPath)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	found := make(map[string]bool)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		parts := strings.SplitN(trimmed, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if value, ok := values[key]; ok {
+			lines[i] = key + "=" + value
+			found[key] = true
+		}
+	}
+	var missing []string
+	for key := range values {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		if len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = append(lines[:len(lines)-1], key+"="+values[key], "")
+		} else {
+			lines = append(lines, key+"="+values[key])
+		}
+	}
+	// Write a temporary file and rename it so the server never reads a
+	// half-written file.
+	tmp := filepath.Join(filepath.Dir(serverPropsPath), ".server.properties.tmp")
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, serverPropsPath)
+}
+
 // ensureArchiveDirectories creates the archive directory structure
 func ensureArchiveDirectories() error {
 	dirs := []string{behaviorPackArchiveDir, resourcePackArchiveDir}
@@ -391,20 +493,16 @@ func sendCommandHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusBadRequest, "Empty command")
 		return
 	}
-	fifo, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
-	if err != nil {
-		log.Printf("Error opening FIFO file: %v", err)
-		writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+	if !allowCommand(w, r, command) {
 		return
 	}
-	defer fifo.Close()
-	_, err = fifo.Write([]byte(command + "\n"))
-	if err != nil {
-		log.Printf("Error writing to FIFO: %v", err)
+	if err := sendServerCommand(command); err != nil {
+		log.Printf("Error sending command: %v", err)
 		writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 	log.Printf("Command sent: %s", command)
+	publishEvent("command_executed", "Command sent: "+command, map[string]interface{}{"command": command, "source": "api"})
 	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Command sent successfully"})
 }
 
@@ -482,170 +580,235 @@ func uploadMcAddonHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	tmpFile.Close()
 
-	zipReader, err := zip.OpenReader(tmpFile.Name())
+	packs, err := installMcAddonFile(tmpFile.Name())
 	if err != nil {
-		log.Printf("Error opening zip archive: %v", err)
-		writeJSONError(w, http.StatusBadRequest, "Invalid mcaddon file")
-		return
-	}
-	defer zipReader.Close()
-
-	extractDir, err := os.MkdirTemp("", "mcaddon-extract")
-	if err != nil {
-		log.Printf("Error creating temporary extraction directory: %v", err)
+		if errors.Is(err, errInvalidAddon) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid mcaddon file")
+			return
+		}
+		log.Printf("Error installing mcaddon: %v", err)
 		writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
-	defer os.RemoveAll(extractDir)
 
-	for _, f := range zipReader.File {
-		fpath := filepath.Join(extractDir, f.Name)
-		if !strings.HasPrefix(fpath, filepath.Clean(extractDir)+string(os.PathSeparator)) {
-			log.Printf("illegal file path: %s", fpath)
-			continue
-		}
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(fpath, os.ModePerm)
-			continue
-		}
-		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-			log.Printf("Error creating directory: %v", err)
-			continue
-		}
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			log.Printf("Error opening file for extraction: %v", err)
-			continue
-		}
-		rc, err := f.Open()
-		if err != nil {
-			outFile.Close()
-			log.Printf("Error opening file in zip: %v", err)
-			continue
-		}
-		_, err = io.Copy(outFile, rc)
-		outFile.Close()
-		rc.Close()
-		if err != nil {
-			log.Printf("Error extracting file: %v", err)
-			continue
-		}
-	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "mcaddon processed and installed successfully", "packs": packs})
+}
 
-	// Process extracted mcpacks - look for them recursively
-	behaviorMcpacks := []string{}
-	resourceMcpacks := []string{}
+var errInvalidAddon = errors.New("invalid mcaddon file")
 
-	filepath.Walk(extractDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// Outcomes of installing an uploaded pack.
+const (
+	packInstalled = "install"
+	packUpgraded  = "upgrade"
+	packNoop      = "noop"
+)
+
+// InstalledPack is a pack installed from an uploaded archive. Action says
+// whether it was new, replaced an older copy or left the installed copy
+// alone because it was the same or newer.
+type InstalledPack struct {
+	UUID            string `json:"uuid"`
+	Type            string `json:"type"`
+	Version         []int  `json:"version"`
+	Folder          string `json:"folder"`
+	Action          string `json:"action"`
+	PreviousVersion []int  `json:"previous_version,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// compareVersions compares two pack header versions element by element,
+// returning -1, 0 or 1.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
 		}
-		if info.IsDir() {
-			return nil
+		if i < len(b) {
+			y = b[i]
 		}
-
-		lower := strings.ToLower(path)
-		if !strings.HasSuffix(lower, ".mcpack") && !strings.HasSuffix(lower, ".zip") {
-			return nil
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
 		}
+	}
+	return 0
+}
 
-		// Try to determine pack type by reading manifest
-		reader, err := zip.OpenReader(path)
+// installOrUpgradePack installs an unpacked pack unless a copy with the same
+// UUID is already installed at the same or a newer version. An upgrade
+// replaces the old folder and updates the world's pack list if the pack is
+// activated there.
+func installOrUpgradePack(dir, kind string, m packManifest) (InstalledPack, error) {
+	p := InstalledPack{UUID: m.Header.UUID, Type: kind, Version: m.Header.Version, Action: packInstalled}
+	targetRoot := behaviorPacksDir
+	if kind == "resource" {
+		targetRoot = resourcePacksDir
+	}
+	if existing, err := findPackByUUID(targetRoot, p.UUID); err == nil && existing != "" {
+		old, _, err := readPackManifest(existing)
 		if err != nil {
-			return nil
+			return p, err
 		}
-		defer reader.Close()
-
-		isResource := false
-		for _, f := range reader.File {
-			if f.Name == "manifest.json" {
-				rc, _ := f.Open()
-				if rc != nil {
-					data, _ := io.ReadAll(rc)
-					rc.Close()
-					var manifest Manifest
-					if err := json.Unmarshal(data, &manifest); err == nil {
-						// Try to identify type from directory structure or manifest
-						// For now, we'll check if it's in a "resource" subfolder or similar
-						if strings.Contains(filepath.ToSlash(path), "resource") {
-							isResource = true
-						}
-					}
-				}
-				break
+		p.PreviousVersion = old.Header.Version
+		if cmp := compareVersions(p.Version, old.Header.Version); cmp <= 0 {
+			p.Action, p.Folder = packNoop, filepath.Base(existing)
+			p.Reason = "installed version is the same"
+			if cmp < 0 {
+				p.Reason = "installed version is newer"
 			}
+			return p, nil
 		}
-
-		if isResource {
-			resourceMcpacks = append(resourceMcpacks, path)
-		} else {
-			behaviorMcpacks = append(behaviorMcpacks, path)
+		p.Action = packUpgraded
+	}
+	folder, err := installPackDir(dir, kind, p.UUID)
+	if err != nil {
+		return p, err
+	}
+	p.Folder = folder
+	if p.Action == packUpgraded {
+		if _, err := updatePackActivationVersion(kind, p.UUID, p.Version); err != nil {
+			return p, fmt.Errorf("updating world pack list: %w", err)
 		}
-
-		return nil
+	}
+	verb := "Installed"
+	if p.Action == packUpgraded {
+		verb = "Upgraded"
+	}
+	publishEvent("addon_installed", fmt.Sprintf("%s %s pack %s", verb, kind, folder), map[string]interface{}{
+		"uuid": p.UUID, "type": kind, "version": p.Version, "folder": folder, "action": p.Action,
 	})
+	return p, nil
+}
 
-	// Save behavior packs to archive and extract
-	for _, mcpackPath := range behaviorMcpacks {
-		archivePath, _, err := saveMcpackToArchive(mcpackPath, "behavior")
-		if err != nil {
-			log.Printf("Error saving behavior pack to archive: %v", err)
-			continue
-		}
-		log.Printf("Saved behavior pack to archive: %s", archivePath)
+// installMcAddonFile extracts an .mcaddon archive and installs every pack it
+// contains, archiving each one so it can be restored later. Packs are found
+// by their manifest.json wherever they sit in the archive, including inside
+// nested .mcpack files, and classified by module type rather than by folder
+// name.
+func installMcAddonFile(path string) ([]InstalledPack, error) {
+	extractDir, err := os.MkdirTemp("", "mcaddon-extract")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary extraction directory: %w", err)
+	}
+	defer os.RemoveAll(extractDir)
+	if err := extractMcpackToDir(path, extractDir); err != nil {
+		log.Printf("Error opening zip archive: %v", err)
+		return nil, errInvalidAddon
+	}
+	if err := expandNestedPacks(extractDir); err != nil {
+		return nil, err
+	}
 
-		// Extract to installation directory
-		tmpExtractDir, err := os.MkdirTemp("", "extract-bp")
+	dirs, err := findPackDirs(extractDir)
+	if err != nil {
+		return nil, err
+	}
+	installed := []InstalledPack{}
+	for _, dir := range dirs {
+		m, kind, err := readPackManifest(dir)
 		if err != nil {
-			log.Printf("Error creating temp extraction dir: %v", err)
+			log.Printf("Skipping pack in %s: %v", filepath.Base(dir), err)
 			continue
 		}
-		if err := extractMcpackToDir(mcpackPath, tmpExtractDir); err != nil {
-			log.Printf("Error extracting behavior pack: %v", err)
-			os.RemoveAll(tmpExtractDir)
+		if kind == "" {
+			log.Printf("Skipping %s: no data, script or resources module", m.Header.UUID)
 			continue
 		}
-		if err := copyDir(tmpExtractDir, behaviorPacksDir); err != nil {
-			log.Printf("Error copying behavior pack: %v", err)
+		p, err := installOrUpgradePack(dir, kind, m)
+		if err != nil {
+			return installed, fmt.Errorf("installing %s pack %s: %w", kind, m.Header.UUID, err)
 		}
-		os.RemoveAll(tmpExtractDir)
+		log.Printf("%s %s pack %s %v in %s", p.Action, kind, p.UUID, p.Version, p.Folder)
+		installed = append(installed, p)
 	}
+	if len(installed) == 0 {
+		return nil, errInvalidAddon
+	}
+	return installed, nil
+}
 
-	// Save resource packs to archive and extract
-	for _, mcpackPath := range resourceMcpacks {
-		archivePath, _, err := saveMcpackToArchive(mcpackPath, "resource")
+// expandNestedPacks extracts every .mcpack or .zip below root into a folder
+// named after the file, so their manifests can be found.
+func expandNestedPacks(root string) error {
+	var archives []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			log.Printf("Error saving resource pack to archive: %v", err)
-			continue
+			return err
 		}
-		log.Printf("Saved resource pack to archive: %s", archivePath)
-
-		// Extract to installation directory
-		tmpExtractDir, err := os.MkdirTemp("", "extract-rp")
-		if err != nil {
-			log.Printf("Error creating temp extraction dir: %v", err)
-			continue
+		lower := strings.ToLower(d.Name())
+		if !d.IsDir() && (strings.HasSuffix(lower, ".mcpack") || strings.HasSuffix(lower, ".zip")) {
+			archives = append(archives, path)
 		}
-		if err := extractMcpackToDir(mcpackPath, tmpExtractDir); err != nil {
-			log.Printf("Error extracting resource pack: %v", err)
-			os.RemoveAll(tmpExtractDir)
-			continue
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, archive := range archives {
+		dir := strings.TrimSuffix(archive, filepath.Ext(archive))
+		if _, err := os.Stat(dir); err == nil {
+			dir += "_pack"
 		}
-		if err := copyDir(tmpExtractDir, resourcePacksDir); err != nil {
-			log.Printf("Error copying resource pack: %v", err)
+		if err := extractMcpackToDir(archive, dir); err != nil {
+			log.Printf("Skipping %s: %v", filepath.Base(archive), err)
+			continue
 		}
-		os.RemoveAll(tmpExtractDir)
+		os.Remove(archive)
 	}
+	return nil
+}
 
-	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "mcaddon processed and installed successfully"})
+// installMcpackFile installs a single .mcpack. Its manifest decides the
+// pack type, falling back to packType when it has no recognised module.
+// A copy with the same UUID is upgraded rather than duplicated.
+func installMcpackFile(mcpackPath, packType string) error {
+	tmpExtractDir, err := os.MkdirTemp("", "extract-pack")
+	if err != nil {
+		return fmt.Errorf("creating temp extraction dir: %w", err)
+	}
+	defer os.RemoveAll(tmpExtractDir)
+	name := strings.TrimSuffix(filepath.Base(mcpackPath), filepath.Ext(mcpackPath))
+	dir := filepath.Join(tmpExtractDir, name)
+	if err := extractMcpackToDir(mcpackPath, dir); err != nil {
+		return fmt.Errorf("extracting %s pack: %w", packType, err)
+	}
+	dirs, err := findPackDirs(dir)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("%s: manifest.json not found", filepath.Base(mcpackPath))
+	}
+	m, kind, err := readPackManifest(dirs[0])
+	if err != nil {
+		return err
+	}
+	if kind == "" {
+		kind = packType
+	}
+	p, err := installOrUpgradePack(dirs[0], kind, m)
+	if err != nil {
+		return fmt.Errorf("installing %s pack: %w", kind, err)
+	}
+	log.Printf("%s %s pack %s %v in %s", p.Action, kind, p.UUID, p.Version, p.Folder)
+	return nil
 }
 
-// copyDir recursively copies a directory tree from src to dst.
+// copyDir recursively copies a directory tree from src to dst. Symlinks and
+// other special files are skipped rather than followed, so a tree from an
+// upload or a git repository cannot pull in files from outside it.
 func copyDir(src string, dst string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			log.Printf("Skipping %s while copying: not a regular file", path)
+			return nil
+		}
 		relPath, err := filepath.Rel(src, path)
 		if err != nil {
 			return err
@@ -772,6 +935,11 @@ func activeAddonsHandler(w http.ResponseWriter, r *http.Request) {
 
 // uiHandler serves the web UI
 func uiHandler(w http.ResponseWriter, r *http.Request) {
+	// The game's /connect command opens a WebSocket on the root path.
+	if isWebSocketUpgrade(r) {
+		mcwsConnectHandler(w, r)
+		return
+	}
 	html := `<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -1056,6 +1224,24 @@ func uiHandler(w http.ResponseWriter, r *http.Request) {
 
     <script src="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/js/bootstrap.bundle.min.js"></script>
     <script>
+        // Send the stored API key with every request, asking for a new one
+        // when the sidecar rejects it.
+        const plainFetch = window.fetch.bind(window);
+        window.fetch = async function (url, options = {}) {
+            const withKey = () => plainFetch(url, Object.assign({}, options, {
+                headers: Object.assign({}, options.headers, { 'X-API-Key': localStorage.getItem('apiKey') || '' })
+            }));
+            let response = await withKey();
+            if (response.status === 401) {
+                const key = prompt('API key');
+                if (key) {
+                    localStorage.setItem('apiKey', key);
+                    response = await withKey();
+                }
+            }
+            return response;
+        };
+
         async function executeCommand(command) {
             try {
                 const response = await fetch('/send-command', {
@@ -1204,8 +1390,13 @@ func uiHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, html)
 }
 
-// playerCoordsHandler returns approximate player coordinates (simulated)
+// playerCoordsHandler returns player coordinates reported by the script
+// bridge, falling back to simulated data when the bridge is not installed.
 func playerCoordsHandler(w http.ResponseWriter, r *http.Request) {
+	if players := bridgePlayerPositions(); len(players) > 0 {
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"players": players, "source": "bridge"})
+		return
+	}
 	// In a real implementation, you'd read this from world data
 	// For now, return mock data
 	players := []PlayerCoords{
@@ -1226,11 +1417,21 @@ func addCustomCommandHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusBadRequest, "Invalid request")
 		return
 	}
+	if !allowCommand(w, r, req.Command) {
+		return
+	}
 	req.CreatedAt = time.Now()
 
 	commandsMutex.Lock()
+	if findMacro(req.Name) >= 0 {
+		commandsMutex.Unlock()
+		writeJSONError(w, http.StatusConflict, "Custom command already exists")
+		return
+	}
 	customCommands = append(customCommands, req)
+	saveMacros()
 	commandsMutex.Unlock()
+	configChanged()
 
 	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Custom command added"})
 }
@@ -1263,20 +1464,28 @@ func executeCustomCommandHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusNotFound, "Command not found")
 		return
 	}
-	customCommands[index].ExecutedAt = time.Now()
+	// Panels acting for a player name them so group policy can be applied.
+	if player := r.Header.Get("X-On-Behalf-Of"); player != "" && !playerMayRunMacro(player, customCommands[index].Name) {
+		commandsMutex.Unlock()
+		writeJSONError(w, http.StatusForbidden, "Player is not allowed to run this command")
+		return
+	}
 	cmd := customCommands[index]
 	commandsMutex.Unlock()
-
-	// Execute the command
-	fifo, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
-	if err != nil {
-		writeJSONError(w, http.StatusInternalServerError, "Failed to execute command")
+	// The policy may have changed since the command was saved.
+	if !allowCommand(w, r, cmd.Command) {
 		return
 	}
-	defer fifo.Close()
+	commandsMutex.Lock()
+	if i := findMacro(cmd.Name); i >= 0 {
+		customCommands[i].ExecutedAt = time.Now()
+	}
+	commandsMutex.Unlock()
+	observeActivity(r.Header.Get("X-On-Behalf-Of"), activityCommand)
 
-	_, err = fifo.Write([]byte(cmd.Command + "\n"))
-	if err != nil {
+	// Execute the command
+	if err := sendServerCommand(cmd.Command); err != nil {
+		log.Printf("Error sending custom command: %v", err)
 		writeJSONError(w, http.StatusInternalServerError, "Failed to execute command")
 		return
 	}
@@ -1305,7 +1514,9 @@ func deleteCustomCommandHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	customCommands = append(customCommands[:index], customCommands[index+1:]...)
+	saveMacros()
 	commandsMutex.Unlock()
+	configChanged()
 
 	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Custom command deleted"})
 }
@@ -1360,20 +1571,17 @@ func teleportToSpawnHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Construct teleport command for all players
 	cmd := fmt.Sprintf("tp @a %.2f %.2f %.2f", sp.X, sp.Y, sp.Z)
-	fifo, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
-	if err != nil {
-		writeJSONError(w, http.StatusInternalServerError, "Failed to open FIFO")
-		return
-	}
-	defer fifo.Close()
-	if _, err := fifo.Write([]byte(cmd + "\n")); err != nil {
-		writeJSONError(w, http.StatusInternalServerError, "Failed to write to FIFO")
+	if err := sendServerCommand(cmd); err != nil {
+		log.Printf("Error sending teleport command: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to send command")
 		return
 	}
 	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Teleported to spawn", "command": cmd})
 }
 
 func main() {
+	checkSettings()
+
 	// Initialize archive directories
 	if err := ensureArchiveDirectories(); err != nil {
 		log.Fatalf("Failed to initialize archive directories: %v", err)
@@ -1384,26 +1592,449 @@ func main() {
 		log.Printf("Error during pack restoration: %v", err)
 	}
 
+	// Provision an empty data volume from the boot spec, or else render
+	// server.properties from the environment's template, if any
+	if err := loadState(tenantValuesStateFile, &tenantValues); err != nil {
+		log.Printf("Error loading tenant values: %v", err)
+	}
+	if tenantValues == nil {
+		tenantValues = map[string]string{}
+	}
+	if !provisionOnBoot() {
+		applyStartupPropertyTemplate()
+	}
+	enableContentLog()
+
+	// Select how console commands reach the server
+	transport, err := newCommandTransport(os.Getenv(transportEnv))
+	if err != nil {
+		log.Fatalf("Invalid command transport: %v", err)
+	}
+	commandTransport = transport
+
+	// Run the server as a child process in supervisor mode
+	startSupervisor()
+	log.Printf("Using %s command transport", commandTransport.Name())
+
+	// Validate the container environment before serving
+	logSelfTest(runSelfTest())
+
+	// Load lag mitigation rules and start evaluating them
+	if err := loadMitigations(); err != nil {
+		log.Printf("Error loading mitigation rules: %v", err)
+	}
+	startMitigationLoop()
+
+	// Load alert rules and start evaluating them
+	if err := loadAlertRules(); err != nil {
+		log.Printf("Error loading alert rules: %v", err)
+	}
+	startAlertRuleEngine()
+
+	// Put the server to sleep when nobody is playing
+	startHibernationLoop()
+
+	// Track player counts for autoscalers
+	startPlayerSampling()
+
+	// Schedule world resets
+	if err := loadState(worldResetsStateFile, &worldResetJobs); err != nil {
+		log.Printf("Error loading world reset jobs: %v", err)
+	}
+	startWorldResetScheduler()
+
+	// Rotate worlds on schedule
+	if err := loadState(rotationStateFile, &rotation); err != nil {
+		log.Printf("Error loading world rotation: %v", err)
+	}
+	startRotationScheduler()
+
+	// Expire sandbox worlds
+	if err := loadState(sandboxStateFile, &activeSandbox); err != nil {
+		log.Printf("Error loading sandbox state: %v", err)
+	}
+	startSandboxReaper()
+	startTrashReaper()
+	startResourceSampler()
+	startMetricsPush()
+
+	// Load the structure library index
+	if err := loadState(structuresStateFile, &structureLibrary); err != nil {
+		log.Printf("Error loading structure library: %v", err)
+	}
+
+	// Load permission groups
+	if err := loadState(groupsStateFile, &groups); err != nil {
+		log.Printf("Error loading permission groups: %v", err)
+	}
+	if groups.Members == nil {
+		groups.Members = map[string][]string{}
+	}
+	if groups.Tags == nil {
+		groups.Tags = map[string][]string{}
+	}
+	if groups.TagRemovals == nil {
+		groups.TagRemovals = map[string][]string{}
+	}
+
+	// Load stream event mappings
+	if err := loadState(streamStateFile, &streamMappings); err != nil {
+		log.Printf("Error loading stream mappings: %v", err)
+	}
+
+	// Load player-scoped API tokens
+	if err := loadState(playerTokensStateFile, &playerTokens); err != nil {
+		log.Printf("Error loading player tokens: %v", err)
+	}
+
+	// Load player homes
+	if err := loadState(homesStateFile, &homes); err != nil {
+		log.Printf("Error loading homes: %v", err)
+	}
+	if homes.Homes == nil {
+		homes.Homes = map[string]map[string]Home{}
+	}
+	if homes.Config.GroupLimits == nil {
+		homes.Config.GroupLimits = map[string]int{}
+	}
+
+	// Load the points ledger and shop
+	if err := loadState(pointsStateFile, &points); err != nil {
+		log.Printf("Error loading points ledger: %v", err)
+	}
+	if points.Balances == nil {
+		points.Balances = map[string]int64{}
+	}
+	if points.Transactions == nil {
+		points.Transactions = map[string][]PointsTransaction{}
+	}
+	if err := loadState(shopStateFile, &shopItems); err != nil {
+		log.Printf("Error loading shop: %v", err)
+	}
+
+	// Load player stats and daily rewards
+	if err := loadState(playerStatsStateFile, &playerStats); err != nil {
+		log.Printf("Error loading player stats: %v", err)
+	}
+	if playerStats == nil {
+		playerStats = map[string]*PlayerStats{}
+	}
+	if err := loadState(dailyRewardsStateFile, &dailyRewards); err != nil {
+		log.Printf("Error loading daily rewards: %v", err)
+	}
+
+	// Load quests and start evaluating playtime conditions
+	if err := loadState(questsStateFile, &quests); err != nil {
+		log.Printf("Error loading quests: %v", err)
+	}
+	if quests.Progress == nil {
+		quests.Progress = map[string]map[string]*QuestProgress{}
+	}
+	startQuestLoop()
+
+	// Load timed player effects
+	if err := loadState(effectsStateFile, &playerEffects); err != nil {
+		log.Printf("Error loading player effects: %v", err)
+	}
+	startEffectLoop()
+
+	// Load spawn points set through the API
+	if err := loadState(playerSpawnsStateFile, &playerSpawns); err != nil {
+		log.Printf("Error loading player spawns: %v", err)
+	}
+	if playerSpawns == nil {
+		playerSpawns = map[string]PlayerSpawn{}
+	}
+
+	// Load the entity IDs used to find offline players' saved inventories
+	if err := loadState(playerEntityIDsStateFile, &playerEntityIDs); err != nil {
+		log.Printf("Error loading player entity IDs: %v", err)
+	}
+	if playerEntityIDs == nil {
+		playerEntityIDs = map[string]string{}
+	}
+
+	// Load weather and time locks and keep them applied
+	if err := loadState(environmentStateFile, &environmentLocks); err != nil {
+		log.Printf("Error loading environment locks: %v", err)
+	}
+	startEnvironmentLockLoop()
+
+	// Load leaderboards and start mirroring them in game
+	if err := loadState(leaderboardsStateFile, &leaderboards); err != nil {
+		log.Printf("Error loading leaderboards: %v", err)
+	}
+	if leaderboards.Scores == nil {
+		leaderboards.Scores = map[string]map[string]int64{}
+	}
+	startLeaderboardMirror()
+
+	// Load region snapshots used by rollbacks
+	if err := loadState(snapshotsStateFile, &regionSnapshots); err != nil {
+		log.Printf("Error loading region snapshots: %v", err)
+	}
+
+	// Load suspicious activity rules and temporary bans
+	if err := loadState(securityStateFile, &security); err != nil {
+		log.Printf("Error loading security rules: %v", err)
+	}
+	if security.Bans == nil {
+		security.Bans = map[string]time.Time{}
+	}
+	if err := loadState(bansStateFile, &bans); err != nil {
+		log.Printf("Error loading bans: %v", err)
+	}
+	if bans == nil {
+		bans = []Ban{}
+	}
+
+	// Load the data erasure audit trail
+	if err := loadState(erasureAuditStateFile, &erasureAudit); err != nil {
+		log.Printf("Error loading erasure audit: %v", err)
+	}
+
+	// Load retention windows and start pruning
+	if err := loadState(retentionStateFile, &retention); err != nil {
+		log.Printf("Error loading retention settings: %v", err)
+	}
+	if retention.Days == nil {
+		retention.Days = map[string]int{}
+	}
+	if retention.Status == nil {
+		retention.Status = map[string]*RetentionCategoryStatus{}
+	}
+	startRetentionLoop()
+
+	// Load inbound automation hooks
+	if err := loadState(hooksStateFile, &inboundHooks); err != nil {
+		log.Printf("Error loading inbound hooks: %v", err)
+	}
+
+	// Load custom commands and warps
+	if err := loadState(macrosStateFile, &customCommands); err != nil {
+		log.Printf("Error loading custom commands: %v", err)
+	}
+	if err := loadState(warpsStateFile, &warps); err != nil {
+		log.Printf("Error loading warps: %v", err)
+	}
+
+	if err := loadState(processTuningStateFile, &processTuning); err != nil {
+		log.Printf("Error loading process tuning: %v", err)
+	}
+
+	// Load cron jobs, then let the declarative config file override them
+	if err := loadState(cronJobsStateFile, &cronJobs); err != nil {
+		log.Printf("Error loading cron jobs: %v", err)
+	}
+	if path := os.Getenv(configFileEnv); path != "" {
+		if err := loadConfigFile(path); err != nil {
+			log.Fatalf("Error loading config file %s: %v", path, err)
+		}
+		startConfigWatcher(path)
+	}
+	startCronScheduler()
+	loadTransferLimits()
+	loadBackupCompression()
+	loadBackupSchedule()
+	initRemoteBackupStorage()
+	loadDebugLogging()
+	loadConsoleSessions()
+	if err := loadState(commandPolicyStateFile, &commandRules); err != nil {
+		log.Printf("Error loading command policy: %v", err)
+	}
+	if commandRules == nil {
+		commandRules = []CommandRule{}
+	}
+	if err := loadState(notificationsStateFile, &notificationChannels); err != nil {
+		log.Printf("Error loading notification channels: %v", err)
+	}
+	if notificationChannels == nil {
+		notificationChannels = []NotificationChannel{}
+	}
+	startEventBus()
+	startConsoleTail()
+	startStandbyLoop()
+	startDevPackWatcher()
+	startScriptErrorTracker()
+	startLogEvents()
+	startAvailabilityProbe()
+	startLatencyProbe()
+	startWatchdog()
+
 	// Generate some spawn points on boot
 	generateSpawnPoints(5)
 
-	http.HandleFunc("/", uiHandler)
-	http.HandleFunc("/send-command", sendCommandHandler)
-	http.HandleFunc("/list-addons", listAddonsHandler)
-	http.HandleFunc("/upload-mcaddon", uploadMcAddonHandler)
-	http.HandleFunc("/active-addons", activeAddonsHandler)
-	http.HandleFunc("/player-coords", playerCoordsHandler)
-	http.HandleFunc("/add-custom-command", addCustomCommandHandler)
-	http.HandleFunc("/get-custom-commands", getCustomCommandsHandler)
-	http.HandleFunc("/execute-custom-command/", executeCustomCommandHandler)
-	http.HandleFunc("/delete-custom-command/", deleteCustomCommandHandler)
-	http.HandleFunc("/spawn-points", spawnPointsHandler)
-	http.HandleFunc("/teleport-to-spawn/", teleportToSpawnHandler)
-
-	port := "8080"
-	log.Printf("Starting sidecar command server on port %s...", port)
-	log.Printf("Web UI available at http://localhost:%s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	// Use a dedicated mux so importing net/http/pprof does not expose
+	// profiling handlers on the default mux without authentication.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", uiHandler)
+	mux.HandleFunc("/send-command", sendCommandHandler)
+	mux.HandleFunc("/send-command/policy", requireAdmin(commandPolicyHandler))
+	mux.HandleFunc("/list-addons", sparseFields(listAddonsHandler))
+	mux.HandleFunc("/upload-mcaddon", uploadMcAddonHandler)
+	mux.HandleFunc("/upload-mcworld", requireAdmin(uploadMcworldHandler))
+	mux.HandleFunc("/worlds", sparseFields(worldsHandler))
+	mux.HandleFunc("/worlds/", sparseFields(worldHandler))
+	mux.HandleFunc("/allowlist/import", requireAdmin(allowlistImportHandler))
+	mux.HandleFunc("/bans", requireAdmin(bansHandler))
+	mux.HandleFunc("/bans/", requireAdmin(banHandler))
+	mux.HandleFunc("/permissions", requireAdmin(permissionsHandler))
+	mux.HandleFunc("/permissions/", requireAdmin(permissionHandler))
+	mux.HandleFunc("/active-addons", sparseFields(activeAddonsHandler))
+	mux.HandleFunc("/activate-addon", activateAddonHandler)
+	mux.HandleFunc("/deactivate-addon", deactivateAddonHandler)
+	mux.HandleFunc("/reorder-addons", reorderAddonsHandler)
+	mux.HandleFunc("/player-coords", playerCoordsHandler)
+	mux.HandleFunc("/add-custom-command", addCustomCommandHandler)
+	mux.HandleFunc("/get-custom-commands", getCustomCommandsHandler)
+	mux.HandleFunc("/execute-custom-command/", executeCustomCommandHandler)
+	mux.HandleFunc("/delete-custom-command/", deleteCustomCommandHandler)
+	mux.HandleFunc("/spawn-points", spawnPointsHandler)
+	mux.HandleFunc("/teleport-to-spawn/", teleportToSpawnHandler)
+	mux.HandleFunc("/mcws/connect", mcwsConnectHandler)
+	mux.HandleFunc("/mcws/status", mcwsStatusHandler)
+	mux.HandleFunc("/mcws/events", mcwsEventsHandler)
+	mux.HandleFunc("/bridge/install", bridgeInstallHandler)
+	mux.HandleFunc("/bridge/events", bridgeEventsHandler)
+	mux.HandleFunc("/entities/summary", sparseFields(entitiesSummaryHandler))
+	mux.HandleFunc("/mitigations", mitigationsHandler)
+	mux.HandleFunc("/mitigations/", mitigationHandler)
+	mux.HandleFunc("/alerts/rules", alertRulesHandler)
+	mux.HandleFunc("/alerts/rules/", alertRuleHandler)
+	mux.HandleFunc("/notifications", requireAdmin(notificationsHandler))
+	mux.HandleFunc("/notifications/test", requireAdmin(notificationsTestHandler))
+	mux.HandleFunc("/hibernation", hibernationHandler)
+	mux.HandleFunc("/hibernation/wake", hibernationWakeHandler)
+	mux.HandleFunc("/queue", queueHandler)
+	mux.HandleFunc("/queue/", queuePlayerHandler)
+	mux.HandleFunc("/autoscale", autoscaleHandler)
+	mux.HandleFunc("/autoscale/keda", autoscaleKEDAHandler)
+	mux.HandleFunc("/autoscale/metrics", autoscaleMetricsHandler)
+	mux.HandleFunc("/seed", seedHandler)
+	mux.HandleFunc("/seed/slime-chunks", slimeChunksHandler)
+	mux.HandleFunc("/seed/biome", biomeHandler)
+	mux.HandleFunc("/map", mapHandler)
+	mux.HandleFunc("/world-templates", worldTemplatesHandler)
+	mux.HandleFunc("/world-resets", worldResetsHandler)
+	mux.HandleFunc("/world-resets/", worldResetHandler)
+	mux.HandleFunc("/rotation", rotationHandler)
+	mux.HandleFunc("/rotation/advance", rotationAdvanceHandler)
+	mux.HandleFunc("/sandbox", sandboxHandler)
+	mux.HandleFunc("/structures", structuresHandler)
+	mux.HandleFunc("/structures/", structureHandler)
+	mux.HandleFunc("/groups", groupsHandler)
+	mux.HandleFunc("/groups/", groupHandler)
+	mux.HandleFunc("/tags", tagsHandler)
+	mux.HandleFunc("/tags/", tagHandler)
+	mux.HandleFunc("/chat/send", chatSendHandler)
+	mux.HandleFunc("/chat/events", chatEventsHandler)
+	mux.HandleFunc("/chat/stream", chatStreamHandler)
+	mux.HandleFunc("/stream/mappings", streamMappingsHandler)
+	mux.HandleFunc("/stream/history", streamHistoryHandler)
+	mux.HandleFunc("/stream/webhook/twitch", twitchWebhookHandler)
+	mux.HandleFunc("/stream/webhook/generic", genericStreamWebhookHandler)
+	mux.HandleFunc("/player-tokens", requireAdmin(playerTokensHandler))
+	mux.HandleFunc("/player-tokens/", requireAdmin(playerTokenHandler))
+	mux.HandleFunc("/me", requirePlayerToken(scopeProfileRead, meHandler))
+	mux.HandleFunc("/me/position", requirePlayerToken(scopePositionRead, mePositionHandler))
+	mux.HandleFunc("/homes/", homesHandler)
+	mux.HandleFunc("/homes-config", homesConfigHandler)
+	mux.HandleFunc("/me/homes", meHomesHandler)
+	mux.HandleFunc("/me/homes/", meHomesHandler)
+	mux.HandleFunc("/points/", requireAdmin(pointsHandler))
+	mux.HandleFunc("/shop", shopHandler)
+	mux.HandleFunc("/shop/buy", shopTradeHandler)
+	mux.HandleFunc("/shop/sell", shopTradeHandler)
+	mux.HandleFunc("/players", sparseFields(playerListHandler))
+	mux.HandleFunc("/players/bulk", playersBulkHandler)
+	mux.HandleFunc("/players/", sparseFields(playersHandler))
+	mux.HandleFunc("/daily-rewards", dailyRewardsHandler)
+	mux.HandleFunc("/quests", questsHandler)
+	mux.HandleFunc("/quests/", questHandler)
+	mux.HandleFunc("/effects", effectsHandler)
+	mux.HandleFunc("/effects/", effectHandler)
+	mux.HandleFunc("/spawn", spawnHandler)
+	mux.HandleFunc("/spawn/radius", spawnRadiusHandler)
+	mux.HandleFunc("/weather", weatherHandler)
+	mux.HandleFunc("/time", timeHandler)
+	mux.HandleFunc("/leaderboards", leaderboardsHandler)
+	mux.HandleFunc("/leaderboards/", leaderboardHandler)
+	mux.HandleFunc("/rollback", rollbackHandler)
+	mux.HandleFunc("/rollback/snapshots", rollbackSnapshotsHandler)
+	mux.HandleFunc("/security/rules", securityRulesHandler)
+	mux.HandleFunc("/security/", securityHandler)
+	mux.HandleFunc("/privacy/erasures", requireAdmin(erasureAuditHandler))
+	mux.HandleFunc("/retention", retentionHandler)
+	mux.HandleFunc("/hooks", requireAdmin(hooksHandler))
+	mux.HandleFunc("/hooks/", hookHandler)
+	mux.HandleFunc("/jobs", cronJobsHandler)
+	mux.HandleFunc("/jobs/", cronJobHandler)
+	mux.HandleFunc("/config-file", configFileHandler)
+	mux.HandleFunc("/macros", macrosHandler)
+	mux.HandleFunc("/macros/", macroHandler)
+	mux.HandleFunc("/warps", warpsHandler)
+	mux.HandleFunc("/warps/", warpHandler)
+	mux.HandleFunc("/pack-activations/", packActivationsHandler)
+	mux.HandleFunc("/openapi.json", openAPIHandler)
+	mux.HandleFunc("/docs/examples", docsExamplesHandler)
+	mux.HandleFunc("/docs/postman", docsPostmanHandler)
+	mux.HandleFunc("/events", requireAdmin(eventsStreamHandler))
+	mux.HandleFunc("/events/schema", eventsSchemaHandler)
+	mux.HandleFunc("/server-properties/templates", propertyTemplatesHandler)
+	mux.HandleFunc("/server-properties/templates/", propertyTemplateHandler)
+	mux.HandleFunc("/server-properties/apply-template", applyPropertyTemplateHandler)
+	mux.HandleFunc("/server-properties/tenant", tenantValuesHandler)
+	mux.HandleFunc("/tasks", tasksHandler)
+	mux.HandleFunc("/tasks/", tasksHandler)
+	mux.HandleFunc("/provision", requireAdmin(provisionHandler))
+	mux.HandleFunc("/clone-from", requireAdmin(cloneFromHandler))
+	mux.HandleFunc("/clone/", requireAdmin(cloneSourceHandler))
+	mux.HandleFunc("/sync", requireAdmin(syncHandler))
+	mux.HandleFunc("/sync/", requireAdmin(syncSourceHandler))
+	mux.HandleFunc("/standby", standbyHandler)
+	mux.HandleFunc("/failover", requireAdmin(failoverHandler))
+	mux.HandleFunc("/transfer-limits", requireAdmin(transferLimitsHandler))
+	mux.HandleFunc("/backups/compression", requireAdmin(backupCompressionHandler))
+	mux.HandleFunc("/backups/schedule", requireAdmin(backupScheduleHandler))
+	mux.HandleFunc("/backups/remote", requireAdmin(remoteBackupsHandler))
+	mux.HandleFunc("/backups", requireAdmin(backupsHandler))
+	mux.HandleFunc("/backups/", requireAdmin(backupHandler))
+	mux.HandleFunc("/restore", requireAdmin(restoreHandler))
+	mux.HandleFunc("/share", requireAdmin(shareHandler))
+	mux.HandleFunc("/trash", requireAdmin(trashHandler))
+	mux.HandleFunc("/trash/", requireAdmin(trashItemHandler))
+	mux.HandleFunc("/shared/", sharedHandler)
+	mux.HandleFunc("/console", requireAdmin(consoleHandler))
+	mux.HandleFunc("/console/sessions", requireAdmin(consoleSessionsHandler))
+	mux.HandleFunc("/console/sessions/", requireAdmin(consoleSessionHandler))
+	mux.HandleFunc("/logs", requireAdmin(logsHandler))
+	mux.HandleFunc("/server/resources", serverResourcesHandler)
+	mux.HandleFunc("/server/resources/metrics", serverResourcesMetricsHandler)
+	mux.HandleFunc("/metrics/dashboard", metricsDashboardHandler)
+	mux.HandleFunc("/server/process", requireAdmin(serverProcessHandler))
+	mux.HandleFunc("/server/supervisor", requireAdmin(supervisorHandler))
+	mux.HandleFunc("/server/", requireAdmin(serverHandler))
+	mux.HandleFunc("/api-keys", requireAdmin(apiKeysHandler))
+	mux.HandleFunc("/addons/install-from-git", requireAdmin(installFromGitHandler))
+	mux.HandleFunc("/dev/packs", sparseFields(devPacksHandler))
+	mux.HandleFunc("/dev/packs/events", devPackEventsHandler)
+	mux.HandleFunc("/script-errors", scriptErrorsHandler)
+	mux.HandleFunc("/addons/conflicts", addonConflictsHandler)
+	mux.HandleFunc("/addons/", contentWarningsHandler)
+	mux.HandleFunc("/debug/logging", requireAdmin(debugLoggingHandler))
+	mux.HandleFunc("/availability", sparseFields(availabilityHandler))
+	mux.HandleFunc("/availability/slo", requireAdmin(availabilitySLOHandler))
+	mux.HandleFunc("/status", sparseFields(serverStatusHandler))
+	mux.HandleFunc("/status/latency", sparseFields(latencyHandler))
+	mux.HandleFunc("/selftest", selfTestHandler)
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	registerDebugHandlers(mux)
+
+	log.Printf("Starting sidecar command server on port %s...", listenPort)
+	startAPIKeyWatcher()
+	startPublicListener()
+	if err := listenAndServe(":"+listenPort, logRequests(requireSignature(requireAPIKey(mux)))); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
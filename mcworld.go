@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importMcworld extracts a .mcworld archive into worldsDir. The world is
+// named name, or after the archive's levelname.txt when name is empty. It
+// returns the world's folder name.
+func importMcworld(archive, name string) (string, error) {
+	if err := os.MkdirAll(worldsDir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(worldsDir, ".incoming-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractArchive(archive, tmp); err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidWorld, err)
+	}
+	root, err := extractedWorldRoot(tmp)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidWorld, err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "db")); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: no db folder", errInvalidWorld)
+	}
+	if name == "" {
+		data, _ := os.ReadFile(filepath.Join(root, "levelname.txt"))
+		name = strings.TrimSpace(string(data))
+	}
+	if !validName(name) {
+		return name, errInvalidWorldName
+	}
+	dst := filepath.Join(worldsDir, name)
+	if _, err := os.Stat(dst); err == nil {
+		return name, errWorldExists
+	}
+	if err := os.Rename(root, dst); err != nil {
+		return "", err
+	}
+	log.Printf("Imported world %s from %s", name, filepath.Base(archive))
+	return name, nil
+}
+
+var (
+	errInvalidWorld     = errors.New("invalid mcworld file")
+	errInvalidWorldName = errors.New("invalid world name")
+	errWorldExists      = errors.New("world already exists")
+)
+
+// uploadMcworldHandler imports an uploaded .mcworld (multipart field
+// "file") as a new world, named by ?name= or the world's own level name.
+// With ?activate=true level-name is switched to it, restarting the server
+// if it is running.
+func uploadMcworldHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name != "" && !validName(name) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid world name")
+		return
+	}
+	archive, err := receiveWorldUpload(w, r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid upload: "+err.Error())
+		return
+	}
+	defer os.Remove(archive)
+
+	name, err = importMcworld(archive, name)
+	switch {
+	case errors.Is(err, errInvalidWorld):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, errInvalidWorldName):
+		writeJSONError(w, http.StatusBadRequest, "The world has no usable name; pass ?name=")
+		return
+	case errors.Is(err, errWorldExists):
+		writeJSONError(w, http.StatusConflict, "A world named "+name+" already exists")
+		return
+	case err != nil:
+		log.Printf("Error importing mcworld: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	activated := false
+	if r.URL.Query().Get("activate") == "true" {
+		apply := func() error { return setServerProperty("level-name", name) }
+		if serverUp() {
+			err = withServerStopped(apply)
+		} else {
+			err = apply()
+		}
+		if err != nil {
+			log.Printf("Error switching to world %s: %v", name, err)
+			writeJSONError(w, http.StatusInternalServerError, "World imported but switching to it failed: "+err.Error())
+			return
+		}
+		activated = true
+		log.Printf("Switched level-name to %s", name)
+	}
+	writeJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"message":   "World imported",
+		"world":     name,
+		"path":      filepath.Join(worldsDir, name),
+		"activated": activated,
+	})
+}
+
+// worldExportSource returns the folder to archive for an export and a
+// cleanup function. The active world of a running server is first copied
+// under "save hold" so the export is consistent.
+func worldExportSource(name string) (string, func(), error) {
+	src := filepath.Join(worldsDir, name)
+	if info, err := os.Stat(src); err != nil || !info.IsDir() {
+		return "", nil, os.ErrNotExist
+	}
+	if current, err := currentLevelName(); err != nil || current != name || !serverUp() {
+		return src, func() {}, nil
+	}
+	staging, err := os.MkdirTemp("", "world-export")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := copyWorldConsistent(src, staging); err != nil {
+		os.RemoveAll(staging)
+		return "", nil, err
+	}
+	return staging, func() { os.RemoveAll(staging) }, nil
+}
+
+// worldExportHandler serves GET /worlds/{name}/export, the world as a
+// .mcworld zip with level.dat at its root.
+func worldExportHandler(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	src, cleanup, err := worldExportSource(name)
+	if os.IsNotExist(err) {
+		writeJSONError(w, http.StatusNotFound, "World not found")
+		return
+	} else if err != nil {
+		log.Printf("Error copying world %s for export: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to copy the world")
+		return
+	}
+	defer cleanup()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.mcworld"`)
+	if _, err := writeArchive(limitedResponseWriter{w, uploadLimiter}, src, BackupCompression{Format: formatZip, Method: "deflate"}); err != nil {
+		// The status line has already been sent, so the client only sees
+		// a truncated download.
+		log.Printf("Error exporting world %s: %v", name, err)
+	}
+}
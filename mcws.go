@@ -0,0 +1,351 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Bedrock's in-game WebSocket protocol: a player with operator rights runs
+// "/connect <host>:<port>/?token=<token>" and the client opens a WebSocket to
+// the sidecar. We can then subscribe to game events and issue commands as
+// that player. The token is BEDROCK_API_MCWS_TOKEN; without it anyone who
+// can reach the port could inject chat events or take over the command
+// channel, so connections are refused until it is set.
+
+const (
+	mcwsEventBufferLen = 500
+	mcwsCommandTimeout = 10 * time.Second
+)
+
+// mcwsSubscriptions are the events requested from every connected client.
+var mcwsSubscriptions = []string{
+	"PlayerMessage",
+	"BlockPlaced",
+	"BlockBroken",
+	"PlayerTransform",
+	"PlayerDied",
+	"MobKilled",
+	"ItemUsed",
+}
+
+// MCWSHeader is the header of every message in the game's WebSocket protocol.
+type MCWSHeader struct {
+	Version        int    `json:"version"`
+	RequestID      string `json:"requestId"`
+	MessagePurpose string `json:"messagePurpose"`
+	MessageType    string `json:"messageType,omitempty"`
+	EventName      string `json:"eventName,omitempty"`
+}
+
+// MCWSMessage is a single protocol message.
+type MCWSMessage struct {
+	Header MCWSHeader      `json:"header"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// MCWSEvent is a game event received from a connected client.
+type MCWSEvent struct {
+	Session    string          `json:"session"`
+	Name       string          `json:"name"`
+	Body       json.RawMessage `json:"body"`
+	ReceivedAt time.Time       `json:"received_at"`
+}
+
+// mcwsCommandResult is the body of a commandResponse message.
+type mcwsCommandResult struct {
+	StatusCode    int    `json:"statusCode"`
+	StatusMessage string `json:"statusMessage"`
+}
+
+// mcwsSession is one connected game client.
+type mcwsSession struct {
+	ID          string    `json:"id"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+
+	conn    *wsConn
+	mu      sync.Mutex
+	pending map[string]chan mcwsCommandResult
+}
+
+// mcwsHub tracks connected game clients and recent events.
+type mcwsHub struct {
+	mu       sync.RWMutex
+	sessions map[string]*mcwsSession
+	order    []string
+	events   []MCWSEvent
+}
+
+var mcws = &mcwsHub{sessions: make(map[string]*mcwsSession)}
+
+// newUUID returns a random RFC 4122 version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func (s *mcwsSession) send(purpose, messageType string, body interface{}) (string, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	msg := MCWSMessage{
+		Header: MCWSHeader{
+			Version:        1,
+			RequestID:      newUUID(),
+			MessagePurpose: purpose,
+			MessageType:    messageType,
+		},
+		Body: raw,
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return msg.Header.RequestID, s.conn.WriteText(data)
+}
+
+// subscribe asks the client to forward the named event.
+func (s *mcwsSession) subscribe(event string) error {
+	_, err := s.send("subscribe", "commandRequest", map[string]string{"eventName": event})
+	return err
+}
+
+// runCommand executes a command as the connected player and waits for the result.
+func (s *mcwsSession) runCommand(command string) (mcwsCommandResult, error) {
+	body := map[string]interface{}{
+		"version":     1,
+		"commandLine": command,
+		"origin":      map[string]string{"type": "player"},
+	}
+	ch := make(chan mcwsCommandResult, 1)
+	// Register before sending so a fast response is not lost.
+	requestID := newUUID()
+	s.mu.Lock()
+	s.pending[requestID] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, requestID)
+		s.mu.Unlock()
+	}()
+
+	raw, _ := json.Marshal(body)
+	data, _ := json.Marshal(MCWSMessage{
+		Header: MCWSHeader{Version: 1, RequestID: requestID, MessagePurpose: "commandRequest", MessageType: "commandRequest"},
+		Body:   raw,
+	})
+	if err := s.conn.WriteText(data); err != nil {
+		return mcwsCommandResult{}, err
+	}
+	select {
+	case res := <-ch:
+		return res, nil
+	case <-time.After(mcwsCommandTimeout):
+		return mcwsCommandResult{}, errors.New("timed out waiting for command response")
+	}
+}
+
+func (h *mcwsHub) add(s *mcwsSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessions[s.ID] = s
+	h.order = append(h.order, s.ID)
+}
+
+func (h *mcwsHub) remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, id)
+	for i, sid := range h.order {
+		if sid == id {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// primary returns the longest-connected session, or nil when none is connected.
+func (h *mcwsHub) primary() *mcwsSession {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.order) == 0 {
+		return nil
+	}
+	return h.sessions[h.order[0]]
+}
+
+func (h *mcwsHub) recordEvent(ev MCWSEvent) {
+	h.mu.Lock()
+	h.events = append(h.events, ev)
+	if len(h.events) > mcwsEventBufferLen {
+		h.events = h.events[len(h.events)-mcwsEventBufferLen:]
+	}
+	h.mu.Unlock()
+}
+
+// handle reads messages from a session until it disconnects.
+func (h *mcwsHub) handle(s *mcwsSession) {
+	defer func() {
+		h.remove(s.ID)
+		s.conn.Close()
+		log.Printf("Game WebSocket session %s disconnected", s.ID)
+	}()
+	for _, ev := range mcwsSubscriptions {
+		if err := s.subscribe(ev); err != nil {
+			log.Printf("Failed to subscribe to %s: %v", ev, err)
+			return
+		}
+	}
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg MCWSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("Invalid game WebSocket message: %v", err)
+			continue
+		}
+		switch msg.Header.MessagePurpose {
+		case "event":
+			name := msg.Header.EventName
+			if name == "" {
+				var body struct {
+					EventName string `json:"eventName"`
+				}
+				json.Unmarshal(msg.Body, &body)
+				name = body.EventName
+			}
+			h.recordEvent(MCWSEvent{Session: s.ID, Name: name, Body: msg.Body, ReceivedAt: time.Now()})
+			if name == "PlayerMessage" {
+				var chat struct {
+					Message string `json:"message"`
+					Sender  string `json:"sender"`
+					Type    string `json:"type"`
+				}
+				if json.Unmarshal(msg.Body, &chat) == nil && chat.Type == "chat" {
+					publishChat(ChatMessage{Direction: "inbound", Source: "game", Sender: chat.Sender, Message: chat.Message})
+				}
+			}
+		case "commandResponse", "error":
+			var res mcwsCommandResult
+			json.Unmarshal(msg.Body, &res)
+			s.mu.Lock()
+			ch, ok := s.pending[msg.Header.RequestID]
+			s.mu.Unlock()
+			if ok {
+				ch <- res
+			}
+		}
+	}
+}
+
+// mcwsConnectHandler accepts a WebSocket connection from the game client.
+func mcwsConnectHandler(w http.ResponseWriter, r *http.Request) {
+	if !envEnabled(mcwsEnabledEnv) {
+		writeJSONError(w, http.StatusNotFound, "Game WebSocket support is disabled")
+		return
+	}
+	token := os.Getenv(mcwsTokenEnv)
+	if token == "" {
+		log.Printf("Refused game WebSocket from %s: %s is not set", r.RemoteAddr, mcwsTokenEnv)
+		writeJSONError(w, http.StatusForbidden, "Game WebSocket token not configured")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		log.Printf("Refused game WebSocket from %s: bad token", r.RemoteAddr)
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		log.Printf("Game WebSocket upgrade failed: %v", err)
+		return
+	}
+	s := &mcwsSession{
+		ID:          newUUID(),
+		RemoteAddr:  r.RemoteAddr,
+		ConnectedAt: time.Now(),
+		conn:        conn,
+		pending:     make(map[string]chan mcwsCommandResult),
+	}
+	mcws.add(s)
+	log.Printf("Game WebSocket session %s connected from %s", s.ID, s.RemoteAddr)
+	go mcws.handle(s)
+}
+
+// mcwsStatusHandler lists connected game clients.
+func mcwsStatusHandler(w http.ResponseWriter, r *http.Request) {
+	mcws.mu.RLock()
+	sessions := make([]*mcwsSession, 0, len(mcws.order))
+	for _, id := range mcws.order {
+		sessions = append(sessions, mcws.sessions[id])
+	}
+	mcws.mu.RUnlock()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"enabled":       envEnabled(mcwsEnabledEnv),
+		"sessions":      sessions,
+		"subscriptions": mcwsSubscriptions,
+	})
+}
+
+// mcwsEventsHandler returns recent game events, optionally filtered by name.
+func mcwsEventsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, mcwsEventBufferLen)
+	}
+	name := r.URL.Query().Get("name")
+
+	mcws.mu.RLock()
+	events := []MCWSEvent{}
+	for i := len(mcws.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if name == "" || mcws.events[i].Name == name {
+			events = append(events, mcws.events[i])
+		}
+	}
+	mcws.mu.RUnlock()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"events": events})
+}
+
+// mcwsTransport sends commands through the first connected game client.
+type mcwsTransport struct{}
+
+func (mcwsTransport) Name() string { return "mcws" }
+
+func (mcwsTransport) Send(command string) error {
+	s := mcws.primary()
+	if s == nil {
+		return errors.New("no game client connected via /connect")
+	}
+	res, err := s.runCommand(command)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 0 {
+		return fmt.Errorf("command failed: %s", res.StatusMessage)
+	}
+	return nil
+}
+
+func (mcwsTransport) Close() error { return nil }
+
+func (mcwsTransport) ReportsCommandResults() bool { return true }
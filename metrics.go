@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultMetricsInterval = time.Minute
+
+// metricSample is the current value of a gauge or counter, as served to
+// Prometheus and pushed to InfluxDB and Graphite.
+type metricSample struct {
+	Name  string
+	Help  string
+	Type  string // gauge or counter
+	Value float64
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func formatMetric(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// writePrometheus writes samples in the Prometheus text format.
+func writePrometheus(w http.ResponseWriter, samples []metricSample) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range samples {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", m.Name, m.Help, m.Name, m.Type, m.Name, formatMetric(m.Value))
+	}
+}
+
+// metricTags parses BEDROCK_API_METRICS_TAGS, "key=value" pairs separated
+// by commas, sorted by key as InfluxDB prefers.
+func metricTags() [][2]string {
+	var tags [][2]string
+	for _, pair := range strings.Split(os.Getenv(metricsTagsEnv), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" || v == "" {
+			continue
+		}
+		tags = append(tags, [2]string{strings.TrimSpace(k), strings.TrimSpace(v)})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i][0] < tags[j][0] })
+	return tags
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxLines formats samples in InfluxDB line protocol, one measurement
+// per metric with the value in the "value" field.
+func influxLines(samples []metricSample, prefix string, tags [][2]string, at time.Time) []byte {
+	var b bytes.Buffer
+	for _, m := range samples {
+		b.WriteString(influxEscaper.Replace(prefix + m.Name))
+		for _, t := range tags {
+			fmt.Fprintf(&b, ",%s=%s", influxEscaper.Replace(t[0]), influxEscaper.Replace(t[1]))
+		}
+		fmt.Fprintf(&b, " value=%s %d\n", formatMetric(m.Value), at.UnixNano())
+	}
+	return b.Bytes()
+}
+
+var graphiteEscaper = strings.NewReplacer(" ", "_", ";", "_", "=", "_")
+
+// graphiteLines formats samples in Graphite's plaintext protocol, with
+// tags in the name;key=value form Graphite 1.1 understands.
+func graphiteLines(samples []metricSample, prefix string, tags [][2]string, at time.Time) []byte {
+	var b bytes.Buffer
+	for _, m := range samples {
+		b.WriteString(graphiteEscaper.Replace(prefix + m.Name))
+		for _, t := range tags {
+			fmt.Fprintf(&b, ";%s=%s", graphiteEscaper.Replace(t[0]), graphiteEscaper.Replace(t[1]))
+		}
+		fmt.Fprintf(&b, " %s %d\n", formatMetric(m.Value), at.Unix())
+	}
+	return b.Bytes()
+}
+
+// pushInflux posts lines to an InfluxDB write URL, such as
+// http://influx:8086/api/v2/write?org=home&bucket=bedrock or the 1.x
+// http://influx:8086/write?db=bedrock. BEDROCK_API_INFLUX_TOKEN is sent
+// as a 2.x API token.
+func pushInflux(url string, lines []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token := os.Getenv(influxTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("influxdb returned %s", resp.Status)
+	}
+	return nil
+}
+
+// pushGraphite sends lines to a Graphite plaintext listener (host:2003).
+func pushGraphite(addr string, lines []byte) error {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = conn.Write(lines)
+	return err
+}
+
+// startMetricsPush pushes the Prometheus metrics to InfluxDB
+// (BEDROCK_API_INFLUX_URL) and Graphite (BEDROCK_API_GRAPHITE_ADDR) every
+// BEDROCK_API_METRICS_INTERVAL, for setups that cannot scrape. Names get
+// BEDROCK_API_METRICS_PREFIX prepended and every sample carries
+// BEDROCK_API_METRICS_TAGS.
+func startMetricsPush() {
+	influxURL, graphiteAddr := os.Getenv(influxURLEnv), os.Getenv(graphiteAddrEnv)
+	if influxURL == "" && graphiteAddr == "" {
+		return
+	}
+	interval, err := time.ParseDuration(envOrDefault(metricsIntervalEnv, defaultMetricsInterval.String()))
+	if err != nil || interval < time.Second {
+		log.Printf("Invalid %s, using %s", metricsIntervalEnv, defaultMetricsInterval)
+		interval = defaultMetricsInterval
+	}
+	prefix, tags := os.Getenv(metricsPrefixEnv), metricTags()
+	log.Printf("Pushing metrics every %s", interval)
+	go func() {
+		for range time.Tick(interval) {
+			samples := append(autoscaleMetrics(), resourceMetrics()...)
+			now := time.Now()
+			if influxURL != "" {
+				if err := pushInflux(influxURL, influxLines(samples, prefix, tags, now)); err != nil {
+					log.Printf("Error pushing metrics to InfluxDB: %v", err)
+				}
+			}
+			if graphiteAddr != "" {
+				if err := pushGraphite(graphiteAddr, graphiteLines(samples, prefix, tags, now)); err != nil {
+					log.Printf("Error pushing metrics to Graphite: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mitigationsStateFile      = "mitigations.json"
+	mitigationCheckInterval   = 30 * time.Second
+	mitigationHistoryLen      = 200
+	defaultMitigationCooldown = 300 // seconds
+)
+
+// Mitigation trigger types.
+const (
+	triggerEntityCount = "entity_count" // entity census exceeds Threshold
+	triggerTickLag     = "tick_lag"     // bridge reports a tick stall of at least Threshold ms
+	triggerLogPattern  = "log_pattern"  // a server log line matches Pattern
+)
+
+// Mitigation action types.
+const (
+	actionWarn               = "warn"
+	actionCommand            = "command"
+	actionDisableTickingArea = "disable_ticking_area"
+	actionRestart            = "restart"
+)
+
+// MitigationRule pairs a lag trigger with an automatic response.
+type MitigationRule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+
+	Trigger    string `json:"trigger"`
+	EntityType string `json:"entity_type,omitempty"`
+	Dimension  string `json:"dimension,omitempty"`
+	Threshold  int    `json:"threshold,omitempty"`
+	Pattern    string `json:"pattern,omitempty"`
+
+	Action       string `json:"action"`
+	Message      string `json:"message,omitempty"`
+	Command      string `json:"command,omitempty"`
+	TickingArea  string `json:"ticking_area,omitempty"`
+	RestartDelay int    `json:"restart_delay_seconds,omitempty"`
+
+	CooldownSeconds int       `json:"cooldown_seconds"`
+	LastTriggered   time.Time `json:"last_triggered,omitempty"`
+}
+
+// MitigationTrigger records one execution of a rule.
+type MitigationTrigger struct {
+	RuleID      string    `json:"rule_id"`
+	RuleName    string    `json:"rule_name"`
+	Reason      string    `json:"reason"`
+	Action      string    `json:"action"`
+	Error       string    `json:"error,omitempty"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+var (
+	mitigationRules   = make([]MitigationRule, 0)
+	mitigationHistory = make([]MitigationTrigger, 0)
+	mitigationMutex   sync.Mutex
+	// mitigationPatterns caches compiled log_pattern regexps by pattern.
+	mitigationPatterns = map[string]*regexp.Regexp{}
+	// mitigationLogLines queues server output for log_pattern rules, so a
+	// slow mitigation never stalls the console tail.
+	mitigationLogLines = make(chan string, 256)
+)
+
+// validate checks a rule for completeness and fills in defaults.
+func (rule *MitigationRule) validate() error {
+	switch rule.Trigger {
+	case triggerEntityCount, triggerTickLag:
+		if rule.Threshold <= 0 {
+			return fmt.Errorf("threshold must be positive for %s rules", rule.Trigger)
+		}
+	case triggerLogPattern:
+		if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("pattern must be a valid regular expression")
+		}
+	default:
+		return fmt.Errorf("unknown trigger %q", rule.Trigger)
+	}
+	switch rule.Action {
+	case actionWarn:
+		if rule.Message == "" {
+			rule.Message = "Server lag detected: " + rule.Name
+		}
+	case actionCommand:
+		if strings.TrimSpace(rule.Command) == "" {
+			return fmt.Errorf("command is required for command actions")
+		}
+	case actionDisableTickingArea:
+		if rule.TickingArea == "" {
+			return fmt.Errorf("ticking_area is required for disable_ticking_area actions")
+		}
+	case actionRestart:
+		if rule.RestartDelay < 0 {
+			return fmt.Errorf("restart_delay_seconds must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.CooldownSeconds <= 0 {
+		rule.CooldownSeconds = defaultMitigationCooldown
+	}
+	return nil
+}
+
+// loadMitigations restores rules from the state directory.
+func loadMitigations() error {
+	mitigationMutex.Lock()
+	defer mitigationMutex.Unlock()
+	return loadState(mitigationsStateFile, &mitigationRules)
+}
+
+// saveMitigations persists rules. Callers must hold mitigationMutex.
+func saveMitigations() {
+	if err := saveState(mitigationsStateFile, mitigationRules); err != nil {
+		log.Printf("Error saving mitigation rules: %v", err)
+	}
+	configChanged()
+}
+
+// runMitigation performs a rule's action.
+func runMitigation(rule MitigationRule) error {
+	switch rule.Action {
+	case actionWarn:
+		return sendServerCommand("say " + rule.Message)
+	case actionCommand:
+		return sendServerCommand(rule.Command)
+	case actionDisableTickingArea:
+		return sendServerCommand("tickingarea remove " + rule.TickingArea)
+	case actionRestart:
+		delay := time.Duration(rule.RestartDelay) * time.Second
+		if delay > 0 {
+			sendServerCommand(fmt.Sprintf("say Server restarting in %d seconds to reduce lag", rule.RestartDelay))
+			time.AfterFunc(delay, func() {
+				if err := restartServer(); err != nil {
+					log.Printf("Scheduled restart failed: %v", err)
+				}
+			})
+			return nil
+		}
+		return restartServer()
+	}
+	return fmt.Errorf("unknown action %q", rule.Action)
+}
+
+// fireMitigation starts rule i's action if it is off cooldown. Callers must
+// hold mitigationMutex; the action runs in the background, since a restart
+// can take a minute, and a failure is added to its history entry after.
+func fireMitigation(i int, reason string) {
+	rule := &mitigationRules[i]
+	now := time.Now()
+	if now.Sub(rule.LastTriggered) < time.Duration(rule.CooldownSeconds)*time.Second {
+		return
+	}
+	rule.LastTriggered = now
+	entry := MitigationTrigger{RuleID: rule.ID, RuleName: rule.Name, Reason: reason, Action: rule.Action, TriggeredAt: now}
+	log.Printf("Mitigation %q triggered: %s", rule.Name, reason)
+	mitigationHistory = append(mitigationHistory, entry)
+	if len(mitigationHistory) > mitigationHistoryLen {
+		mitigationHistory = mitigationHistory[len(mitigationHistory)-mitigationHistoryLen:]
+	}
+	saveMitigations()
+
+	go func(rule MitigationRule) {
+		err := runMitigation(rule)
+		if err == nil {
+			return
+		}
+		log.Printf("Mitigation %q failed: %v", rule.Name, err)
+		mitigationMutex.Lock()
+		defer mitigationMutex.Unlock()
+		for j := len(mitigationHistory) - 1; j >= 0; j-- {
+			if h := &mitigationHistory[j]; h.RuleID == entry.RuleID && h.TriggeredAt.Equal(entry.TriggeredAt) {
+				h.Error = err.Error()
+				break
+			}
+		}
+	}(*rule)
+}
+
+// censusEntityCount totals entities of a type (or all types) in a dimension
+// (or all dimensions) from the latest bridge census.
+func censusEntityCount(entityType, dimension string) int {
+	bridgeMutex.RLock()
+	defer bridgeMutex.RUnlock()
+	total := 0
+	for dim, census := range entityCensus {
+		if dimension != "" && dim != dimension {
+			continue
+		}
+		for typ, n := range census.Counts {
+			if entityType == "" || typ == entityType {
+				total += n
+			}
+		}
+	}
+	return total
+}
+
+// evaluateEntityRules checks entity_count rules against the latest census.
+func evaluateEntityRules() {
+	mitigationMutex.Lock()
+	defer mitigationMutex.Unlock()
+	for i, rule := range mitigationRules {
+		if !rule.Enabled || rule.Trigger != triggerEntityCount {
+			continue
+		}
+		if n := censusEntityCount(rule.EntityType, rule.Dimension); n >= rule.Threshold {
+			what := rule.EntityType
+			if what == "" {
+				what = "entities"
+			}
+			fireMitigation(i, fmt.Sprintf("%d %s (threshold %d)", n, what, rule.Threshold))
+		}
+	}
+}
+
+// checkMitigationTickLag evaluates tick_lag rules for a reported stall.
+func checkMitigationTickLag(ms int) {
+	mitigationMutex.Lock()
+	defer mitigationMutex.Unlock()
+	for i, rule := range mitigationRules {
+		if rule.Enabled && rule.Trigger == triggerTickLag && ms >= rule.Threshold {
+			fireMitigation(i, fmt.Sprintf("tick stall of %d ms (threshold %d)", ms, rule.Threshold))
+		}
+	}
+}
+
+// checkMitigationLogLine evaluates log_pattern rules against a server log line.
+func checkMitigationLogLine(line string) {
+	mitigationMutex.Lock()
+	defer mitigationMutex.Unlock()
+	for i, rule := range mitigationRules {
+		if !rule.Enabled || rule.Trigger != triggerLogPattern {
+			continue
+		}
+		re, ok := mitigationPatterns[rule.Pattern]
+		if !ok {
+			re, _ = regexp.Compile(rule.Pattern)
+			mitigationPatterns[rule.Pattern] = re
+		}
+		if re != nil && re.MatchString(line) {
+			fireMitigation(i, "log line matched: "+line)
+		}
+	}
+}
+
+// queueMitigationLogLine hands a server output line to the log_pattern
+// rules, dropping it if they are backed up.
+func queueMitigationLogLine(line string) {
+	select {
+	case mitigationLogLines <- line:
+	default:
+	}
+}
+
+// startMitigationLoop periodically evaluates census-based rules and checks
+// server output against log_pattern rules.
+func startMitigationLoop() {
+	go func() {
+		for line := range mitigationLogLines {
+			checkMitigationLogLine(line)
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(mitigationCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			evaluateEntityRules()
+		}
+	}()
+}
+
+// mitigationsHandler lists (GET) and creates (POST) mitigation rules.
+func mitigationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		mitigationMutex.Lock()
+		defer mitigationMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"rules": mitigationRules})
+	case http.MethodPost:
+		var rule MitigationRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := rule.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule.ID = newUUID()
+		rule.LastTriggered = time.Time{}
+		mitigationMutex.Lock()
+		mitigationRules = append(mitigationRules, rule)
+		saveMitigations()
+		mitigationMutex.Unlock()
+		writeJSONResponse(w, http.StatusCreated, rule)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// mitigationHandler reads, replaces or deletes a single rule by ID, and
+// serves the trigger history at /mitigations/history.
+func mitigationHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/mitigations/")
+	if id == "history" {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		mitigationMutex.Lock()
+		defer mitigationMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"history": mitigationHistory})
+		return
+	}
+
+	mitigationMutex.Lock()
+	defer mitigationMutex.Unlock()
+	index := -1
+	for i, rule := range mitigationRules {
+		if rule.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 && r.Method != http.MethodPut {
+		writeJSONError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, mitigationRules[index])
+	case http.MethodPut:
+		// PUT upserts, so callers may choose stable rule IDs.
+		var rule MitigationRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := rule.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule.ID = id
+		if index < 0 {
+			if !validName(id) {
+				writeJSONError(w, http.StatusBadRequest, "Invalid rule id")
+				return
+			}
+			mitigationRules = append(mitigationRules, rule)
+			saveMitigations()
+			writeJSONResponse(w, http.StatusCreated, rule)
+			return
+		}
+		rule.LastTriggered = mitigationRules[index].LastTriggered
+		mitigationRules[index] = rule
+		saveMitigations()
+		writeJSONResponse(w, http.StatusOK, rule)
+	case http.MethodDelete:
+		mitigationRules = append(mitigationRules[:index], mitigationRules[index+1:]...)
+		saveMitigations()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Rule deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Little-endian NBT as used by Bedrock's level.dat and LevelDB values.
+
+const (
+	nbtEnd byte = iota
+	nbtByte
+	nbtShort
+	nbtInt
+	nbtLong
+	nbtFloat
+	nbtDouble
+	nbtByteArray
+	nbtString
+	nbtList
+	nbtCompound
+	nbtIntArray
+	nbtLongArray
+)
+
+const nbtMaxDepth = 512
+
+type nbtReader struct {
+	r io.Reader
+}
+
+func (n *nbtReader) read(v interface{}) error {
+	return binary.Read(n.r, binary.LittleEndian, v)
+}
+
+func (n *nbtReader) readString() (string, error) {
+	var l uint16
+	if err := n.read(&l); err != nil {
+		return "", err
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(n.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (n *nbtReader) readLength() (int, error) {
+	var l int32
+	if err := n.read(&l); err != nil {
+		return 0, err
+	}
+	if l < 0 || l > 1<<24 {
+		return 0, fmt.Errorf("invalid nbt length %d", l)
+	}
+	return int(l), nil
+}
+
+// readPayload decodes the payload of a tag of the given type into Go values:
+// int8, int16, int32, int64, float32, float64, []byte, string, []interface{},
+// map[string]interface{}, []int32 and []int64.
+func (n *nbtReader) readPayload(tag byte, depth int) (interface{}, error) {
+	if depth > nbtMaxDepth {
+		return nil, errors.New("nbt nesting too deep")
+	}
+	switch tag {
+	case nbtByte:
+		var v int8
+		return v, n.read(&v)
+	case nbtShort:
+		var v int16
+		return v, n.read(&v)
+	case nbtInt:
+		var v int32
+		return v, n.read(&v)
+	case nbtLong:
+		var v int64
+		return v, n.read(&v)
+	case nbtFloat:
+		var v float32
+		return v, n.read(&v)
+	case nbtDouble:
+		var v float64
+		return v, n.read(&v)
+	case nbtByteArray:
+		l, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, l)
+		_, err = io.ReadFull(n.r, buf)
+		return buf, err
+	case nbtString:
+		return n.readString()
+	case nbtList:
+		var elem byte
+		if err := n.read(&elem); err != nil {
+			return nil, err
+		}
+		l, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, 0, l)
+		for i := 0; i < l; i++ {
+			v, err := n.readPayload(elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case nbtCompound:
+		m := make(map[string]interface{})
+		for {
+			var t byte
+			if err := n.read(&t); err != nil {
+				return nil, err
+			}
+			if t == nbtEnd {
+				return m, nil
+			}
+			name, err := n.readString()
+			if err != nil {
+				return nil, err
+			}
+			v, err := n.readPayload(t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m[name] = v
+		}
+	case nbtIntArray:
+		l, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		v := make([]int32, l)
+		return v, n.read(v)
+	case nbtLongArray:
+		l, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		v := make([]int64, l)
+		return v, n.read(v)
+	}
+	return nil, fmt.Errorf("unknown nbt tag %d", tag)
+}
+
+// decodeNBT reads a single named root tag and returns its name and value.
+func decodeNBT(r io.Reader) (string, interface{}, error) {
+	n := &nbtReader{r: r}
+	var tag byte
+	if err := n.read(&tag); err != nil {
+		return "", nil, err
+	}
+	if tag == nbtEnd {
+		return "", nil, errors.New("empty nbt document")
+	}
+	name, err := n.readString()
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := n.readPayload(tag, 0)
+	return name, v, err
+}
+
+// nbtTagOf returns the tag type of a decoded NBT value.
+func nbtTagOf(v interface{}) (byte, error) {
+	switch v.(type) {
+	case int8:
+		return nbtByte, nil
+	case int16:
+		return nbtShort, nil
+	case int32:
+		return nbtInt, nil
+	case int64:
+		return nbtLong, nil
+	case float32:
+		return nbtFloat, nil
+	case float64:
+		return nbtDouble, nil
+	case []byte:
+		return nbtByteArray, nil
+	case string:
+		return nbtString, nil
+	case []interface{}:
+		return nbtList, nil
+	case map[string]interface{}:
+		return nbtCompound, nil
+	case []int32:
+		return nbtIntArray, nil
+	case []int64:
+		return nbtLongArray, nil
+	}
+	return 0, fmt.Errorf("no nbt tag for %T", v)
+}
+
+// writeNBTPayload encodes a value decoded by readPayload. Decoding does not
+// keep the element type of empty lists, so they are written as lists of
+// End, which readers accept.
+func writeNBTPayload(w *bytes.Buffer, v interface{}) error {
+	writeString := func(s string) {
+		binary.Write(w, binary.LittleEndian, uint16(len(s)))
+		w.WriteString(s)
+	}
+	switch t := v.(type) {
+	case []byte:
+		binary.Write(w, binary.LittleEndian, int32(len(t)))
+		w.Write(t)
+	case string:
+		if len(t) > math.MaxUint16 {
+			return errors.New("nbt string too long")
+		}
+		writeString(t)
+	case []interface{}:
+		elem := nbtEnd
+		if len(t) > 0 {
+			var err error
+			if elem, err = nbtTagOf(t[0]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(elem)
+		binary.Write(w, binary.LittleEndian, int32(len(t)))
+		for _, e := range t {
+			if tag, err := nbtTagOf(e); err != nil || tag != elem {
+				return errors.New("nbt list elements differ in type")
+			}
+			if err := writeNBTPayload(w, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		names := make([]string, 0, len(t))
+		for name := range t {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			tag, err := nbtTagOf(t[name])
+			if err != nil {
+				return err
+			}
+			w.WriteByte(tag)
+			writeString(name)
+			if err := writeNBTPayload(w, t[name]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(nbtEnd)
+	case []int32:
+		binary.Write(w, binary.LittleEndian, int32(len(t)))
+		binary.Write(w, binary.LittleEndian, t)
+	case []int64:
+		binary.Write(w, binary.LittleEndian, int32(len(t)))
+		binary.Write(w, binary.LittleEndian, t)
+	default:
+		if _, err := nbtTagOf(v); err != nil {
+			return err
+		}
+		binary.Write(w, binary.LittleEndian, v)
+	}
+	return nil
+}
+
+// encodeNBT writes a single named root tag, the inverse of decodeNBT.
+func encodeNBT(name string, v interface{}) ([]byte, error) {
+	tag, err := nbtTagOf(v)
+	if err != nil {
+		return nil, err
+	}
+	var w bytes.Buffer
+	w.WriteByte(tag)
+	binary.Write(&w, binary.LittleEndian, uint16(len(name)))
+	w.WriteString(name)
+	if err := writeNBTPayload(&w, v); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// readLevelDat parses a Bedrock level.dat (8-byte header followed by NBT).
+func readLevelDat(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, errors.New("level.dat too short")
+	}
+	_, v, err := decodeNBT(bytes.NewReader(data[8:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse level.dat: %w", err)
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("level.dat root is not a compound")
+	}
+	return root, nil
+}
+
+// nbtToJSON converts decoded NBT values into JSON-friendly values. Floats
+// that are not finite are replaced with nil.
+func nbtToJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[k] = nbtToJSON(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = nbtToJSON(val)
+		}
+		return out
+	case float32:
+		if math.IsNaN(float64(t)) || math.IsInf(float64(t), 0) {
+			return nil
+		}
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const notificationsStateFile = "notifications.json"
+
+// Notification channel types.
+const (
+	channelWebhook = "webhook" // the full event payload
+	channelDiscord = "discord"
+	channelSlack   = "slack"
+	channelEmail   = "email"
+)
+
+// NotificationChannel is one destination for notifications and the rules
+// deciding what reaches it. Events lists the event types routed to the
+// channel; "*" matches all and a trailing * matches a prefix, so
+// "resource_*" takes every resource alert. During QuietHours, a local time
+// range such as "22:00-07:00", only the events in QuietExempt are sent. An
+// event with the same type and message as one sent within DedupSeconds is
+// dropped.
+type NotificationChannel struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	URL          string   `json:"url,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Events       []string `json:"events"`
+	QuietHours   string   `json:"quiet_hours,omitempty"`
+	QuietExempt  []string `json:"quiet_exempt,omitempty"`
+	DedupSeconds int      `json:"dedup_seconds,omitempty"`
+}
+
+// NotificationResult reports a channel's handling of a test notification.
+type NotificationResult struct {
+	Channel string `json:"channel"`
+	Routed  bool   `json:"routed"`          // the event type is routed to the channel
+	Quiet   bool   `json:"quiet,omitempty"` // it would be held back by quiet hours now
+	Error   string `json:"error,omitempty"`
+}
+
+var (
+	notificationChannels = make([]NotificationChannel, 0)
+	// notificationSent is when each channel last sent each event type and
+	// message, for deduplication.
+	notificationSent   = map[string]time.Time{}
+	notificationsMutex sync.Mutex
+)
+
+// matchEventPattern reports whether an event type matches any pattern.
+func matchEventPattern(patterns []string, event string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == event || (strings.HasSuffix(p, "*") && strings.HasPrefix(event, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuietHours parses "HH:MM-HH:MM" into minutes after midnight.
+func parseQuietHours(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, errors.New("quiet_hours must look like 22:00-07:00")
+	}
+	minutes := func(v string) (int, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(v))
+		if err != nil {
+			return 0, errors.New("quiet_hours must look like 22:00-07:00")
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if start, err = minutes(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = minutes(to); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// quietAt reports whether t falls in the channel's quiet hours, which may
+// span midnight.
+func (c *NotificationChannel) quietAt(t time.Time) bool {
+	if c.QuietHours == "" {
+		return false
+	}
+	start, end, err := parseQuietHours(c.QuietHours)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// validate checks a channel for completeness.
+func (c *NotificationChannel) validate() error {
+	if !validName(c.Name) {
+		return errors.New("name must be a valid name")
+	}
+	switch c.Type {
+	case channelWebhook, channelDiscord, channelSlack:
+		if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+			return fmt.Errorf("url must be an http(s) URL for %s channels", c.Type)
+		}
+	case channelEmail:
+		if len(c.To) == 0 {
+			return errors.New("to is required for email channels")
+		}
+		if os.Getenv(smtpAddrEnv) == "" {
+			return errors.New("email channels need " + smtpAddrEnv)
+		}
+	default:
+		return fmt.Errorf("unknown channel type %q", c.Type)
+	}
+	if len(c.Events) == 0 {
+		return errors.New("events is required; use [\"*\"] for every event")
+	}
+	if c.QuietHours != "" {
+		if _, _, err := parseQuietHours(c.QuietHours); err != nil {
+			return err
+		}
+	}
+	if c.DedupSeconds < 0 {
+		return errors.New("dedup_seconds must not be negative")
+	}
+	return nil
+}
+
+// sendEmail mails a notification through the SMTP relay at
+// BEDROCK_API_SMTP_ADDR, logging in when BEDROCK_API_SMTP_USER is set.
+func sendEmail(to []string, subject, body string) error {
+	addr := os.Getenv(smtpAddrEnv)
+	if addr == "" {
+		return errors.New(smtpAddrEnv + " is not set")
+	}
+	from := envOrDefault(smtpFromEnv, "bedrock-sidecar@localhost")
+	var auth smtp.Auth
+	if user := os.Getenv(smtpUserEnv); user != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		auth = smtp.PlainAuth("", user, os.Getenv(smtpPasswordEnv), host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		from, strings.Join(to, ", "), subject, strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(addr, auth, from, to, []byte(msg))
+}
+
+// deliver sends an event to the channel in the channel's format.
+func (c *NotificationChannel) deliver(ev BusEvent) error {
+	content := fmt.Sprint(ev.Payload["content"])
+	switch c.Type {
+	case channelDiscord:
+		return postWebhook(c.URL, map[string]string{"content": content})
+	case channelSlack:
+		return postWebhook(c.URL, map[string]string{"text": content})
+	case channelEmail:
+		body := content
+		if data, ok := ev.Payload["data"].(map[string]interface{}); ok && len(data) > 0 {
+			pretty, _ := json.MarshalIndent(data, "", "  ")
+			body += "\n\n" + string(pretty)
+		}
+		return sendEmail(c.To, "[bedrock] "+ev.Type, body)
+	}
+	payload := ev.Payload
+	if v := emittedEventSchema(); v != eventSchemaVersion {
+		data, _ := payload["data"].(map[string]interface{})
+		payload = eventPayload(v, ev.Type, content, data)
+	}
+	return postWebhook(c.URL, payload)
+}
+
+// notificationRouter is the event sink that routes every event to the
+// notification channels.
+type notificationRouter struct{}
+
+func (notificationRouter) Name() string { return "notifications" }
+
+func (notificationRouter) Publish(ev BusEvent) error {
+	now := time.Now()
+	content := fmt.Sprint(ev.Payload["content"])
+	var due []NotificationChannel
+	notificationsMutex.Lock()
+	for _, c := range notificationChannels {
+		if !matchEventPattern(c.Events, ev.Type) {
+			continue
+		}
+		if c.quietAt(now) && !matchEventPattern(c.QuietExempt, ev.Type) {
+			continue
+		}
+		if c.DedupSeconds > 0 {
+			key := c.Name + "\x00" + ev.Type + "\x00" + content
+			if last, ok := notificationSent[key]; ok && now.Sub(last) < time.Duration(c.DedupSeconds)*time.Second {
+				continue
+			}
+			notificationSent[key] = now
+		}
+		due = append(due, c)
+	}
+	// Forget sends older than any window.
+	for key, t := range notificationSent {
+		if now.Sub(t) > 24*time.Hour {
+			delete(notificationSent, key)
+		}
+	}
+	notificationsMutex.Unlock()
+
+	var errs []string
+	for _, c := range due {
+		if err := c.deliver(ev); err != nil {
+			errs = append(errs, c.Name+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// notificationsHandler serves the notification channels: GET lists them
+// and PUT replaces them all.
+func notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		notificationsMutex.Lock()
+		defer notificationsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"channels": notificationChannels})
+	case http.MethodPut:
+		var body struct {
+			Channels []NotificationChannel `json:"channels"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		seen := map[string]bool{}
+		for i := range body.Channels {
+			c := &body.Channels[i]
+			if err := c.validate(); err != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("channels[%d]: %v", i, err))
+				return
+			}
+			if seen[c.Name] {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("channels[%d]: duplicate name %q", i, c.Name))
+				return
+			}
+			seen[c.Name] = true
+		}
+		if body.Channels == nil {
+			body.Channels = []NotificationChannel{}
+		}
+		notificationsMutex.Lock()
+		notificationChannels = body.Channels
+		if err := saveState(notificationsStateFile, notificationChannels); err != nil {
+			log.Printf("Error saving notification channels: %v", err)
+		}
+		notificationsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"channels": body.Channels})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// notificationsTestHandler serves POST /notifications/test. It sends a
+// test notification to every channel, or to ?channel=, and reports how
+// each would route ?event= (notification_test by default). The test is
+// sent even where the event is not routed, in quiet hours or within a
+// deduplication window.
+func notificationsTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	event := r.URL.Query().Get("event")
+	if event == "" {
+		event = "notification_test"
+	}
+	only := r.URL.Query().Get("channel")
+	notificationsMutex.Lock()
+	channels := append([]NotificationChannel{}, notificationChannels...)
+	notificationsMutex.Unlock()
+	content := "Test notification from the Bedrock sidecar"
+	ev := BusEvent{Type: event, Payload: eventPayload(eventSchemaVersion, event, content, map[string]interface{}{"test": true})}
+	now := time.Now()
+	results := []NotificationResult{}
+	for _, c := range channels {
+		if only != "" && c.Name != only {
+			continue
+		}
+		res := NotificationResult{
+			Channel: c.Name,
+			Routed:  matchEventPattern(c.Events, event),
+			Quiet:   c.quietAt(now) && !matchEventPattern(c.QuietExempt, event),
+		}
+		if err := c.deliver(ev); err != nil {
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+	if only != "" && len(results) == 0 {
+		writeJSONError(w, http.StatusNotFound, "Channel not found")
+		return
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Channel < results[j].Channel })
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"event": event, "results": results})
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	packUUIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	// worldPacksMutex serialises edits of the world pack lists.
+	worldPacksMutex sync.Mutex
+)
+
+// worldPacksPath returns the world's pack list for "behavior" or "resource"
+// packs, honouring the British spelling when only that file exists.
+func worldPacksPath(kind string) (string, error) {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case "behavior":
+		path := filepath.Join(worldFolder, "world_behavior_packs.json")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			alt := filepath.Join(worldFolder, "world_behaviour_packs.json")
+			if _, err := os.Stat(alt); err == nil {
+				return alt, nil
+			}
+		}
+		return path, nil
+	case "resource":
+		return filepath.Join(worldFolder, "world_resource_packs.json"), nil
+	}
+	return "", fmt.Errorf("unknown pack type %q", kind)
+}
+
+// readWorldPacks returns the activated packs of a kind. A missing list is empty.
+func readWorldPacks(kind string) ([]ActiveAddon, error) {
+	path, err := worldPacksPath(kind)
+	if err != nil {
+		return nil, err
+	}
+	addons := []ActiveAddon{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return addons, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &addons); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return addons, nil
+}
+
+// writeWorldPacks replaces the activated packs of a kind.
+func writeWorldPacks(kind string, addons []ActiveAddon) error {
+	path, err := worldPacksPath(kind)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(addons, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// setPackActivation activates a pack or updates its version. It reports
+// whether the pack was newly activated.
+func setPackActivation(kind, uuid string, version []int) (bool, error) {
+	worldPacksMutex.Lock()
+	defer worldPacksMutex.Unlock()
+	addons, err := readWorldPacks(kind)
+	if err != nil {
+		return false, err
+	}
+	for i := range addons {
+		if addons[i].PackID == uuid {
+			addons[i].Version = version
+			return false, writeWorldPacks(kind, addons)
+		}
+	}
+	addons = append(addons, ActiveAddon{PackID: uuid, Version: version})
+	return true, writeWorldPacks(kind, addons)
+}
+
+// updatePackActivationVersion sets the version of a pack already in the
+// world's list. It reports whether the pack was listed.
+func updatePackActivationVersion(kind, uuid string, version []int) (bool, error) {
+	worldPacksMutex.Lock()
+	defer worldPacksMutex.Unlock()
+	addons, err := readWorldPacks(kind)
+	if err != nil {
+		return false, err
+	}
+	for i := range addons {
+		if addons[i].PackID == uuid {
+			addons[i].Version = version
+			return true, writeWorldPacks(kind, addons)
+		}
+	}
+	return false, nil
+}
+
+// removePackActivation removes a pack from the world's list. It reports
+// whether the pack was listed.
+func removePackActivation(kind, uuid string) (bool, error) {
+	worldPacksMutex.Lock()
+	defer worldPacksMutex.Unlock()
+	addons, err := readWorldPacks(kind)
+	if err != nil {
+		return false, err
+	}
+	for i, a := range addons {
+		if a.PackID == uuid {
+			return true, writeWorldPacks(kind, append(addons[:i], addons[i+1:]...))
+		}
+	}
+	return false, nil
+}
+
+// reorderPackActivations moves the listed packs to the front of the world's
+// list in the given order; the rest keep their relative order behind them.
+// Bedrock gives packs earlier in the list priority.
+func reorderPackActivations(kind string, order []string) ([]ActiveAddon, error) {
+	worldPacksMutex.Lock()
+	defer worldPacksMutex.Unlock()
+	addons, err := readWorldPacks(kind)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]ActiveAddon{}
+	for _, a := range addons {
+		byID[a.PackID] = a
+	}
+	placed := map[string]bool{}
+	reordered := make([]ActiveAddon, 0, len(addons))
+	for _, uuid := range order {
+		a, ok := byID[uuid]
+		if !ok {
+			return nil, fmt.Errorf("pack %s is not activated", uuid)
+		}
+		if placed[uuid] {
+			return nil, fmt.Errorf("pack %s is listed twice", uuid)
+		}
+		placed[uuid] = true
+		reordered = append(reordered, a)
+	}
+	for _, a := range addons {
+		if !placed[a.PackID] {
+			reordered = append(reordered, a)
+		}
+	}
+	return reordered, writeWorldPacks(kind, reordered)
+}
+
+// packActivationsHandler serves /pack-activations/{kind} (GET list) and
+// /pack-activations/{kind}/{uuid} (GET, PUT upsert with {"version": [..]},
+// DELETE). Changes apply on the next world load.
+func packActivationsHandler(w http.ResponseWriter, r *http.Request) {
+	kind, uuid, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/pack-activations/"), "/")
+	if kind != "behavior" && kind != "resource" {
+		writeJSONError(w, http.StatusNotFound, "Unknown pack type")
+		return
+	}
+	if uuid == "" {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		addons, err := readWorldPacks(kind)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"activations": addons})
+		return
+	}
+	if !packUUIDPattern.MatchString(uuid) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid pack UUID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		addons, err := readWorldPacks(kind)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, a := range addons {
+			if a.PackID == uuid {
+				writeJSONResponse(w, http.StatusOK, a)
+				return
+			}
+		}
+		writeJSONError(w, http.StatusNotFound, "Pack is not activated")
+	case http.MethodPut:
+		var req struct {
+			Version []int `json:"version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Version) != 3 {
+			writeJSONError(w, http.StatusBadRequest, "version must be [major, minor, patch]")
+			return
+		}
+		created, err := setPackActivation(kind, uuid, req.Version)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSONResponse(w, status, ActiveAddon{PackID: uuid, Version: req.Version})
+	case http.MethodDelete:
+		removed, err := removePackActivation(kind, uuid)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !removed {
+			writeJSONError(w, http.StatusNotFound, "Pack is not activated")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Pack deactivated"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// ActivateAddonRequest names an installed pack to activate on the world.
+// Type is only needed when the UUID is installed as both kinds; a missing
+// version means the installed one.
+type ActivateAddonRequest struct {
+	UUID    string `json:"uuid"`
+	Version []int  `json:"version,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// findInstalledPack looks a pack UUID up in the installed behavior and
+// resource packs, returning its kind and manifest version.
+func findInstalledPack(uuid, kind string) (string, []int, error) {
+	var found []string
+	versions := map[string][]int{}
+	for k, dir := range map[string]string{"behavior": behaviorPacksDir, "resource": resourcePacksDir} {
+		if kind != "" && kind != k {
+			continue
+		}
+		installed, err := getInstalledAddons(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return "", nil, err
+		}
+		if path, ok := installed[uuid]; ok {
+			m, _, err := readPackManifest(path)
+			if err != nil {
+				return "", nil, err
+			}
+			found = append(found, k)
+			versions[k] = m.Header.Version
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", nil, os.ErrNotExist
+	case 1:
+		return found[0], versions[found[0]], nil
+	}
+	return "", nil, fmt.Errorf("pack %s is installed as both behavior and resource pack; set type", uuid)
+}
+
+// activateAddonHandler adds an installed pack to the world's pack list, or
+// updates its version when already listed. The list file is created if
+// missing. Changes apply on the next world load.
+func activateAddonHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req ActivateAddonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if !packUUIDPattern.MatchString(req.UUID) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid pack UUID")
+		return
+	}
+	if req.Type != "" && req.Type != "behavior" && req.Type != "resource" {
+		writeJSONError(w, http.StatusBadRequest, "type must be behavior or resource")
+		return
+	}
+	if req.Version != nil && len(req.Version) != 3 {
+		writeJSONError(w, http.StatusBadRequest, "version must be [major, minor, patch]")
+		return
+	}
+	kind, installed, err := findInstalledPack(req.UUID, req.Type)
+	if os.IsNotExist(err) {
+		writeJSONError(w, http.StatusNotFound, "Pack is not installed")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	version := req.Version
+	if version == nil {
+		version = installed
+	} else if fmt.Sprint(version) != fmt.Sprint(installed) {
+		writeJSONError(w, http.StatusConflict, fmt.Sprintf("Installed version is %v, not %v", installed, version))
+		return
+	}
+	created, err := setPackActivation(kind, req.UUID, version)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSONResponse(w, status, map[string]interface{}{"type": kind, "pack_id": req.UUID, "version": version})
+}
+
+// activatedPackKinds returns the pack kinds whose world list has uuid.
+func activatedPackKinds(uuid string) ([]string, error) {
+	var kinds []string
+	for _, kind := range []string{"behavior", "resource"} {
+		addons, err := readWorldPacks(kind)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addons {
+			if a.PackID == uuid {
+				kinds = append(kinds, kind)
+				break
+			}
+		}
+	}
+	return kinds, nil
+}
+
+// deactivateAddonHandler removes a pack from the world's pack lists. Type
+// limits removal to one list; otherwise the pack is removed wherever it is
+// listed. Changes apply on the next world load.
+func deactivateAddonHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req ActivateAddonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if !packUUIDPattern.MatchString(req.UUID) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid pack UUID")
+		return
+	}
+	kinds := []string{req.Type}
+	switch req.Type {
+	case "behavior", "resource":
+	case "":
+		var err error
+		if kinds, err = activatedPackKinds(req.UUID); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		writeJSONError(w, http.StatusBadRequest, "type must be behavior or resource")
+		return
+	}
+	removedFrom := []string{}
+	for _, kind := range kinds {
+		removed, err := removePackActivation(kind, req.UUID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if removed {
+			removedFrom = append(removedFrom, kind)
+		}
+	}
+	if len(removedFrom) == 0 {
+		writeJSONError(w, http.StatusNotFound, "Pack is not activated")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"pack_id": req.UUID, "deactivated": removedFrom})
+}
+
+// reorderAddonsHandler sets the priority of a world's packs with
+// {"type": "resource", "order": [uuid, ...]}, highest priority first.
+// Packs left out of the order keep their relative order after the listed
+// ones.
+func reorderAddonsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req struct {
+		Type  string   `json:"type"`
+		Order []string `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Type != "behavior" && req.Type != "resource" {
+		writeJSONError(w, http.StatusBadRequest, "type must be behavior or resource")
+		return
+	}
+	if len(req.Order) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "order must list at least one pack UUID")
+		return
+	}
+	addons, err := reorderPackActivations(req.Type, req.Order)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"type": req.Type, "activations": addons})
+}
+
+// PackFolder is an installed pack folder as found on disk.
+type PackFolder struct {
+	Type    string `json:"type"`
+	Folder  string `json:"folder"`
+	Version []int  `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// addonConflictsHandler reports installed packs that share a header UUID
+// across folders, and folders whose manifest.json is missing or unreadable.
+func addonConflictsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	byUUID := map[string][]PackFolder{}
+	var uuids []string
+	missing := []PackFolder{}
+	invalid := []PackFolder{}
+	for _, kind := range []string{"behavior", "resource"} {
+		root := behaviorPacksDir
+		if kind == "resource" {
+			root = resourcePacksDir
+		}
+		entries, err := os.ReadDir(root)
+		if err != nil && !os.IsNotExist(err) {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			dir := filepath.Join(root, e.Name())
+			f := PackFolder{Type: kind, Folder: e.Name()}
+			m, _, err := readPackManifest(dir)
+			switch {
+			case os.IsNotExist(err):
+				missing = append(missing, f)
+			case err != nil:
+				f.Error = strings.TrimPrefix(err.Error(), dir+": ")
+				invalid = append(invalid, f)
+			default:
+				f.Version = m.Header.Version
+				if _, seen := byUUID[m.Header.UUID]; !seen {
+					uuids = append(uuids, m.Header.UUID)
+				}
+				byUUID[m.Header.UUID] = append(byUUID[m.Header.UUID], f)
+			}
+		}
+	}
+	conflicts := []map[string]interface{}{}
+	for _, uuid := range uuids {
+		if folders := byUUID[uuid]; len(folders) > 1 {
+			conflicts = append(conflicts, map[string]interface{}{"uuid": uuid, "folders": folders})
+		}
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"duplicate_uuids":  conflicts,
+		"missing_manifest": missing,
+		"invalid_manifest": invalid,
+	})
+}
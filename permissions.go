@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	xuidPattern      = regexp.MustCompile(`^[0-9]{1,20}$`)
+	permissionLevels = map[string]bool{"operator": true, "member": true, "visitor": true}
+)
+
+// PermissionInfo is a permissions.json entry with the player's name from
+// the allowlist, when it is there.
+type PermissionInfo struct {
+	PermissionEntry
+	Name string `json:"name,omitempty"`
+}
+
+// reloadPermissions tells a running server to re-read permissions.json.
+func reloadPermissions() bool {
+	if !serverUp() {
+		return false
+	}
+	if err := sendServerCommand("permission reload"); err != nil {
+		log.Printf("Error reloading permissions: %v", err)
+		return false
+	}
+	return true
+}
+
+// permissionsHandler lists permissions.json with names from the allowlist.
+func permissionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	allowlistMutex.Lock()
+	defer allowlistMutex.Unlock()
+	perms := []PermissionEntry{}
+	allowlist := []AllowlistEntry{}
+	if err := readJSONList(permissionsPath, &perms); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read permissions: "+err.Error())
+		return
+	}
+	readJSONList(allowlistPath, &allowlist)
+	names := make(map[string]string, len(allowlist))
+	for _, e := range allowlist {
+		if e.XUID != "" {
+			names[e.XUID] = e.Name
+		}
+	}
+	out := make([]PermissionInfo, 0, len(perms))
+	for _, p := range perms {
+		out = append(out, PermissionInfo{PermissionEntry: p, Name: names[p.XUID]})
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"permissions": out})
+}
+
+// permissionHandler serves PUT /permissions/{xuid} ({"permission"}) and
+// DELETE /permissions/{xuid}, which edit permissions.json and reload it,
+// and POST /permissions/op and /permissions/deop ({"player"}), which run
+// op or deop for a player by name.
+func permissionHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/permissions/")
+	if id == "op" || id == "deop" {
+		opHandler(w, r, id)
+		return
+	}
+	if !xuidPattern.MatchString(id) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid XUID")
+		return
+	}
+	var level string
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Permission string `json:"permission"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if !permissionLevels[req.Permission] {
+			writeJSONError(w, http.StatusBadRequest, "permission must be operator, member or visitor")
+			return
+		}
+		level = req.Permission
+	case http.MethodDelete:
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	allowlistMutex.Lock()
+	perms := []PermissionEntry{}
+	if err := readJSONList(permissionsPath, &perms); err != nil {
+		allowlistMutex.Unlock()
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read permissions: "+err.Error())
+		return
+	}
+	idx := -1
+	for i, p := range perms {
+		if p.XUID == id {
+			idx = i
+			break
+		}
+	}
+	switch {
+	case level != "" && idx < 0:
+		perms = append(perms, PermissionEntry{Permission: level, XUID: id})
+	case level != "":
+		perms[idx].Permission = level
+	case idx < 0:
+		allowlistMutex.Unlock()
+		writeJSONError(w, http.StatusNotFound, "No permission set for that XUID")
+		return
+	default:
+		perms = append(perms[:idx], perms[idx+1:]...)
+	}
+	err := writeJSONList(permissionsPath, perms)
+	allowlistMutex.Unlock()
+	if err != nil {
+		log.Printf("Error writing permissions: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if level == "" {
+		log.Printf("Permission for %s removed", id)
+	} else {
+		log.Printf("Permission for %s set to %s", id, level)
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"xuid": id, "permission": level, "reloaded": reloadPermissions()})
+}
+
+// opHandler runs op or deop for an online player. The server records the
+// change in permissions.json itself.
+func opHandler(w http.ResponseWriter, r *http.Request, command string) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req struct {
+		Player string `json:"player"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !validPlayerName(req.Player) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := sendServerCommand(command + " " + quotePlayer(req.Player)); err != nil {
+		log.Printf("Error running %s for %s: %v", command, req.Player, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to run "+command)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": command + " sent", "player": req.Player})
+}
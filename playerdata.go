@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	playerDataBackupsDir = "player_data_backups"
+	// playerEyeHeight is how far above the feet Bedrock saves a player's
+	// position.
+	playerEyeHeight = 1.62001
+	// randomSpawnY is level.dat's SpawnY when the spawn height is not fixed.
+	randomSpawnY = 32767
+)
+
+var errNoPlayerData = errors.New("no saved data for the player in this world")
+
+// PlayerDataEdit is the body of PATCH /players/{name}/world-data. Each
+// true field is one edit of the player's saved data.
+type PlayerDataEdit struct {
+	ResetPosition  bool `json:"reset_position,omitempty"`  // move to the world spawn in the overworld
+	ClearInventory bool `json:"clear_inventory,omitempty"` // inventory, armour and offhand
+	RevokeOperator bool `json:"revoke_operator,omitempty"`
+}
+
+// PlayerDataEditResult reports an edit and where the original was backed up.
+type PlayerDataEditResult struct {
+	Player    string   `json:"player"`
+	World     string   `json:"world"`
+	Key       string   `json:"key"`
+	Applied   []string `json:"applied"`
+	Backup    string   `json:"backup"`
+	Restarted bool     `json:"restarted"`
+}
+
+// setNBTInt sets an integer tag, keeping its type if it exists.
+func setNBTInt(m map[string]interface{}, key string, v int, def interface{}) {
+	switch m[key].(type) {
+	case int8:
+		m[key] = int8(v)
+	case int16:
+		m[key] = int16(v)
+	case int32:
+		m[key] = int32(v)
+	case int64:
+		m[key] = int64(v)
+	default:
+		m[key] = def
+	}
+}
+
+// apply makes the edits to decoded player data.
+func (e *PlayerDataEdit) apply(world string, root map[string]interface{}) ([]string, error) {
+	var applied []string
+	if e.ResetPosition {
+		level, err := readLevelDat(filepath.Join(worldsDir, world, "level.dat"))
+		if err != nil {
+			return nil, err
+		}
+		x, _ := levelInt(level, "SpawnX")
+		y, _ := levelInt(level, "SpawnY")
+		z, _ := levelInt(level, "SpawnZ")
+		if y == randomSpawnY {
+			return nil, errors.New("the world spawn has no fixed height; set one with PUT /spawn first")
+		}
+		root["Pos"] = []interface{}{float32(x) + 0.5, float32(y) + playerEyeHeight, float32(z) + 0.5}
+		root["Motion"] = []interface{}{float32(0), float32(0), float32(0)}
+		setNBTInt(root, "DimensionId", 0, int32(0))
+		applied = append(applied, "reset_position")
+	}
+	if e.ClearInventory {
+		// Slots are emptied in place; the server expects every slot listed.
+		for _, key := range []string{"Inventory", "Armor", "Offhand"} {
+			list, _ := root[key].([]interface{})
+			for i, raw := range list {
+				item, ok := raw.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				empty := map[string]interface{}{"Count": int8(0), "Damage": int16(0), "Name": "", "WasPickedUp": int8(0)}
+				if slot, ok := item["Slot"]; ok {
+					empty["Slot"] = slot
+				}
+				list[i] = empty
+			}
+		}
+		applied = append(applied, "clear_inventory")
+	}
+	if e.RevokeOperator {
+		abilities, ok := root["abilities"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("the player data has no abilities")
+		}
+		setNBTInt(abilities, "op", 0, int8(0))
+		setNBTInt(abilities, "permissionsLevel", 0, int32(0))
+		setNBTInt(abilities, "playerPermissionsLevel", 1, int32(1)) // member
+		applied = append(applied, "revoke_operator")
+	}
+	return applied, nil
+}
+
+// editPlayerData edits a player's saved data in a world that no server has
+// open, after backing up the original record under the sidecar's state.
+func editPlayerData(world, player string, edit PlayerDataEdit) (PlayerDataEditResult, error) {
+	result := PlayerDataEditResult{Player: player, World: world}
+	id, ok := playerEntityID(player)
+	if !ok {
+		return result, errNoPlayerData
+	}
+	dir := filepath.Join(worldsDir, world, "db")
+	db, err := openLDB(dir)
+	if err != nil {
+		return result, err
+	}
+	entry, root, err := findPlayerData(db, id)
+	if err != nil {
+		return result, err
+	}
+	if entry.Key == nil {
+		return result, errNoPlayerData
+	}
+	result.Key = string(entry.Key)
+	if result.Applied, err = edit.apply(world, root); err != nil {
+		return result, err
+	}
+	value, err := encodeNBT("", root)
+	if err != nil {
+		return result, err
+	}
+
+	backupDir := filepath.Join(stateDir, playerDataBackupsDir, world)
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return result, err
+	}
+	result.Backup = filepath.Join(backupDir, fmt.Sprintf("%s-%s.nbt", time.Now().Format("20060102-150405"), entry.Key))
+	if err := writeSynced(result.Backup, entry.Value); err != nil {
+		return result, fmt.Errorf("backing up player data: %w", err)
+	}
+	if err := writeLDBBatch(dir, []ldbEntry{{Key: entry.Key, Value: value}}); err != nil {
+		return result, err
+	}
+	log.Printf("Edited %s's saved data in world %s (%v); original backed up to %s", player, world, result.Applied, result.Backup)
+	return result, nil
+}
+
+// playerWorldDataHandler serves PATCH /players/{name}/world-data, limited
+// edits of a player's saved data in the active world or ?world=. The
+// server keeps the active world's database open, so while it runs the
+// player must be offline and ?restart=true stops the server for the edit.
+// Saved data is found by the entity ID the bridge reported on join.
+// Operator entries in permissions.json still apply after revoke_operator.
+func playerWorldDataHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodPatch {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var edit PlayerDataEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if !edit.ResetPosition && !edit.ClearInventory && !edit.RevokeOperator {
+		writeJSONError(w, http.StatusBadRequest, "No edits requested")
+		return
+	}
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	active, _ := currentLevelName()
+	world := r.URL.Query().Get("world")
+	if world == "" {
+		world = active
+	}
+	if !validName(world) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid world name")
+		return
+	}
+	if _, err := os.Stat(filepath.Join(worldsDir, world, "db", "CURRENT")); err != nil {
+		writeJSONError(w, http.StatusNotFound, "World not found")
+		return
+	}
+	restart := world == active && serverUp()
+	if restart {
+		if playerOnline(player) {
+			writeJSONError(w, http.StatusConflict, "The player is online")
+			return
+		}
+		if r.URL.Query().Get("restart") != "true" {
+			writeJSONError(w, http.StatusConflict, "The world is in use; pass restart=true to stop the server for the edit")
+			return
+		}
+	}
+	var result PlayerDataEditResult
+	apply := func() error {
+		var err error
+		result, err = editPlayerData(world, player, edit)
+		return err
+	}
+	var err error
+	if restart {
+		err = withServerStopped(apply)
+	} else {
+		err = apply()
+	}
+	result.Restarted = restart
+	if errors.Is(err, errNoPlayerData) {
+		writeJSONError(w, http.StatusNotFound, "No saved data found; the player must have joined with the bridge installed")
+		return
+	}
+	if err != nil {
+		log.Printf("Error editing %s's saved data: %v", player, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to edit player data: "+err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server logs "Player connected: Steve, xuid: 2535412345678901" and
+// "Player disconnected: Steve, xuid: 2535412345678901" as players come and
+// go; newer builds append ", pfid: ..." to both.
+var (
+	playerConnectedLine    = regexp.MustCompile(`Player connected: (.+?), xuid: (\d*)`)
+	playerDisconnectedLine = regexp.MustCompile(`Player disconnected: (.+?), xuid: (\d*)`)
+)
+
+// Lines the server logs once it accepts players and after a clean shutdown.
+const (
+	serverStartedLine = "Server started."
+	serverStoppedLine = "Quit correctly"
+)
+
+var (
+	// logSessions are the players seen joining in the log, with the time
+	// they joined.
+	logSessions      = map[string]time.Time{}
+	logSessionsMutex sync.Mutex
+)
+
+// startLogEvents follows joins, leaves, starts and stops in the server log.
+// Banned players are kicked as they join; everyone else's joins and leaves
+// are posted to BEDROCK_API_PLAYER_WEBHOOKS. Unlike the bridge pack's
+// events these need no add-on and carry the XUID. Everything is published
+// on the event bus.
+func startLogEvents() {
+	if os.Getenv(serverLogEnv) == "" {
+		return
+	}
+	ch := make(chan ConsoleMessage, 256)
+	consoleMutex.Lock()
+	consoleSubscribers[ch] = struct{}{}
+	consoleMutex.Unlock()
+	go func() {
+		for msg := range ch {
+			if m := playerConnectedLine.FindStringSubmatch(msg.Line); m != nil {
+				if !enforceBans(m[1], m[2]) {
+					playerJoinedLog(m[1], m[2], msg.Time)
+				}
+			} else if m := playerDisconnectedLine.FindStringSubmatch(msg.Line); m != nil {
+				playerLeftLog(m[1], m[2], msg.Time)
+			} else if strings.Contains(msg.Line, serverStartedLine) {
+				publishEvent("server_start", "The server started", map[string]interface{}{})
+			} else if strings.Contains(msg.Line, serverStoppedLine) {
+				logSessionsMutex.Lock()
+				logSessions = map[string]time.Time{}
+				logSessionsMutex.Unlock()
+				publishEvent("server_stop", "The server stopped", map[string]interface{}{})
+			}
+		}
+	}()
+}
+
+func playerJoinedLog(player, xuid string, at time.Time) {
+	logSessionsMutex.Lock()
+	logSessions[player] = at
+	logSessionsMutex.Unlock()
+	emitEvent(webhookURLs(playerWebhooksEnv), "player_join", player+" joined the server",
+		map[string]interface{}{"player": player, "xuid": xuid})
+}
+
+// playerLeftLog posts a leave with the session length. Leaves without a
+// join, such as banned players being kicked, are not posted.
+func playerLeftLog(player, xuid string, at time.Time) {
+	logSessionsMutex.Lock()
+	joined, ok := logSessions[player]
+	delete(logSessions, player)
+	logSessionsMutex.Unlock()
+	if !ok {
+		return
+	}
+	session := at.Sub(joined).Round(time.Second)
+	emitEvent(webhookURLs(playerWebhooksEnv), "player_leave", fmt.Sprintf("%s left the server after %s", player, session),
+		map[string]interface{}{"player": player, "xuid": xuid, "session_seconds": int64(session / time.Second)})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const listTimeout = 5 * time.Second
+
+// listHeader matches the first line of the list command's answer, "There
+// are 2/10 players online:", after any log prefix. The names follow on the
+// next line.
+var listHeader = regexp.MustCompile(`There are (\d+)/(\d+) players online`)
+
+// PlayerList is the server's answer to the list command.
+type PlayerList struct {
+	Players []string  `json:"players"`
+	Count   int       `json:"count"`
+	Max     int       `json:"max"`
+	Time    time.Time `json:"time"`
+}
+
+// listMutex keeps concurrent callers from reading each other's answers.
+var listMutex sync.Mutex
+
+// parseListNames splits the names line of the list answer.
+func parseListNames(line string) []string {
+	names := []string{}
+	for _, name := range strings.Split(line, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// queryPlayerList runs list and reads the answer from the server log.
+func queryPlayerList() (PlayerList, error) {
+	listMutex.Lock()
+	defer listMutex.Unlock()
+	ch := make(chan ConsoleMessage, 256)
+	consoleMutex.Lock()
+	consoleSubscribers[ch] = struct{}{}
+	consoleMutex.Unlock()
+	defer func() {
+		consoleMutex.Lock()
+		delete(consoleSubscribers, ch)
+		consoleMutex.Unlock()
+	}()
+
+	if err := sendServerCommand("list"); err != nil {
+		return PlayerList{}, err
+	}
+	deadline := time.After(listTimeout)
+	var list *PlayerList
+	for {
+		select {
+		case msg := <-ch:
+			if list != nil {
+				list.Players = parseListNames(msg.Line)
+				return *list, nil
+			}
+			if m := listHeader.FindStringSubmatch(msg.Line); m != nil {
+				count, _ := strconv.Atoi(m[1])
+				capacity, _ := strconv.Atoi(m[2])
+				list = &PlayerList{Players: []string{}, Count: count, Max: capacity, Time: msg.Time}
+				if count == 0 {
+					return *list, nil
+				}
+			}
+		case <-deadline:
+			return PlayerList{}, errors.New("timed out waiting for the list answer")
+		}
+	}
+}
+
+// playerListHandler serves GET /players, the players online as the server
+// reports them.
+func playerListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if os.Getenv(serverLogEnv) == "" {
+		writeJSONError(w, http.StatusServiceUnavailable, "Server output is not available: set "+serverLogEnv)
+		return
+	}
+	list, err := queryPlayerList()
+	if err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "Failed to list players: "+err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, list)
+}
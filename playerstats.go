@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const playerStatsStateFile = "player_stats.json"
+
+// PlayerStats accumulates per-player activity across restarts.
+type PlayerStats struct {
+	Player          string    `json:"player"`
+	FirstSeen       time.Time `json:"first_seen"`
+	LastSeen        time.Time `json:"last_seen"`
+	Joins           int       `json:"joins"`
+	PlaytimeSeconds int64     `json:"playtime_seconds"`
+	SessionStart    time.Time `json:"session_start,omitempty"`
+	DailyStreak     int       `json:"daily_streak"`
+	LongestStreak   int       `json:"longest_streak"`
+	LastDailyReward string    `json:"last_daily_reward,omitempty"` // YYYY-MM-DD
+}
+
+var (
+	playerStats      = make(map[string]*PlayerStats)
+	playerStatsMutex sync.Mutex
+)
+
+func savePlayerStats() {
+	if err := saveState(playerStatsStateFile, playerStats); err != nil {
+		log.Printf("Error saving player stats: %v", err)
+	}
+}
+
+// statsFor returns the stats record for a player, creating it if needed.
+// Callers must hold playerStatsMutex.
+func statsFor(player string) *PlayerStats {
+	st, ok := playerStats[player]
+	if !ok {
+		st = &PlayerStats{Player: player, FirstSeen: time.Now()}
+		playerStats[player] = st
+	}
+	return st
+}
+
+// livePlaytime returns the stored playtime plus the current session.
+func (st *PlayerStats) livePlaytime() int64 {
+	if st.SessionStart.IsZero() {
+		return st.PlaytimeSeconds
+	}
+	return st.PlaytimeSeconds + int64(time.Since(st.SessionStart)/time.Second)
+}
+
+// recordPlayerJoin starts a session for an admitted player.
+func recordPlayerJoin(player string) {
+	playerStatsMutex.Lock()
+	st := statsFor(player)
+	if !st.SessionStart.IsZero() {
+		// Missed leave event; close the stale session at the last sighting.
+		st.PlaytimeSeconds += int64(st.LastSeen.Sub(st.SessionStart) / time.Second)
+	}
+	st.Joins++
+	st.SessionStart = time.Now()
+	st.LastSeen = st.SessionStart
+	savePlayerStats()
+	playerStatsMutex.Unlock()
+
+	grantDailyReward(player)
+	questCheckStats(player)
+}
+
+// recordPlayerLeave closes the player's session and adds its playtime.
+func recordPlayerLeave(player string) {
+	playerStatsMutex.Lock()
+	defer playerStatsMutex.Unlock()
+	st := statsFor(player)
+	st.PlaytimeSeconds = st.livePlaytime()
+	st.SessionStart = time.Time{}
+	st.LastSeen = time.Now()
+	savePlayerStats()
+}
+
+// playersHandler routes the per-player endpoints under /players/{name}/.
+func playersHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/players/")
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 {
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	player, err := url.PathUnescape(rest[:i])
+	if err != nil || !validPlayerName(player) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid player")
+		return
+	}
+	switch rest[i+1:] {
+	case "stats":
+		playerStatsHandler(w, r, player)
+	case "quests":
+		playerQuestsHandler(w, r, player)
+	case "tags":
+		playerTagsHandler(w, r, player)
+	case "spawn":
+		playerSpawnHandler(w, r, player)
+	case "inventory":
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { playerInventoryHandler(w, r, player) })(w, r)
+	case "world-data":
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { playerWorldDataHandler(w, r, player) })(w, r)
+	case "kick":
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { playerKickHandler(w, r, player) })(w, r)
+	case "data-export":
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { playerDataExportHandler(w, r, player) })(w, r)
+	case "data":
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { playerDataHandler(w, r, player) })(w, r)
+	default:
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// playerStatsHandler serves GET /players/{name}/stats.
+func playerStatsHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	playerStatsMutex.Lock()
+	st, ok := playerStats[player]
+	var out PlayerStats
+	if ok {
+		out = *st
+		out.PlaytimeSeconds = st.livePlaytime()
+	}
+	playerStatsMutex.Unlock()
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Player not found")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, out)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	playerTokensStateFile = "player_tokens.json"
+	playerTokenPrefix     = "bpt_"
+	maxPlayerTokenTTL     = 365 * 24 * time.Hour
+)
+
+// Scopes grantable to player tokens.
+const (
+	scopeProfileRead  = "profile:read"
+	scopePositionRead = "position:read"
+)
+
+// playerScopes lists every scope a player token may carry.
+var playerScopes = map[string]bool{
+	scopeProfileRead:  true,
+	scopePositionRead: true,
+}
+
+// PlayerToken is a limited-scope credential bound to a single player, for
+// use by community companion apps. Only the token's hash is stored.
+type PlayerToken struct {
+	ID        string    `json:"id"`
+	Player    string    `json:"player"`
+	Scopes    []string  `json:"scopes"`
+	Label     string    `json:"label,omitempty"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	LastUsed  time.Time `json:"last_used,omitempty"`
+}
+
+// PlayerTokenRequest is the body of POST /player-tokens.
+type PlayerTokenRequest struct {
+	Player string   `json:"player"`
+	Scopes []string `json:"scopes"`
+	Label  string   `json:"label"`
+	TTL    string   `json:"ttl"` // Go duration; empty means no expiry
+}
+
+var (
+	playerTokens      = make([]PlayerToken, 0)
+	playerTokensMutex sync.Mutex
+)
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func savePlayerTokens() {
+	if err := saveState(playerTokensStateFile, playerTokens); err != nil {
+		log.Printf("Error saving player tokens: %v", err)
+	}
+}
+
+// hasScope reports whether the token grants scope.
+func (t PlayerToken) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// requirePlayerToken authenticates a bearer player token carrying scope and
+// passes it to next.
+func requirePlayerToken(scope string, next func(http.ResponseWriter, *http.Request, PlayerToken)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !strings.HasPrefix(raw, playerTokenPrefix) {
+			writeJSONError(w, http.StatusUnauthorized, "Player token required")
+			return
+		}
+		hash := hashToken(raw)
+		playerTokensMutex.Lock()
+		var tok *PlayerToken
+		for i := range playerTokens {
+			if playerTokens[i].Hash == hash {
+				tok = &playerTokens[i]
+			}
+		}
+		if tok == nil || (!tok.ExpiresAt.IsZero() && time.Now().After(tok.ExpiresAt)) {
+			playerTokensMutex.Unlock()
+			writeJSONError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+		if !tok.hasScope(scope) {
+			playerTokensMutex.Unlock()
+			writeJSONError(w, http.StatusForbidden, "Token lacks scope "+scope)
+			return
+		}
+		tok.LastUsed = time.Now()
+		snapshot := *tok
+		playerTokensMutex.Unlock()
+		next(w, r, snapshot)
+	}
+}
+
+// playerTokensHandler lists (GET) or issues (POST) player tokens. The raw
+// token is returned only once, at issue time.
+func playerTokensHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		playerTokensMutex.Lock()
+		defer playerTokensMutex.Unlock()
+		player := r.URL.Query().Get("player")
+		list := []PlayerToken{}
+		for _, t := range playerTokens {
+			if player == "" || t.Player == player {
+				t.Hash = ""
+				list = append(list, t)
+			}
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"tokens": list})
+	case http.MethodPost:
+		var req PlayerTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if strings.TrimSpace(req.Player) == "" || !validPlayerName(req.Player) || len(req.Scopes) == 0 {
+			writeJSONError(w, http.StatusBadRequest, "player and scopes are required")
+			return
+		}
+		for _, s := range req.Scopes {
+			if !playerScopes[s] {
+				writeJSONError(w, http.StatusBadRequest, "Unknown scope "+s)
+				return
+			}
+		}
+		var b [32]byte
+		rand.Read(b[:])
+		raw := playerTokenPrefix + hex.EncodeToString(b[:])
+		tok := PlayerToken{
+			ID:        newUUID(),
+			Player:    req.Player,
+			Scopes:    req.Scopes,
+			Label:     req.Label,
+			Hash:      hashToken(raw),
+			CreatedAt: time.Now(),
+		}
+		if req.TTL != "" {
+			d, err := time.ParseDuration(req.TTL)
+			if err != nil || d <= 0 || d > maxPlayerTokenTTL {
+				writeJSONError(w, http.StatusBadRequest, "Invalid ttl")
+				return
+			}
+			tok.ExpiresAt = tok.CreatedAt.Add(d)
+		}
+		playerTokensMutex.Lock()
+		playerTokens = append(playerTokens, tok)
+		savePlayerTokens()
+		playerTokensMutex.Unlock()
+		tok.Hash = ""
+		writeJSONResponse(w, http.StatusCreated, map[string]interface{}{"token": raw, "info": tok})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// playerTokenHandler revokes a token via DELETE /player-tokens/{id}.
+func playerTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/player-tokens/")
+	playerTokensMutex.Lock()
+	defer playerTokensMutex.Unlock()
+	for i, t := range playerTokens {
+		if t.ID == id {
+			playerTokens = append(playerTokens[:i], playerTokens[i+1:]...)
+			savePlayerTokens()
+			writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Token revoked"})
+			return
+		}
+	}
+	writeJSONError(w, http.StatusNotFound, "Token not found")
+}
+
+// meHandler returns the authenticated player's token details.
+func meHandler(w http.ResponseWriter, r *http.Request, tok PlayerToken) {
+	queueMutex.Lock()
+	_, online := onlineSet[tok.Player]
+	queueMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"player":     tok.Player,
+		"scopes":     tok.Scopes,
+		"online":     online,
+		"expires_at": tok.ExpiresAt,
+	})
+}
+
+// mePositionHandler returns the authenticated player's last known position.
+func mePositionHandler(w http.ResponseWriter, r *http.Request, tok PlayerToken) {
+	bridgeMutex.RLock()
+	pos, ok := bridgePositions[tok.Player]
+	bridgeMutex.RUnlock()
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Position unknown")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, pos)
+}
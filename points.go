@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pointsStateFile        = "points.json"
+	maxTransactionsPerUser = 200
+)
+
+// PointsTransaction is one change to a player's balance.
+type PointsTransaction struct {
+	Amount  int64     `json:"amount"`
+	Balance int64     `json:"balance"`
+	Reason  string    `json:"reason"`
+	Time    time.Time `json:"time"`
+}
+
+type pointsState struct {
+	Balances     map[string]int64               `json:"balances"`
+	Transactions map[string][]PointsTransaction `json:"transactions"`
+}
+
+var (
+	points      = pointsState{Balances: map[string]int64{}, Transactions: map[string][]PointsTransaction{}}
+	pointsMutex sync.Mutex
+)
+
+var errInsufficientPoints = errors.New("insufficient points")
+
+// adjustPoints changes a player's balance by amount, refusing to go negative,
+// and records the transaction. It returns the new balance.
+func adjustPoints(player string, amount int64, reason string) (int64, error) {
+	pointsMutex.Lock()
+	defer pointsMutex.Unlock()
+	balance := points.Balances[player] + amount
+	if balance < 0 {
+		return points.Balances[player], errInsufficientPoints
+	}
+	points.Balances[player] = balance
+	tx := append(points.Transactions[player], PointsTransaction{Amount: amount, Balance: balance, Reason: reason, Time: time.Now()})
+	if len(tx) > maxTransactionsPerUser {
+		tx = tx[len(tx)-maxTransactionsPerUser:]
+	}
+	points.Transactions[player] = tx
+	if err := saveState(pointsStateFile, points); err != nil {
+		log.Printf("Error saving points ledger: %v", err)
+	}
+	return balance, nil
+}
+
+// pointsBalance returns a player's balance.
+func pointsBalance(player string) int64 {
+	pointsMutex.Lock()
+	defer pointsMutex.Unlock()
+	return points.Balances[player]
+}
+
+// pointsHandler serves GET /points/{player} (balance and history) and
+// POST /points/{player} (admin adjustment with {amount, reason}).
+func pointsHandler(w http.ResponseWriter, r *http.Request) {
+	player, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/points/"))
+	if err != nil || !validPlayerName(player) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid player")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		pointsMutex.Lock()
+		defer pointsMutex.Unlock()
+		history := points.Transactions[player]
+		if history == nil {
+			history = []PointsTransaction{}
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"player":       player,
+			"balance":      points.Balances[player],
+			"transactions": history,
+		})
+	case http.MethodPost:
+		var req struct {
+			Amount int64  `json:"amount"`
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount == 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = "admin adjustment"
+		}
+		balance, err := adjustPoints(player, req.Amount, req.Reason)
+		if err != nil {
+			writeJSONError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"player": player, "balance": balance})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
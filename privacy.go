@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const erasureAuditStateFile = "erasure_audit.json"
+
+// ErasureRecord is the audit entry for a data erasure. The player is stored
+// only as a hash so the audit trail does not itself retain the identity.
+type ErasureRecord struct {
+	PlayerHash  string    `json:"player_hash"`
+	Categories  []string  `json:"categories"`
+	RequestedBy string    `json:"requested_by"`
+	Time        time.Time `json:"time"`
+}
+
+var (
+	erasureAudit      = make([]ErasureRecord, 0)
+	erasureAuditMutex sync.Mutex
+)
+
+// mcwsEventSender returns the sender of a raw game event, if it has one.
+func mcwsEventSender(ev MCWSEvent) string {
+	var body struct {
+		Sender string `json:"sender"`
+	}
+	json.Unmarshal(ev.Body, &body)
+	return body.Sender
+}
+
+// exportPlayerData bundles everything the sidecar stores about a player.
+// Players are identified by gamertag, which is what the game reports to us.
+func exportPlayerData(player string) map[string]interface{} {
+	out := map[string]interface{}{"player": player, "exported_at": time.Now()}
+
+	playerStatsMutex.Lock()
+	if st, ok := playerStats[player]; ok {
+		out["stats"] = *st
+	}
+	playerStatsMutex.Unlock()
+
+	pointsMutex.Lock()
+	out["ledger"] = map[string]interface{}{
+		"balance":      points.Balances[player],
+		"transactions": points.Transactions[player],
+	}
+	pointsMutex.Unlock()
+
+	chatMutex.Lock()
+	chat := []ChatMessage{}
+	for _, m := range chatLog {
+		if m.Sender == player {
+			chat = append(chat, m)
+		}
+	}
+	chatMutex.Unlock()
+	out["chat"] = chat
+
+	bridgeMutex.RLock()
+	events := []BridgeEvent{}
+	for _, ev := range bridgeEvents {
+		if ev.Player == player {
+			events = append(events, ev)
+		}
+	}
+	if pos, ok := bridgePositions[player]; ok {
+		out["last_position"] = pos
+	}
+	bridgeMutex.RUnlock()
+	out["sessions_and_events"] = events
+
+	mcws.mu.RLock()
+	raw := []MCWSEvent{}
+	for _, ev := range mcws.events {
+		if mcwsEventSender(ev) == player {
+			raw = append(raw, ev)
+		}
+	}
+	mcws.mu.RUnlock()
+	out["game_events"] = raw
+
+	homesMutex.Lock()
+	out["homes"] = homes.Homes[player]
+	homesMutex.Unlock()
+
+	questsMutex.Lock()
+	out["quests"] = quests.Progress[player]
+	questsMutex.Unlock()
+
+	groupsMutex.RLock()
+	out["groups"] = groups.Members[player]
+	groupsMutex.RUnlock()
+
+	playerTokensMutex.Lock()
+	tokens := []PlayerToken{}
+	for _, t := range playerTokens {
+		if t.Player == player {
+			t.Hash = ""
+			tokens = append(tokens, t)
+		}
+	}
+	playerTokensMutex.Unlock()
+	out["api_tokens"] = tokens
+
+	leaderboardsMutex.Lock()
+	scores := map[string]int64{}
+	for obj, m := range leaderboards.Scores {
+		if s, ok := m[player]; ok {
+			scores[obj] = s
+		}
+	}
+	leaderboardsMutex.Unlock()
+	out["scoreboard_scores"] = scores
+
+	securityMutex.Lock()
+	secEvents := []SecurityEvent{}
+	for _, ev := range securityEvents {
+		if ev.Player == player {
+			secEvents = append(secEvents, ev)
+		}
+	}
+	if until, ok := security.Bans[player]; ok {
+		out["ban_until"] = until
+	}
+	securityMutex.Unlock()
+	out["security_events"] = secEvents
+	if b, ok := findBan(player, ""); ok {
+		out["ban"] = b
+	}
+
+	inventoryMutex.Lock()
+	if id, ok := playerEntityIDs[player]; ok {
+		out["entity_id"] = id
+	}
+	inventoryMutex.Unlock()
+
+	queueMutex.Lock()
+	if pos := queuePosition(player); pos > 0 {
+		out["queue_entry"] = joinQueue[pos-1]
+	}
+	queueMutex.Unlock()
+	return out
+}
+
+// erasePlayerData removes a player's data from every store and returns the
+// categories that held something.
+func erasePlayerData(player string) []string {
+	var erased []string
+
+	playerStatsMutex.Lock()
+	if _, ok := playerStats[player]; ok {
+		delete(playerStats, player)
+		savePlayerStats()
+		erased = append(erased, "stats")
+	}
+	playerStatsMutex.Unlock()
+
+	pointsMutex.Lock()
+	_, hasBalance := points.Balances[player]
+	_, hasTx := points.Transactions[player]
+	if hasBalance || hasTx {
+		delete(points.Balances, player)
+		delete(points.Transactions, player)
+		if err := saveState(pointsStateFile, points); err != nil {
+			log.Printf("Error saving points ledger: %v", err)
+		}
+		erased = append(erased, "ledger")
+	}
+	pointsMutex.Unlock()
+
+	chatMutex.Lock()
+	kept := make([]ChatMessage, 0, len(chatLog))
+	for _, m := range chatLog {
+		if m.Sender != player {
+			kept = append(kept, m)
+		}
+	}
+	if len(kept) != len(chatLog) {
+		erased = append(erased, "chat")
+	}
+	chatLog = kept
+	chatMutex.Unlock()
+
+	bridgeMutex.Lock()
+	keptEvents := make([]BridgeEvent, 0, len(bridgeEvents))
+	for _, ev := range bridgeEvents {
+		if ev.Player != player {
+			keptEvents = append(keptEvents, ev)
+		}
+	}
+	if len(keptEvents) != len(bridgeEvents) {
+		erased = append(erased, "events")
+	}
+	bridgeEvents = keptEvents
+	delete(bridgePositions, player)
+	bridgeMutex.Unlock()
+
+	mcws.mu.Lock()
+	keptRaw := make([]MCWSEvent, 0, len(mcws.events))
+	for _, ev := range mcws.events {
+		if mcwsEventSender(ev) != player {
+			keptRaw = append(keptRaw, ev)
+		}
+	}
+	if len(keptRaw) != len(mcws.events) {
+		erased = append(erased, "game_events")
+	}
+	mcws.events = keptRaw
+	mcws.mu.Unlock()
+
+	homesMutex.Lock()
+	if _, ok := homes.Homes[player]; ok {
+		delete(homes.Homes, player)
+		saveHomes()
+		erased = append(erased, "homes")
+	}
+	delete(homeCooldowns, player)
+	homesMutex.Unlock()
+
+	questsMutex.Lock()
+	if _, ok := quests.Progress[player]; ok {
+		delete(quests.Progress, player)
+		saveQuests()
+		erased = append(erased, "quests")
+	}
+	questsMutex.Unlock()
+
+	groupsMutex.Lock()
+	if _, ok := groups.Members[player]; ok {
+		delete(groups.Members, player)
+		saveGroups()
+		erased = append(erased, "groups")
+	}
+	groupsMutex.Unlock()
+
+	playerTokensMutex.Lock()
+	keptTokens := make([]PlayerToken, 0, len(playerTokens))
+	for _, t := range playerTokens {
+		if t.Player != player {
+			keptTokens = append(keptTokens, t)
+		}
+	}
+	if len(keptTokens) != len(playerTokens) {
+		playerTokens = keptTokens
+		savePlayerTokens()
+		erased = append(erased, "api_tokens")
+	}
+	playerTokensMutex.Unlock()
+
+	leaderboardsMutex.Lock()
+	removed := false
+	for _, m := range leaderboards.Scores {
+		if _, ok := m[player]; ok {
+			delete(m, player)
+			removed = true
+		}
+	}
+	if removed {
+		if err := saveState(leaderboardsStateFile, leaderboards); err != nil {
+			log.Printf("Error saving leaderboards: %v", err)
+		}
+		erased = append(erased, "scoreboard_scores")
+	}
+	leaderboardsMutex.Unlock()
+
+	// Bans are kept: erasure must not be a way around a ban. Their events go.
+	securityMutex.Lock()
+	keptSec := make([]SecurityEvent, 0, len(securityEvents))
+	for _, ev := range securityEvents {
+		if ev.Player != player {
+			keptSec = append(keptSec, ev)
+		}
+	}
+	if len(keptSec) != len(securityEvents) {
+		erased = append(erased, "security_events")
+	}
+	securityEvents = keptSec
+	delete(playerActivity, player)
+	securityMutex.Unlock()
+
+	inventoryMutex.Lock()
+	if _, ok := playerEntityIDs[player]; ok {
+		delete(playerEntityIDs, player)
+		if err := saveState(playerEntityIDsStateFile, playerEntityIDs); err != nil {
+			log.Printf("Error saving player entity IDs: %v", err)
+		}
+		erased = append(erased, "entity_id")
+	}
+	inventoryMutex.Unlock()
+
+	queueMutex.Lock()
+	if pos := queuePosition(player); pos > 0 {
+		joinQueue = append(joinQueue[:pos-1], joinQueue[pos:]...)
+		erased = append(erased, "queue_entry")
+	}
+	queueMutex.Unlock()
+
+	return erased
+}
+
+// playerDataExportHandler serves GET /players/{name}/data-export.
+func playerDataExportHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="player-data.json"`)
+	writeJSONResponse(w, http.StatusOK, exportPlayerData(player))
+}
+
+// playerDataHandler serves DELETE /players/{name}/data, erasing the player's
+// data and recording the erasure in the audit trail.
+func playerDataHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	categories := erasePlayerData(player)
+	rec := ErasureRecord{
+		PlayerHash:  hashToken(player),
+		Categories:  categories,
+		RequestedBy: r.RemoteAddr,
+		Time:        time.Now(),
+	}
+	if rec.Categories == nil {
+		rec.Categories = []string{}
+	}
+	erasureAuditMutex.Lock()
+	erasureAudit = append(erasureAudit, rec)
+	err := saveState(erasureAuditStateFile, erasureAudit)
+	erasureAuditMutex.Unlock()
+	if err != nil {
+		log.Printf("Error saving erasure audit: %v", err)
+	}
+	log.Printf("Erased player data (%s): %v", rec.PlayerHash[:12], rec.Categories)
+	writeJSONResponse(w, http.StatusOK, rec)
+}
+
+// erasureAuditHandler serves GET /privacy/erasures.
+func erasureAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	erasureAuditMutex.Lock()
+	defer erasureAuditMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"erasures": erasureAudit})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const processTuningStateFile = "process_tuning.json"
+
+// ProcessTuning is the scheduling and resource settings applied to the
+// bedrock process whenever a new one is seen, so they hold across restarts.
+// Unset fields leave the process as it was started.
+type ProcessTuning struct {
+	Nice        *int   `json:"nice,omitempty"`          // -20 (highest) to 19
+	CPUs        []int  `json:"cpus,omitempty"`          // CPU affinity
+	OOMScoreAdj *int   `json:"oom_score_adj,omitempty"` // -1000 to 1000
+	OpenFiles   uint64 `json:"open_files,omitempty"`    // RLIMIT_NOFILE
+}
+
+func (t *ProcessTuning) validate() error {
+	if t.Nice != nil && (*t.Nice < -20 || *t.Nice > 19) {
+		return errors.New("nice must be between -20 and 19")
+	}
+	for _, cpu := range t.CPUs {
+		if cpu < 0 || cpu >= maxAffinityCPUs {
+			return fmt.Errorf("cpu %d out of range", cpu)
+		}
+	}
+	if t.OOMScoreAdj != nil && (*t.OOMScoreAdj < -1000 || *t.OOMScoreAdj > 1000) {
+		return errors.New("oom_score_adj must be between -1000 and 1000")
+	}
+	return nil
+}
+
+func (t *ProcessTuning) isZero() bool {
+	return t.Nice == nil && len(t.CPUs) == 0 && t.OOMScoreAdj == nil && t.OpenFiles == 0
+}
+
+// ProcessTuningStatus is the outcome of the last time the tuning was applied.
+type ProcessTuningStatus struct {
+	PID     int        `json:"pid,omitempty"`
+	Applied *time.Time `json:"applied,omitempty"`
+	Errors  []string   `json:"errors,omitempty"`
+}
+
+var (
+	processTuning       ProcessTuning
+	processTuningStatus ProcessTuningStatus
+	// tunedProcessStart identifies the process last tuned, as PIDs are reused.
+	tunedProcessStart  uint64
+	processTuningMutex sync.Mutex
+)
+
+func saveProcessTuning() {
+	if err := saveState(processTuningStateFile, processTuning); err != nil {
+		log.Printf("Error saving process tuning: %v", err)
+	}
+	configChanged()
+}
+
+// applyProcessTuning applies the tuning to pid and records the outcome.
+// Lowering nice or oom_score_adj and raising the open files hard limit
+// need CAP_SYS_NICE or CAP_SYS_RESOURCE. Callers must hold
+// processTuningMutex.
+func applyProcessTuning(pid int, start uint64) {
+	var errs []string
+	t := processTuning
+	if t.Nice != nil {
+		if err := setProcessNice(pid, *t.Nice); err != nil {
+			errs = append(errs, "nice: "+err.Error())
+		}
+	}
+	if len(t.CPUs) > 0 {
+		if err := setProcessAffinity(pid, t.CPUs); err != nil {
+			errs = append(errs, "cpus: "+err.Error())
+		}
+	}
+	if t.OOMScoreAdj != nil {
+		if err := setProcessOOMScoreAdj(pid, *t.OOMScoreAdj); err != nil {
+			errs = append(errs, "oom_score_adj: "+err.Error())
+		}
+	}
+	if t.OpenFiles > 0 {
+		if err := setProcessOpenFiles(pid, t.OpenFiles); err != nil {
+			errs = append(errs, "open_files: "+err.Error())
+		}
+	}
+	for _, e := range errs {
+		log.Printf("Error tuning server process %d: %s", pid, e)
+	}
+	now := time.Now()
+	processTuningStatus = ProcessTuningStatus{PID: pid, Applied: &now, Errors: errs}
+	tunedProcessStart = start
+}
+
+// tuneServerProcess applies the tuning once to each new bedrock process.
+// The resource sampler calls it, so a started server is tuned within one
+// sample interval.
+func tuneServerProcess(pid int, start uint64) {
+	processTuningMutex.Lock()
+	defer processTuningMutex.Unlock()
+	if pid == processTuningStatus.PID && start == tunedProcessStart {
+		return
+	}
+	applyProcessTuning(pid, start)
+}
+
+// serverProcessHandler shows the process tuning and how it was last applied
+// (GET), or replaces it and applies it to the running process (PUT).
+func serverProcessHandler(w http.ResponseWriter, r *http.Request) {
+	processTuningMutex.Lock()
+	defer processTuningMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if runtime.GOOS != "linux" {
+			writeJSONError(w, http.StatusNotImplemented, "Process tuning is only supported on Linux")
+			return
+		}
+		var t ProcessTuning
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := t.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		processTuning = t
+		saveProcessTuning()
+		if pid, err := findServerPID(); err == nil {
+			if _, _, start, err := readProcStat(pid); err == nil {
+				applyProcessTuning(pid, start)
+			}
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"tuning": processTuning, "status": processTuningStatus})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// maxAffinityCPUs is the size of the CPU mask passed to sched_setaffinity.
+const maxAffinityCPUs = 1024
+
+// processThreads returns the thread IDs of pid. Linux keeps the nice value
+// and CPU affinity per thread, so both are applied to every thread; threads
+// started later inherit them.
+func processThreads(pid int) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(procRoot, strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+func setProcessNice(pid, nice int) error {
+	tids, err := processThreads(pid)
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setProcessAffinity(pid int, cpus []int) error {
+	var mask [maxAffinityCPUs / 64]uint64
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << (cpu % 64)
+	}
+	tids, err := processThreads(pid)
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+		if errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
+
+func setProcessOOMScoreAdj(pid, adj int) error {
+	return os.WriteFile(filepath.Join(procRoot, strconv.Itoa(pid), "oom_score_adj"), []byte(strconv.Itoa(adj)), 0644)
+}
+
+// setProcessOpenFiles sets both the soft and hard open files limit with
+// prlimit.
+func setProcessOpenFiles(pid int, n uint64) error {
+	limit := syscall.Rlimit{Cur: n, Max: n}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), syscall.RLIMIT_NOFILE, uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
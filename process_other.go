@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+const maxAffinityCPUs = 1024
+
+var errProcessTuningUnsupported = errors.New("process tuning is only supported on Linux")
+
+func setProcessNice(pid, nice int) error { return errProcessTuningUnsupported }
+
+func setProcessAffinity(pid int, cpus []int) error { return errProcessTuningUnsupported }
+
+func setProcessOOMScoreAdj(pid, adj int) error { return errProcessTuningUnsupported }
+
+func setProcessOpenFiles(pid int, n uint64) error { return errProcessTuningUnsupported }
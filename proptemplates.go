@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const tenantValuesStateFile = "tenant_values.json"
+
+var (
+	propertyTemplatesDir = filepath.Join(stateDir, "property_templates")
+	templateVarPattern   = regexp.MustCompile(`\$\{([A-Za-z0-9_.]+)(:-([^}]*))?\}`)
+	propertyKeyPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9\-_.]*$`)
+
+	// tenantValues are per-deployment values referenced as ${tenant.KEY}.
+	tenantValues      = make(map[string]string)
+	tenantValuesMutex sync.Mutex
+)
+
+// PropertyTemplateApply is the body of POST /server-properties/apply-template.
+type PropertyTemplateApply struct {
+	Template  string            `json:"template"`
+	Variables map[string]string `json:"variables,omitempty"`
+	DryRun    bool              `json:"dry_run,omitempty"`
+	Restart   bool              `json:"restart,omitempty"`
+}
+
+// renderPropertyTemplate substitutes ${NAME}, ${tenant.KEY} and
+// ${NAME:-default} in a properties template. Request variables win over
+// tenant values, which win over environment variables. Any unresolved
+// variable fails the whole render.
+func renderPropertyTemplate(text string, vars map[string]string) (map[string]string, error) {
+	tenantValuesMutex.Lock()
+	tenant := make(map[string]string, len(tenantValues))
+	for k, v := range tenantValues {
+		tenant[k] = v
+	}
+	tenantValuesMutex.Unlock()
+
+	var missing []string
+	lookup := func(m []string) string {
+		name := m[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if key, ok := strings.CutPrefix(name, "tenant."); ok {
+			if v, ok := tenant[key]; ok {
+				return v
+			}
+		} else if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		missing = append(missing, name)
+		return ""
+	}
+
+	out := make(map[string]string)
+	for n, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !propertyKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected key=value", n+1)
+		}
+		value = templateVarPattern.ReplaceAllStringFunc(strings.TrimSpace(value), func(s string) string {
+			return lookup(templateVarPattern.FindStringSubmatch(s))
+		})
+		if strings.ContainsAny(value, "\n\r") {
+			return nil, fmt.Errorf("line %d: value of %s contains a newline", n+1, key)
+		}
+		out[key] = value
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("unresolved variables: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// readPropertyTemplate loads a named template, or a file path if ref
+// contains a path separator.
+func readPropertyTemplate(ref string) (string, error) {
+	path := ref
+	if !strings.ContainsAny(ref, `/\`) {
+		if !validName(ref) {
+			return "", fmt.Errorf("invalid template name %q", ref)
+		}
+		path = filepath.Join(propertyTemplatesDir, ref+".properties")
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// applyPropertyTemplate renders a template and writes it into
+// server.properties, returning the properties whose values changed.
+func applyPropertyTemplate(ref string, vars map[string]string, dryRun bool) (map[string]string, error) {
+	text, err := readPropertyTemplate(ref)
+	if err != nil {
+		return nil, err
+	}
+	values, err := renderPropertyTemplate(text, vars)
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]string)
+	for k, v := range values {
+		if cur, err := getServerProperty(k, ""); err != nil || cur != v {
+			changed[k] = v
+		}
+	}
+	if dryRun || len(changed) == 0 {
+		return changed, nil
+	}
+	return changed, setServerProperties(changed)
+}
+
+// applyStartupPropertyTemplate applies BEDROCK_API_PROPERTIES_TEMPLATE before
+// the server is started by the sidecar.
+func applyStartupPropertyTemplate() {
+	ref := os.Getenv(propertiesTemplateEnv)
+	if ref == "" {
+		return
+	}
+	changed, err := applyPropertyTemplate(ref, nil, false)
+	if err != nil {
+		log.Fatalf("Error applying server properties template %s: %v", ref, err)
+	}
+	log.Printf("Applied server properties template %s (%d changed)", ref, len(changed))
+}
+
+// propertyTemplatesHandler lists stored templates.
+func propertyTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	names := []string{}
+	entries, _ := os.ReadDir(propertyTemplatesDir)
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".properties"); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"templates": names})
+}
+
+// propertyTemplateHandler serves GET, PUT (raw template text) and DELETE
+// /server-properties/templates/{name}.
+func propertyTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/server-properties/templates/")
+	if !validName(name) || strings.ContainsAny(name, `/\`) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid template name")
+		return
+	}
+	path := filepath.Join(propertyTemplatesDir, name+".properties")
+	switch r.Method {
+	case http.MethodGet:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "Template not found")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+	case http.MethodPut:
+		data, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Failed to read template")
+			return
+		}
+		// Check the syntax without requiring every variable to be set yet.
+		probe := templateVarPattern.ReplaceAllString(string(data), "x")
+		if _, err := renderPropertyTemplate(probe, nil); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := os.MkdirAll(propertyTemplatesDir, 0755); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		_, statErr := os.Stat(path)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		status := http.StatusOK
+		if os.IsNotExist(statErr) {
+			status = http.StatusCreated
+		}
+		writeJSONResponse(w, status, map[string]string{"message": "Template saved"})
+	case http.MethodDelete:
+		if err := os.Remove(path); err != nil {
+			writeJSONError(w, http.StatusNotFound, "Template not found")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Template deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// applyPropertyTemplateHandler serves POST /server-properties/apply-template.
+func applyPropertyTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req PropertyTemplateApply
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Template == "" {
+		writeJSONError(w, http.StatusBadRequest, "template is required")
+		return
+	}
+	if strings.ContainsAny(req.Template, `/\`) {
+		writeJSONError(w, http.StatusBadRequest, "Only stored templates can be applied over the API")
+		return
+	}
+	changed, err := applyPropertyTemplate(req.Template, req.Variables, req.DryRun)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	resp := map[string]interface{}{"changed": changed, "dry_run": req.DryRun}
+	if req.Restart && !req.DryRun && len(changed) > 0 {
+		if err := restartServer(); err != nil {
+			resp["restart_error"] = err.Error()
+		} else {
+			resp["restarted"] = true
+		}
+	}
+	writeJSONResponse(w, http.StatusOK, resp)
+}
+
+// tenantValuesHandler returns (GET) or replaces (PUT) the tenant values.
+func tenantValuesHandler(w http.ResponseWriter, r *http.Request) {
+	tenantValuesMutex.Lock()
+	defer tenantValuesMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, tenantValues)
+	case http.MethodPut:
+		var values map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if values == nil {
+			values = map[string]string{}
+		}
+		tenantValues = values
+		if err := saveState(tenantValuesStateFile, tenantValues); err != nil {
+			log.Printf("Error saving tenant values: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save tenant values")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, tenantValues)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
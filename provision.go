@@ -0,0 +1,340 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const maxDownloadSize = 2 << 30
+
+var downloadClient = &http.Client{Timeout: 30 * time.Minute}
+
+// ProvisionPack is a pack to install while provisioning. Type is required
+// for single .mcpack files; .mcaddon bundles are detected automatically.
+type ProvisionPack struct {
+	URL      string `json:"url"`
+	Type     string `json:"type,omitempty"` // behavior or resource
+	Activate bool   `json:"activate,omitempty"`
+}
+
+// ProvisionSpec describes how to turn an empty data volume into a server.
+type ProvisionSpec struct {
+	ServerURL          string            `json:"server_url"`
+	Properties         map[string]string `json:"properties,omitempty"`
+	PropertiesTemplate string            `json:"properties_template,omitempty"`
+	Packs              []ProvisionPack   `json:"packs,omitempty"`
+	LevelName          string            `json:"level_name,omitempty"`
+	Seed               string            `json:"seed,omitempty"`
+	Start              bool              `json:"start,omitempty"`
+	Force              bool              `json:"force,omitempty"`
+}
+
+// dataDirEmpty reports whether the data directory has no server install or
+// worlds yet. The sidecar's own directories are ignored.
+func dataDirEmpty() bool {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	for _, e := range entries {
+		switch e.Name() {
+		case filepath.Base(stateDir), "pack_archives", "lost+found":
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// downloadToTemp fetches url into a temporary file and returns its path.
+func downloadToTemp(url, pattern string) (string, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return "", fmt.Errorf("unsupported URL %q", url)
+	}
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, limitedReader{io.LimitReader(resp.Body, maxDownloadSize+1), downloadLimiter})
+	f.Close()
+	if err == nil && n > maxDownloadSize {
+		err = errors.New("download exceeds size limit")
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// unzipArchive extracts src into dst, refusing entries that escape dst.
+func unzipArchive(src, dst string) error {
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	root := filepath.Clean(dst) + string(os.PathSeparator)
+	for _, f := range reader.File {
+		path := filepath.Join(dst, f.Name)
+		if !strings.HasPrefix(path, root) {
+			return fmt.Errorf("illegal path in archive: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		mode := f.Mode().Perm()
+		if mode == 0 {
+			mode = 0644
+		}
+		out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			out.Close()
+			return err
+		}
+		_, err = io.Copy(out, rc)
+		rc.Close()
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installPackFromURL downloads and installs one pack.
+func installPackFromURL(p ProvisionPack) error {
+	path, err := downloadToTemp(p.URL, "pack-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	if strings.HasSuffix(strings.ToLower(strings.SplitN(p.URL, "?", 2)[0]), ".mcaddon") {
+		_, err := installMcAddonFile(path)
+		return err
+	}
+	if p.Type != "behavior" && p.Type != "resource" {
+		return fmt.Errorf("pack %s needs type behavior or resource", p.URL)
+	}
+	if err := installMcpackFile(path, p.Type); err != nil {
+		return err
+	}
+	if !p.Activate {
+		return nil
+	}
+	uuid, err := extractPackUUIDFromMcpack(path)
+	if err != nil {
+		return err
+	}
+	version, err := mcpackVersion(path)
+	if err != nil {
+		return err
+	}
+	_, err = setPackActivation(p.Type, uuid, version)
+	return err
+}
+
+// mcpackVersion reads the header version from an .mcpack's manifest.
+func mcpackVersion(path string) ([]int, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	for _, f := range reader.File {
+		if f.Name != "manifest.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		var m Manifest
+		err = json.NewDecoder(rc).Decode(&m)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		return m.Header.Version, nil
+	}
+	return nil, errors.New("manifest.json not found")
+}
+
+// runProvisioning executes a provisioning spec step by step.
+func runProvisioning(t *Task, spec ProvisionSpec) error {
+	if !spec.Force && !dataDirEmpty() {
+		return errors.New("data directory is not empty; set force to provision anyway")
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+
+	if spec.ServerURL != "" {
+		t.step(5, "Downloading server from %s", spec.ServerURL)
+		archive, err := downloadToTemp(spec.ServerURL, "bedrock-server-*.zip")
+		if err != nil {
+			return fmt.Errorf("downloading server: %w", err)
+		}
+		defer os.Remove(archive)
+		t.step(30, "Extracting server")
+		if err := unzipArchive(archive, dataDir); err != nil {
+			return fmt.Errorf("extracting server: %w", err)
+		}
+		if runtime.GOOS != "windows" {
+			os.Chmod(filepath.Join(dataDir, "bedrock_server"), 0755)
+		}
+	}
+
+	t.step(40, "Writing server.properties")
+	if _, err := os.Stat(serverPropsPath); os.IsNotExist(err) {
+		if err := os.WriteFile(serverPropsPath, nil, 0644); err != nil {
+			return err
+		}
+	}
+	if spec.PropertiesTemplate != "" {
+		if _, err := applyPropertyTemplate(spec.PropertiesTemplate, nil, false); err != nil {
+			return fmt.Errorf("applying template: %w", err)
+		}
+	}
+	props := make(map[string]string, len(spec.Properties)+2)
+	for k, v := range spec.Properties {
+		props[k] = v
+	}
+	if spec.LevelName != "" {
+		props["level-name"] = spec.LevelName
+	}
+	if spec.Seed != "" {
+		props["level-seed"] = spec.Seed
+	}
+	if len(props) > 0 {
+		if err := setServerProperties(props); err != nil {
+			return fmt.Errorf("writing server.properties: %w", err)
+		}
+	}
+
+	// The world itself is generated by the server on first start from
+	// level-name and level-seed; make its folder so packs can be activated.
+	if spec.LevelName != "" {
+		if err := os.MkdirAll(filepath.Join(worldsDir, spec.LevelName), 0755); err != nil {
+			return err
+		}
+	}
+
+	for i, p := range spec.Packs {
+		t.step(50+40*i/len(spec.Packs), "Installing pack %s", p.URL)
+		if err := installPackFromURL(p); err != nil {
+			return fmt.Errorf("installing pack %s: %w", p.URL, err)
+		}
+	}
+
+	if spec.Start {
+		t.step(95, "Starting server")
+		if err := startServer(); err != nil {
+			return fmt.Errorf("starting server: %w", err)
+		}
+	}
+	t.step(100, "Provisioning complete")
+	return nil
+}
+
+// startProvisioning validates a spec and runs it as a background task.
+func startProvisioning(spec ProvisionSpec) (*Task, error) {
+	if _, busy := runningTask("provision"); busy {
+		return nil, errors.New("provisioning is already running")
+	}
+	if spec.LevelName != "" && !validName(spec.LevelName) {
+		return nil, errors.New("invalid level_name")
+	}
+	if strings.ContainsAny(spec.Seed, "\n\r") {
+		return nil, errors.New("invalid seed")
+	}
+	for k, v := range spec.Properties {
+		if !propertyKeyPattern.MatchString(k) || strings.ContainsAny(v, "\n\r") {
+			return nil, fmt.Errorf("invalid property %q", k)
+		}
+	}
+	return startTask("provision", func(t *Task) error { return runProvisioning(t, spec) }), nil
+}
+
+// provisionOnBoot runs the spec in BEDROCK_API_PROVISION_SPEC when the data
+// directory is empty, reporting whether provisioning was started.
+func provisionOnBoot() bool {
+	path := os.Getenv(provisionSpecEnv)
+	if path == "" || !dataDirEmpty() {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Error reading provisioning spec %s: %v", path, err)
+	}
+	var spec ProvisionSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		log.Fatalf("Error parsing provisioning spec %s: %v", path, err)
+	}
+	if _, err := startProvisioning(spec); err != nil {
+		log.Fatalf("Error starting provisioning: %v", err)
+	}
+	log.Printf("Empty data directory; provisioning from %s", path)
+	return true
+}
+
+// provisionHandler starts provisioning (POST) or returns the latest
+// provisioning task (GET).
+func provisionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		t, ok := latestTask("provision")
+		if !ok {
+			writeJSONResponse(w, http.StatusOK, map[string]interface{}{"empty": dataDirEmpty()})
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"empty": dataDirEmpty(), "task": t})
+	case http.MethodPost:
+		var spec ProvisionSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if !spec.Force && !dataDirEmpty() {
+			writeJSONError(w, http.StatusConflict, "Data directory is not empty; set force to provision anyway")
+			return
+		}
+		t, err := startProvisioning(spec)
+		if err != nil {
+			writeJSONError(w, http.StatusConflict, err.Error())
+			return
+		}
+		w.Header().Set("Location", "/tasks/"+t.ID)
+		writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// cachedResponse is a public response kept for the cache TTL.
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// cacheRecorder captures a response so it can be cached.
+type cacheRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *cacheRecorder) Header() http.Header { return c.header }
+
+func (c *cacheRecorder) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(b)
+}
+
+func (c *cacheRecorder) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+var (
+	publicCache      = map[string]cachedResponse{}
+	publicCacheMutex sync.Mutex
+)
+
+// publicCacheTTL returns how long public responses are cached.
+func publicCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(envOrDefault(publicCacheTTLEnv, "30s"))
+	if err != nil || ttl < 0 {
+		return 30 * time.Second
+	}
+	return ttl
+}
+
+// publicReadOnly serves GET and HEAD requests from a shared cache, so
+// however many status pages poll the public listener the handlers run at
+// most once per TTL per URL. Other methods are rejected.
+func publicReadOnly(ttl time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		key := r.URL.RequestURI()
+		publicCacheMutex.Lock()
+		cached, ok := publicCache[key]
+		publicCacheMutex.Unlock()
+		if !ok || time.Now().After(cached.expires) {
+			rec := &cacheRecorder{header: http.Header{}}
+			get := r.Clone(r.Context())
+			get.Method = http.MethodGet
+			next(rec, get)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			cached = cachedResponse{status: rec.status, header: rec.header, body: rec.body.Bytes(), expires: time.Now().Add(ttl)}
+			if cached.status < 500 {
+				publicCacheMutex.Lock()
+				for k, c := range publicCache {
+					if time.Now().After(c.expires) {
+						delete(publicCache, k)
+					}
+				}
+				publicCache[key] = cached
+				publicCacheMutex.Unlock()
+			}
+		}
+		for k, v := range cached.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(ttl.Seconds())))
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(cached.status)
+		if r.Method == http.MethodGet {
+			w.Write(cached.body)
+		}
+	}
+}
+
+// serverStatusHandler reports whether the game server is online with its
+// MOTD, version, protocol and player counts from a RakNet unconnected
+// ping, for public status pages.
+func serverStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	hibernationMutex.Lock()
+	state := hibernation.State
+	hibernationMutex.Unlock()
+	status := map[string]interface{}{"online": false}
+	if state != hibernationAwake {
+		status["hibernating"] = true
+	} else if pong, err := pingServer(gameAddr(), 2*time.Second); err == nil {
+		status["online"] = true
+		status["motd"] = pong.MOTD
+		status["edition"] = pong.Edition
+		status["version"] = pong.Version
+		status["protocol_version"] = pong.ProtocolVersion
+		status["players"] = pong.Players
+		status["max_players"] = pong.MaxPlayers
+		status["game_mode"] = pong.GameMode
+		status["level_name"] = pong.LevelName
+		status["latency_ms"] = pong.LatencyMS
+	}
+	now := time.Now()
+	availabilityMutex.Lock()
+	monitored, down := availabilityTotals(now.AddDate(0, 0, -30), now)
+	availabilityMutex.Unlock()
+	status["uptime_30d_percent"] = uptimePercent(monitored, down)
+	status["time"] = now
+	writeJSONResponse(w, http.StatusOK, status)
+}
+
+// startPublicListener serves the read-only public routes on
+// BEDROCK_API_PUBLIC_ADDR, without API keys, when it is set. The admin
+// listener keeps the full API. The public map is always the default view:
+// its query is dropped so callers cannot make the listener scan the world
+// for arbitrary areas.
+func startPublicListener() {
+	addr := os.Getenv(publicAddrEnv)
+	if addr == "" {
+		return
+	}
+	ttl := publicCacheTTL()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", publicReadOnly(ttl, serverStatusHandler))
+	mux.HandleFunc("/leaderboards", publicReadOnly(ttl, leaderboardsHandler))
+	mux.HandleFunc("/leaderboards/", publicReadOnly(ttl, leaderboardHandler))
+	publicMap := publicReadOnly(ttl, mapHandler)
+	mux.HandleFunc("/map", func(w http.ResponseWriter, r *http.Request) {
+		r.URL.RawQuery = ""
+		publicMap(w, r)
+	})
+	log.Printf("Serving the public status API on %s (cached for %s)", addr, ttl)
+	go func() {
+		if err := http.ListenAndServe(addr, logRequests(mux)); err != nil {
+			log.Fatalf("Public listener failed: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const questsStateFile = "quests.json"
+
+// Quest condition types.
+const (
+	questChat     = "chat"     // chat messages containing Match
+	questEvent    = "event"    // bridge events of type Event, optionally matching block/entity
+	questPlaytime = "playtime" // total playtime in seconds
+	questJoins    = "joins"    // number of joins
+)
+
+// QuestCondition is one requirement of a quest. Count is the number of
+// matching occurrences, or the threshold for playtime and joins.
+type QuestCondition struct {
+	Type  string `json:"type"`
+	Event string `json:"event,omitempty"`
+	Match string `json:"match,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// Quest is an operator-defined goal with a reward.
+type Quest struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Conditions  []QuestCondition `json:"conditions"`
+	Points      int64            `json:"points,omitempty"`
+	Items       []RewardItem     `json:"items,omitempty"`
+	Commands    []string         `json:"commands,omitempty"`
+}
+
+// QuestProgress tracks one player's progress on one quest.
+type QuestProgress struct {
+	Counts    []int64    `json:"counts"`
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+type questState struct {
+	Quests   []Quest                              `json:"quests"`
+	Progress map[string]map[string]*QuestProgress `json:"progress"` // player -> quest -> progress
+}
+
+var (
+	quests      = questState{Quests: []Quest{}, Progress: map[string]map[string]*QuestProgress{}}
+	questsMutex sync.Mutex
+)
+
+func (q *Quest) validate() error {
+	if !validName(q.ID) {
+		return errors.New("invalid id")
+	}
+	if q.Name == "" {
+		q.Name = q.ID
+	}
+	if len(q.Conditions) == 0 {
+		return errors.New("at least one condition is required")
+	}
+	for i := range q.Conditions {
+		c := &q.Conditions[i]
+		switch c.Type {
+		case questChat, questPlaytime, questJoins:
+		case questEvent:
+			if c.Event == "" {
+				return errors.New("event conditions need an event type")
+			}
+		default:
+			return fmt.Errorf("unknown condition type %q", c.Type)
+		}
+		if c.Count <= 0 {
+			c.Count = 1
+		}
+	}
+	for _, it := range q.Items {
+		if !itemIDPattern.MatchString(it.Item) {
+			return fmt.Errorf("invalid item %q", it.Item)
+		}
+	}
+	return nil
+}
+
+func saveQuests() {
+	if err := saveState(questsStateFile, quests); err != nil {
+		log.Printf("Error saving quests: %v", err)
+	}
+}
+
+// progressFor returns a player's progress on a quest. Callers must hold
+// questsMutex.
+func progressFor(player string, q Quest) *QuestProgress {
+	pp, ok := quests.Progress[player]
+	if !ok {
+		pp = map[string]*QuestProgress{}
+		quests.Progress[player] = pp
+	}
+	p, ok := pp[q.ID]
+	if !ok {
+		p = &QuestProgress{}
+		pp[q.ID] = p
+	}
+	if len(p.Counts) != len(q.Conditions) {
+		counts := make([]int64, len(q.Conditions))
+		copy(counts, p.Counts)
+		p.Counts = counts
+	}
+	return p
+}
+
+// advanceQuests applies match to every open condition for a player, then
+// completes any quest whose conditions are all met. match returns the new
+// count for a condition, or -1 to leave it alone.
+func advanceQuests(player string, match func(c QuestCondition, count int64) int64) {
+	var completed []Quest
+	questsMutex.Lock()
+	changed := false
+	for _, q := range quests.Quests {
+		p := progressFor(player, q)
+		if p.Completed != nil {
+			continue
+		}
+		done := true
+		for i, c := range q.Conditions {
+			if n := match(c, p.Counts[i]); n >= 0 && n != p.Counts[i] {
+				if n > c.Count {
+					n = c.Count
+				}
+				p.Counts[i] = n
+				changed = true
+			}
+			if p.Counts[i] < c.Count {
+				done = false
+			}
+		}
+		if done {
+			now := time.Now()
+			p.Completed = &now
+			completed = append(completed, q)
+		}
+	}
+	if changed {
+		saveQuests()
+	}
+	questsMutex.Unlock()
+
+	for _, q := range completed {
+		log.Printf("Player %s completed quest %s", player, q.ID)
+		grantReward(player, "quest "+q.ID, q.Points, q.Items, q.Commands, nil)
+		if err := sendServerCommand(tellrawCommand("@a", fmt.Sprintf("%s completed the quest %s!", player, q.Name))); err != nil {
+			log.Printf("Failed to announce quest completion: %v", err)
+		}
+	}
+}
+
+// questOnChat counts chat conditions.
+func questOnChat(player, message string) {
+	lower := strings.ToLower(message)
+	advanceQuests(player, func(c QuestCondition, count int64) int64 {
+		if c.Type == questChat && strings.Contains(lower, strings.ToLower(c.Match)) {
+			return count + 1
+		}
+		return -1
+	})
+}
+
+// questOnEvent counts bridge event conditions.
+func questOnEvent(ev BridgeEvent) {
+	advanceQuests(ev.Player, func(c QuestCondition, count int64) int64 {
+		if c.Type != questEvent || c.Event != ev.Type {
+			return -1
+		}
+		if c.Match != "" && c.Match != ev.Block && c.Match != ev.Entity && c.Match != ev.Cause {
+			return -1
+		}
+		return count + 1
+	})
+}
+
+// questCheckStats updates playtime and join conditions from player stats.
+func questCheckStats(player string) {
+	playerStatsMutex.Lock()
+	st, ok := playerStats[player]
+	var playtime, joins int64
+	if ok {
+		playtime, joins = st.livePlaytime(), int64(st.Joins)
+	}
+	playerStatsMutex.Unlock()
+	if !ok {
+		return
+	}
+	advanceQuests(player, func(c QuestCondition, count int64) int64 {
+		switch c.Type {
+		case questPlaytime:
+			return playtime
+		case questJoins:
+			return joins
+		}
+		return -1
+	})
+}
+
+// startQuestLoop re-evaluates stat-based conditions for online players.
+func startQuestLoop() {
+	go func() {
+		for range time.Tick(time.Minute) {
+			queueMutex.Lock()
+			online := make([]string, 0, len(onlineSet))
+			for p := range onlineSet {
+				online = append(online, p)
+			}
+			queueMutex.Unlock()
+			for _, p := range online {
+				questCheckStats(p)
+			}
+		}
+	}()
+}
+
+// questsHandler lists quests (GET) or creates or replaces one (POST).
+func questsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		questsMutex.Lock()
+		defer questsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"quests": quests.Quests})
+	case http.MethodPost:
+		var q Quest
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := q.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		questsMutex.Lock()
+		replaced := false
+		for i := range quests.Quests {
+			if quests.Quests[i].ID == q.ID {
+				quests.Quests[i] = q
+				replaced = true
+			}
+		}
+		if !replaced {
+			quests.Quests = append(quests.Quests, q)
+		}
+		// Conditions may have changed shape, so reset open progress.
+		for _, pp := range quests.Progress {
+			if p, ok := pp[q.ID]; ok && p.Completed == nil {
+				delete(pp, q.ID)
+			}
+		}
+		saveQuests()
+		questsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, q)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// questHandler serves GET and DELETE /quests/{id}.
+func questHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/quests/")
+	questsMutex.Lock()
+	defer questsMutex.Unlock()
+	idx := -1
+	for i, q := range quests.Quests {
+		if q.ID == id {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		writeJSONError(w, http.StatusNotFound, "Quest not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, quests.Quests[idx])
+	case http.MethodDelete:
+		quests.Quests = append(quests.Quests[:idx], quests.Quests[idx+1:]...)
+		for _, pp := range quests.Progress {
+			delete(pp, id)
+		}
+		saveQuests()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Quest deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// playerQuestsHandler serves GET /players/{name}/quests.
+func playerQuestsHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	type questView struct {
+		Quest
+		Progress QuestProgress `json:"progress"`
+	}
+	questsMutex.Lock()
+	defer questsMutex.Unlock()
+	out := make([]questView, 0, len(quests.Quests))
+	for _, q := range quests.Quests {
+		var p QuestProgress
+		if pp, ok := quests.Progress[player][q.ID]; ok {
+			p = *pp
+		}
+		if len(p.Counts) != len(q.Conditions) {
+			counts := make([]int64, len(q.Conditions))
+			copy(counts, p.Counts)
+			p.Counts = counts
+		}
+		out = append(out, questView{Quest: q, Progress: p})
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"player": player, "quests": out})
+}
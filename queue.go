@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// queueReservation is how long a freed slot is held for the player at the
+// head of the queue before it is offered to the next one.
+const queueReservation = 2 * time.Minute
+
+// QueueEntry is a player waiting for a free slot.
+type QueueEntry struct {
+	Player        string    `json:"player"`
+	QueuedAt      time.Time `json:"queued_at"`
+	NotifiedAt    time.Time `json:"notified_at,omitempty"`
+	ReservedUntil time.Time `json:"reserved_until,omitempty"`
+}
+
+var (
+	joinQueue  = make([]QueueEntry, 0)
+	onlineSet  = make(map[string]time.Time)
+	queueMutex sync.Mutex
+)
+
+// queueCapacity returns the number of players admitted before queueing.
+func queueCapacity() int {
+	if v := os.Getenv(queueCapacityEnv); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	v, _ := getServerProperty("max-players", "10")
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 10
+	}
+	return n
+}
+
+// quotePlayer quotes a player name for use as a command target.
+func quotePlayer(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
+
+// validPlayerName reports whether a name taken from a request can be used as
+// a command target. Control characters are refused: a newline would end the
+// command and start another.
+func validPlayerName(name string) bool {
+	return name != "" && strings.IndexFunc(name, unicode.IsControl) < 0
+}
+
+// queuePosition returns the 1-based position of player, or 0. Callers must hold queueMutex.
+func queuePosition(player string) int {
+	for i, e := range joinQueue {
+		if e.Player == player {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// expireReservations drops queue heads whose reserved slot was not claimed.
+// Callers must hold queueMutex.
+func expireReservations() {
+	for len(joinQueue) > 0 && !joinQueue[0].ReservedUntil.IsZero() && time.Now().After(joinQueue[0].ReservedUntil) {
+		log.Printf("Queue reservation for %s expired", joinQueue[0].Player)
+		joinQueue = joinQueue[1:]
+	}
+}
+
+// queueOnJoin admits or queues a joining player, reporting whether they
+// were admitted.
+func queueOnJoin(player string) bool {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	expireReservations()
+	onlineSet[player] = time.Now()
+
+	capacity := queueCapacity()
+	reserved := 0
+	if len(joinQueue) > 0 && !joinQueue[0].ReservedUntil.IsZero() {
+		reserved = 1
+	}
+	pos := queuePosition(player)
+	// The player at the head with a reserved slot is always admitted.
+	if pos == 1 && reserved == 1 {
+		joinQueue = joinQueue[1:]
+		log.Printf("Queued player %s claimed their slot", player)
+		notifyQueueHead()
+		return true
+	}
+	if len(onlineSet)+reserved <= capacity {
+		if pos > 0 {
+			joinQueue = append(joinQueue[:pos-1], joinQueue[pos:]...)
+		}
+		return true
+	}
+
+	if pos == 0 {
+		joinQueue = append(joinQueue, QueueEntry{Player: player, QueuedAt: time.Now()})
+		pos = len(joinQueue)
+	}
+	delete(onlineSet, player)
+	msg := fmt.Sprintf("Server full - you are #%d in the queue", pos)
+	if err := sendServerCommand("kick " + quotePlayer(player) + " " + msg); err != nil {
+		log.Printf("Failed to kick queued player %s: %v", player, err)
+	}
+	log.Printf("Queued player %s at position %d", player, pos)
+	return false
+}
+
+// queueOnLeave frees a slot and notifies the next queued player. It reports
+// false for players that were never admitted.
+func queueOnLeave(player string) bool {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	if _, ok := onlineSet[player]; !ok {
+		return false
+	}
+	delete(onlineSet, player)
+	expireReservations()
+	if len(onlineSet) < queueCapacity() {
+		notifyQueueHead()
+	}
+	return true
+}
+
+// notifyQueueHead reserves a slot for the first queued player and sends the
+// webhook notification. Callers must hold queueMutex.
+func notifyQueueHead() {
+	if len(joinQueue) == 0 || !joinQueue[0].ReservedUntil.IsZero() {
+		return
+	}
+	head := &joinQueue[0]
+	head.NotifiedAt = time.Now()
+	head.ReservedUntil = head.NotifiedAt.Add(queueReservation)
+	hook := os.Getenv(queueWebhookEnv)
+	if hook == "" {
+		return
+	}
+	emitEvent([]string{hook}, "queue_slot_open",
+		fmt.Sprintf("A slot is open for %s - join within %s to claim it", head.Player, queueReservation),
+		map[string]interface{}{"player": head.Player, "reserved_until": head.ReservedUntil})
+}
+
+// queueHandler returns the queue state.
+func queueHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	expireReservations()
+	online := make([]string, 0, len(onlineSet))
+	for p := range onlineSet {
+		online = append(online, p)
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"capacity": queueCapacity(),
+		"online":   online,
+		"queue":    joinQueue,
+	})
+}
+
+// queuePlayerHandler removes a player from the queue.
+func queuePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	player, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/queue/"))
+	if err != nil || !validPlayerName(player) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid player")
+		return
+	}
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	pos := queuePosition(player)
+	if pos == 0 {
+		writeJSONError(w, http.StatusNotFound, "Player not queued")
+		return
+	}
+	joinQueue = append(joinQueue[:pos-1], joinQueue[pos:]...)
+	if pos == 1 && len(onlineSet) < queueCapacity() {
+		notifyQueueHead()
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Player removed from queue"})
+}
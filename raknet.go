@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RakNet unconnected ping/pong, the packets Bedrock clients use to populate
+// the server list. They let the sidecar query the game without a session.
+
+const (
+	raknetUnconnectedPing byte = 0x01
+	raknetUnconnectedPong byte = 0x1c
+)
+
+var raknetMagic = []byte{0x00, 0xff, 0xff, 0x00, 0xfe, 0xfe, 0xfe, 0xfe, 0xfd, 0xfd, 0xfd, 0xfd, 0x12, 0x34, 0x56, 0x78}
+
+// ServerPong is the parsed advertisement returned by a Bedrock server.
+type ServerPong struct {
+	Edition         string  `json:"edition"`
+	MOTD            string  `json:"motd"`
+	ProtocolVersion int     `json:"protocol_version"`
+	Version         string  `json:"version"`
+	Players         int     `json:"players"`
+	MaxPlayers      int     `json:"max_players"`
+	ServerGUID      string  `json:"server_guid"`
+	LevelName       string  `json:"level_name"`
+	GameMode        string  `json:"game_mode"`
+	LatencyMS       float64 `json:"latency_ms"`
+}
+
+// encodePing builds an unconnected ping packet.
+func encodePing(clientGUID int64) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(raknetUnconnectedPing)
+	binary.Write(buf, binary.BigEndian, time.Now().UnixMilli())
+	buf.Write(raknetMagic)
+	binary.Write(buf, binary.BigEndian, clientGUID)
+	return buf.Bytes()
+}
+
+// encodePong builds an unconnected pong packet advertising the given status.
+func encodePong(pingTime, serverGUID int64, advert string) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(raknetUnconnectedPong)
+	binary.Write(buf, binary.BigEndian, pingTime)
+	binary.Write(buf, binary.BigEndian, serverGUID)
+	buf.Write(raknetMagic)
+	binary.Write(buf, binary.BigEndian, uint16(len(advert)))
+	buf.WriteString(advert)
+	return buf.Bytes()
+}
+
+// isUnconnectedPing reports whether packet is an unconnected ping and returns its timestamp.
+func isUnconnectedPing(packet []byte) (int64, bool) {
+	if len(packet) < 1+8+16 || (packet[0] != raknetUnconnectedPing && packet[0] != 0x02) {
+		return 0, false
+	}
+	if !bytes.Equal(packet[9:25], raknetMagic) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(packet[1:9])), true
+}
+
+// parsePong decodes an unconnected pong packet.
+func parsePong(packet []byte) (ServerPong, error) {
+	var pong ServerPong
+	if len(packet) < 1+8+8+16+2 || packet[0] != raknetUnconnectedPong {
+		return pong, errors.New("not an unconnected pong")
+	}
+	n := int(binary.BigEndian.Uint16(packet[33:35]))
+	if len(packet) < 35+n {
+		return pong, errors.New("truncated pong")
+	}
+	fields := strings.Split(string(packet[35:35+n]), ";")
+	get := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	pong.Edition = get(0)
+	pong.MOTD = get(1)
+	pong.ProtocolVersion, _ = strconv.Atoi(get(2))
+	pong.Version = get(3)
+	pong.Players, _ = strconv.Atoi(get(4))
+	pong.MaxPlayers, _ = strconv.Atoi(get(5))
+	pong.ServerGUID = get(6)
+	pong.LevelName = get(7)
+	pong.GameMode = get(8)
+	return pong, nil
+}
+
+// pingServer sends an unconnected ping to addr and returns the server's advertisement.
+func pingServer(addr string, timeout time.Duration) (ServerPong, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return ServerPong{}, err
+	}
+	defer conn.Close()
+	start := time.Now()
+	conn.SetDeadline(start.Add(timeout))
+	if _, err := conn.Write(encodePing(rand.Int63())); err != nil {
+		return ServerPong{}, err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return ServerPong{}, fmt.Errorf("no response from %s: %w", addr, err)
+	}
+	pong, err := parsePong(buf[:n])
+	if err != nil {
+		return pong, err
+	}
+	pong.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	return pong, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupStorage is a remote location backups are shipped to.
+type BackupStorage interface {
+	Name() string
+	Upload(name, path string) error
+	List() ([]RemoteBackup, error)
+	Delete(name string) error
+}
+
+// RemoteBackup is an object in remote backup storage.
+type RemoteBackup struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// remoteBackupStorage is nil when no remote storage is configured.
+var remoteBackupStorage BackupStorage
+
+// initRemoteBackupStorage configures remote storage from the environment.
+func initRemoteBackupStorage() {
+	s3, err := newS3StorageFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid S3 backup storage: %v", err)
+	}
+	if s3 != nil {
+		remoteBackupStorage = s3
+		log.Printf("Backups are shipped to %s", s3.Name())
+	}
+}
+
+// shipBackup uploads an archive and its manifest, then applies the remote
+// lifecycle.
+func shipBackup(path string) error {
+	if remoteBackupStorage == nil {
+		return nil
+	}
+	name := filepath.Base(path)
+	if err := remoteBackupStorage.Upload(name, path); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path + ".manifest.json"); err == nil {
+		if err := remoteBackupStorage.Upload(name+".manifest.json", path+".manifest.json"); err != nil {
+			return err
+		}
+	}
+	log.Printf("Uploaded backup %s to %s", name, remoteBackupStorage.Name())
+	pruneRemoteBackups()
+	return nil
+}
+
+// pruneRemoteBackups deletes remote archives beyond the configured count
+// or age, always keeping the newest.
+func pruneRemoteBackups() {
+	keepLast, _ := strconv.Atoi(os.Getenv(s3KeepLastEnv))
+	maxAgeDays, _ := strconv.Atoi(os.Getenv(s3MaxAgeDaysEnv))
+	if keepLast <= 0 && maxAgeDays <= 0 {
+		return
+	}
+	objects, err := remoteBackupStorage.List()
+	if err != nil {
+		log.Printf("Error listing remote backups: %v", err)
+		return
+	}
+	var archives []RemoteBackup
+	for _, o := range objects {
+		if !strings.HasSuffix(o.Name, ".manifest.json") {
+			archives = append(archives, o)
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Modified.After(archives[j].Modified) })
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
+	for i, a := range archives {
+		if i == 0 || !((keepLast > 0 && i >= keepLast) || (maxAgeDays > 0 && a.Modified.Before(cutoff))) {
+			continue
+		}
+		if err := remoteBackupStorage.Delete(a.Name); err != nil {
+			log.Printf("Error deleting remote backup %s: %v", a.Name, err)
+			continue
+		}
+		remoteBackupStorage.Delete(a.Name + ".manifest.json")
+		log.Printf("Deleted remote backup %s", a.Name)
+	}
+}
+
+// remoteBackupsHandler lists remote backups (GET) or uploads a local
+// backup by ID (POST {"backup_id": ...}).
+func remoteBackupsHandler(w http.ResponseWriter, r *http.Request) {
+	if remoteBackupStorage == nil {
+		writeJSONError(w, http.StatusNotFound, "Remote backup storage is not configured: set "+s3BucketEnv)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		objects, err := remoteBackupStorage.List()
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		if objects == nil {
+			objects = []RemoteBackup{}
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"storage": remoteBackupStorage.Name(), "backups": objects})
+	case http.MethodPost:
+		var req struct {
+			BackupID string `json:"backup_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		path, err := backupPath(req.BackupID)
+		if os.IsNotExist(err) {
+			writeJSONError(w, http.StatusNotFound, "Backup not found")
+			return
+		} else if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		t := startTask("upload", func(t *Task) error {
+			t.step(10, "Uploading %s to %s", req.BackupID, remoteBackupStorage.Name())
+			if err := shipBackup(path); err != nil {
+				return fmt.Errorf("uploading backup: %w", err)
+			}
+			t.step(100, "Uploaded %s", req.BackupID)
+			return nil
+		})
+		w.Header().Set("Location", "/tasks/"+t.ID)
+		writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
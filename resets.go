@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	worldResetsStateFile = "world_resets.json"
+	jobCheckInterval     = 30 * time.Second
+)
+
+// WorldResetJob replaces a world from a template on a schedule or on demand.
+type WorldResetJob struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Template    string    `json:"template"`
+	World       string    `json:"world,omitempty"` // defaults to the active world
+	KickMessage string    `json:"kick_message,omitempty"`
+	Interval    string    `json:"interval,omitempty"` // Go duration; empty means API-triggered only
+	NextRun     time.Time `json:"next_run,omitempty"`
+	LastRun     time.Time `json:"last_run,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+var (
+	worldResetJobs  = make([]WorldResetJob, 0)
+	worldResetMutex sync.Mutex
+	// worldOpMutex serialises operations that stop the server and swap worlds.
+	worldOpMutex sync.Mutex
+)
+
+func (job *WorldResetJob) validate() error {
+	if job.Name == "" {
+		return errors.New("name is required")
+	}
+	if !validName(job.Template) {
+		return errors.New("invalid template")
+	}
+	if job.World != "" && !validName(job.World) {
+		return errors.New("invalid world")
+	}
+	if job.KickMessage == "" {
+		job.KickMessage = "World reset in progress - please rejoin shortly"
+	}
+	job.NextRun = time.Time{}
+	if job.Interval != "" {
+		d, err := time.ParseDuration(job.Interval)
+		if err != nil || d < time.Minute {
+			return errors.New("interval must be a duration of at least 1m")
+		}
+		job.NextRun = time.Now().Add(d)
+	}
+	return nil
+}
+
+func saveWorldResets() {
+	if err := saveState(worldResetsStateFile, worldResetJobs); err != nil {
+		log.Printf("Error saving world reset jobs: %v", err)
+	}
+}
+
+// kickAllPlayers broadcasts message and kicks every known online player.
+func kickAllPlayers(message string) {
+	sendServerCommand("say " + message)
+	queueMutex.Lock()
+	players := make([]string, 0, len(onlineSet))
+	for p := range onlineSet {
+		players = append(players, p)
+	}
+	queueMutex.Unlock()
+	for _, p := range players {
+		if err := sendServerCommand("kick " + quotePlayer(p) + " " + message); err != nil {
+			log.Printf("Failed to kick %s: %v", p, err)
+		}
+	}
+}
+
+// runWorldReset kicks players, replaces the world from the template and restarts.
+func runWorldReset(job WorldResetJob) error {
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	world := job.World
+	if world == "" {
+		var err error
+		if world, err = currentLevelName(); err != nil {
+			return err
+		}
+	}
+	log.Printf("Running world reset %q: %s <- template %s", job.Name, world, job.Template)
+	kickAllPlayers(job.KickMessage)
+	return withServerStopped(func() error {
+		return replaceWorldFromTemplate(job.Template, world)
+	})
+}
+
+// executeWorldResetJob runs the job with the given ID and records the outcome.
+func executeWorldResetJob(id string) error {
+	worldResetMutex.Lock()
+	var job *WorldResetJob
+	for i := range worldResetJobs {
+		if worldResetJobs[i].ID == id {
+			job = &worldResetJobs[i]
+		}
+	}
+	if job == nil {
+		worldResetMutex.Unlock()
+		return errors.New("job not found")
+	}
+	snapshot := *job
+	worldResetMutex.Unlock()
+
+	err := runWorldReset(snapshot)
+
+	worldResetMutex.Lock()
+	defer worldResetMutex.Unlock()
+	for i := range worldResetJobs {
+		if worldResetJobs[i].ID != id {
+			continue
+		}
+		j := &worldResetJobs[i]
+		j.LastRun = time.Now()
+		j.LastError = ""
+		if err != nil {
+			j.LastError = err.Error()
+		}
+		if d, perr := time.ParseDuration(j.Interval); perr == nil && j.Interval != "" {
+			j.NextRun = j.LastRun.Add(d)
+		}
+	}
+	saveWorldResets()
+	return err
+}
+
+// startWorldResetScheduler runs due reset jobs.
+func startWorldResetScheduler() {
+	go func() {
+		ticker := time.NewTicker(jobCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			worldResetMutex.Lock()
+			due := []string{}
+			for _, j := range worldResetJobs {
+				if !j.NextRun.IsZero() && time.Now().After(j.NextRun) {
+					due = append(due, j.ID)
+				}
+			}
+			worldResetMutex.Unlock()
+			for _, id := range due {
+				if err := executeWorldResetJob(id); err != nil {
+					log.Printf("World reset job %s failed: %v", id, err)
+				}
+			}
+		}
+	}()
+}
+
+// worldResetsHandler lists (GET) and creates (POST) reset jobs.
+func worldResetsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		worldResetMutex.Lock()
+		defer worldResetMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"jobs": worldResetJobs})
+	case http.MethodPost:
+		var job WorldResetJob
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := job.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		job.ID = newUUID()
+		job.LastRun, job.LastError = time.Time{}, ""
+		worldResetMutex.Lock()
+		worldResetJobs = append(worldResetJobs, job)
+		saveWorldResets()
+		worldResetMutex.Unlock()
+		writeJSONResponse(w, http.StatusCreated, job)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// worldResetHandler reads or deletes a job, or triggers it via
+// POST /world-resets/{id}/run.
+func worldResetHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/world-resets/")
+	id, action, _ := strings.Cut(rest, "/")
+
+	if action == "run" {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		if err := executeWorldResetJob(id); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("World reset failed: %v", err))
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "World reset completed"})
+		return
+	}
+	if action != "" {
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	worldResetMutex.Lock()
+	defer worldResetMutex.Unlock()
+	for i, job := range worldResetJobs {
+		if job.ID != id {
+			continue
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSONResponse(w, http.StatusOK, job)
+		case http.MethodDelete:
+			worldResetJobs = append(worldResetJobs[:i], worldResetJobs[i+1:]...)
+			saveWorldResets()
+			writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Job deleted"})
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		return
+	}
+	writeJSONError(w, http.StatusNotFound, "Job not found")
+}
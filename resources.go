@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serverProcessName = "bedrock_server"
+	resourceInterval  = 10 * time.Second
+	// clockTicks is USER_HZ, the unit of the CPU times in /proc/<pid>/stat.
+	// It is 100 on every Linux architecture the server runs on.
+	clockTicks = 100
+)
+
+// ServerResources is the bedrock process's resource usage as of the last
+// sample. Rates are averaged over the interval between the last two
+// samples; I/O is only known when the sidecar may read /proc/<pid>/io.
+type ServerResources struct {
+	Available    bool         `json:"available"`
+	Reason       string       `json:"reason,omitempty"`
+	PID          int          `json:"pid,omitempty"`
+	CPUPercent   float64      `json:"cpu_percent"`
+	CPUSeconds   float64      `json:"cpu_seconds_total"`
+	RSSBytes     uint64       `json:"rss_bytes"`
+	Threads      int          `json:"threads"`
+	IOAvailable  bool         `json:"io_available"`
+	ReadBytes    uint64       `json:"read_bytes_total,omitempty"`
+	WriteBytes   uint64       `json:"write_bytes_total,omitempty"`
+	ReadPerSec   float64      `json:"read_bytes_per_second,omitempty"`
+	WritePerSec  float64      `json:"write_bytes_per_second,omitempty"`
+	Cgroup       *CgroupUsage `json:"cgroup,omitempty"`
+	SampledAt    time.Time    `json:"sampled_at"`
+	ProcessStart uint64       `json:"-"` // start time in ticks, to notice PID reuse
+}
+
+var (
+	serverResources      ServerResources
+	serverResourcesMutex sync.Mutex
+	procRoot             = "/proc"
+)
+
+// findServerPID returns the bedrock process's PID: the child's when the
+// sidecar supervises the server, from the PID file an external supervisor
+// writes when one is configured, or by looking for the process in /proc,
+// which needs a PID namespace shared with the server.
+func findServerPID() (int, error) {
+	if supervisor != nil {
+		if pid := supervisor.pid(); pid != 0 {
+			return pid, nil
+		}
+		return 0, errors.New("the supervised server is not running")
+	}
+	if path := os.Getenv(serverPIDFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read PID file: %w", err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid <= 0 {
+			return 0, errors.New("PID file does not hold a PID")
+		}
+		return pid, nil
+	}
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procRoot, e.Name(), "comm"))
+		if err == nil && strings.TrimSpace(string(comm)) == serverProcessName {
+			return pid, nil
+		}
+	}
+	return 0, errors.New(serverProcessName + " process not found; share the PID namespace or set " + serverPIDFileEnv)
+}
+
+// readProcStat reads CPU time in ticks, thread count and start time from
+// /proc/<pid>/stat. The command name may contain spaces, so the fields are
+// counted from the closing parenthesis.
+func readProcStat(pid int) (cpuTicks uint64, threads int, start uint64, err error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return 0, 0, 0, errors.New("malformed stat")
+	}
+	// fields[0] is the state, the third field of the file.
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return 0, 0, 0, errors.New("malformed stat")
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ = strconv.Atoi(fields[17])
+	start, _ = strconv.ParseUint(fields[19], 10, 64)
+	return utime + stime, threads, start, nil
+}
+
+// readProcKeyValues reads a /proc file of "key: value" lines.
+func readProcKeyValues(pid int, name string) (map[string]string, error) {
+	f, err := os.Open(filepath.Join(procRoot, strconv.Itoa(pid), name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), ":"); ok {
+			values[k] = strings.TrimSpace(v)
+		}
+	}
+	return values, scanner.Err()
+}
+
+// sampleServerResources takes a sample of the bedrock process and derives
+// rates from the previous one when it was of the same process.
+func sampleServerResources(prev ServerResources) ServerResources {
+	now := time.Now()
+	pid, err := findServerPID()
+	if err != nil {
+		return ServerResources{Reason: err.Error(), SampledAt: now}
+	}
+	ticks, threads, start, err := readProcStat(pid)
+	if err != nil {
+		return ServerResources{Reason: "failed to read process stats: " + err.Error(), SampledAt: now}
+	}
+	s := ServerResources{
+		Available:    true,
+		PID:          pid,
+		CPUSeconds:   float64(ticks) / clockTicks,
+		Threads:      threads,
+		SampledAt:    now,
+		ProcessStart: start,
+	}
+	if status, err := readProcKeyValues(pid, "status"); err == nil {
+		kb, _ := strconv.ParseUint(strings.TrimSuffix(status["VmRSS"], " kB"), 10, 64)
+		s.RSSBytes = kb * 1024
+	}
+	if io, err := readProcKeyValues(pid, "io"); err == nil {
+		s.IOAvailable = true
+		s.ReadBytes, _ = strconv.ParseUint(io["read_bytes"], 10, 64)
+		s.WriteBytes, _ = strconv.ParseUint(io["write_bytes"], 10, 64)
+	}
+	elapsed := now.Sub(prev.SampledAt).Seconds()
+	if prev.Available && prev.PID == pid && prev.ProcessStart == start && elapsed > 0 {
+		s.CPUPercent = (s.CPUSeconds - prev.CPUSeconds) / elapsed * 100
+		if s.IOAvailable && prev.IOAvailable {
+			s.ReadPerSec = float64(s.ReadBytes-prev.ReadBytes) / elapsed
+			s.WritePerSec = float64(s.WriteBytes-prev.WriteBytes) / elapsed
+		}
+	}
+	return s
+}
+
+// startResourceSampler samples the bedrock process and its cgroup every ten
+// seconds, tuning each new process it finds.
+func startResourceSampler() {
+	go func() {
+		for {
+			serverResourcesMutex.Lock()
+			prev := serverResources
+			s := sampleServerResources(prev)
+			if s.Available {
+				tuneServerProcess(s.PID, s.ProcessStart)
+			}
+			if u, err := sampleCgroup(s.PID, prev.Cgroup); err == nil {
+				checkCgroupLimits(u, prev.Cgroup)
+				s.Cgroup = u
+			}
+			serverResources = s
+			serverResourcesMutex.Unlock()
+			time.Sleep(resourceInterval)
+		}
+	}()
+}
+
+// serverResourcesHandler returns the last sample of the bedrock process.
+func serverResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	serverResourcesMutex.Lock()
+	s := serverResources
+	serverResourcesMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, s)
+}
+
+// resourceMetrics returns the last resource sample and the data disk's
+// usage as metrics.
+func resourceMetrics() []metricSample {
+	serverResourcesMutex.Lock()
+	s := serverResources
+	serverResourcesMutex.Unlock()
+	m := []metricSample{{"bedrock_process_available", "Whether the bedrock process could be sampled.", "gauge", boolMetric(s.Available)}}
+	if u := s.Cgroup; u != nil {
+		m = append(m,
+			metricSample{"bedrock_cgroup_memory_working_set_bytes", "Working set of the server's cgroup.", "gauge", float64(u.MemoryBytes)},
+			metricSample{"bedrock_cgroup_memory_limit_bytes", "Memory limit of the server's cgroup, 0 when unlimited.", "gauge", float64(u.MemoryLimit)},
+			metricSample{"bedrock_cgroup_cpu_throttled_periods_total", "Scheduler periods in which the server's cgroup was throttled.", "counter", float64(u.ThrottledPeriods)},
+			metricSample{"bedrock_cgroup_cpu_periods_total", "Scheduler periods of the server's cgroup.", "counter", float64(u.Periods)},
+			metricSample{"bedrock_cgroup_oom_kills_total", "Processes the OOM killer ended in the server's cgroup.", "counter", float64(u.OOMKills)},
+		)
+	}
+	if size, used, avail, err := diskUsage(dataDir); err == nil && used+avail > 0 {
+		m = append(m,
+			metricSample{"bedrock_data_disk_size_bytes", "Size of the filesystem holding the data directory.", "gauge", float64(size)},
+			metricSample{"bedrock_data_disk_used_bytes", "Space used on the filesystem holding the data directory.", "gauge", float64(used)},
+			metricSample{"bedrock_data_disk_used_percent", "Space used on the data directory's filesystem, as df reports it.", "gauge", 100 * float64(used) / float64(used+avail)},
+		)
+	}
+	if !s.Available {
+		return m
+	}
+	m = append(m,
+		metricSample{"bedrock_process_cpu_seconds_total", "CPU time used by the bedrock process.", "counter", s.CPUSeconds},
+		metricSample{"bedrock_process_cpu_percent", "CPU usage over the last sample interval, 100 per core.", "gauge", s.CPUPercent},
+		metricSample{"bedrock_process_resident_memory_bytes", "Resident set size of the bedrock process.", "gauge", float64(s.RSSBytes)},
+		metricSample{"bedrock_process_threads", "Threads of the bedrock process.", "gauge", float64(s.Threads)},
+	)
+	if s.IOAvailable {
+		m = append(m,
+			metricSample{"bedrock_process_read_bytes_total", "Bytes the bedrock process read from storage.", "counter", float64(s.ReadBytes)},
+			metricSample{"bedrock_process_write_bytes_total", "Bytes the bedrock process wrote to storage.", "counter", float64(s.WriteBytes)},
+		)
+	}
+	return m
+}
+
+// serverResourcesMetricsHandler exposes the last sample in the Prometheus
+// text format.
+func serverResourcesMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	writePrometheus(w, resourceMetrics())
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// restoreSafetyDir keeps the world replaced by the most recent restore.
+var restoreSafetyDir = filepath.Join(stateDir, "restore_safety")
+
+// RestoreRequest restores the world from an archive in the backups
+// directory. Uploads are sent as multipart form field "file" instead.
+type RestoreRequest struct {
+	BackupID string `json:"backup_id"`
+}
+
+// extractedWorldRoot returns the folder holding level.dat in an extracted
+// archive: the root itself or its only subfolder, as in some .mcworld files.
+func extractedWorldRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "level.dat")); err == nil {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		sub := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(sub, "level.dat")); err == nil {
+			return sub, nil
+		}
+	}
+	return "", errors.New("archive does not contain a world (no level.dat)")
+}
+
+// restoreWorldArchive replaces the current world with the contents of a
+// world archive. The current world is moved to restoreSafetyDir and moved
+// back if the swap fails.
+func restoreWorldArchive(archive string) error {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return err
+	}
+	tmp := worldFolder + ".incoming"
+	os.RemoveAll(tmp)
+	defer os.RemoveAll(tmp)
+	if err := extractArchive(archive, tmp); err != nil {
+		return err
+	}
+	root, err := extractedWorldRoot(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(restoreSafetyDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(restoreSafetyDir, 0755); err != nil {
+		return err
+	}
+	safety := filepath.Join(restoreSafetyDir, fmt.Sprintf("%s-%s", filepath.Base(worldFolder), time.Now().Format("20060102-150405")))
+	hadWorld := true
+	if err := moveDir(worldFolder, safety); os.IsNotExist(err) {
+		hadWorld = false
+	} else if err != nil {
+		return fmt.Errorf("keeping safety copy: %w", err)
+	}
+	if err := os.Rename(root, worldFolder); err != nil {
+		if hadWorld {
+			if rerr := moveDir(safety, worldFolder); rerr != nil {
+				return fmt.Errorf("%v; additionally failed to put the world back from %s: %w", err, safety, rerr)
+			}
+		}
+		return err
+	}
+	if hadWorld {
+		log.Printf("Restored world from %s; previous world kept in %s", filepath.Base(archive), safety)
+	}
+	return nil
+}
+
+// moveDir renames a directory, copying it when src and dst are on
+// different filesystems.
+func moveDir(src, dst string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyDir(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// runRestore swaps in the archive, stopping the server around the swap if
+// it is running.
+func runRestore(t *Task, archive string) error {
+	apply := func() error {
+		t.step(50, "Replacing the world")
+		return restoreWorldArchive(archive)
+	}
+	var err error
+	if serverUp() {
+		t.step(10, "Stopping server")
+		err = withServerStopped(apply)
+	} else {
+		err = apply()
+	}
+	if err != nil {
+		return err
+	}
+	t.step(100, "World restored from %s", filepath.Base(archive))
+	return nil
+}
+
+// receiveWorldUpload streams the multipart "file" field to a temporary
+// file without buffering it in memory.
+func receiveWorldUpload(w http.ResponseWriter, r *http.Request) (string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDownloadSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return "", err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New(`missing "file" field`)
+			}
+			return "", err
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		f, err := os.CreateTemp("", "restore-*"+filepath.Ext(part.FileName()))
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(f, part)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		return f.Name(), nil
+	}
+}
+
+// restoreHandler shows the safety copy kept by the last restore (GET) or
+// starts restoring the world from a backup ID or an uploaded zip or
+// .mcworld (POST).
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		copies := []string{}
+		entries, _ := os.ReadDir(restoreSafetyDir)
+		for _, e := range entries {
+			copies = append(copies, filepath.Join(restoreSafetyDir, e.Name()))
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"safety_copies": copies})
+		return
+	case http.MethodPost:
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if _, busy := runningTask("restore"); busy {
+		writeJSONError(w, http.StatusConflict, "A restore is already running")
+		return
+	}
+
+	var archive string
+	cleanup := false
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		path, err := receiveWorldUpload(w, r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid upload: "+err.Error())
+			return
+		}
+		archive, cleanup = path, true
+	} else {
+		var req RestoreRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		path, err := backupPath(req.BackupID)
+		if os.IsNotExist(err) {
+			writeJSONError(w, http.StatusNotFound, "Backup not found")
+			return
+		} else if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		archive = path
+	}
+
+	t := startTask("restore", func(t *Task) error {
+		if cleanup {
+			defer os.Remove(archive)
+		}
+		return runRestore(t, archive)
+	})
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	retentionStateFile = "retention.json"
+	retentionInterval  = time.Hour
+)
+
+// retentionCategories prunes one category of data older than the cutoff and
+// returns how many records were removed and the oldest remaining timestamp.
+var retentionCategories = map[string]func(cutoff time.Time) (int, time.Time){
+	"chat":            pruneChat,
+	"events":          pruneEvents,
+	"sessions":        pruneSessions,
+	"transactions":    pruneTransactions,
+	"security_events": pruneSecurityEvents,
+	"stream_history":  pruneStreamHistory,
+}
+
+// RetentionCategoryStatus reports the state of one category.
+type RetentionCategoryStatus struct {
+	Days        int       `json:"days"` // 0 keeps data until buffer limits drop it
+	LastPruned  int       `json:"last_pruned"`
+	TotalPruned int       `json:"total_pruned"`
+	Oldest      time.Time `json:"oldest,omitempty"`
+}
+
+type retentionState struct {
+	Days    map[string]int                      `json:"days"`
+	LastRun time.Time                           `json:"last_run,omitempty"`
+	Status  map[string]*RetentionCategoryStatus `json:"status"`
+}
+
+var (
+	retention      = retentionState{Days: map[string]int{}, Status: map[string]*RetentionCategoryStatus{}}
+	retentionMutex sync.Mutex
+)
+
+// olderFirst returns how many leading entries of a time-ordered slice are
+// older than cutoff.
+func olderFirst(n int, at func(i int) time.Time, cutoff time.Time) int {
+	return sort.Search(n, func(i int) bool { return !at(i).Before(cutoff) })
+}
+
+func pruneChat(cutoff time.Time) (int, time.Time) {
+	chatMutex.Lock()
+	defer chatMutex.Unlock()
+	i := olderFirst(len(chatLog), func(i int) time.Time { return chatLog[i].Time }, cutoff)
+	chatLog = append([]ChatMessage{}, chatLog[i:]...)
+	if len(chatLog) == 0 {
+		return i, time.Time{}
+	}
+	return i, chatLog[0].Time
+}
+
+func pruneEvents(cutoff time.Time) (int, time.Time) {
+	bridgeMutex.Lock()
+	i := olderFirst(len(bridgeEvents), func(i int) time.Time { return bridgeEvents[i].ReceivedAt }, cutoff)
+	bridgeEvents = append([]BridgeEvent{}, bridgeEvents[i:]...)
+	var oldest time.Time
+	if len(bridgeEvents) > 0 {
+		oldest = bridgeEvents[0].ReceivedAt
+	}
+	bridgeMutex.Unlock()
+
+	mcws.mu.Lock()
+	j := olderFirst(len(mcws.events), func(i int) time.Time { return mcws.events[i].ReceivedAt }, cutoff)
+	mcws.events = append([]MCWSEvent{}, mcws.events[j:]...)
+	if len(mcws.events) > 0 && (oldest.IsZero() || mcws.events[0].ReceivedAt.Before(oldest)) {
+		oldest = mcws.events[0].ReceivedAt
+	}
+	mcws.mu.Unlock()
+	return i + j, oldest
+}
+
+// pruneSessions drops stats for players not seen since the cutoff.
+func pruneSessions(cutoff time.Time) (int, time.Time) {
+	playerStatsMutex.Lock()
+	defer playerStatsMutex.Unlock()
+	n := 0
+	var oldest time.Time
+	for p, st := range playerStats {
+		if st.SessionStart.IsZero() && st.LastSeen.Before(cutoff) {
+			delete(playerStats, p)
+			n++
+			continue
+		}
+		if oldest.IsZero() || st.LastSeen.Before(oldest) {
+			oldest = st.LastSeen
+		}
+	}
+	if n > 0 {
+		savePlayerStats()
+	}
+	return n, oldest
+}
+
+// pruneTransactions drops ledger history; balances are kept.
+func pruneTransactions(cutoff time.Time) (int, time.Time) {
+	pointsMutex.Lock()
+	defer pointsMutex.Unlock()
+	n := 0
+	var oldest time.Time
+	for p, tx := range points.Transactions {
+		i := olderFirst(len(tx), func(i int) time.Time { return tx[i].Time }, cutoff)
+		n += i
+		if i == len(tx) {
+			delete(points.Transactions, p)
+			continue
+		}
+		points.Transactions[p] = append([]PointsTransaction{}, tx[i:]...)
+		if oldest.IsZero() || tx[i].Time.Before(oldest) {
+			oldest = tx[i].Time
+		}
+	}
+	if n > 0 {
+		if err := saveState(pointsStateFile, points); err != nil {
+			log.Printf("Error saving points ledger: %v", err)
+		}
+	}
+	return n, oldest
+}
+
+func pruneSecurityEvents(cutoff time.Time) (int, time.Time) {
+	securityMutex.Lock()
+	defer securityMutex.Unlock()
+	i := olderFirst(len(securityEvents), func(i int) time.Time { return securityEvents[i].Time }, cutoff)
+	securityEvents = append([]SecurityEvent{}, securityEvents[i:]...)
+	if len(securityEvents) == 0 {
+		return i, time.Time{}
+	}
+	return i, securityEvents[0].Time
+}
+
+func pruneStreamHistory(cutoff time.Time) (int, time.Time) {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	i := olderFirst(len(streamHistory), func(i int) time.Time { return streamHistory[i].ReceivedAt }, cutoff)
+	streamHistory = append([]StreamEvent{}, streamHistory[i:]...)
+	if len(streamHistory) == 0 {
+		return i, time.Time{}
+	}
+	return i, streamHistory[0].ReceivedAt
+}
+
+// runRetention prunes every category with a retention window. Categories
+// without one are only measured.
+func runRetention() {
+	retentionMutex.Lock()
+	defer retentionMutex.Unlock()
+	now := time.Now()
+	for name, prune := range retentionCategories {
+		st, ok := retention.Status[name]
+		if !ok {
+			st = &RetentionCategoryStatus{}
+			retention.Status[name] = st
+		}
+		st.Days = retention.Days[name]
+		cutoff := time.Time{}
+		if st.Days > 0 {
+			cutoff = now.AddDate(0, 0, -st.Days)
+		}
+		n, oldest := prune(cutoff)
+		st.LastPruned = n
+		st.TotalPruned += n
+		st.Oldest = oldest
+		if n > 0 {
+			log.Printf("Retention pruned %d %s records", n, name)
+		}
+	}
+	retention.LastRun = now
+	if err := saveState(retentionStateFile, retention); err != nil {
+		log.Printf("Error saving retention state: %v", err)
+	}
+}
+
+// startRetentionLoop prunes data periodically.
+func startRetentionLoop() {
+	go func() {
+		runRetention()
+		for range time.Tick(retentionInterval) {
+			runRetention()
+		}
+	}()
+}
+
+// retentionHandler reports retention status (GET), replaces the per-category
+// windows in days (PUT), or prunes immediately (POST).
+func retentionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var days map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&days); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		for name, d := range days {
+			if _, ok := retentionCategories[name]; !ok {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Unknown category %q", name))
+				return
+			}
+			if d < 0 {
+				writeJSONError(w, http.StatusBadRequest, "Retention days must not be negative")
+				return
+			}
+		}
+		if days == nil {
+			days = map[string]int{}
+		}
+		retentionMutex.Lock()
+		retention.Days = days
+		retentionMutex.Unlock()
+		runRetention()
+	case http.MethodPost:
+		runRetention()
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	retentionMutex.Lock()
+	defer retentionMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, retention)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotsStateFile = "region_snapshots.json"
+	snapshotPrefix     = "sidecar_rb_"
+)
+
+// BlockPos is an integer block position.
+type BlockPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z"`
+}
+
+// Region is an inclusive box of blocks in one dimension.
+type Region struct {
+	From      BlockPos `json:"from"`
+	To        BlockPos `json:"to"`
+	Dimension string   `json:"dimension,omitempty"`
+}
+
+// RegionSnapshot is a region saved in the world with "structure save".
+type RegionSnapshot struct {
+	ID        string    `json:"id"`
+	Region    Region    `json:"region"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RollbackRequest is the body of POST /rollback.
+type RollbackRequest struct {
+	Region
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until,omitempty"`
+	Player string    `json:"player,omitempty"` // only revert this player's changes
+	DryRun bool      `json:"dry_run,omitempty"`
+}
+
+// RollbackReport describes what a rollback did and could not do.
+type RollbackReport struct {
+	Snapshot    *RegionSnapshot `json:"snapshot,omitempty"`
+	Reverted    []string        `json:"reverted"`
+	Failed      []string        `json:"failed"`
+	NotReverted []string        `json:"not_reverted"`
+	Warnings    []string        `json:"warnings"`
+}
+
+var (
+	regionSnapshots = make([]RegionSnapshot, 0)
+	snapshotsMutex  sync.Mutex
+)
+
+// normalize orders the corners and strips the dimension namespace.
+func (r *Region) normalize() {
+	if r.From.X > r.To.X {
+		r.From.X, r.To.X = r.To.X, r.From.X
+	}
+	if r.From.Y > r.To.Y {
+		r.From.Y, r.To.Y = r.To.Y, r.From.Y
+	}
+	if r.From.Z > r.To.Z {
+		r.From.Z, r.To.Z = r.To.Z, r.From.Z
+	}
+	r.Dimension = strings.TrimPrefix(r.Dimension, "minecraft:")
+	if r.Dimension == "" {
+		r.Dimension = "overworld"
+	}
+}
+
+func (r Region) contains(dim string, l *BridgeLocation) bool {
+	if l == nil || strings.TrimPrefix(dim, "minecraft:") != r.Dimension {
+		return false
+	}
+	x, y, z := int(math.Floor(l.X)), int(math.Floor(l.Y)), int(math.Floor(l.Z))
+	return x >= r.From.X && x <= r.To.X && y >= r.From.Y && y <= r.To.Y && z >= r.From.Z && z <= r.To.Z
+}
+
+func (r Region) covers(o Region) bool {
+	return r.Dimension == o.Dimension &&
+		r.From.X <= o.From.X && r.From.Y <= o.From.Y && r.From.Z <= o.From.Z &&
+		r.To.X >= o.To.X && r.To.Y >= o.To.Y && r.To.Z >= o.To.Z
+}
+
+// inDimension wraps a command so it runs in the region's dimension.
+func (r Region) inDimension(cmd string) string {
+	return "execute in " + r.Dimension + " run " + cmd
+}
+
+// takeRegionSnapshot saves a region into the world so it can be restored.
+func takeRegionSnapshot(region Region) (RegionSnapshot, error) {
+	region.normalize()
+	if region.To.X-region.From.X >= 64 || region.To.Z-region.From.Z >= 64 || region.To.Y-region.From.Y >= 384 {
+		return RegionSnapshot{}, errors.New("region exceeds the 64x384x64 structure limit")
+	}
+	snap := RegionSnapshot{ID: snapshotPrefix + strings.ReplaceAll(newUUID(), "-", "")[:12], Region: region, CreatedAt: time.Now()}
+	cmd := fmt.Sprintf("structure save %s %d %d %d %d %d %d disk", snap.ID,
+		region.From.X, region.From.Y, region.From.Z, region.To.X, region.To.Y, region.To.Z)
+	if err := sendServerCommand(region.inDimension(cmd)); err != nil {
+		return RegionSnapshot{}, err
+	}
+	snapshotsMutex.Lock()
+	defer snapshotsMutex.Unlock()
+	regionSnapshots = append(regionSnapshots, snap)
+	if err := saveState(snapshotsStateFile, regionSnapshots); err != nil {
+		log.Printf("Error saving region snapshots: %v", err)
+	}
+	return snap, nil
+}
+
+// eventTime prefers the game's timestamp over the time the sidecar got it.
+func eventTime(ev BridgeEvent) time.Time {
+	if ev.Time > 0 {
+		return time.UnixMilli(ev.Time)
+	}
+	return ev.ReceivedAt
+}
+
+// runRollback restores the newest snapshot taken before the window when one
+// covers the region, and otherwise replays inverse block commands from the
+// bridge event history.
+func runRollback(req RollbackRequest) RollbackReport {
+	report := RollbackReport{Reverted: []string{}, Failed: []string{}, NotReverted: []string{}, Warnings: []string{}}
+	if req.Until.IsZero() {
+		req.Until = time.Now()
+	}
+
+	snapshotsMutex.Lock()
+	for i := len(regionSnapshots) - 1; i >= 0; i-- {
+		s := regionSnapshots[i]
+		if !s.CreatedAt.After(req.Since) && s.Region.covers(req.Region) {
+			report.Snapshot = &s
+			break
+		}
+	}
+	snapshotsMutex.Unlock()
+
+	bridgeMutex.RLock()
+	var events []BridgeEvent
+	oldest := time.Now()
+	for _, ev := range bridgeEvents {
+		t := eventTime(ev)
+		if t.Before(oldest) {
+			oldest = t
+		}
+		if t.Before(req.Since) || t.After(req.Until) || !req.Region.contains(ev.Dimension, ev.Location) {
+			continue
+		}
+		if req.Player != "" && ev.Player != req.Player {
+			continue
+		}
+		events = append(events, ev)
+	}
+	bridgeMutex.RUnlock()
+
+	if report.Snapshot != nil && req.Player == "" {
+		s := report.Snapshot
+		cmd := s.Region.inDimension(fmt.Sprintf("structure load %s %d %d %d", s.ID, s.Region.From.X, s.Region.From.Y, s.Region.From.Z))
+		desc := fmt.Sprintf("restored snapshot %s taken %s", s.ID, s.CreatedAt.Format(time.RFC3339))
+		if req.DryRun {
+			report.Reverted = append(report.Reverted, desc)
+		} else if err := sendServerCommand(cmd); err != nil {
+			report.Failed = append(report.Failed, desc+": "+err.Error())
+		} else {
+			report.Reverted = append(report.Reverted, desc)
+		}
+		report.Warnings = append(report.Warnings, "snapshot restore also reverts changes made between the snapshot and the window start")
+		for _, ev := range events {
+			if ev.Type == "entity_died" {
+				report.NotReverted = append(report.NotReverted, fmt.Sprintf("%s died at %.0f %.0f %.0f", ev.Entity, ev.Location.X, ev.Location.Y, ev.Location.Z))
+			}
+		}
+		return report
+	}
+	if report.Snapshot != nil {
+		report.Snapshot = nil
+		report.Warnings = append(report.Warnings, "snapshot ignored because the rollback is limited to one player")
+	}
+	if oldest.After(req.Since) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("event history only reaches back to %s; earlier changes are not covered", oldest.Format(time.RFC3339)))
+	}
+
+	// Undo newest first so repeated edits of one block end at its original state.
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		x, y, z := int(math.Floor(ev.Location.X)), int(math.Floor(ev.Location.Y)), int(math.Floor(ev.Location.Z))
+		var cmd, desc string
+		switch ev.Type {
+		case "block_broken":
+			cmd = fmt.Sprintf("setblock %d %d %d %s", x, y, z, ev.Block)
+			desc = fmt.Sprintf("replaced %s broken by %s at %d %d %d", ev.Block, ev.Player, x, y, z)
+		case "block_placed":
+			cmd = fmt.Sprintf("setblock %d %d %d air", x, y, z)
+			desc = fmt.Sprintf("removed %s placed by %s at %d %d %d", ev.Block, ev.Player, x, y, z)
+		case "entity_died":
+			report.NotReverted = append(report.NotReverted, fmt.Sprintf("%s died at %d %d %d", ev.Entity, x, y, z))
+			continue
+		default:
+			continue
+		}
+		if req.DryRun {
+			report.Reverted = append(report.Reverted, desc)
+			continue
+		}
+		if err := sendServerCommand(req.Region.inDimension(cmd)); err != nil {
+			report.Failed = append(report.Failed, desc+": "+err.Error())
+		} else {
+			report.Reverted = append(report.Reverted, desc)
+		}
+	}
+	if len(report.Reverted) > 0 {
+		report.Warnings = append(report.Warnings, "replaced blocks use default states; container contents and block orientation are not restored")
+	}
+	return report
+}
+
+// rollbackHandler serves POST /rollback.
+func rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Since.IsZero() {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Region.normalize()
+	report := runRollback(req)
+	log.Printf("Rollback of %v since %s: %d reverted, %d failed", req.Region, req.Since.Format(time.RFC3339), len(report.Reverted), len(report.Failed))
+	writeJSONResponse(w, http.StatusOK, report)
+}
+
+// rollbackSnapshotsHandler lists snapshots (GET) or takes one (POST).
+func rollbackSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		snapshotsMutex.Lock()
+		defer snapshotsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"snapshots": regionSnapshots})
+	case http.MethodPost:
+		var region Region
+		if err := json.NewDecoder(r.Body).Decode(&region); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		snap, err := takeRegionSnapshot(region)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusCreated, snap)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
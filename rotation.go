@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const rotationStateFile = "rotation.json"
+
+// RotationConfig rotates the active world through a list on a fixed interval.
+type RotationConfig struct {
+	Enabled    bool      `json:"enabled"`
+	Worlds     []string  `json:"worlds"`
+	Interval   string    `json:"interval"`
+	Backup     bool      `json:"backup"`
+	NextSwitch time.Time `json:"next_switch,omitempty"`
+	LastSwitch time.Time `json:"last_switch,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// RotationSlot is an entry in the upcoming rotation schedule.
+type RotationSlot struct {
+	World  string    `json:"world"`
+	Starts time.Time `json:"starts"`
+}
+
+var (
+	rotation      RotationConfig
+	rotationMutex sync.Mutex
+)
+
+func (c *RotationConfig) validate() error {
+	if len(c.Worlds) < 2 {
+		return errors.New("at least two worlds are required")
+	}
+	for _, w := range c.Worlds {
+		if !validName(w) {
+			return fmt.Errorf("invalid world %q", w)
+		}
+		if info, err := os.Stat(filepath.Join(worldsDir, w)); err != nil || !info.IsDir() {
+			return fmt.Errorf("world %q not found", w)
+		}
+	}
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < time.Minute {
+		return errors.New("interval must be a duration of at least 1m")
+	}
+	return nil
+}
+
+// nextRotationWorld returns the world following current in the list.
+func nextRotationWorld(worlds []string, current string) string {
+	for i, w := range worlds {
+		if w == current {
+			return worlds[(i+1)%len(worlds)]
+		}
+	}
+	return worlds[0]
+}
+
+// upcomingRotation lists the next switches, one per configured world.
+func upcomingRotation(c RotationConfig, current string) []RotationSlot {
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || c.NextSwitch.IsZero() {
+		return nil
+	}
+	slots := make([]RotationSlot, 0, len(c.Worlds))
+	world := current
+	at := c.NextSwitch
+	for range c.Worlds {
+		world = nextRotationWorld(c.Worlds, world)
+		slots = append(slots, RotationSlot{World: world, Starts: at})
+		at = at.Add(d)
+	}
+	return slots
+}
+
+// rotateWorld backs up the outgoing world, switches level-name to the next
+// world and restarts the server.
+func rotateWorld() error {
+	rotationMutex.Lock()
+	cfg := rotation
+	rotationMutex.Unlock()
+
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	current, err := currentLevelName()
+	if err != nil {
+		return err
+	}
+	next := nextRotationWorld(cfg.Worlds, current)
+	log.Printf("Rotating world %s -> %s", current, next)
+	kickAllPlayers("Map rotation: switching to " + next)
+	err = withServerStopped(func() error {
+		if cfg.Backup {
+			if _, err := createWorldBackup(); err != nil {
+				return fmt.Errorf("backup of outgoing world failed: %w", err)
+			}
+		}
+		return setServerProperty("level-name", next)
+	})
+
+	rotationMutex.Lock()
+	defer rotationMutex.Unlock()
+	rotation.LastSwitch = time.Now()
+	rotation.LastError = ""
+	if err != nil {
+		rotation.LastError = err.Error()
+	}
+	if d, perr := time.ParseDuration(rotation.Interval); perr == nil {
+		rotation.NextSwitch = rotation.LastSwitch.Add(d)
+	}
+	if serr := saveState(rotationStateFile, rotation); serr != nil {
+		log.Printf("Error saving rotation: %v", serr)
+	}
+	return err
+}
+
+// startRotationScheduler switches worlds when the next switch is due.
+func startRotationScheduler() {
+	go func() {
+		ticker := time.NewTicker(jobCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rotationMutex.Lock()
+			due := rotation.Enabled && !rotation.NextSwitch.IsZero() && time.Now().After(rotation.NextSwitch)
+			rotationMutex.Unlock()
+			if due {
+				if err := rotateWorld(); err != nil {
+					log.Printf("World rotation failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// rotationHandler returns the rotation config and upcoming schedule (GET)
+// or replaces the config (PUT).
+func rotationHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		current, _ := currentLevelName()
+		rotationMutex.Lock()
+		cfg := rotation
+		rotationMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"config":        cfg,
+			"current_world": current,
+			"upcoming":      upcomingRotation(cfg, current),
+		})
+	case http.MethodPut:
+		var cfg RotationConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := cfg.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		d, _ := time.ParseDuration(cfg.Interval)
+		if cfg.NextSwitch.IsZero() || cfg.NextSwitch.Before(time.Now()) {
+			cfg.NextSwitch = time.Now().Add(d)
+		}
+		rotationMutex.Lock()
+		cfg.LastSwitch, cfg.LastError = rotation.LastSwitch, rotation.LastError
+		rotation = cfg
+		err := saveState(rotationStateFile, rotation)
+		rotationMutex.Unlock()
+		if err != nil {
+			log.Printf("Error saving rotation: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save rotation")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, cfg)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// rotationAdvanceHandler switches to the next world immediately.
+func rotationAdvanceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	rotationMutex.Lock()
+	configured := len(rotation.Worlds) >= 2
+	rotationMutex.Unlock()
+	if !configured {
+		writeJSONError(w, http.StatusConflict, "Rotation is not configured")
+		return
+	}
+	if err := rotateWorld(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Rotation failed: %v", err))
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "World rotated"})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3EmptyPayload is the SHA-256 of an empty body.
+const s3EmptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Storage stores backups in an S3-compatible bucket such as AWS S3 or
+// MinIO, signing requests with AWS Signature Version 4.
+type s3Storage struct {
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	prefix       string
+	accessKey    string
+	secretKey    string
+	pathStyle    bool
+	storageClass string
+}
+
+// newS3StorageFromEnv returns the configured S3 storage, or nil when no
+// bucket is set.
+func newS3StorageFromEnv() (*s3Storage, error) {
+	bucket := os.Getenv(s3BucketEnv)
+	if bucket == "" {
+		return nil, nil
+	}
+	region := envOrDefault(s3RegionEnv, "us-east-1")
+	endpoint := envOrDefault(s3EndpointEnv, "https://s3."+region+".amazonaws.com")
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s", s3EndpointEnv)
+	}
+	s := &s3Storage{
+		endpoint:     u,
+		region:       region,
+		bucket:       bucket,
+		prefix:       strings.Trim(os.Getenv(s3PrefixEnv), "/"),
+		accessKey:    os.Getenv(s3AccessKeyEnv),
+		secretKey:    os.Getenv(s3SecretKeyEnv),
+		pathStyle:    envEnabled(s3PathStyleEnv),
+		storageClass: os.Getenv(s3StorageClassEnv),
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("%s and %s are required", s3AccessKeyEnv, s3SecretKeyEnv)
+	}
+	if s.prefix != "" {
+		s.prefix += "/"
+	}
+	return s, nil
+}
+
+func (s *s3Storage) Name() string { return "s3://" + s.bucket + "/" + s.prefix }
+
+// s3Escape percent-encodes s as SigV4 expects, leaving "/" alone when
+// encoding a path.
+func s3Escape(s string, path bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', path && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// newRequest builds a signed request for an object key (empty for the
+// bucket itself).
+func (s *s3Storage) newRequest(method, key string, query url.Values, body io.Reader, payloadHash string) (*http.Request, error) {
+	u := *s.endpoint
+	path := strings.TrimSuffix(u.Path, "/") + "/"
+	if s.pathStyle {
+		path += s.bucket + "/"
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	path += key
+	u.Path, u.RawPath = path, s3Escape(path, true)
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, s3Escape(k, false)+"="+s3Escape(query.Get(k), false))
+	}
+	u.RawQuery = strings.Join(pairs, "&")
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if method == http.MethodPut && s.storageClass != "" {
+		req.Header.Set("X-Amz-Storage-Class", s.storageClass)
+	}
+
+	signed := []string{"host"}
+	canonicalHeaders := "host:" + req.URL.Host + "\n"
+	for _, h := range []string{"x-amz-content-sha256", "x-amz-date", "x-amz-storage-class"} {
+		if v := req.Header.Get(h); v != "" {
+			signed = append(signed, h)
+			canonicalHeaders += h + ":" + v + "\n"
+		}
+	}
+	canonical := strings.Join([]string{method, u.RawPath, u.RawQuery, canonicalHeaders, strings.Join(signed, ";"), payloadHash}, "\n")
+	scope := now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	signingKey := mac(mac(mac(mac([]byte("AWS4"+s.secretKey), now.Format("20060102")), s.region), "s3"), "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, strings.Join(signed, ";"), hex.EncodeToString(mac(signingKey, toSign))))
+	return req, nil
+}
+
+// do sends a request and turns S3 error responses into errors.
+func (s *s3Storage) do(req *http.Request) (*http.Response, error) {
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("s3 %s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	return resp, nil
+}
+
+// Upload puts a local file under prefix+name, throttled by the upload
+// transfer limit.
+func (s *s3Storage) Upload(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(http.MethodPut, s.prefix+name, nil, limitedReader{f, uploadLimiter}, "UNSIGNED-PAYLOAD")
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the objects under the prefix.
+func (s *s3Storage) List() ([]RemoteBackup, error) {
+	var objects []RemoteBackup
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.newRequest(http.MethodGet, "", query, nil, s3EmptyPayload)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string `xml:"Key"`
+				Size         string `xml:"Size"`
+				LastModified string `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			size, _ := strconv.ParseInt(c.Size, 10, 64)
+			modified, _ := time.Parse(time.RFC3339, c.LastModified)
+			objects = append(objects, RemoteBackup{Name: strings.TrimPrefix(c.Key, s.prefix), Size: size, Modified: modified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete removes prefix+name.
+func (s *s3Storage) Delete(name string) error {
+	if name == "" {
+		return errors.New("empty object name")
+	}
+	req, err := s.newRequest(http.MethodDelete, s.prefix+name, nil, nil, s3EmptyPayload)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
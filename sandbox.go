@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	sandboxStateFile   = "sandbox.json"
+	maxSandboxLifetime = 7 * 24 * time.Hour
+)
+
+// Sandbox is a temporary world created from a template that is discarded,
+// and the previous world restored, when it expires.
+type Sandbox struct {
+	Template      string    `json:"template"`
+	World         string    `json:"world"`
+	PreviousWorld string    `json:"previous_world"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// SandboxRequest is the body of POST /sandbox.
+type SandboxRequest struct {
+	Template string `json:"template"`
+	Duration string `json:"duration"` // Go duration, e.g. "2h"
+}
+
+var (
+	activeSandbox *Sandbox
+	sandboxMutex  sync.Mutex
+)
+
+func saveSandbox() {
+	if err := saveState(sandboxStateFile, activeSandbox); err != nil {
+		log.Printf("Error saving sandbox state: %v", err)
+	}
+}
+
+// createSandbox provisions a world from template and switches to it.
+func createSandbox(req SandboxRequest) (*Sandbox, error) {
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d < time.Minute || d > maxSandboxLifetime {
+		return nil, fmt.Errorf("duration must be between 1m and %s", maxSandboxLifetime)
+	}
+	if !validName(req.Template) {
+		return nil, errors.New("invalid template")
+	}
+
+	sandboxMutex.Lock()
+	defer sandboxMutex.Unlock()
+	if activeSandbox != nil {
+		return nil, errors.New("a sandbox is already active")
+	}
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	previous, err := currentLevelName()
+	if err != nil {
+		return nil, err
+	}
+	sb := &Sandbox{
+		Template:      req.Template,
+		World:         "sandbox-" + time.Now().Format("20060102-150405"),
+		PreviousWorld: previous,
+		CreatedAt:     time.Now(),
+		ExpiresAt:     time.Now().Add(d),
+	}
+	kickAllPlayers("Switching to sandbox world " + sb.World)
+	err = withServerStopped(func() error {
+		if err := replaceWorldFromTemplate(sb.Template, sb.World); err != nil {
+			return err
+		}
+		return setServerProperty("level-name", sb.World)
+	})
+	if err != nil {
+		os.RemoveAll(filepath.Join(worldsDir, sb.World))
+		return nil, err
+	}
+	activeSandbox = sb
+	saveSandbox()
+	log.Printf("Sandbox %s created from %s, expires %s", sb.World, sb.Template, sb.ExpiresAt.Format(time.RFC3339))
+	return sb, nil
+}
+
+// endSandbox restores the previous world and deletes the sandbox world.
+func endSandbox() error {
+	sandboxMutex.Lock()
+	defer sandboxMutex.Unlock()
+	if activeSandbox == nil {
+		return errors.New("no sandbox is active")
+	}
+	sb := activeSandbox
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	kickAllPlayers("Sandbox expired, restoring " + sb.PreviousWorld)
+	err := withServerStopped(func() error {
+		return setServerProperty("level-name", sb.PreviousWorld)
+	})
+	if err != nil {
+		return err
+	}
+	if validName(sb.World) {
+		if err := moveToTrash("world", filepath.Join(worldsDir, sb.World), "sandbox ended"); err != nil {
+			log.Printf("Failed to delete sandbox world %s: %v", sb.World, err)
+		}
+	}
+	activeSandbox = nil
+	saveSandbox()
+	log.Printf("Sandbox %s ended, restored %s", sb.World, sb.PreviousWorld)
+	return nil
+}
+
+// startSandboxReaper ends the sandbox once it expires.
+func startSandboxReaper() {
+	go func() {
+		ticker := time.NewTicker(jobCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sandboxMutex.Lock()
+			expired := activeSandbox != nil && time.Now().After(activeSandbox.ExpiresAt)
+			sandboxMutex.Unlock()
+			if expired {
+				if err := endSandbox(); err != nil {
+					log.Printf("Failed to end expired sandbox: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// sandboxHandler reports (GET), creates (POST) or ends (DELETE) the sandbox.
+func sandboxHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sandboxMutex.Lock()
+		defer sandboxMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"sandbox": activeSandbox})
+	case http.MethodPost:
+		var req SandboxRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		sb, err := createSandbox(req)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Failed to create sandbox: %v", err))
+			return
+		}
+		writeJSONResponse(w, http.StatusCreated, sb)
+	case http.MethodDelete:
+		if err := endSandbox(); err != nil {
+			writeJSONError(w, http.StatusConflict, fmt.Sprintf("Failed to end sandbox: %v", err))
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Sandbox ended"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
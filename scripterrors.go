@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxScriptErrorGroups  = 500
+	maxScriptErrorFrames  = 20
+	scriptErrorFlushDelay = time.Second
+)
+
+var (
+	// scriptErrorPattern matches Scripting API errors and warnings in the
+	// server log; scriptStackPattern matches the stack frames that follow.
+	scriptErrorPattern = regexp.MustCompile(`(?i)(error|warn).*\[scripting\]|\[scripting\].*(error|warn)`)
+	scriptStackPattern = regexp.MustCompile(`^\s+at\s`)
+	// scriptPluginPattern captures the pack name from "Plugin [Name - 1.0.0]".
+	scriptPluginPattern = regexp.MustCompile(`Plugin \[(.+?)(?: - v?[\d.]+)?\]`)
+	// scriptPrefixPattern strips the timestamp, level and [Scripting] tag.
+	scriptPrefixPattern = regexp.MustCompile(`^(?:\[[^\]]*\]\s*)*?\[[Ss]cripting\]\s*`)
+	// scriptVolatilePattern matches numbers and quoted values that differ
+	// between occurrences of the same error.
+	scriptVolatilePattern = regexp.MustCompile(`"[^"]*"|'[^']*'|\b\d+(\.\d+)?\b`)
+	scriptInlineStack     = regexp.MustCompile(`\s+at\s`)
+)
+
+// ScriptErrorGroup is every occurrence of one error signature in one pack.
+type ScriptErrorGroup struct {
+	ID        string    `json:"id"`
+	Pack      string    `json:"pack"`
+	Signature string    `json:"signature"`
+	Message   string    `json:"message"`
+	Stack     []string  `json:"stack,omitempty"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+var (
+	scriptErrorGroups = make(map[string]*ScriptErrorGroup)
+	scriptErrorMutex  sync.Mutex
+)
+
+// scriptErrorSignature normalises a message and its top stack frame so
+// repeats of the same fault group together.
+func scriptErrorSignature(message string, stack []string) string {
+	sig := scriptVolatilePattern.ReplaceAllString(message, "_")
+	if len(stack) > 0 {
+		sig += " @ " + scriptVolatilePattern.ReplaceAllString(stack[0], "_")
+	}
+	return sig
+}
+
+// recordScriptError adds one parsed error to its group.
+func recordScriptError(header string, stack []string, at time.Time) {
+	pack := "unknown"
+	if m := scriptPluginPattern.FindStringSubmatch(header); m != nil {
+		pack = m[1]
+	}
+	message := strings.TrimSpace(scriptPrefixPattern.ReplaceAllString(header, ""))
+	// Some versions print the stack on the same line as the message.
+	if loc := scriptInlineStack.FindStringIndex(message); loc != nil {
+		var inline []string
+		for _, frame := range scriptInlineStack.Split(message[loc[0]:], -1)[1:] {
+			inline = append(inline, "at "+strings.TrimRight(strings.TrimSpace(frame), "]"))
+		}
+		stack = append(inline, stack...)
+		message = strings.TrimSpace(message[:loc[0]])
+	}
+	if len(stack) > maxScriptErrorFrames {
+		stack = stack[:maxScriptErrorFrames]
+	}
+	sig := scriptErrorSignature(message, stack)
+	sum := sha256.Sum256([]byte(pack + "\x00" + sig))
+	id := hex.EncodeToString(sum[:8])
+
+	scriptErrorMutex.Lock()
+	defer scriptErrorMutex.Unlock()
+	g, ok := scriptErrorGroups[id]
+	if !ok {
+		if len(scriptErrorGroups) >= maxScriptErrorGroups {
+			evictOldestScriptError()
+		}
+		g = &ScriptErrorGroup{ID: id, Pack: pack, Signature: sig, FirstSeen: at}
+		scriptErrorGroups[id] = g
+	}
+	g.Message, g.Stack, g.LastSeen = message, stack, at
+	g.Count++
+}
+
+// evictOldestScriptError drops the group seen least recently. The caller
+// holds scriptErrorMutex.
+func evictOldestScriptError() {
+	var oldest *ScriptErrorGroup
+	for _, g := range scriptErrorGroups {
+		if oldest == nil || g.LastSeen.Before(oldest.LastSeen) {
+			oldest = g
+		}
+	}
+	if oldest != nil {
+		delete(scriptErrorGroups, oldest.ID)
+	}
+}
+
+// startScriptErrorTracker parses Scripting API errors and their stack
+// frames from the server log.
+func startScriptErrorTracker() {
+	if os.Getenv(serverLogEnv) == "" {
+		return
+	}
+	ch := make(chan ConsoleMessage, 256)
+	consoleMutex.Lock()
+	consoleSubscribers[ch] = struct{}{}
+	consoleMutex.Unlock()
+	go func() {
+		var header string
+		var stack []string
+		var at time.Time
+		flush := func() {
+			if header != "" {
+				recordScriptError(header, stack, at)
+			}
+			header, stack = "", nil
+		}
+		timer := time.NewTimer(scriptErrorFlushDelay)
+		for {
+			select {
+			case msg := <-ch:
+				switch {
+				case scriptErrorPattern.MatchString(msg.Line):
+					flush()
+					header, at = msg.Line, msg.Time
+				case header != "" && scriptStackPattern.MatchString(msg.Line):
+					stack = append(stack, strings.TrimSpace(msg.Line))
+				default:
+					flush()
+				}
+				timer.Reset(scriptErrorFlushDelay)
+			case <-timer.C:
+				// The last error in a burst has no line after it.
+				flush()
+			}
+		}
+	}()
+}
+
+// scriptErrorsHandler lists grouped script errors, most recent first,
+// optionally for one ?pack= (GET), or clears them (DELETE).
+func scriptErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		pack := r.URL.Query().Get("pack")
+		scriptErrorMutex.Lock()
+		groups := []ScriptErrorGroup{}
+		for _, g := range scriptErrorGroups {
+			if pack == "" || g.Pack == pack {
+				groups = append(groups, *g)
+			}
+		}
+		scriptErrorMutex.Unlock()
+		sort.Slice(groups, func(i, j int) bool { return groups[i].LastSeen.After(groups[j].LastSeen) })
+		resp := map[string]interface{}{"errors": groups}
+		if os.Getenv(serverLogEnv) == "" {
+			resp["message"] = "Server output is not available: set " + serverLogEnv
+		}
+		writeJSONResponse(w, http.StatusOK, resp)
+	case http.MethodDelete:
+		scriptErrorMutex.Lock()
+		scriptErrorGroups = make(map[string]*ScriptErrorGroup)
+		scriptErrorMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Script errors cleared"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
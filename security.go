@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	securityStateFile       = "security.json"
+	securityEventsLen       = 500
+	defaultSecurityCooldown = 300 // seconds
+)
+
+// Activity kinds observed per player.
+const (
+	activityJoin    = "join"
+	activityChat    = "chat"
+	activityCommand = "command"
+)
+
+// Detector types.
+const (
+	detectJoinCycling = "join_cycling" // Threshold joins within Window
+	detectCommandSpam = "command_spam" // Threshold commands within Window
+	detectChatFlood   = "chat_flood"   // Threshold chat messages within Window
+)
+
+// Security actions.
+const (
+	securityActionLog     = "log"
+	securityActionKick    = "kick"
+	securityActionTempban = "tempban"
+)
+
+var detectorActivity = map[string]string{
+	detectJoinCycling: activityJoin,
+	detectCommandSpam: activityCommand,
+	detectChatFlood:   activityChat,
+}
+
+// SecurityRule raises a security event when a player exceeds a rate.
+type SecurityRule struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Enabled         bool   `json:"enabled"`
+	Detector        string `json:"detector"`
+	Threshold       int    `json:"threshold"`
+	WindowSeconds   int    `json:"window_seconds"`
+	Action          string `json:"action"`
+	BanMinutes      int    `json:"ban_minutes,omitempty"`
+	CooldownSeconds int    `json:"cooldown_seconds"`
+}
+
+// SecurityEvent records a rule firing.
+type SecurityEvent struct {
+	RuleID string    `json:"rule_id"`
+	Rule   string    `json:"rule"`
+	Player string    `json:"player"`
+	Reason string    `json:"reason"`
+	Action string    `json:"action"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+type securityState struct {
+	Rules []SecurityRule       `json:"rules"`
+	Bans  map[string]time.Time `json:"bans"` // player -> banned until
+}
+
+var (
+	security       = securityState{Rules: []SecurityRule{}, Bans: map[string]time.Time{}}
+	securityEvents = make([]SecurityEvent, 0)
+	playerActivity = make(map[string]map[string][]time.Time)
+	securityFired  = make(map[string]time.Time) // rule id + player -> last fired
+	securityMutex  sync.Mutex
+)
+
+// validate checks a rule and fills in defaults.
+func (rule *SecurityRule) validate() error {
+	if _, ok := detectorActivity[rule.Detector]; !ok {
+		return fmt.Errorf("unknown detector %q", rule.Detector)
+	}
+	if rule.Threshold <= 0 || rule.WindowSeconds <= 0 {
+		return fmt.Errorf("threshold and window_seconds must be positive")
+	}
+	switch rule.Action {
+	case securityActionLog, securityActionKick:
+	case securityActionTempban:
+		if rule.BanMinutes <= 0 {
+			return fmt.Errorf("ban_minutes must be positive for tempban actions")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.Name == "" {
+		rule.Name = rule.Detector
+	}
+	if rule.CooldownSeconds <= 0 {
+		rule.CooldownSeconds = defaultSecurityCooldown
+	}
+	return nil
+}
+
+// saveSecurity persists rules and bans. Callers must hold securityMutex.
+func saveSecurity() {
+	if err := saveState(securityStateFile, security); err != nil {
+		log.Printf("Error saving security rules: %v", err)
+	}
+}
+
+// securityBanned reports whether a player is under a temporary ban.
+func securityBanned(player string) (time.Time, bool) {
+	securityMutex.Lock()
+	defer securityMutex.Unlock()
+	until, ok := security.Bans[player]
+	if ok && time.Now().After(until) {
+		delete(security.Bans, player)
+		saveSecurity()
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// observeActivity records an action by a player and runs matching rules.
+func observeActivity(player, kind string) {
+	if player == "" {
+		return
+	}
+	now := time.Now()
+	var fired []SecurityEvent
+
+	securityMutex.Lock()
+	acts, ok := playerActivity[player]
+	if !ok {
+		acts = map[string][]time.Time{}
+		playerActivity[player] = acts
+	}
+	acts[kind] = append(acts[kind], now)
+
+	longest := 0
+	for _, rule := range security.Rules {
+		if detectorActivity[rule.Detector] == kind && rule.WindowSeconds > longest {
+			longest = rule.WindowSeconds
+		}
+	}
+	// Drop samples older than any rule cares about.
+	cutoff := now.Add(-time.Duration(longest) * time.Second)
+	times := acts[kind]
+	for len(times) > 0 && times[0].Before(cutoff) {
+		times = times[1:]
+	}
+	acts[kind] = times
+
+	for _, rule := range security.Rules {
+		if !rule.Enabled || detectorActivity[rule.Detector] != kind {
+			continue
+		}
+		since := now.Add(-time.Duration(rule.WindowSeconds) * time.Second)
+		count := 0
+		for _, t := range times {
+			if !t.Before(since) {
+				count++
+			}
+		}
+		if count < rule.Threshold {
+			continue
+		}
+		key := rule.ID + "\x00" + player
+		if now.Sub(securityFired[key]) < time.Duration(rule.CooldownSeconds)*time.Second {
+			continue
+		}
+		securityFired[key] = now
+		if rule.Action == securityActionTempban {
+			security.Bans[player] = now.Add(time.Duration(rule.BanMinutes) * time.Minute)
+			saveSecurity()
+		}
+		fired = append(fired, SecurityEvent{
+			RuleID: rule.ID,
+			Rule:   rule.Name,
+			Player: player,
+			Reason: fmt.Sprintf("%d %s events in %ds", count, kind, rule.WindowSeconds),
+			Action: rule.Action,
+			Time:   now,
+		})
+	}
+	securityMutex.Unlock()
+
+	for _, ev := range fired {
+		log.Printf("Security rule %q triggered for %s: %s", ev.Rule, ev.Player, ev.Reason)
+		var err error
+		switch ev.Action {
+		case securityActionKick:
+			err = sendServerCommand("kick " + quotePlayer(player) + " Suspicious activity detected")
+		case securityActionTempban:
+			err = sendServerCommand("kick " + quotePlayer(player) + " Temporarily banned for suspicious activity")
+		}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		securityMutex.Lock()
+		securityEvents = append(securityEvents, ev)
+		if len(securityEvents) > securityEventsLen {
+			securityEvents = securityEvents[len(securityEvents)-securityEventsLen:]
+		}
+		securityMutex.Unlock()
+	}
+}
+
+// enforceTempban kicks a joining player who is banned, reporting whether
+// they were kicked.
+func enforceTempban(player string) bool {
+	until, banned := securityBanned(player)
+	if !banned {
+		return false
+	}
+	msg := fmt.Sprintf("You are banned until %s", until.Format("2006-01-02 15:04 MST"))
+	if err := sendServerCommand("kick " + quotePlayer(player) + " " + msg); err != nil {
+		log.Printf("Failed to kick banned player %s: %v", player, err)
+	}
+	return true
+}
+
+// securityRulesHandler lists rules (GET) or creates one (POST).
+func securityRulesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		securityMutex.Lock()
+		defer securityMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"rules": security.Rules})
+	case http.MethodPost:
+		var rule SecurityRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := rule.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule.ID = newUUID()
+		securityMutex.Lock()
+		security.Rules = append(security.Rules, rule)
+		saveSecurity()
+		securityMutex.Unlock()
+		writeJSONResponse(w, http.StatusCreated, rule)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// securityHandler serves /security/rules/{id}, /security/events and
+// /security/bans[/{player}].
+func securityHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/security/")
+	securityMutex.Lock()
+	defer securityMutex.Unlock()
+
+	switch {
+	case rest == "events":
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"events": securityEvents})
+		return
+	case rest == "bans":
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"bans": security.Bans})
+		return
+	case strings.HasPrefix(rest, "bans/"):
+		player, err := url.PathUnescape(strings.TrimPrefix(rest, "bans/"))
+		if err != nil || r.Method != http.MethodDelete {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		if _, ok := security.Bans[player]; !ok {
+			writeJSONError(w, http.StatusNotFound, "Player is not banned")
+			return
+		}
+		delete(security.Bans, player)
+		saveSecurity()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Ban lifted"})
+		return
+	}
+
+	id := strings.TrimPrefix(rest, "rules/")
+	index := -1
+	for i, rule := range security.Rules {
+		if rule.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		writeJSONError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, security.Rules[index])
+	case http.MethodPut:
+		var rule SecurityRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := rule.validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule.ID = id
+		security.Rules[index] = rule
+		saveSecurity()
+		writeJSONResponse(w, http.StatusOK, rule)
+	case http.MethodDelete:
+		security.Rules = append(security.Rules[:index], security.Rules[index+1:]...)
+		saveSecurity()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Rule deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
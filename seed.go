@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+const maxSlimeRadius = 64
+
+// SlimeChunk is a chunk in which slimes can spawn below y=40.
+type SlimeChunk struct {
+	ChunkX int32 `json:"chunk_x"`
+	ChunkZ int32 `json:"chunk_z"`
+	BlockX int32 `json:"block_x"`
+	BlockZ int32 `json:"block_z"`
+}
+
+// mt19937First returns the first output of a 32-bit Mersenne Twister seeded
+// with seed. Only the state words needed for the first output are generated.
+func mt19937First(seed uint32) uint32 {
+	var mt [398]uint32
+	mt[0] = seed
+	for i := 1; i < len(mt); i++ {
+		mt[i] = 1812433253*(mt[i-1]^(mt[i-1]>>30)) + uint32(i)
+	}
+	y := (mt[0] & 0x80000000) | (mt[1] & 0x7fffffff)
+	v := mt[397] ^ (y >> 1)
+	if y&1 != 0 {
+		v ^= 0x9908b0df
+	}
+	v ^= v >> 11
+	v ^= (v << 7) & 0x9d2c5680
+	v ^= (v << 15) & 0xefc60000
+	v ^= v >> 18
+	return v
+}
+
+// isSlimeChunk implements Bedrock's slime chunk test. Unlike Java edition it
+// does not depend on the world seed.
+func isSlimeChunk(chunkX, chunkZ int32) bool {
+	seed := uint32(chunkX)*0x1f1f1f1f ^ uint32(chunkZ)
+	return mt19937First(seed)%10 == 0
+}
+
+// worldSeed reads RandomSeed from the current world's level.dat.
+func worldSeed() (int64, error) {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return 0, err
+	}
+	level, err := readLevelDat(filepath.Join(worldFolder, "level.dat"))
+	if err != nil {
+		return 0, err
+	}
+	seed, _ := level["RandomSeed"].(int64)
+	return seed, nil
+}
+
+// seedHandler returns the current world seed.
+func seedHandler(w http.ResponseWriter, r *http.Request) {
+	seed, err := worldSeed()
+	if err != nil {
+		log.Printf("Error reading world seed: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read world seed")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"seed": seed, "seed_string": strconv.FormatInt(seed, 10)})
+}
+
+// slimeChunksHandler lists slime chunks around a block position.
+// Query: x, z (block coordinates, default 0) and radius (in chunks, default 8).
+func slimeChunksHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	parse := func(key string, def int) (int, bool) {
+		v := q.Get(key)
+		if v == "" {
+			return def, true
+		}
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	x, okX := parse("x", 0)
+	z, okZ := parse("z", 0)
+	radius, okR := parse("radius", 8)
+	if !okX || !okZ || !okR || radius < 0 || radius > maxSlimeRadius {
+		writeJSONError(w, http.StatusBadRequest, "Invalid x, z or radius")
+		return
+	}
+	centerX, centerZ := int32(x)>>4, int32(z)>>4
+	chunks := []SlimeChunk{}
+	for cx := centerX - int32(radius); cx <= centerX+int32(radius); cx++ {
+		for cz := centerZ - int32(radius); cz <= centerZ+int32(radius); cz++ {
+			if isSlimeChunk(cx, cz) {
+				chunks = append(chunks, SlimeChunk{ChunkX: cx, ChunkZ: cz, BlockX: cx * 16, BlockZ: cz * 16})
+			}
+		}
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"center_chunk": map[string]int32{"x": centerX, "z": centerZ},
+		"radius":       radius,
+		"slime_chunks": chunks,
+	})
+}
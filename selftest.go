@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SelfCheck is the outcome of a single startup environment check.
+type SelfCheck struct {
+	Name     string `json:"name"`
+	Critical bool   `json:"critical"`
+	OK       bool   `json:"ok"`
+	Message  string `json:"message"`
+}
+
+// SelfTestReport aggregates all startup checks.
+type SelfTestReport struct {
+	Ready     bool        `json:"ready"`
+	CheckedAt time.Time   `json:"checked_at"`
+	Checks    []SelfCheck `json:"checks"`
+}
+
+var (
+	selfTestReport SelfTestReport
+	selfTestMutex  sync.RWMutex
+)
+
+// checkFIFO verifies the command FIFO exists, is a named pipe and is writable.
+func checkFIFO() SelfCheck {
+	c := SelfCheck{Name: "command_pipe", Critical: true}
+	info, err := os.Stat(fifoPath)
+	if err != nil {
+		c.Message = fmt.Sprintf("%s not found: mount the shared volume containing the server's command FIFO (%v)", fifoPath, err)
+		return c
+	}
+	// Windows named pipes do not report a FIFO mode, so only the
+	// existence check applies there.
+	if runtime.GOOS != "windows" && info.Mode()&os.ModeNamedPipe == 0 {
+		c.Message = fmt.Sprintf("%s exists but is not a named pipe: create it with mkfifo in the server container", fifoPath)
+		return c
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0222 == 0 {
+		c.Message = fmt.Sprintf("%s is not writable: check the FIFO permissions and the sidecar's user ID", fifoPath)
+		return c
+	}
+	c.OK = true
+	c.Message = "FIFO present and writable"
+	return c
+}
+
+// checkDataWritable verifies the data volume is mounted read-write.
+func checkDataWritable() SelfCheck {
+	c := SelfCheck{Name: "data_writable", Critical: true}
+	f, err := os.CreateTemp(dataDir, ".selftest-*")
+	if err != nil {
+		c.Message = fmt.Sprintf("%s is not writable: mount the server data volume read-write (%v)", dataDir, err)
+		return c
+	}
+	name := f.Name()
+	f.Close()
+	os.Remove(name)
+	c.OK = true
+	c.Message = "data directory writable"
+	return c
+}
+
+// checkServerProperties verifies server.properties exists and has a level-name.
+func checkServerProperties() SelfCheck {
+	c := SelfCheck{Name: "server_properties", Critical: true}
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		c.Message = fmt.Sprintf("cannot determine world from %s: start the server once to generate it or set level-name (%v)", serverPropsPath, err)
+		return c
+	}
+	c.OK = true
+	c.Message = "world folder " + worldFolder
+	return c
+}
+
+// checkDirectory verifies that dir exists and is a directory.
+func checkDirectory(name, dir string, critical bool) SelfCheck {
+	c := SelfCheck{Name: name, Critical: critical}
+	info, err := os.Stat(dir)
+	if err != nil {
+		c.Message = fmt.Sprintf("%s missing: it will be created by the server on first start, or create it manually (%v)", dir, err)
+		return c
+	}
+	if !info.IsDir() {
+		c.Message = fmt.Sprintf("%s is not a directory", dir)
+		return c
+	}
+	c.OK = true
+	c.Message = dir + " present"
+	return c
+}
+
+// runSelfTest executes all environment checks and stores the report.
+func runSelfTest() SelfTestReport {
+	transportCheck := SelfCheck{Name: "command_transport", Critical: true, OK: true,
+		Message: "using " + commandTransport.Name() + " transport"}
+	if _, ok := commandTransport.(*pipeTransport); ok {
+		transportCheck = checkFIFO()
+	}
+	checks := []SelfCheck{
+		transportCheck,
+		checkDataWritable(),
+		checkServerProperties(),
+		checkDirectory("behavior_packs_dir", behaviorPacksDir, false),
+		checkDirectory("resource_packs_dir", resourcePacksDir, false),
+		checkDirectory("worlds_dir", filepath.Join(dataDir, "worlds"), false),
+		checkDirectory("behavior_archive_dir", behaviorPackArchiveDir, false),
+		checkDirectory("resource_archive_dir", resourcePackArchiveDir, false),
+	}
+	report := SelfTestReport{Ready: true, CheckedAt: time.Now(), Checks: checks}
+	for _, c := range checks {
+		if c.Critical && !c.OK {
+			report.Ready = false
+		}
+	}
+
+	selfTestMutex.Lock()
+	selfTestReport = report
+	selfTestMutex.Unlock()
+	return report
+}
+
+// logSelfTest writes a human-readable summary of the report to the log.
+func logSelfTest(report SelfTestReport) {
+	for _, c := range report.Checks {
+		status := "OK"
+		if !c.OK {
+			status = "WARN"
+			if c.Critical {
+				status = "FAIL"
+			}
+		}
+		log.Printf("Self-check %-22s %-4s %s", c.Name, status, c.Message)
+	}
+	if !report.Ready {
+		log.Printf("Self-check: critical checks failed, sidecar will report not ready")
+	}
+}
+
+// selfTestHandler re-runs the environment checks and returns the report.
+func selfTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	report := runSelfTest()
+	code := http.StatusOK
+	if !report.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSONResponse(w, code, report)
+}
+
+// readyHandler reports readiness based on the last self-test run. Critical
+// checks are retried so the sidecar becomes ready once the environment is fixed.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	selfTestMutex.RLock()
+	report := selfTestReport
+	selfTestMutex.RUnlock()
+	if !report.Ready {
+		report = runSelfTest()
+	}
+	if !report.Ready {
+		writeJSONError(w, http.StatusServiceUnavailable, "Not ready: see /selftest")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ready"})
+}
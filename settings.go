@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sidecarSetting is a startup setting that can come from a command-line
+// flag, an environment variable or the settings file, in that order of
+// precedence. Its flag is -name and its settings file key is name with
+// underscores for dashes.
+type sidecarSetting struct {
+	name  string
+	env   string
+	path  bool // a filesystem path, resolved against the settings file's directory
+	usage string
+}
+
+var sidecarSettings = []sidecarSetting{
+	{"data-dir", dataDirEnv, true, "data root of the server install (default /data)"},
+	{"command-pipe", commandPipeEnv, true, "server command FIFO or named pipe"},
+	{"behavior-packs-dir", behaviorPacksDirEnv, true, "behavior packs directory (default <data-dir>/behavior_packs)"},
+	{"resource-packs-dir", resourcePacksDirEnv, true, "resource packs directory (default <data-dir>/resource_packs)"},
+	{"server-properties", serverPropsEnv, true, "server.properties path (default <data-dir>/server.properties)"},
+	{"state-dir", stateDirEnv, true, "where the sidecar keeps its own state (default <data-dir>/sidecar)"},
+	{"port", listenPortEnv, false, "API listen port (default 8080)"},
+}
+
+// startupSettings holds the values given by flags and the settings file,
+// resolved once when the package initialises because the filesystem layout
+// is derived from them.
+var startupSettings = loadSettings(os.Args[1:])
+
+type settingValues struct {
+	flags    map[string]string
+	file     map[string]string
+	filePath string
+	err      error
+	usage    func(io.Writer)
+}
+
+// loadSettings parses the command line and then the settings file named by
+// -settings or BEDROCK_API_SETTINGS. Errors are kept for main to report,
+// since they surface before logging is set up.
+func loadSettings(args []string) *settingValues {
+	s := &settingValues{flags: map[string]string{}, file: map[string]string{}}
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	values := map[string]*string{}
+	for _, def := range sidecarSettings {
+		values[def.name] = fs.String(def.name, "", def.usage+"; env "+def.env)
+	}
+	settingsFile := fs.String("settings", "", "YAML settings file; env "+settingsFileEnv)
+	s.usage = func(w io.Writer) {
+		fmt.Fprintf(w, "Usage of %s:\n", fs.Name())
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+	if s.err = fs.Parse(args); s.err != nil {
+		return s
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if v, ok := values[f.Name]; ok {
+			s.flags[f.Name] = *v
+		}
+	})
+
+	s.filePath = *settingsFile
+	if s.filePath == "" {
+		s.filePath = os.Getenv(settingsFileEnv)
+	}
+	if s.filePath == "" {
+		return s
+	}
+	file, err := readSettingsFile(s.filePath)
+	if err != nil {
+		s.err = fmt.Errorf("settings file %s: %w", s.filePath, err)
+		return s
+	}
+	s.file = file
+	return s
+}
+
+// readSettingsFile reads a flat YAML (or JSON) document of settings keyed
+// like data_dir. Relative paths are taken from the file's directory, so a
+// file can describe the layout beside it.
+func readSettingsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML([]byte(os.ExpandEnv(string(data))))
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return map[string]string{}, nil
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping of settings")
+	}
+	out := map[string]string{}
+	for key, raw := range m {
+		def, ok := lookupSetting(strings.ReplaceAll(key, "_", "-"))
+		if !ok || strings.Contains(key, "-") {
+			return nil, fmt.Errorf("unknown setting %q", key)
+		}
+		v := fmt.Sprint(raw)
+		if def.path && v != "" && !filepath.IsAbs(v) {
+			v = filepath.Join(filepath.Dir(path), v)
+		}
+		out[def.name] = v
+	}
+	return out, nil
+}
+
+func lookupSetting(name string) (sidecarSetting, bool) {
+	for _, def := range sidecarSettings {
+		if def.name == name {
+			return def, true
+		}
+	}
+	return sidecarSetting{}, false
+}
+
+// settingOrDefault returns a setting from its flag, environment variable or
+// the settings file, or def when none gives it.
+func settingOrDefault(name, def string) string {
+	v, _ := resolveSetting(name)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// resolveSetting returns a setting's explicit value and where it came from.
+func resolveSetting(name string) (value, source string) {
+	setting, _ := lookupSetting(name)
+	if v := startupSettings.flags[name]; v != "" {
+		return v, "flag -" + name
+	}
+	if v := os.Getenv(setting.env); v != "" {
+		return v, "env " + setting.env
+	}
+	if v := startupSettings.file[name]; v != "" {
+		return v, "settings file"
+	}
+	return "", "default"
+}
+
+// checkSettings reports a bad command line or settings file and exits.
+// -h prints the flags.
+func checkSettings() {
+	err := startupSettings.err
+	if err == flag.ErrHelp {
+		startupSettings.usage(os.Stdout)
+		os.Exit(0)
+	}
+	if err == nil {
+		if n, perr := strconv.Atoi(listenPort); perr != nil || n < 1 || n > 65535 {
+			err = fmt.Errorf("invalid port %q", listenPort)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		startupSettings.usage(os.Stderr)
+		os.Exit(2)
+	}
+	if startupSettings.filePath != "" {
+		log.Printf("Loaded settings from %s", startupSettings.filePath)
+	}
+	resolved := map[string]string{
+		"data-dir":           dataDir,
+		"command-pipe":       fifoPath,
+		"behavior-packs-dir": behaviorPacksDir,
+		"resource-packs-dir": resourcePacksDir,
+		"server-properties":  serverPropsPath,
+		"state-dir":          stateDir,
+		"port":               listenPort,
+	}
+	for _, def := range sidecarSettings {
+		_, source := resolveSetting(def.name)
+		log.Printf("Setting %s = %s (%s)", def.name, resolved[def.name], source)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	shareSecretStateFile = "share_secret.json"
+	defaultShareExpiry   = 24 * time.Hour
+	maxShareExpiry       = 7 * 24 * time.Hour
+)
+
+// ShareRequest asks for a download link. Type is backup (ID is the backup
+// ID), structure (ID is the structure ID) or world (a fresh world export
+// made when the link is opened).
+type ShareRequest struct {
+	Type      string `json:"type"`
+	ID        string `json:"id,omitempty"`
+	ExpiresIn string `json:"expires_in,omitempty"`
+}
+
+var (
+	shareSecret      []byte
+	shareSecretMutex sync.Mutex
+)
+
+// shareKey returns the signing secret, creating it on first use. Rotating
+// it revokes every link handed out so far.
+func shareKey(rotate bool) ([]byte, error) {
+	shareSecretMutex.Lock()
+	defer shareSecretMutex.Unlock()
+	if shareSecret != nil && !rotate {
+		return shareSecret, nil
+	}
+	var state struct {
+		Secret string `json:"secret"`
+	}
+	if !rotate {
+		if err := loadState(shareSecretStateFile, &state); err != nil {
+			return nil, err
+		}
+	}
+	secret, err := hex.DecodeString(state.Secret)
+	if err != nil || len(secret) < 32 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		state.Secret = hex.EncodeToString(secret)
+		if err := saveState(shareSecretStateFile, state); err != nil {
+			return nil, err
+		}
+	}
+	shareSecret = secret
+	return secret, nil
+}
+
+// shareSignature signs a link's type, ID and expiry.
+func shareSignature(key []byte, kind, id string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%d", kind, id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sharedFile resolves what a link points at. World exports have no file
+// until the link is opened.
+func sharedFile(kind, id string) (string, error) {
+	switch kind {
+	case "backup":
+		return backupPath(id)
+	case "structure":
+		structuresMutex.RLock()
+		defer structuresMutex.RUnlock()
+		for _, s := range structureLibrary {
+			if s.ID == id {
+				return filepath.Join(structurePackDir(), "structures", structureNamespace, id+".mcstructure"), nil
+			}
+		}
+		return "", os.ErrNotExist
+	case "world":
+		if id != "" {
+			return "", fmt.Errorf("world links take no id")
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("type must be backup, structure or world")
+}
+
+// requestBaseURL returns the scheme and host the client used.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// shareHandler creates a signed, expiring download link (POST) or revokes
+// every link by rotating the signing secret (DELETE).
+func shareHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req ShareRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		expiry := defaultShareExpiry
+		if req.ExpiresIn != "" {
+			d, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || d <= 0 || d > maxShareExpiry {
+				writeJSONError(w, http.StatusBadRequest, "expires_in must be a duration up to 168h")
+				return
+			}
+			expiry = d
+		}
+		if _, err := sharedFile(req.Type, req.ID); os.IsNotExist(err) {
+			writeJSONError(w, http.StatusNotFound, "Nothing to share with that id")
+			return
+		} else if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		key, err := shareKey(false)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		expires := time.Now().Add(expiry).Truncate(time.Second)
+		path := "/shared/" + req.Type
+		if req.ID != "" {
+			path += "/" + url.PathEscape(req.ID)
+		}
+		query := url.Values{"expires": {strconv.FormatInt(expires.Unix(), 10)}, "sig": {shareSignature(key, req.Type, req.ID, expires.Unix())}}
+		writeJSONResponse(w, http.StatusCreated, map[string]interface{}{
+			"url":        requestBaseURL(r) + path + "?" + query.Encode(),
+			"expires_at": expires,
+		})
+	case http.MethodDelete:
+		if _, err := shareKey(true); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Printf("Share links revoked")
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "All share links revoked"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// sharedHandler serves a download for a valid, unexpired signed link. It
+// needs no API key: the signature is the credential.
+func sharedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	kind, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/shared/"), "/")
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	key, keyErr := shareKey(false)
+	if err != nil || keyErr != nil ||
+		!hmac.Equal([]byte(r.URL.Query().Get("sig")), []byte(shareSignature(key, kind, id, expires))) {
+		writeJSONError(w, http.StatusForbidden, "Invalid link")
+		return
+	}
+	if time.Now().Unix() > expires {
+		writeJSONError(w, http.StatusGone, "Link expired")
+		return
+	}
+	path, err := sharedFile(kind, id)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Shared file no longer exists")
+		return
+	}
+	if kind == "world" {
+		if path, err = createHotBackup(); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer os.Remove(path)
+		defer os.Remove(path + ".manifest.json")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(path))
+	serveLimitedFile(w, r, path)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	shopStateFile   = "shop.json"
+	maxShopQuantity = 64 * 36
+)
+
+var itemIDPattern = regexp.MustCompile(`^[a-z0-9_]+(:[a-z0-9_]+)?$`)
+
+// ShopItem is an entry in the shop definition. A zero price disables that
+// direction of trade.
+type ShopItem struct {
+	ID        string `json:"id"`
+	Item      string `json:"item"`     // Minecraft item identifier
+	Quantity  int    `json:"quantity"` // items per unit
+	BuyPrice  int64  `json:"buy_price"`
+	SellPrice int64  `json:"sell_price"`
+}
+
+// ShopTradeRequest is the body of POST /shop/buy and /shop/sell.
+type ShopTradeRequest struct {
+	Player string `json:"player"`
+	ID     string `json:"id"`
+	Units  int    `json:"units"`
+}
+
+// commandResultReporter is implemented by transports that can tell whether a
+// command succeeded in game, not merely that it was delivered.
+type commandResultReporter interface {
+	ReportsCommandResults() bool
+}
+
+var (
+	shopItems = make([]ShopItem, 0)
+	shopMutex sync.RWMutex
+	// tradeMutex serialises trades so balance checks and commands are atomic.
+	tradeMutex sync.Mutex
+)
+
+func (it *ShopItem) validate() error {
+	if !validName(it.ID) || strings.Contains(it.ID, " ") {
+		return errors.New("invalid id")
+	}
+	if !itemIDPattern.MatchString(it.Item) {
+		return fmt.Errorf("invalid item %q", it.Item)
+	}
+	if it.Quantity <= 0 {
+		it.Quantity = 1
+	}
+	if it.BuyPrice < 0 || it.SellPrice < 0 {
+		return errors.New("prices must not be negative")
+	}
+	return nil
+}
+
+func findShopItem(id string) (ShopItem, bool) {
+	shopMutex.RLock()
+	defer shopMutex.RUnlock()
+	for _, it := range shopItems {
+		if it.ID == id {
+			return it, true
+		}
+	}
+	return ShopItem{}, false
+}
+
+// shopBuy debits the player and gives the items, refunding if delivery fails.
+func shopBuy(player, id string, units int) (int64, error) {
+	it, ok := findShopItem(id)
+	if !ok {
+		return 0, errors.New("unknown item")
+	}
+	if it.BuyPrice == 0 {
+		return 0, errors.New("item is not for sale")
+	}
+	if units <= 0 || units*it.Quantity > maxShopQuantity {
+		return 0, errors.New("invalid quantity")
+	}
+	tradeMutex.Lock()
+	defer tradeMutex.Unlock()
+	cost := it.BuyPrice * int64(units)
+	balance, err := adjustPoints(player, -cost, fmt.Sprintf("buy %dx %s", units, it.ID))
+	if err != nil {
+		return balance, err
+	}
+	cmd := fmt.Sprintf("give %s %s %d", quotePlayer(player), it.Item, units*it.Quantity)
+	if err := sendServerCommand(cmd); err != nil {
+		if _, rerr := adjustPoints(player, cost, "refund: "+it.ID+" delivery failed"); rerr != nil {
+			log.Printf("Failed to refund %s: %v", player, rerr)
+		}
+		return pointsBalance(player), fmt.Errorf("delivery failed: %w", err)
+	}
+	return balance, nil
+}
+
+// shopSell clears the items from the player's inventory and credits them.
+// It needs a transport that reports command results, because otherwise the
+// sidecar cannot tell whether the player actually had the items.
+func shopSell(player, id string, units int) (int64, error) {
+	if rr, ok := commandTransport.(commandResultReporter); !ok || !rr.ReportsCommandResults() {
+		return 0, errors.New("selling requires a transport that reports command results (mcws)")
+	}
+	it, ok := findShopItem(id)
+	if !ok {
+		return 0, errors.New("unknown item")
+	}
+	if it.SellPrice == 0 {
+		return 0, errors.New("item cannot be sold")
+	}
+	if units <= 0 || units*it.Quantity > maxShopQuantity {
+		return 0, errors.New("invalid quantity")
+	}
+	tradeMutex.Lock()
+	defer tradeMutex.Unlock()
+	// The hasitem filter makes the selector match nobody, and so the command
+	// fail, unless the player holds the full amount.
+	count := units * it.Quantity
+	target := fmt.Sprintf("@a[name=%s,hasitem={item=%s,quantity=%d..}]", quotePlayer(player), it.Item, count)
+	if err := sendServerCommand(fmt.Sprintf("clear %s %s 0 %d", target, it.Item, count)); err != nil {
+		return pointsBalance(player), fmt.Errorf("player does not have the items: %w", err)
+	}
+	return adjustPoints(player, it.SellPrice*int64(units), fmt.Sprintf("sell %dx %s", units, it.ID))
+}
+
+// handleShopChatCommand handles !shop, !balance, !buy and !sell.
+func handleShopChatCommand(player, message string) bool {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return false
+	}
+	units := 1
+	if len(fields) > 2 {
+		if n, err := strconv.Atoi(fields[2]); err == nil {
+			units = n
+		}
+	}
+	switch fields[0] {
+	case "!shop":
+		shopMutex.RLock()
+		lines := []string{}
+		for _, it := range shopItems {
+			lines = append(lines, fmt.Sprintf("%s: %dx %s buy %d sell %d", it.ID, it.Quantity, it.Item, it.BuyPrice, it.SellPrice))
+		}
+		shopMutex.RUnlock()
+		tellPlayer(player, "Shop: "+strings.Join(lines, "; "))
+	case "!balance":
+		tellPlayer(player, fmt.Sprintf("Balance: %d points", pointsBalance(player)))
+	case "!buy", "!sell":
+		if len(fields) < 2 {
+			tellPlayer(player, "Usage: "+fields[0]+" <item> [units]")
+			return true
+		}
+		trade := shopBuy
+		if fields[0] == "!sell" {
+			trade = shopSell
+		}
+		balance, err := trade(player, fields[1], units)
+		if err != nil {
+			tellPlayer(player, "Trade failed: "+err.Error())
+		} else {
+			tellPlayer(player, fmt.Sprintf("Trade complete, balance: %d points", balance))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// shopHandler returns (GET) or replaces (PUT) the shop definition.
+func shopHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		shopMutex.RLock()
+		defer shopMutex.RUnlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"items": shopItems})
+	case http.MethodPut:
+		var items []ShopItem
+		if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		seen := map[string]bool{}
+		for i := range items {
+			if err := items[i].validate(); err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if seen[items[i].ID] {
+				writeJSONError(w, http.StatusBadRequest, "Duplicate id "+items[i].ID)
+				return
+			}
+			seen[items[i].ID] = true
+		}
+		shopMutex.Lock()
+		shopItems = items
+		err := saveState(shopStateFile, shopItems)
+		shopMutex.Unlock()
+		if err != nil {
+			log.Printf("Error saving shop: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save shop")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"items": items})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// shopTradeHandler serves POST /shop/buy and POST /shop/sell.
+func shopTradeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req ShopTradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !validPlayerName(req.Player) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Units == 0 {
+		req.Units = 1
+	}
+	trade := shopBuy
+	if strings.HasSuffix(r.URL.Path, "/sell") {
+		trade = shopSell
+	}
+	balance, err := trade(req.Player, req.ID, req.Units)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"player": req.Player, "balance": balance})
+}
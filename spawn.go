@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	playerSpawnsStateFile = "player_spawns.json"
+	maxHorizontalCoord    = 30000000
+	minBuildHeight        = -64
+	maxBuildHeight        = 319
+	maxSpawnRadius        = 128
+)
+
+// validateSpawnPos checks that a position lies within the world border
+// and the overworld's build height.
+func validateSpawnPos(p BlockPos) error {
+	if p.X < -maxHorizontalCoord || p.X > maxHorizontalCoord || p.Z < -maxHorizontalCoord || p.Z > maxHorizontalCoord {
+		return fmt.Errorf("x and z must be within ±%d", maxHorizontalCoord)
+	}
+	if p.Y < minBuildHeight || p.Y > maxBuildHeight {
+		return fmt.Errorf("y must be between %d and %d", minBuildHeight, maxBuildHeight)
+	}
+	return nil
+}
+
+// PlayerSpawn is a spawn point set for a player through the API. Spawn
+// points set in game by beds or commands are stored in the world database
+// and are not visible here.
+type PlayerSpawn struct {
+	BlockPos
+	Updated time.Time `json:"updated"`
+}
+
+var (
+	playerSpawns      = map[string]PlayerSpawn{}
+	playerSpawnsMutex sync.Mutex
+)
+
+// levelInt returns an integer tag of a decoded level.dat.
+func levelInt(level map[string]interface{}, key string) (int, bool) {
+	switch v := level[key].(type) {
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int8:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// readActiveLevelDat reads the active world's level.dat as of the server's
+// last save.
+func readActiveLevelDat() (map[string]interface{}, error) {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return nil, err
+	}
+	return readLevelDat(filepath.Join(worldFolder, "level.dat"))
+}
+
+// spawnHandler shows the world spawn from level.dat (GET) or moves it with
+// setworldspawn (PUT {"x","y","z"}).
+func spawnHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		level, err := readActiveLevelDat()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		x, _ := levelInt(level, "SpawnX")
+		y, _ := levelInt(level, "SpawnY")
+		z, _ := levelInt(level, "SpawnZ")
+		writeJSONResponse(w, http.StatusOK, BlockPos{X: x, Y: y, Z: z})
+	case http.MethodPut:
+		var pos BlockPos
+		if err := json.NewDecoder(r.Body).Decode(&pos); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := validateSpawnPos(pos); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := sendServerCommand(fmt.Sprintf("setworldspawn %d %d %d", pos.X, pos.Y, pos.Z)); err != nil {
+			log.Printf("Error setting world spawn: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to set world spawn")
+			return
+		}
+		log.Printf("World spawn set to %d %d %d", pos.X, pos.Y, pos.Z)
+		writeJSONResponse(w, http.StatusOK, pos)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// spawnRadiusHandler reads (GET) or sets (PUT {"radius"}) the spawnradius
+// game rule, how far from the world spawn players first appear. Bedrock
+// has no spawn-protection property, so this is the only spawn area setting
+// the server offers.
+func spawnRadiusHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		level, err := readActiveLevelDat()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		radius, ok := levelInt(level, "spawnradius")
+		if !ok {
+			radius = 5
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]int{"radius": radius})
+	case http.MethodPut:
+		var req struct {
+			Radius *int `json:"radius"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Radius == nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if *req.Radius < 0 || *req.Radius > maxSpawnRadius {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("radius must be between 0 and %d", maxSpawnRadius))
+			return
+		}
+		if err := sendServerCommand(fmt.Sprintf("gamerule spawnradius %d", *req.Radius)); err != nil {
+			log.Printf("Error setting spawn radius: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to set spawn radius")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]int{"radius": *req.Radius})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// playerSpawnHandler serves /players/{name}/spawn: GET returns the spawn
+// point last set through the API and PUT {"x","y","z"} sets it with
+// spawnpoint. The player must be online for the command to apply.
+func playerSpawnHandler(w http.ResponseWriter, r *http.Request, player string) {
+	switch r.Method {
+	case http.MethodGet:
+		playerSpawnsMutex.Lock()
+		sp, ok := playerSpawns[player]
+		playerSpawnsMutex.Unlock()
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "No spawn point set through the API")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, sp)
+	case http.MethodPut:
+		var pos BlockPos
+		if err := json.NewDecoder(r.Body).Decode(&pos); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := validateSpawnPos(pos); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		queueMutex.Lock()
+		_, online := onlineSet[player]
+		queueMutex.Unlock()
+		if !online {
+			writeJSONError(w, http.StatusConflict, "Player is not online")
+			return
+		}
+		if err := sendServerCommand(fmt.Sprintf("spawnpoint %s %d %d %d", quotePlayer(player), pos.X, pos.Y, pos.Z)); err != nil {
+			log.Printf("Error setting spawn point for %s: %v", player, err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to set spawn point")
+			return
+		}
+		playerSpawnsMutex.Lock()
+		sp := PlayerSpawn{BlockPos: pos, Updated: time.Now()}
+		playerSpawns[player] = sp
+		if err := saveState(playerSpawnsStateFile, playerSpawns); err != nil {
+			log.Printf("Error saving player spawns: %v", err)
+		}
+		playerSpawnsMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, sp)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
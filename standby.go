@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	standbyStateFile       = "standby.json"
+	defaultStandbyInterval = 15 * time.Minute
+)
+
+// Standby roles.
+const (
+	standbyRoleStandby  = "standby"
+	standbyRolePromoted = "promoted"
+)
+
+// StandbyStatus describes a replica that keeps its world in step with a
+// primary sidecar until it is promoted.
+type StandbyStatus struct {
+	Role       string    `json:"role"`
+	Primary    string    `json:"primary"`
+	Interval   string    `json:"interval"`
+	LastSync   time.Time `json:"last_sync,omitempty"`
+	LastTask   string    `json:"last_task,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+	PromotedAt time.Time `json:"promoted_at,omitempty"`
+}
+
+// FailoverRequest promotes a standby. Properties are applied to
+// server.properties before the server starts; FinalSync pulls once more
+// from the primary first if it is still reachable.
+type FailoverRequest struct {
+	Properties map[string]string `json:"properties,omitempty"`
+	FinalSync  bool              `json:"final_sync,omitempty"`
+}
+
+var (
+	standby      StandbyStatus
+	standbyMutex sync.Mutex
+)
+
+// standbyInterval returns the configured pull interval.
+func standbyInterval() time.Duration {
+	v := os.Getenv(standbyIntervalEnv)
+	if v == "" {
+		return defaultStandbyInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", standbyIntervalEnv, v, defaultStandbyInterval)
+		return defaultStandbyInterval
+	}
+	return d
+}
+
+func standbyRemote() (*remoteSidecar, error) {
+	return newRemoteSidecar(os.Getenv(standbyPrimaryEnv), os.Getenv(standbyTokenEnv))
+}
+
+// pullFromPrimary syncs the world from the primary as a "sync" task and
+// records the outcome. It holds standbyMutex while starting so a failover
+// never overlaps a pull.
+func pullFromPrimary(remote *remoteSidecar) (*Task, error) {
+	standbyMutex.Lock()
+	defer standbyMutex.Unlock()
+	if standby.Role != standbyRoleStandby {
+		return nil, errors.New("standby was promoted")
+	}
+	if _, busy := runningTask("sync"); busy {
+		return nil, errors.New("a sync is already running")
+	}
+	if serverUp() {
+		return nil, errors.New("standby server is running; not replacing its world")
+	}
+	return startTask("sync", func(t *Task) error {
+		err := runWorldSync(t, remote, false)
+		standbyMutex.Lock()
+		defer standbyMutex.Unlock()
+		standby.LastTask = t.ID
+		if err != nil {
+			standby.LastError = err.Error()
+			return err
+		}
+		standby.LastSync = time.Now()
+		standby.LastError = ""
+		return nil
+	}), nil
+}
+
+// startStandbyLoop pulls the primary's world periodically while this
+// sidecar is an unpromoted standby.
+func startStandbyLoop() {
+	primary := os.Getenv(standbyPrimaryEnv)
+	if primary == "" {
+		return
+	}
+	remote, err := standbyRemote()
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", standbyPrimaryEnv, err)
+	}
+	interval := standbyInterval()
+	standbyMutex.Lock()
+	if err := loadState(standbyStateFile, &standby); err != nil {
+		log.Printf("Error loading standby state: %v", err)
+	}
+	if standby.Role == "" {
+		standby.Role = standbyRoleStandby
+	}
+	standby.Primary = primary
+	standby.Interval = interval.String()
+	promoted := standby.Role == standbyRolePromoted
+	standbyMutex.Unlock()
+	if promoted {
+		log.Printf("Standby was promoted; not pulling from %s", primary)
+		return
+	}
+	log.Printf("Standby mode: pulling world from %s every %s", primary, interval)
+
+	go func() {
+		for {
+			_, err := pullFromPrimary(remote)
+			standbyMutex.Lock()
+			if standby.Role != standbyRoleStandby {
+				standbyMutex.Unlock()
+				return
+			}
+			if err != nil {
+				log.Printf("Standby pull skipped: %v", err)
+				standby.LastError = err.Error()
+			}
+			standbyMutex.Unlock()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// promoteStandby stops pulling, optionally syncs once more, applies the
+// failover properties and starts the server.
+func promoteStandby(t *Task, req FailoverRequest) error {
+	if req.FinalSync {
+		t.step(10, "Final sync from primary")
+		remote, err := standbyRemote()
+		if err == nil {
+			err = runWorldSync(t, remote, false)
+		}
+		if err != nil {
+			t.step(50, "Final sync failed, promoting with the last synced world: %v", err)
+		}
+	}
+	if len(req.Properties) > 0 {
+		t.step(60, "Applying failover properties")
+		if err := setServerProperties(req.Properties); err != nil {
+			return err
+		}
+	}
+	t.step(80, "Starting server")
+	if err := startServer(); err != nil {
+		return err
+	}
+	t.step(100, "Promoted to primary")
+	log.Printf("Standby promoted to primary")
+	return nil
+}
+
+// standbyHandler reports standby status.
+func standbyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	standbyMutex.Lock()
+	defer standbyMutex.Unlock()
+	if standby.Role == "" {
+		writeJSONError(w, http.StatusNotFound, "Standby mode is not configured")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, standby)
+}
+
+// failoverHandler promotes this standby to serve players.
+func failoverHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req FailoverRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+	}
+	for k, v := range req.Properties {
+		if !propertyKeyPattern.MatchString(k) || strings.ContainsAny(v, "\n\r") {
+			writeJSONError(w, http.StatusBadRequest, "Invalid property "+k)
+			return
+		}
+	}
+	if !canStartServer() {
+		writeJSONError(w, http.StatusConflict, "A start command is required: set "+startCommandEnv)
+		return
+	}
+	standbyMutex.Lock()
+	if standby.Role != standbyRoleStandby {
+		standbyMutex.Unlock()
+		writeJSONError(w, http.StatusConflict, "Not a standby")
+		return
+	}
+	if _, busy := runningTask("sync"); busy {
+		// A pull in flight would race with the start; let it finish.
+		standbyMutex.Unlock()
+		writeJSONError(w, http.StatusConflict, "A sync is running; retry shortly")
+		return
+	}
+	standby.Role = standbyRolePromoted
+	standby.PromotedAt = time.Now()
+	if err := saveState(standbyStateFile, standby); err != nil {
+		log.Printf("Error saving standby state: %v", err)
+	}
+	standbyMutex.Unlock()
+
+	t := startTask("failover", func(t *Task) error { return promoteStandby(t, req) })
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+}
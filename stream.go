@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamStateFile     = "stream.json"
+	streamHistoryLen    = 200
+	twitchMaxMessageAge = 10 * time.Minute
+	maxStreamBody       = 1 << 20 // 1 MB
+)
+
+// StreamMapping maps a stream event type to in-game commands. Commands may
+// use the placeholders {user}, {reward}, {input} and {amount}.
+type StreamMapping struct {
+	Event    string   `json:"event"`            // follow, subscribe, gift, cheer, raid, redemption
+	Reward   string   `json:"reward,omitempty"` // redemption title filter
+	Commands []string `json:"commands,omitempty"`
+	Macro    string   `json:"macro,omitempty"` // custom command name to run instead
+}
+
+// StreamEvent is a normalised stream platform event.
+type StreamEvent struct {
+	Platform   string    `json:"platform"`
+	Event      string    `json:"event"`
+	User       string    `json:"user"`
+	Reward     string    `json:"reward,omitempty"`
+	Input      string    `json:"input,omitempty"`
+	Amount     string    `json:"amount,omitempty"`
+	Commands   []string  `json:"commands,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+var (
+	streamMappings   = make([]StreamMapping, 0)
+	streamHistory    = make([]StreamEvent, 0)
+	seenTwitchIDs    = make(map[string]time.Time)
+	streamMutex      sync.Mutex
+	unsafeStreamText = regexp.MustCompile(`[^A-Za-z0-9 _.!?'-]`)
+)
+
+// twitchEventTypes maps EventSub subscription types to event names.
+var twitchEventTypes = map[string]string{
+	"channel.follow":            "follow",
+	"channel.subscribe":         "subscribe",
+	"channel.subscription.gift": "gift",
+	"channel.cheer":             "cheer",
+	"channel.raid":              "raid",
+	"channel.channel_points_custom_reward_redemption.add": "redemption",
+}
+
+// sanitizeStreamText strips characters that could break out of a command
+// argument; stream usernames and redemption input are untrusted.
+func sanitizeStreamText(s string) string {
+	s = unsafeStreamText.ReplaceAllString(s, "")
+	if len(s) > 64 {
+		s = s[:64]
+	}
+	return s
+}
+
+// expandStreamCommand fills placeholders in a command template.
+func expandStreamCommand(tmpl string, ev StreamEvent) string {
+	return strings.NewReplacer(
+		"{user}", sanitizeStreamText(ev.User),
+		"{reward}", sanitizeStreamText(ev.Reward),
+		"{input}", sanitizeStreamText(ev.Input),
+		"{amount}", sanitizeStreamText(ev.Amount),
+	).Replace(tmpl)
+}
+
+// customCommandByName returns the command text of a named custom command.
+func customCommandByName(name string) (string, bool) {
+	commandsMutex.RLock()
+	defer commandsMutex.RUnlock()
+	for _, c := range customCommands {
+		if c.Name == name {
+			return c.Command, true
+		}
+	}
+	return "", false
+}
+
+// handleStreamEvent runs the commands mapped to ev and records it.
+func handleStreamEvent(ev StreamEvent) {
+	streamMutex.Lock()
+	var commands []string
+	for _, m := range streamMappings {
+		if m.Event != ev.Event || (m.Reward != "" && !strings.EqualFold(m.Reward, ev.Reward)) {
+			continue
+		}
+		for _, c := range m.Commands {
+			commands = append(commands, expandStreamCommand(c, ev))
+		}
+		if m.Macro != "" {
+			if c, ok := customCommandByName(m.Macro); ok {
+				commands = append(commands, expandStreamCommand(c, ev))
+			}
+		}
+	}
+	ev.Commands = commands
+	ev.ReceivedAt = time.Now()
+	streamHistory = append(streamHistory, ev)
+	if len(streamHistory) > streamHistoryLen {
+		streamHistory = streamHistory[len(streamHistory)-streamHistoryLen:]
+	}
+	streamMutex.Unlock()
+
+	for _, c := range commands {
+		if err := sendServerCommand(c); err != nil {
+			log.Printf("Stream event %s: failed to run %q: %v", ev.Event, c, err)
+		}
+	}
+}
+
+// verifyTwitchSignature checks the EventSub HMAC and message freshness.
+func verifyTwitchSignature(r *http.Request, body []byte) error {
+	secret := os.Getenv(twitchSecretEnv)
+	if secret == "" {
+		return errors.New("twitch integration not configured")
+	}
+	id := r.Header.Get("Twitch-Eventsub-Message-Id")
+	ts := r.Header.Get("Twitch-Eventsub-Message-Timestamp")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + ts))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("Twitch-Eventsub-Message-Signature"))) {
+		return errors.New("invalid signature")
+	}
+	sent, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil || time.Since(sent) > twitchMaxMessageAge {
+		return errors.New("stale message")
+	}
+	return nil
+}
+
+// twitchWebhookHandler receives Twitch EventSub webhook notifications.
+func twitchWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxStreamBody))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	if err := verifyTwitchSignature(r, body); err != nil {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	var payload struct {
+		Challenge    string `json:"challenge"`
+		Subscription struct {
+			Type string `json:"type"`
+		} `json:"subscription"`
+		Event map[string]interface{} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	switch r.Header.Get("Twitch-Eventsub-Message-Type") {
+	case "webhook_callback_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, payload.Challenge)
+		return
+	case "notification":
+	default:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Twitch retries deliveries; process each message ID once.
+	id := r.Header.Get("Twitch-Eventsub-Message-Id")
+	streamMutex.Lock()
+	for k, t := range seenTwitchIDs {
+		if time.Since(t) > twitchMaxMessageAge {
+			delete(seenTwitchIDs, k)
+		}
+	}
+	_, seen := seenTwitchIDs[id]
+	seenTwitchIDs[id] = time.Now()
+	streamMutex.Unlock()
+	if seen {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	event, ok := twitchEventTypes[payload.Subscription.Type]
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	str := func(key string) string {
+		v, _ := payload.Event[key].(string)
+		return v
+	}
+	ev := StreamEvent{Platform: "twitch", Event: event, User: str("user_name"), Input: str("user_input")}
+	if ev.User == "" {
+		ev.User = str("from_broadcaster_user_name")
+	}
+	if reward, ok := payload.Event["reward"].(map[string]interface{}); ok {
+		ev.Reward, _ = reward["title"].(string)
+	}
+	for _, key := range []string{"bits", "viewers", "total", "tier"} {
+		switch v := payload.Event[key].(type) {
+		case float64:
+			ev.Amount = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			ev.Amount = v
+		}
+		if ev.Amount != "" {
+			break
+		}
+	}
+	handleStreamEvent(ev)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// genericStreamWebhookHandler accepts normalised events from relays such as
+// StreamElements or YouTube bots, authenticated with X-Stream-Secret.
+func genericStreamWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	secret := os.Getenv(streamSecretEnv)
+	if secret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Stream-Secret")), []byte(secret)) != 1 {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var ev StreamEvent
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxStreamBody)).Decode(&ev); err != nil || ev.Event == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid event")
+		return
+	}
+	if ev.Platform == "" {
+		ev.Platform = "generic"
+	}
+	handleStreamEvent(ev)
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Event processed"})
+}
+
+// streamMappingsHandler returns (GET) or replaces (PUT) the event mappings.
+func streamMappingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		streamMutex.Lock()
+		defer streamMutex.Unlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"mappings": streamMappings})
+	case http.MethodPut:
+		var mappings []StreamMapping
+		if err := json.NewDecoder(r.Body).Decode(&mappings); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		for _, m := range mappings {
+			if m.Event == "" || (len(m.Commands) == 0 && m.Macro == "") {
+				writeJSONError(w, http.StatusBadRequest, "Each mapping needs an event and commands or a macro")
+				return
+			}
+			for _, c := range m.Commands {
+				if strings.ContainsAny(c, "\r\n") {
+					writeJSONError(w, http.StatusBadRequest, "Commands must be single lines")
+					return
+				}
+			}
+		}
+		streamMutex.Lock()
+		streamMappings = mappings
+		err := saveState(streamStateFile, streamMappings)
+		streamMutex.Unlock()
+		if err != nil {
+			log.Printf("Error saving stream mappings: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save mappings")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"mappings": mappings})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// streamHistoryHandler lists recently processed stream events.
+func streamHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"events": streamHistory})
+}
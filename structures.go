@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Uploaded structures are installed into a generated behavior pack so the
+// server can load them with "/structure load sidecar:<id>".
+const (
+	structuresStateFile  = "structures.json"
+	structurePackUUID    = "c3e9b0d2-5a47-4f1e-8b6c-2d9a7e4f1b08"
+	structureModuleUUID  = "e1f4a7c2-9b3d-4c58-a6e0-7d2b5f8c3a91"
+	structurePackDirName = "sidecar_structures"
+	structureNamespace   = "sidecar"
+	maxStructureSize     = 4 << 20 // 4 MB
+)
+
+// StructureEntry describes a library structure.
+type StructureEntry struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags"`
+	Author      string    `json:"author,omitempty"`
+	Size        [3]int32  `json:"size"`
+	Bytes       int64     `json:"bytes"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	Identifier  string    `json:"identifier"`
+}
+
+// StructurePlaceRequest is the body of POST /structures/{id}/place.
+type StructurePlaceRequest struct {
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Z        int    `json:"z"`
+	Rotation string `json:"rotation,omitempty"` // 0_degrees, 90_degrees, 180_degrees, 270_degrees
+	Mirror   string `json:"mirror,omitempty"`   // none, x, z, xz
+}
+
+var (
+	structureLibrary = make([]StructureEntry, 0)
+	structuresMutex  sync.RWMutex
+)
+
+// structurePackDir is the generated pack holding uploaded structures.
+func structurePackDir() string {
+	return filepath.Join(behaviorPacksDir, structurePackDirName)
+}
+
+// ensureStructurePack writes the structure pack manifest and activates it.
+func ensureStructurePack() error {
+	dir := structurePackDir()
+	if err := os.MkdirAll(filepath.Join(dir, "structures", structureNamespace), 0755); err != nil {
+		return err
+	}
+	manifest := map[string]interface{}{
+		"format_version": 2,
+		"header": map[string]interface{}{
+			"name":               "Sidecar Structures",
+			"description":        "Structure library managed by the bedrock API sidecar",
+			"uuid":               structurePackUUID,
+			"version":            []int{1, 0, 0},
+			"min_engine_version": []int{1, 20, 0},
+		},
+		"modules": []map[string]interface{}{
+			{"type": "data", "uuid": structureModuleUUID, "version": []int{1, 0, 0}},
+		},
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644); err != nil {
+		return err
+	}
+	return activateBehaviorPack(structurePackUUID, []int{1, 0, 0})
+}
+
+// structureSize reads the size tag of an .mcstructure file.
+func structureSize(data []byte) ([3]int32, error) {
+	var size [3]int32
+	_, v, err := decodeNBT(bytes.NewReader(data))
+	if err != nil {
+		return size, fmt.Errorf("not a valid .mcstructure: %w", err)
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return size, fmt.Errorf("not a valid .mcstructure")
+	}
+	list, ok := root["size"].([]interface{})
+	if !ok || len(list) != 3 {
+		return size, fmt.Errorf(".mcstructure has no size")
+	}
+	for i, n := range list {
+		size[i], _ = n.(int32)
+	}
+	return size, nil
+}
+
+// splitTags normalises a comma-separated tag list.
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func saveStructureLibrary() {
+	if err := saveState(structuresStateFile, structureLibrary); err != nil {
+		log.Printf("Error saving structure library: %v", err)
+	}
+}
+
+// structuresHandler searches the library (GET ?q=&tag=) or uploads a
+// structure (POST multipart: file, name, description, tags, author).
+func structuresHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := strings.ToLower(r.URL.Query().Get("q"))
+		tag := strings.ToLower(r.URL.Query().Get("tag"))
+		structuresMutex.RLock()
+		defer structuresMutex.RUnlock()
+		results := []StructureEntry{}
+		for _, s := range structureLibrary {
+			if q != "" && !strings.Contains(strings.ToLower(s.Name+" "+s.Description), q) {
+				continue
+			}
+			if tag != "" {
+				found := false
+				for _, t := range s.Tags {
+					if t == tag {
+						found = true
+					}
+				}
+				if !found {
+					continue
+				}
+			}
+			results = append(results, s)
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"structures": results})
+	case http.MethodPost:
+		uploadStructure(w, r)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func uploadStructure(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxStructureSize+1<<20)
+	if err := r.ParseMultipartForm(maxStructureSize); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "File too big")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxStructureSize+1))
+	if err != nil || len(data) > maxStructureSize {
+		writeJSONError(w, http.StatusBadRequest, "File too big")
+		return
+	}
+	size, err := structureSize(data)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var idBytes [6]byte
+	rand.Read(idBytes[:])
+	id := "s" + hex.EncodeToString(idBytes[:])
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	}
+	entry := StructureEntry{
+		ID:          id,
+		Name:        name,
+		Description: r.FormValue("description"),
+		Tags:        splitTags(r.FormValue("tags")),
+		Author:      r.FormValue("author"),
+		Size:        size,
+		Bytes:       int64(len(data)),
+		UploadedAt:  time.Now(),
+		Identifier:  structureNamespace + ":" + id,
+	}
+
+	if err := ensureStructurePack(); err != nil {
+		log.Printf("Error preparing structure pack: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to prepare structure pack")
+		return
+	}
+	path := filepath.Join(structurePackDir(), "structures", structureNamespace, id+".mcstructure")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Printf("Error saving structure: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save structure")
+		return
+	}
+
+	structuresMutex.Lock()
+	structureLibrary = append(structureLibrary, entry)
+	saveStructureLibrary()
+	structuresMutex.Unlock()
+	writeJSONResponse(w, http.StatusCreated, entry)
+}
+
+// structureHandler reads or deletes a structure, or places it via
+// POST /structures/{id}/place.
+func structureHandler(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/structures/"), "/")
+
+	structuresMutex.Lock()
+	defer structuresMutex.Unlock()
+	index := -1
+	for i, s := range structureLibrary {
+		if s.ID == id {
+			index = i
+		}
+	}
+	if index < 0 {
+		writeJSONError(w, http.StatusNotFound, "Structure not found")
+		return
+	}
+	entry := structureLibrary[index]
+
+	switch {
+	case action == "place" && r.Method == http.MethodPost:
+		var req StructurePlaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if req.Rotation == "" {
+			req.Rotation = "0_degrees"
+		}
+		if req.Mirror == "" {
+			req.Mirror = "none"
+		}
+		switch req.Rotation {
+		case "0_degrees", "90_degrees", "180_degrees", "270_degrees":
+		default:
+			writeJSONError(w, http.StatusBadRequest, "Invalid rotation")
+			return
+		}
+		switch req.Mirror {
+		case "none", "x", "z", "xz":
+		default:
+			writeJSONError(w, http.StatusBadRequest, "Invalid mirror")
+			return
+		}
+		cmd := fmt.Sprintf("structure load %s %d %d %d %s %s", entry.Identifier, req.X, req.Y, req.Z, req.Rotation, req.Mirror)
+		if err := sendServerCommand(cmd); err != nil {
+			log.Printf("Error placing structure: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to send command")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Structure placed", "command": cmd})
+	case action == "" && r.Method == http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, entry)
+	case action == "" && r.Method == http.MethodDelete:
+		os.Remove(filepath.Join(structurePackDir(), "structures", structureNamespace, id+".mcstructure"))
+		structureLibrary = append(structureLibrary[:index], structureLibrary[index+1:]...)
+		saveStructureLibrary()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Structure deleted"})
+	case action != "" && action != "place":
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
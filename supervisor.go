@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	supervisorMinBackoff = time.Second
+	supervisorMaxBackoff = 5 * time.Minute
+	// supervisorStableAfter is how long the server must run before its
+	// crashes stop counting towards the restart backoff.
+	supervisorStableAfter = 10 * time.Minute
+	// supervisorStopTimeout is how long a stopping server may take to save
+	// before it is killed.
+	supervisorStopTimeout = 2 * time.Minute
+)
+
+// SupervisorStatus reports the supervised server process.
+type SupervisorStatus struct {
+	Command     string     `json:"command"`
+	Running     bool       `json:"running"`
+	PID         int        `json:"pid,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	Starts      int        `json:"starts"`
+	Crashes     int        `json:"crashes"`
+	LastExit    string     `json:"last_exit,omitempty"`
+	LastExitAt  *time.Time `json:"last_exit_at,omitempty"`
+	NextRestart *time.Time `json:"next_restart,omitempty"`
+}
+
+// serverSupervisor runs the dedicated server as a child process. Commands
+// are written to its stdin and its output goes to the console buffer.
+type serverSupervisor struct {
+	args []string
+	dir  string
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	exited   chan struct{} // closed once cmd has exited and been cleared
+	stopping bool          // a stop was requested, so the exit is not a crash
+	backoff  time.Duration
+	restart  *time.Timer
+	status   SupervisorStatus
+}
+
+// supervisor is set when the sidecar runs the server itself.
+var supervisor *serverSupervisor
+
+// newServerSupervisor parses BEDROCK_API_SUPERVISE, the server's command
+// line. The server runs in BEDROCK_API_SUPERVISE_DIR, or else the folder
+// of its executable, as bedrock_server expects.
+func newServerSupervisor(command string) (*serverSupervisor, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty server command")
+	}
+	dir := os.Getenv(superviseDirEnv)
+	if dir == "" {
+		dir = filepath.Dir(args[0])
+	}
+	return &serverSupervisor{args: args, dir: dir, status: SupervisorStatus{Command: command}}, nil
+}
+
+// Write writes to the server's stdin, for the command transport.
+func (s *serverSupervisor) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stdin == nil {
+		return 0, errors.New("the server process is not running")
+	}
+	return s.stdin.Write(p)
+}
+
+// pid returns the server's PID, or 0 when it is not running.
+func (s *serverSupervisor) pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.PID
+}
+
+// snapshot returns a copy of the status.
+func (s *serverSupervisor) snapshot() SupervisorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// start launches the server. A server that is still stopping is waited for
+// first, so a restart does not race the old process's exit.
+func (s *serverSupervisor) start() error {
+	s.mu.Lock()
+	if s.cmd != nil && s.stopping {
+		exited := s.exited
+		s.mu.Unlock()
+		<-exited
+		s.mu.Lock()
+	}
+	defer s.mu.Unlock()
+	return s.startLocked()
+}
+
+// startLocked launches the server. Callers must hold s.mu.
+func (s *serverSupervisor) startLocked() error {
+	if s.cmd != nil {
+		return errors.New("the server process is already running")
+	}
+	s.cancelRestartLocked()
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	cmd.Dir = s.dir
+	cmd.Env = os.Environ()
+	if runtime.GOOS == "linux" && os.Getenv("LD_LIBRARY_PATH") == "" {
+		// bedrock_server loads the libraries shipped next to it.
+		cmd.Env = append(cmd.Env, "LD_LIBRARY_PATH=.")
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// stdout and stderr share one pipe so lines keep their order.
+	output, writer, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = writer, writer
+	log.Printf("Starting server: %s", strings.Join(s.args, " "))
+	err = cmd.Start()
+	writer.Close()
+	if err != nil {
+		stdin.Close()
+		output.Close()
+		return fmt.Errorf("failed to start %s: %w", s.args[0], err)
+	}
+	now := time.Now()
+	s.cmd, s.stdin, s.exited, s.stopping = cmd, stdin, make(chan struct{}), false
+	s.status.Running, s.status.PID, s.status.StartedAt = true, cmd.Process.Pid, &now
+	s.status.Starts++
+	outputDone := make(chan struct{})
+	go func() {
+		captureServerOutput(output)
+		close(outputDone)
+	}()
+	go s.wait(cmd, now, outputDone)
+	expectServer(true)
+	return nil
+}
+
+// captureServerOutput publishes the server's output to the console and
+// echoes it to the sidecar's stdout, so container logs still show it.
+func captureServerOutput(r io.ReadCloser) {
+	defer r.Close()
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" || err == nil {
+			fmt.Fprintln(os.Stdout, line)
+			publishConsoleLine(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait reaps the server and restarts it with backoff if it crashed. A
+// clean exit, such as a stop typed into the console, is not restarted.
+func (s *serverSupervisor) wait(cmd *exec.Cmd, started time.Time, outputDone <-chan struct{}) {
+	err := cmd.Wait()
+	// Let the last lines reach the console, unless a process the server
+	// started still holds the pipe open.
+	select {
+	case <-outputDone:
+	case <-time.After(2 * time.Second):
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.cmd, s.stdin = nil, nil
+	s.status.Running, s.status.PID = false, 0
+	s.status.LastExit, s.status.LastExitAt = cmd.ProcessState.String(), &now
+	defer close(s.exited)
+	if s.stopping || err == nil {
+		log.Printf("Server process exited (%s)", s.status.LastExit)
+		return
+	}
+	s.status.Crashes++
+	if time.Since(started) >= supervisorStableAfter {
+		s.backoff = 0
+	}
+	reason := fmt.Sprintf("exited unexpectedly (%s) after %s", s.status.LastExit, now.Sub(started).Round(time.Second))
+	s.scheduleRestartLocked("Server process " + reason)
+	go reportServerCrash("server_crash", reason, "restarting it in "+s.backoff.String())
+}
+
+// scheduleRestartLocked starts the server again after the next backoff
+// step. Callers must hold s.mu.
+func (s *serverSupervisor) scheduleRestartLocked(reason string) {
+	switch {
+	case s.backoff == 0:
+		s.backoff = supervisorMinBackoff
+	case s.backoff < supervisorMaxBackoff:
+		s.backoff = min(2*s.backoff, supervisorMaxBackoff)
+	}
+	next := time.Now().Add(s.backoff)
+	s.status.NextRestart = &next
+	log.Printf("%s; restarting in %s", reason, s.backoff)
+	s.restart = time.AfterFunc(s.backoff, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status.NextRestart != &next {
+			return // cancelled or superseded
+		}
+		s.restart, s.status.NextRestart = nil, nil
+		if err := s.startLocked(); err != nil {
+			s.scheduleRestartLocked(err.Error())
+		}
+	})
+}
+
+// cancelRestartLocked cancels a pending restart. Callers must hold s.mu.
+func (s *serverSupervisor) cancelRestartLocked() {
+	if s.restart != nil {
+		s.restart.Stop()
+		s.restart, s.status.NextRestart = nil, nil
+	}
+}
+
+// stop asks the server to save and exit, and kills it if it has not
+// within supervisorStopTimeout. A pending restart is cancelled.
+func (s *serverSupervisor) stop() error {
+	expectServer(false)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelRestartLocked()
+	if s.cmd == nil {
+		return nil
+	}
+	s.stopping = true
+	cmd, exited := s.cmd, s.exited
+	if _, err := io.WriteString(s.stdin, "stop\n"); err != nil {
+		log.Printf("Error sending stop to the server, killing it: %v", err)
+		return cmd.Process.Kill()
+	}
+	go func() {
+		select {
+		case <-exited:
+		case <-time.After(supervisorStopTimeout):
+			log.Printf("Server did not stop within %s, killing it", supervisorStopTimeout)
+			cmd.Process.Kill()
+		}
+	}()
+	return nil
+}
+
+// kill ends the server at once and waits for it to exit. The exit is not
+// treated as a crash.
+func (s *serverSupervisor) kill() error {
+	s.mu.Lock()
+	s.cancelRestartLocked()
+	if s.cmd == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopping = true
+	cmd, exited := s.cmd, s.exited
+	s.mu.Unlock()
+	log.Printf("Killing server process %d", cmd.Process.Pid)
+	if err := cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-exited
+	return nil
+}
+
+// stopAndWait stops the server and waits for it to exit.
+func (s *serverSupervisor) stopAndWait() {
+	s.mu.Lock()
+	exited := s.exited
+	running := s.cmd != nil
+	s.mu.Unlock()
+	s.stop()
+	if running {
+		<-exited
+	}
+}
+
+// startSupervisor runs the server as a child process when
+// BEDROCK_API_SUPERVISE is set. Commands then go to the server's stdin in
+// place of the configured transport, its output feeds the console without
+// BEDROCK_API_SERVER_LOG, and starts, stops and restarts need no start
+// command. On SIGTERM or SIGINT the server is stopped cleanly before the
+// sidecar exits.
+func startSupervisor() {
+	command := os.Getenv(superviseEnv)
+	if command == "" {
+		return
+	}
+	s, err := newServerSupervisor(command)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", superviseEnv, err)
+	}
+	supervisor = s
+	commandTransport = &writerTransport{name: "supervisor", w: s}
+	startServer = s.start
+	stopServer = s.stop
+	if err := s.start(); err != nil {
+		s.mu.Lock()
+		s.scheduleRestartLocked(err.Error())
+		s.mu.Unlock()
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %s, stopping the server", sig)
+		s.stopAndWait()
+		os.Exit(0)
+	}()
+}
+
+// supervisorHandler serves GET /server/supervisor, the supervised server
+// process's state and crash count.
+func supervisorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if supervisor == nil {
+		writeJSONError(w, http.StatusNotFound, "The server is not supervised: set "+superviseEnv)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, supervisor.snapshot())
+}
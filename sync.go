@@ -0,0 +1,306 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// syncSnapshotDir holds the frozen copy of the world served to sync targets.
+var (
+	syncSnapshotDir   = filepath.Join(stateDir, "sync_snapshot")
+	syncSnapshotMutex sync.RWMutex
+)
+
+// SyncFile is one world file in a sync manifest.
+type SyncFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SyncManifest lists every file of a world with its hash.
+type SyncManifest struct {
+	Files []SyncFile `json:"files"`
+}
+
+// SyncRequest asks this sidecar to bring its world in line with a source.
+type SyncRequest struct {
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// hashFile returns the SHA-256 of a file.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildSyncManifest hashes every regular file below dir. A missing dir has
+// an empty manifest.
+func buildSyncManifest(dir string) (SyncManifest, error) {
+	m := SyncManifest{Files: []SyncFile{}}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, SyncFile{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum})
+		return nil
+	})
+	return m, err
+}
+
+// takeSyncSnapshot takes a consistent copy of the world as the sync
+// snapshot and returns its manifest.
+func takeSyncSnapshot() (SyncManifest, error) {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return SyncManifest{}, err
+	}
+	syncSnapshotMutex.Lock()
+	defer syncSnapshotMutex.Unlock()
+	if err := os.RemoveAll(syncSnapshotDir); err != nil {
+		return SyncManifest{}, err
+	}
+	if err := copyWorldConsistent(worldFolder, syncSnapshotDir); err != nil {
+		os.RemoveAll(syncSnapshotDir)
+		return SyncManifest{}, err
+	}
+	return buildSyncManifest(syncSnapshotDir)
+}
+
+// syncTargetPath resolves a manifest path below root, rejecting escapes.
+func syncTargetPath(root, rel string) (string, error) {
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if !strings.HasPrefix(path, filepath.Clean(root)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path %q", rel)
+	}
+	return path, nil
+}
+
+// diffSyncManifests returns the files to fetch and the local files to delete
+// to turn local into remote.
+func diffSyncManifests(local, remote SyncManifest) (fetch []SyncFile, remove []string) {
+	have := make(map[string]string, len(local.Files))
+	for _, f := range local.Files {
+		have[f.Path] = f.SHA256
+	}
+	for _, f := range remote.Files {
+		if have[f.Path] != f.SHA256 {
+			fetch = append(fetch, f)
+		}
+		delete(have, f.Path)
+	}
+	for path := range have {
+		remove = append(remove, path)
+	}
+	return fetch, remove
+}
+
+// runWorldSync downloads changed files from the source while the server
+// keeps running, then stops it only to swap them in.
+func runWorldSync(t *Task, remote *remoteSidecar, dryRun bool) error {
+	t.step(5, "Requesting world snapshot from %s", remote.base)
+	var manifest SyncManifest
+	if err := remote.postJSON("/sync/snapshot", &manifest); err != nil {
+		return fmt.Errorf("fetching manifest: %w", err)
+	}
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return err
+	}
+	t.step(15, "Hashing local world")
+	local, err := buildSyncManifest(worldFolder)
+	if err != nil {
+		return err
+	}
+	fetch, remove := diffSyncManifests(local, manifest)
+	var bytes int64
+	for _, f := range fetch {
+		bytes += f.Size
+	}
+	t.step(20, "%d of %d files changed (%d bytes), %d to delete", len(fetch), len(manifest.Files), bytes, len(remove))
+	if dryRun {
+		return nil
+	}
+
+	staging := worldFolder + ".sync"
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	for i, f := range fetch {
+		dst, err := syncTargetPath(staging, f.Path)
+		if err != nil {
+			return err
+		}
+		t.step(20+60*i/len(fetch), "Fetching %s", f.Path)
+		tmp, err := remote.download("/sync/files/"+(&url.URL{Path: f.Path}).EscapedPath(), "sync-*")
+		if err != nil {
+			return fmt.Errorf("fetching %s: %w", f.Path, err)
+		}
+		if sum, err := hashFile(tmp); err != nil || sum != f.SHA256 {
+			os.Remove(tmp)
+			return fmt.Errorf("%s changed during transfer", f.Path)
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := moveFile(tmp, dst); err != nil {
+			return err
+		}
+	}
+
+	apply := func() error {
+		t.step(90, "Applying %d changed and %d deleted files", len(fetch), len(remove))
+		for _, f := range fetch {
+			src, _ := syncTargetPath(staging, f.Path)
+			dst, _ := syncTargetPath(worldFolder, f.Path)
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(src, dst); err != nil {
+				return err
+			}
+		}
+		for _, rel := range remove {
+			if path, err := syncTargetPath(worldFolder, rel); err == nil {
+				os.Remove(path)
+			}
+		}
+		return nil
+	}
+	if len(fetch)+len(remove) > 0 {
+		if serverUp() {
+			t.step(85, "Stopping server to apply changes")
+			err = withServerStopped(apply)
+		} else {
+			err = apply()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.step(100, "World in sync")
+	log.Printf("Synced world from %s: %d files fetched, %d deleted", remote.base, len(fetch), len(remove))
+	return nil
+}
+
+// moveFile renames src to dst, copying across filesystems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	defer os.Remove(src)
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncHandler starts a differential world sync from a source sidecar.
+func syncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var req SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	remote, err := newRemoteSidecar(req.URL, req.Token)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, busy := runningTask("sync"); busy {
+		writeJSONError(w, http.StatusConflict, "A sync is already running")
+		return
+	}
+	t := startTask("sync", func(t *Task) error { return runWorldSync(t, remote, req.DryRun) })
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	writeJSONResponse(w, http.StatusAccepted, map[string]string{"task_id": t.ID})
+}
+
+// syncSourceHandler snapshots the world (POST /sync/snapshot) and serves
+// snapshot files (GET /sync/files/{path}) to sync targets.
+func syncSourceHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/sync/")
+	switch {
+	case rest == "snapshot":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		manifest, err := takeSyncSnapshot()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, manifest)
+	case strings.HasPrefix(rest, "files/"):
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		path, err := syncTargetPath(syncSnapshotDir, strings.TrimPrefix(rest, "files/"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid path")
+			return
+		}
+		syncSnapshotMutex.RLock()
+		defer syncSnapshotMutex.RUnlock()
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			writeJSONError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		serveLimitedFile(w, r, path)
+	default:
+		writeJSONError(w, http.StatusNotFound, "Not found")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TaggedPlayer is a player carrying a tag and where the tag comes from:
+// "direct" or "group:<name>".
+type TaggedPlayer struct {
+	Player  string   `json:"player"`
+	Online  bool     `json:"online"`
+	Sources []string `json:"sources"`
+}
+
+func validTag(tag string) bool {
+	return tag != "" && !strings.ContainsAny(tag, " \"\n\r/")
+}
+
+// taggedPlayers returns every player known to carry a tag, by tag. Only
+// tags the sidecar manages are known; tags set by commands or scripts in
+// game are not. Callers must hold groupsMutex.
+func taggedPlayers() map[string]map[string][]string {
+	byTag := map[string]map[string][]string{}
+	add := func(tag, player, source string) {
+		if byTag[tag] == nil {
+			byTag[tag] = map[string][]string{}
+		}
+		byTag[tag][player] = append(byTag[tag][player], source)
+	}
+	for player, tags := range groups.Tags {
+		for _, t := range tags {
+			add(t, player, "direct")
+		}
+	}
+	for player, names := range groups.Members {
+		for _, name := range names {
+			if i := findGroup(name); i >= 0 {
+				for _, t := range groups.Groups[i].Tags {
+					add(t, player, "group:"+name)
+				}
+			}
+		}
+	}
+	return byTag
+}
+
+// tagPlayerList turns a tag's players into a sorted list.
+func tagPlayerList(players map[string][]string) []TaggedPlayer {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	out := make([]TaggedPlayer, 0, len(players))
+	for p, sources := range players {
+		_, online := onlineSet[p]
+		out = append(out, TaggedPlayer{Player: p, Online: online, Sources: sources})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Player < out[j].Player })
+	return out
+}
+
+// tagsHandler lists the managed tags and how many players carry each.
+func tagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	groupsMutex.RLock()
+	byTag := taggedPlayers()
+	groupsMutex.RUnlock()
+	counts := make(map[string]int, len(byTag))
+	for t, players := range byTag {
+		counts[t] = len(players)
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"tags": counts})
+}
+
+// tagHandler serves GET /tags/{tag}, the players carrying a tag, and
+// PUT or DELETE /tags/{tag}/players/{player}, which give or take the tag
+// directly. The change is applied at once to an online player and
+// otherwise on their next join.
+func tagHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/tags/"), "/", 3)
+	tag := parts[0]
+	if !validTag(tag) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid tag")
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		groupsMutex.RLock()
+		players := taggedPlayers()[tag]
+		groupsMutex.RUnlock()
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"tag": tag, "players": tagPlayerList(players)})
+		return
+	}
+	if len(parts) != 3 || parts[1] != "players" {
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	player, err := url.PathUnescape(parts[2])
+	if err != nil || !validPlayerName(player) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid player")
+		return
+	}
+
+	groupsMutex.Lock()
+	switch r.Method {
+	case http.MethodPut:
+		groups.Tags[player] = append(removeString(groups.Tags[player], tag), tag)
+		groups.TagRemovals[player] = removeString(groups.TagRemovals[player], tag)
+		if len(groups.TagRemovals[player]) == 0 {
+			delete(groups.TagRemovals, player)
+		}
+	case http.MethodDelete:
+		groups.Tags[player] = removeString(groups.Tags[player], tag)
+		if len(groups.Tags[player]) == 0 {
+			delete(groups.Tags, player)
+		}
+		if !wantedTags(player)[tag] {
+			groups.TagRemovals[player] = append(removeString(groups.TagRemovals[player], tag), tag)
+		}
+	default:
+		groupsMutex.Unlock()
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	saveGroups()
+	groupsMutex.Unlock()
+
+	queueMutex.Lock()
+	_, online := onlineSet[player]
+	queueMutex.Unlock()
+	if online {
+		syncPlayerTags(player)
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "Tag updated", "applied": online})
+}
+
+// playerTagsHandler serves GET /players/{name}/tags.
+func playerTagsHandler(w http.ResponseWriter, r *http.Request, player string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	groupsMutex.RLock()
+	want := wantedTags(player)
+	pending := append([]string{}, groups.TagRemovals[player]...)
+	groupsMutex.RUnlock()
+	tags := make([]string, 0, len(want))
+	for t := range want {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"player": player, "tags": tags, "pending_removals": pending})
+}
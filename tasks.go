@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxTasks = 50
+
+// Task states.
+const (
+	taskRunning   = "running"
+	taskSucceeded = "succeeded"
+	taskFailed    = "failed"
+)
+
+// Task tracks a long-running background operation such as provisioning.
+type Task struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	State      string    `json:"state"`
+	Progress   int       `json:"progress"` // percent
+	Step       string    `json:"step"`
+	Log        []string  `json:"log"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	tasks      = make([]*Task, 0)
+	tasksMutex sync.Mutex
+)
+
+// step records progress on a task.
+func (t *Task) step(progress int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+	t.Progress = progress
+	t.Step = msg
+	t.Log = append(t.Log, time.Now().Format(time.RFC3339)+" "+msg)
+	log.Printf("Task %s (%s): %s", t.ID[:8], t.Kind, msg)
+}
+
+// startTask runs fn in the background and tracks it as a task.
+func startTask(kind string, fn func(t *Task) error) *Task {
+	t := &Task{ID: newUUID(), Kind: kind, State: taskRunning, Log: []string{}, StartedAt: time.Now()}
+	tasksMutex.Lock()
+	tasks = append(tasks, t)
+	if len(tasks) > maxTasks {
+		tasks = tasks[len(tasks)-maxTasks:]
+	}
+	tasksMutex.Unlock()
+
+	go func() {
+		err := fn(t)
+		tasksMutex.Lock()
+		defer tasksMutex.Unlock()
+		t.FinishedAt = time.Now()
+		if err != nil {
+			t.State = taskFailed
+			t.Error = err.Error()
+			log.Printf("Task %s (%s) failed: %v", t.ID[:8], kind, err)
+			return
+		}
+		t.State = taskSucceeded
+		t.Progress = 100
+	}()
+	return t
+}
+
+// snapshot returns a copy safe to encode. Callers must hold tasksMutex.
+func (t *Task) snapshot() Task {
+	c := *t
+	c.Log = append([]string{}, t.Log...)
+	return c
+}
+
+// runningTask returns the running task of a kind, if any.
+func runningTask(kind string) (Task, bool) {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+	for _, t := range tasks {
+		if t.Kind == kind && t.State == taskRunning {
+			return t.snapshot(), true
+		}
+	}
+	return Task{}, false
+}
+
+// latestTask returns the most recent task of a kind, if any.
+func latestTask(kind string) (Task, bool) {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+	for i := len(tasks) - 1; i >= 0; i-- {
+		if tasks[i].Kind == kind {
+			return tasks[i].snapshot(), true
+		}
+	}
+	return Task{}, false
+}
+
+// tasksHandler serves GET /tasks (optionally ?kind=) and GET /tasks/{id}.
+func tasksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tasks"), "/")
+	kind := r.URL.Query().Get("kind")
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+	list := make([]Task, 0, len(tasks))
+	for _, t := range tasks {
+		if id != "" && t.ID == id {
+			writeJSONResponse(w, http.StatusOK, t.snapshot())
+			return
+		}
+		if kind == "" || t.Kind == kind {
+			list = append(list, t.snapshot())
+		}
+	}
+	if id != "" {
+		writeJSONError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"tasks": list})
+}
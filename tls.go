@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadingCertificate serves a certificate and key from disk, reloading
+// them when either file changes so rotated certificates are picked up
+// without a restart.
+type reloadingCertificate struct {
+	certPath, keyPath string
+	mutex             sync.Mutex
+	cert              *tls.Certificate
+	certMod, keyMod   time.Time
+	checked           time.Time
+}
+
+func (c *reloadingCertificate) load() error {
+	certInfo, err := os.Stat(c.certPath)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(c.keyPath)
+	if err != nil {
+		return err
+	}
+	if c.cert != nil && certInfo.ModTime().Equal(c.certMod) && keyInfo.ModTime().Equal(c.keyMod) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return err
+	}
+	if c.cert != nil {
+		log.Printf("Reloaded TLS certificate from %s", c.certPath)
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certInfo.ModTime(), keyInfo.ModTime()
+	return nil
+}
+
+// getCertificate checks the files at most once per configPollInterval and
+// keeps serving the previous certificate if a reload fails.
+func (c *reloadingCertificate) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if time.Since(c.checked) >= configPollInterval {
+		c.checked = time.Now()
+		if err := c.load(); err != nil {
+			log.Printf("Error reloading TLS certificate: %v", err)
+		}
+	}
+	return c.cert, nil
+}
+
+// loadTLSConfig builds the listener's TLS configuration from the
+// environment. It returns nil when TLS is not configured.
+func loadTLSConfig() (*tls.Config, error) {
+	certPath, keyPath := os.Getenv(tlsCertEnv), os.Getenv(tlsKeyEnv)
+	caPath := os.Getenv(tlsClientCAEnv)
+	if certPath == "" && keyPath == "" {
+		if caPath != "" {
+			return nil, fmt.Errorf("%s requires %s and %s", tlsClientCAEnv, tlsCertEnv, tlsKeyEnv)
+		}
+		return nil, nil
+	}
+	if certPath == "" || keyPath == "" {
+		return nil, fmt.Errorf("%s and %s must both be set", tlsCertEnv, tlsKeyEnv)
+	}
+	cert := &reloadingCertificate{certPath: certPath, keyPath: keyPath, checked: time.Now()}
+	if err := cert.load(); err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: cert.getCertificate}
+	if caPath == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client CA file contains no certificates")
+	}
+	cfg.ClientCAs = pool
+	switch strings.ToLower(envOrDefault(tlsClientAuthEnv, "require")) {
+	case "require":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		// Clients without a certificate are accepted; API keys still apply.
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("%s must be require or optional", tlsClientAuthEnv)
+	}
+	return cfg, nil
+}
+
+// listenAndServe serves handler on addr, over TLS when it is configured.
+// An optional plain HTTP listener on BEDROCK_API_HTTP_ADDR stays
+// available to in-pod clients such as the bridge pack.
+func listenAndServe(addr string, handler http.Handler) error {
+	tlsConfig, err := loadTLSConfig()
+	if err != nil {
+		return fmt.Errorf("TLS configuration: %w", err)
+	}
+	if tlsConfig == nil {
+		log.Printf("Web UI available at http://localhost%s", addr)
+		return http.ListenAndServe(addr, handler)
+	}
+	if plain := os.Getenv(plainHTTPAddrEnv); plain != "" {
+		log.Printf("Serving plain HTTP on %s", plain)
+		go func() {
+			if err := http.ListenAndServe(plain, handler); err != nil {
+				log.Fatalf("Plain HTTP listener failed: %v", err)
+			}
+		}()
+	}
+	if tlsConfig.ClientCAs != nil {
+		log.Printf("Client certificates verified against %s (%s)", os.Getenv(tlsClientCAEnv), envOrDefault(tlsClientAuthEnv, "require"))
+	}
+	log.Printf("Web UI available at https://localhost%s", addr)
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
+	return server.ListenAndServeTLS("", "")
+}
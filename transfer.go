@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	transferLimitsStateFile = "transfer_limits.json"
+	transferChunk           = 32 << 10
+)
+
+// TransferLimits caps the bandwidth used by background transfers in bytes
+// per second. Zero means unlimited. Each limit is shared by all transfers
+// in that direction.
+type TransferLimits struct {
+	// Download covers server, pack, clone and world sync downloads.
+	Download int64 `json:"download_bytes_per_second"`
+	// Upload covers world exports, pack archives and sync files served to
+	// other sidecars.
+	Upload int64 `json:"upload_bytes_per_second"`
+}
+
+var (
+	transferLimits      TransferLimits
+	transferLimitsMutex sync.Mutex
+	downloadLimiter     = &rateLimiter{}
+	uploadLimiter       = &rateLimiter{}
+)
+
+// rateLimiter is a token bucket holding at most one second of tokens.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func (l *rateLimiter) setRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.tokens = float64(rate)
+	l.last = time.Now()
+}
+
+// wait blocks until n bytes may pass.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if l.tokens > float64(l.rate) {
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// limitedReader throttles reads through a rateLimiter.
+type limitedReader struct {
+	r io.Reader
+	l *rateLimiter
+}
+
+func (lr limitedReader) Read(p []byte) (int, error) {
+	if len(p) > transferChunk {
+		p = p[:transferChunk]
+	}
+	n, err := lr.r.Read(p)
+	lr.l.wait(n)
+	return n, err
+}
+
+// limitedResponseWriter throttles a response body through a rateLimiter.
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	l *rateLimiter
+}
+
+func (lw limitedResponseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > transferChunk {
+			chunk = chunk[:transferChunk]
+		}
+		lw.l.wait(len(chunk))
+		n, err := lw.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// serveLimitedFile serves a file subject to the upload limit.
+func serveLimitedFile(w http.ResponseWriter, r *http.Request, path string) {
+	http.ServeFile(limitedResponseWriter{w, uploadLimiter}, r, path)
+}
+
+func applyTransferLimits() {
+	downloadLimiter.setRate(transferLimits.Download)
+	uploadLimiter.setRate(transferLimits.Upload)
+}
+
+// loadTransferLimits restores the configured limits at startup.
+func loadTransferLimits() {
+	transferLimitsMutex.Lock()
+	defer transferLimitsMutex.Unlock()
+	if err := loadState(transferLimitsStateFile, &transferLimits); err != nil {
+		log.Printf("Error loading transfer limits: %v", err)
+	}
+	applyTransferLimits()
+}
+
+// transferLimitsHandler shows (GET) or replaces (PUT) the transfer limits.
+func transferLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	transferLimitsMutex.Lock()
+	defer transferLimitsMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, transferLimits)
+	case http.MethodPut:
+		var limits TransferLimits
+		if err := json.NewDecoder(r.Body).Decode(&limits); err != nil || limits.Download < 0 || limits.Upload < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		transferLimits = limits
+		applyTransferLimits()
+		if err := saveState(transferLimitsStateFile, transferLimits); err != nil {
+			log.Printf("Error saving transfer limits: %v", err)
+		}
+		writeJSONResponse(w, http.StatusOK, transferLimits)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandTransport delivers console commands to the dedicated server.
+// Implementations must be safe for concurrent use.
+type CommandTransport interface {
+	// Name identifies the transport in logs and status responses.
+	Name() string
+	// Send delivers a single console command.
+	Send(command string) error
+	// Close releases any held connection.
+	Close() error
+}
+
+// commandTransport is the active transport, selected at startup.
+var commandTransport CommandTransport = &pipeTransport{path: fifoPath}
+
+// defaultCommandPipe returns the platform default path of the pipe the
+// dedicated server reads console commands from. On Windows this is a named
+// pipe created by the wrapper launching bedrock_server.exe.
+func defaultCommandPipe() string {
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\bedrock_command`
+	}
+	return "/shared/command_fifo"
+}
+
+// newCommandTransport builds the transport named by kind from the environment.
+func newCommandTransport(kind string) (CommandTransport, error) {
+	switch strings.ToLower(kind) {
+	case "", "fifo", "pipe":
+		return &pipeTransport{path: fifoPath}, nil
+	case "stdin":
+		// Commands are written to our stdout, which is expected to be
+		// piped into the server's stdin (sidecar | bedrock_server).
+		return &writerTransport{name: "stdin", w: os.Stdout}, nil
+	case "rcon":
+		addr := os.Getenv(rconAddrEnv)
+		if addr == "" {
+			return nil, fmt.Errorf("%s must be set for the rcon transport", rconAddrEnv)
+		}
+		return &rconTransport{addr: addr, password: os.Getenv(rconPasswordEnv)}, nil
+	case "websocket", "ws":
+		u := os.Getenv(wsTransportURLEnv)
+		if u == "" {
+			return nil, fmt.Errorf("%s must be set for the websocket transport", wsTransportURLEnv)
+		}
+		return &wsTransport{url: u}, nil
+	case "mcws":
+		return mcwsTransport{}, nil
+	}
+	return nil, fmt.Errorf("unknown command transport %q", kind)
+}
+
+// sendServerCommand delivers a single console command to the dedicated server.
+func sendServerCommand(command string) error {
+	debugf("Sending command via %s: %s", commandTransport.Name(), command)
+	return commandTransport.Send(command)
+}
+
+// pipeTransport writes commands to a FIFO (Linux) or named pipe (Windows).
+// The pipe is opened per command so a restarted server is picked up again.
+type pipeTransport struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (t *pipeTransport) Name() string { return "fifo" }
+
+func (t *pipeTransport) Send(command string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pipe, err := os.OpenFile(t.path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open command pipe %s: %w", t.path, err)
+	}
+	defer pipe.Close()
+	if _, err := pipe.Write([]byte(command + "\n")); err != nil {
+		return fmt.Errorf("failed to write to command pipe: %w", err)
+	}
+	return nil
+}
+
+func (t *pipeTransport) Close() error { return nil }
+
+// writerTransport writes newline-terminated commands to an arbitrary writer,
+// such as the stdin of a supervised server process.
+type writerTransport struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func (t *writerTransport) Name() string { return t.name }
+
+func (t *writerTransport) Send(command string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return errors.New("no server process attached")
+	}
+	if _, err := io.WriteString(t.w, command+"\n"); err != nil {
+		return fmt.Errorf("failed to write command to %s: %w", t.name, err)
+	}
+	return nil
+}
+
+func (t *writerTransport) Close() error { return nil }
+
+// RCON packet types (Source RCON protocol).
+const (
+	rconTypeAuth         int32 = 3
+	rconTypeExecCommand  int32 = 2
+	rconTypeAuthResponse int32 = 2
+)
+
+// rconTransport sends commands over the Source RCON protocol, as exposed by
+// several Bedrock server wrappers. The connection is kept open and
+// re-established on failure.
+type rconTransport struct {
+	addr     string
+	password string
+	mu       sync.Mutex
+	conn     net.Conn
+	nextID   int32
+}
+
+func (t *rconTransport) Name() string { return "rcon" }
+
+func (t *rconTransport) writePacket(id, typ int32, body string) error {
+	size := int32(4 + 4 + len(body) + 2)
+	buf := make([]byte, 0, size+4)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(size))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(id))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(typ))
+	buf = append(buf, body...)
+	buf = append(buf, 0, 0)
+	_, err := t.conn.Write(buf)
+	return err
+}
+
+func (t *rconTransport) readPacket() (id, typ int32, body string, err error) {
+	var sizeBuf [4]byte
+	if _, err = io.ReadFull(t.conn, sizeBuf[:]); err != nil {
+		return
+	}
+	size := int32(binary.LittleEndian.Uint32(sizeBuf[:]))
+	if size < 10 || size > 4096+10 {
+		err = fmt.Errorf("invalid rcon packet size %d", size)
+		return
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(t.conn, data); err != nil {
+		return
+	}
+	id = int32(binary.LittleEndian.Uint32(data[0:4]))
+	typ = int32(binary.LittleEndian.Uint32(data[4:8]))
+	body = strings.TrimRight(string(data[8:]), "\x00")
+	return
+}
+
+// connect dials and authenticates. Callers must hold t.mu.
+func (t *rconTransport) connect() error {
+	conn, err := net.DialTimeout("tcp", t.addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to rcon %s: %w", t.addr, err)
+	}
+	t.conn = conn
+	t.nextID++
+	authID := t.nextID
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	if err := t.writePacket(authID, rconTypeAuth, t.password); err != nil {
+		t.reset()
+		return err
+	}
+	// Servers may send an empty response value packet before the auth response.
+	for {
+		id, typ, _, err := t.readPacket()
+		if err != nil {
+			t.reset()
+			return fmt.Errorf("rcon authentication failed: %w", err)
+		}
+		if typ != rconTypeAuthResponse {
+			continue
+		}
+		if id == -1 {
+			t.reset()
+			return errors.New("rcon authentication rejected")
+		}
+		return nil
+	}
+}
+
+func (t *rconTransport) reset() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+func (t *rconTransport) Send(command string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Retry once on a stale connection.
+	for attempt := 0; attempt < 2; attempt++ {
+		if t.conn == nil {
+			if err := t.connect(); err != nil {
+				return err
+			}
+		}
+		t.nextID++
+		id := t.nextID
+		t.conn.SetDeadline(time.Now().Add(10 * time.Second))
+		err := t.writePacket(id, rconTypeExecCommand, command)
+		if err == nil {
+			_, _, _, err = t.readPacket()
+		}
+		if err == nil {
+			t.conn.SetDeadline(time.Time{})
+			return nil
+		}
+		log.Printf("RCON send failed, reconnecting: %v", err)
+		t.reset()
+	}
+	return fmt.Errorf("failed to send command over rcon to %s", t.addr)
+}
+
+func (t *rconTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset()
+	return nil
+}
+
+// wsTransport sends each command as a text message to a WebSocket console
+// endpoint exposed by a server wrapper.
+type wsTransport struct {
+	url  string
+	mu   sync.Mutex
+	conn *wsConn
+}
+
+func (t *wsTransport) Name() string { return "websocket" }
+
+func (t *wsTransport) Send(command string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		if t.conn == nil {
+			conn, err := dialWebSocket(t.url)
+			if err != nil {
+				return err
+			}
+			t.conn = conn
+			// Drain incoming messages so control frames are answered.
+			go func(c *wsConn) {
+				for {
+					if _, _, err := c.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+		err := t.conn.WriteText([]byte(command))
+		if err == nil {
+			return nil
+		}
+		log.Printf("WebSocket send failed, reconnecting: %v", err)
+		t.conn.conn.Close()
+		t.conn = nil
+	}
+	return fmt.Errorf("failed to send command over websocket to %s", t.url)
+}
+
+func (t *wsTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	trashItemFile      = "item.json"
+	trashDataDir       = "data"
+	trashReapInterval  = time.Hour
+	defaultTrashRetain = 7 * 24 * time.Hour
+)
+
+var (
+	trashDir   = filepath.Join(dataDir, "trash")
+	trashMutex sync.Mutex
+)
+
+// TrashItem is a pack or world folder moved to the trash instead of being
+// deleted.
+type TrashItem struct {
+	ID           string     `json:"id"`
+	Kind         string     `json:"kind"` // behavior-pack, resource-pack, world or world-db
+	Name         string     `json:"name"`
+	OriginalPath string     `json:"original_path"`
+	Reason       string     `json:"reason,omitempty"`
+	DeletedAt    time.Time  `json:"deleted_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// trashRetention returns how long trashed items are kept; zero keeps them
+// until they are restored or purged by hand.
+func trashRetention() time.Duration {
+	v := envOrDefault(trashRetentionEnv, defaultTrashRetain.String())
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Invalid %s %q, using %s", trashRetentionEnv, v, defaultTrashRetain)
+		return defaultTrashRetain
+	}
+	return d
+}
+
+// moveToTrash moves a folder into the trash. Missing folders are ignored.
+func moveToTrash(kind, path, reason string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+	item := TrashItem{
+		ID:           time.Now().Format("20060102-150405") + "-" + newUUID()[:8],
+		Kind:         kind,
+		Name:         filepath.Base(path),
+		OriginalPath: path,
+		Reason:       reason,
+		DeletedAt:    time.Now(),
+	}
+	if retention := trashRetention(); retention > 0 {
+		expires := item.DeletedAt.Add(retention)
+		item.ExpiresAt = &expires
+	}
+	dir := filepath.Join(trashDir, item.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := moveDir(path, filepath.Join(dir, trashDataDir)); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("moving %s to trash: %w", item.Name, err)
+	}
+	data, _ := json.MarshalIndent(item, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, trashItemFile), data, 0644); err != nil {
+		return err
+	}
+	log.Printf("Moved %s %s to trash as %s", kind, item.Name, item.ID)
+	return nil
+}
+
+// listTrash returns the trashed items, newest first. The caller holds
+// trashMutex.
+func listTrash() ([]TrashItem, error) {
+	entries, err := os.ReadDir(trashDir)
+	if os.IsNotExist(err) {
+		return []TrashItem{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	items := []TrashItem{}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(trashDir, e.Name(), trashItemFile))
+		if err != nil {
+			continue
+		}
+		var item TrashItem
+		if json.Unmarshal(data, &item) == nil && item.ID == e.Name() {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// findTrashItem looks up a trashed item by ID. The caller holds trashMutex.
+func findTrashItem(id string) (TrashItem, error) {
+	items, err := listTrash()
+	if err != nil {
+		return TrashItem{}, err
+	}
+	for _, item := range items {
+		if item.ID == id {
+			return item, nil
+		}
+	}
+	return TrashItem{}, os.ErrNotExist
+}
+
+// restoreTrashItem moves an item back to where it came from, refusing to
+// overwrite anything now in its place. The caller holds trashMutex.
+func restoreTrashItem(item TrashItem) error {
+	if _, err := os.Stat(item.OriginalPath); err == nil {
+		return fmt.Errorf("%s already exists", item.OriginalPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(item.OriginalPath), 0755); err != nil {
+		return err
+	}
+	dir := filepath.Join(trashDir, item.ID)
+	if err := moveDir(filepath.Join(dir, trashDataDir), item.OriginalPath); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// pruneTrash permanently deletes expired items.
+func pruneTrash() {
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+	items, err := listTrash()
+	if err != nil {
+		log.Printf("Error listing trash: %v", err)
+		return
+	}
+	for _, item := range items {
+		if item.ExpiresAt == nil || time.Now().Before(*item.ExpiresAt) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(trashDir, item.ID)); err != nil {
+			log.Printf("Error purging %s from trash: %v", item.ID, err)
+			continue
+		}
+		log.Printf("Purged %s %s from trash", item.Kind, item.Name)
+	}
+}
+
+// startTrashReaper purges expired trash every hour.
+func startTrashReaper() {
+	go func() {
+		for {
+			pruneTrash()
+			time.Sleep(trashReapInterval)
+		}
+	}()
+}
+
+// trashHandler lists the trash.
+func trashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+	items, err := listTrash()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"items": items, "retention": trashRetention().String()})
+}
+
+// trashItemHandler serves POST /trash/{id}/restore and DELETE /trash/{id},
+// which purges the item for good.
+func trashItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/trash/"), "/")
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+	item, err := findTrashItem(id)
+	if errors.Is(err, os.ErrNotExist) {
+		writeJSONError(w, http.StatusNotFound, "Trash item not found")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	switch {
+	case action == "restore" && r.Method == http.MethodPost:
+		if err := restoreTrashItem(item); err != nil {
+			writeJSONError(w, http.StatusConflict, err.Error())
+			return
+		}
+		log.Printf("Restored %s %s from trash", item.Kind, item.Name)
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Restored " + item.Name, "path": item.OriginalPath})
+	case action == "" && r.Method == http.MethodDelete:
+		if err := os.RemoveAll(filepath.Join(trashDir, item.ID)); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Trash item purged"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
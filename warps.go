@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const warpsStateFile = "warps.json"
+
+// Warp is a named server-wide teleport destination.
+type Warp struct {
+	Name      string  `json:"name"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Z         float64 `json:"z"`
+	Dimension string  `json:"dimension,omitempty"`
+}
+
+var (
+	warps      = make(map[string]Warp)
+	warpsMutex sync.Mutex
+)
+
+func saveWarps() {
+	if err := saveState(warpsStateFile, warps); err != nil {
+		log.Printf("Error saving warps: %v", err)
+	}
+}
+
+// teleportToWarp moves a player to a warp.
+func teleportToWarp(player string, wp Warp) error {
+	cmd := fmt.Sprintf("tp %s %.2f %.2f %.2f", quotePlayer(player), wp.X, wp.Y, wp.Z)
+	if dim := strings.TrimPrefix(wp.Dimension, "minecraft:"); dim != "" {
+		cmd = "execute in " + dim + " run " + cmd
+	}
+	return sendServerCommand(cmd)
+}
+
+// warpsHandler lists warps (GET) or creates one (POST, 409 if it exists).
+func warpsHandler(w http.ResponseWriter, r *http.Request) {
+	warpsMutex.Lock()
+	defer warpsMutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"warps": warps})
+	case http.MethodPost:
+		var wp Warp
+		if err := json.NewDecoder(r.Body).Decode(&wp); err != nil || !validName(wp.Name) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if _, ok := warps[wp.Name]; ok {
+			writeJSONError(w, http.StatusConflict, "Warp already exists")
+			return
+		}
+		warps[wp.Name] = wp
+		saveWarps()
+		writeJSONResponse(w, http.StatusCreated, wp)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// warpHandler serves GET, PUT (upsert) and DELETE /warps/{name} and
+// POST /warps/{name}/teleport with {"player": ...}.
+func warpHandler(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/warps/"), "/")
+	warpsMutex.Lock()
+	wp, exists := warps[name]
+	if action == "teleport" {
+		warpsMutex.Unlock()
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		if !exists {
+			writeJSONError(w, http.StatusNotFound, "Warp not found")
+			return
+		}
+		var req struct {
+			Player string `json:"player"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !validPlayerName(req.Player) {
+			writeJSONError(w, http.StatusBadRequest, "player is required")
+			return
+		}
+		if err := teleportToWarp(req.Player, wp); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to teleport player")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Teleported " + req.Player + " to " + name})
+		return
+	}
+	defer warpsMutex.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			writeJSONError(w, http.StatusNotFound, "Warp not found")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, wp)
+	case http.MethodPut:
+		var req Warp
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !validName(name) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		req.Name = name
+		warps[name] = req
+		saveWarps()
+		status := http.StatusOK
+		if !exists {
+			status = http.StatusCreated
+		}
+		writeJSONResponse(w, status, req)
+	case http.MethodDelete:
+		if !exists {
+			writeJSONError(w, http.StatusNotFound, "Warp not found")
+			return
+		}
+		delete(warps, name)
+		saveWarps()
+		writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Warp deleted"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	watchdogCheckInterval = 10 * time.Second
+	// watchdogStartGrace is how long a starting server may take to answer.
+	watchdogStartGrace = 3 * time.Minute
+	// watchdogProbeGrace is how long the server may take to answer the
+	// liveness probe command.
+	watchdogProbeGrace     = 15 * time.Second
+	watchdogProbeCommand   = "list"
+	watchdogLogTailLines   = 50
+	defaultWatchdogMaxRuns = 3 // restarts per hour
+)
+
+// Watchdog restart policies.
+const (
+	watchdogPolicyRestart = "restart" // kill a hung server and start it again
+	watchdogPolicyAlert   = "alert"   // only alert
+)
+
+var (
+	// watchdogStopped is set while the server is deliberately down, so its
+	// absence is not mistaken for a crash.
+	watchdogStopped bool
+	// watchdogSeenUp is set once the server answered since it last
+	// (re)started; the watchdog only guards a server it has seen running.
+	watchdogSeenUp     bool
+	watchdogGraceUntil time.Time
+	watchdogRestarts   []time.Time
+	watchdogMutex      sync.Mutex
+)
+
+// expectServer records that the server was deliberately started or
+// stopped. Starting gives it watchdogStartGrace to come up.
+func expectServer(up bool) {
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+	watchdogStopped = !up
+	if up {
+		watchdogGraceUntil = time.Now().Add(watchdogStartGrace)
+	}
+}
+
+// serverLogTail returns the last lines of server output, for diagnostics.
+func serverLogTail(n int) []string {
+	consoleMutex.Lock()
+	defer consoleMutex.Unlock()
+	lines := []string{}
+	for _, msg := range consoleLog.tail(n, func(ConsoleMessage) bool { return true }) {
+		lines = append(lines, msg.Line)
+	}
+	return lines
+}
+
+// reportServerCrash alerts that the server went down unexpectedly, with
+// the end of its output, and waits for it to come back before guarding
+// it again.
+func reportServerCrash(event, reason, action string) {
+	watchdogMutex.Lock()
+	watchdogSeenUp = false
+	watchdogGraceUntil = time.Now().Add(watchdogStartGrace)
+	watchdogMutex.Unlock()
+	fields := map[string]interface{}{"reason": reason, "action": action}
+	if serverOutputAvailable() {
+		fields["log_tail"] = serverLogTail(watchdogLogTailLines)
+	}
+	sendAlert(event, fmt.Sprintf("The server %s; %s", reason, action), fields)
+}
+
+// killServer ends a server that no longer responds.
+func killServer() error {
+	if supervisor != nil {
+		return supervisor.kill()
+	}
+	pid, err := findServerPID()
+	if err != nil {
+		return nil // already gone
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	log.Printf("Killing unresponsive server process %d", pid)
+	return p.Kill()
+}
+
+// recoverServer applies the restart policy and describes what it did.
+func recoverServer(policy string, maxRestarts int) string {
+	if policy == watchdogPolicyAlert {
+		return "not restarted (policy is alert)"
+	}
+	watchdogMutex.Lock()
+	now := time.Now()
+	recent := watchdogRestarts[:0]
+	for _, t := range watchdogRestarts {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	watchdogRestarts = recent
+	if len(recent) >= maxRestarts {
+		watchdogMutex.Unlock()
+		return fmt.Sprintf("not restarted: already restarted %d times in the last hour", len(recent))
+	}
+	watchdogRestarts = append(watchdogRestarts, now)
+	watchdogMutex.Unlock()
+
+	if err := killServer(); err != nil {
+		return "failed to kill it: " + err.Error()
+	}
+	if !canStartServer() {
+		return "killed it for the container restart policy to start again"
+	}
+	if err := waitForServerDown(time.Minute); err != nil {
+		return "failed to restart: " + err.Error()
+	}
+	if err := startServer(); err != nil {
+		return "failed to restart: " + err.Error()
+	}
+	return "restarted it"
+}
+
+// startWatchdog guards the server when BEDROCK_API_WATCHDOG_TIMEOUT is set.
+// A server that stops answering pings for that long has crashed or hung;
+// one that answers pings but has printed nothing for that long is sent a
+// harmless command and has hung if it still prints nothing. Either way an
+// alert carries the end of the server output and, with the default
+// BEDROCK_API_WATCHDOG_POLICY of restart, the server is killed and started
+// again, at most BEDROCK_API_WATCHDOG_MAX_RESTARTS times an hour. Stops made
+// through the API and clean shutdowns in the log are not crashes.
+func startWatchdog() {
+	raw := os.Getenv(watchdogTimeoutEnv)
+	if raw == "" {
+		return
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout < watchdogCheckInterval {
+		log.Printf("Watchdog disabled: %s must be a duration of at least %s", watchdogTimeoutEnv, watchdogCheckInterval)
+		return
+	}
+	policy := envOrDefault(watchdogPolicyEnv, watchdogPolicyRestart)
+	if policy != watchdogPolicyRestart && policy != watchdogPolicyAlert {
+		log.Printf("Invalid %s %q, using %s", watchdogPolicyEnv, policy, watchdogPolicyRestart)
+		policy = watchdogPolicyRestart
+	}
+	maxRestarts, err := strconv.Atoi(envOrDefault(watchdogRestartsEnv, strconv.Itoa(defaultWatchdogMaxRuns)))
+	if err != nil || maxRestarts < 0 {
+		log.Printf("Invalid %s, using %d", watchdogRestartsEnv, defaultWatchdogMaxRuns)
+		maxRestarts = defaultWatchdogMaxRuns
+	}
+	log.Printf("Watchdog enabled: timeout %s, policy %s", timeout, policy)
+
+	// Clean starts and stops seen in the log, whoever made them.
+	events := make(chan BusEvent, 16)
+	busMutex.Lock()
+	busSubscribers[events] = struct{}{}
+	busMutex.Unlock()
+	go func() {
+		for ev := range events {
+			switch ev.Type {
+			case "server_start":
+				expectServer(true)
+			case "server_stop":
+				expectServer(false)
+			}
+		}
+	}()
+
+	go func() {
+		var downSince, probeSent time.Time
+		for range time.Tick(watchdogCheckInterval) {
+			now := time.Now()
+			up := serverUp()
+			watchdogMutex.Lock()
+			if up && !watchdogStopped {
+				watchdogSeenUp = true
+			}
+			guarded := watchdogSeenUp && !watchdogStopped && now.After(watchdogGraceUntil)
+			watchdogMutex.Unlock()
+			if !guarded || (supervisor != nil && supervisor.pid() == 0) {
+				// The supervisor restarts a server that exited by itself.
+				downSince, probeSent = time.Time{}, time.Time{}
+				continue
+			}
+
+			event, reason := "", ""
+			if up {
+				downSince = time.Time{}
+				if serverOutputAvailable() {
+					consoleMutex.Lock()
+					var last time.Time
+					if tail := consoleLog.tail(1, func(ConsoleMessage) bool { return true }); len(tail) == 1 {
+						last = tail[0].Time
+					}
+					consoleMutex.Unlock()
+					switch {
+					case !probeSent.IsZero() && last.After(probeSent):
+						probeSent = time.Time{}
+					case !probeSent.IsZero() && now.Sub(probeSent) >= watchdogProbeGrace:
+						event, reason = "server_hang", fmt.Sprintf("printed nothing for %s, even in answer to %q", now.Sub(last).Round(time.Second), watchdogProbeCommand)
+					case probeSent.IsZero() && now.Sub(last) >= timeout:
+						probeSent = now
+						if err := sendServerCommand(watchdogProbeCommand); err != nil {
+							log.Printf("Watchdog probe failed: %v", err)
+						}
+					}
+				}
+			} else if downSince.IsZero() {
+				downSince = now
+			} else if now.Sub(downSince) >= timeout {
+				event, reason = "server_crash", fmt.Sprintf("has not answered pings for %s", now.Sub(downSince).Round(time.Second))
+			}
+			if event == "" {
+				continue
+			}
+			downSince, probeSent = time.Time{}, time.Time{}
+			log.Printf("Watchdog: the server %s", reason)
+			reportServerCrash(event, reason, recoverServer(policy, maxRestarts))
+		}
+	}()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultMapRadius = 8
+	maxMapRadius     = 32
+)
+
+// biomeColors are the map colours of the base biomes. Mutated variants
+// (ID + 128) are drawn as a lighter shade of their base.
+var biomeColors = map[int]uint32{
+	0: 0x000070, 1: 0x8db360, 2: 0xfa9418, 3: 0x606060, 4: 0x056621,
+	5: 0x0b6659, 6: 0x07f9b2, 7: 0x0000ff, 8: 0xbf3b3b, 9: 0x8080ff,
+	10: 0x7070d6, 11: 0xa0a0ff, 12: 0xffffff, 13: 0xa0a0a0, 14: 0xff00ff,
+	15: 0xa000ff, 16: 0xfade55, 17: 0xd25f12, 18: 0x22551c, 19: 0x163933,
+	20: 0x72789a, 21: 0x537b09, 22: 0x2c4205, 23: 0x628b17, 24: 0x000030,
+	25: 0xa2a284, 26: 0xfaf0c0, 27: 0x307444, 28: 0x1f5f32, 29: 0x40511a,
+	30: 0x31554a, 31: 0x243f36, 32: 0x596651, 33: 0x454f3e, 34: 0x507050,
+	35: 0xbdb25f, 36: 0xa79d64, 37: 0xd94515, 38: 0xb09765, 39: 0xca8c65,
+	40: 0x0000ac, 41: 0x000050, 42: 0x000090, 43: 0x000040, 44: 0x202070,
+	45: 0x202038, 46: 0x7070d6, 47: 0x404090, 48: 0x768e14, 49: 0x3b470a,
+	178: 0x5e3830, 179: 0xdd0808, 180: 0x49907b, 181: 0x403636,
+	182: 0xdcdcc8, 183: 0xb0b3ce, 184: 0xc4c4c4, 185: 0x47726c,
+	186: 0x60a445, 187: 0x7ba331, 188: 0x7b6b55, 189: 0x7b8f74,
+	190: 0x031f29, 191: 0x2ccc8e, 192: 0xff91c8, 193: 0x696d95,
+}
+
+// biomeColor returns a biome's map colour; unknown biomes are magenta.
+func biomeColor(id int) color.NRGBA {
+	rgb, ok := biomeColors[id]
+	lighten := false
+	if !ok && id >= 128 {
+		rgb, ok = biomeColors[id-128]
+		lighten = true
+	}
+	if !ok {
+		rgb = 0xff00ff
+	}
+	c := color.NRGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}
+	if lighten {
+		c.R, c.G, c.B = c.R+(0xff-c.R)/4, c.G+(0xff-c.G)/4, c.B+(0xff-c.B)/4
+	}
+	return c
+}
+
+// readAreaBiomes decodes the biome records of the saved chunks in a square
+// of chunks, keyed by chunk position. Each chunk column is one scan of the
+// database, since chunk keys start with the chunk's x. Chunks that fail to
+// decode are left out.
+func readAreaBiomes(db *ldbDB, minX, maxX, minZ, maxZ int32, dim biomeDimension) (map[[2]int32]*chunkBiomes, error) {
+	keyLen := 9
+	if dim.id != 0 {
+		keyLen = 13
+	}
+	chunks := map[[2]int32]*chunkBiomes{}
+	for cx := minX; cx <= maxX; cx++ {
+		prefix := binary.LittleEndian.AppendUint32(nil, uint32(cx))
+		err := db.scan(prefix, func(e ldbEntry) error {
+			k := e.Key
+			if len(k) != keyLen || (dim.id != 0 && int32(binary.LittleEndian.Uint32(k[8:])) != dim.id) {
+				return nil
+			}
+			cz := int32(binary.LittleEndian.Uint32(k[4:]))
+			pos := [2]int32{cx, cz}
+			if cz < minZ || cz > maxZ || chunks[pos] != nil {
+				return nil
+			}
+			var chunk *chunkBiomes
+			var err error
+			switch k[keyLen-1] {
+			case chunkTagData3D:
+				chunk, err = parseData3D(e.Value, dim)
+			case chunkTagData2D:
+				chunk, err = parseData2D(e.Value, dim)
+			default:
+				return nil
+			}
+			if err != nil {
+				log.Printf("Skipping chunk %d,%d on the map: %v", cx, cz, err)
+				return nil
+			}
+			chunks[pos] = chunk
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+// renderBiomeMap draws the surface biomes of the saved chunks within radius
+// chunks of a block position, one pixel per block with north up. Chunks not
+// yet generated are transparent. It returns the image and the block
+// position of its top-left pixel.
+func renderBiomeMap(x, z, radius int, dim biomeDimension) (*image.NRGBA, int, int, error) {
+	centerX, centerZ := int32(x>>4), int32(z>>4)
+	minX, minZ := centerX-int32(radius), centerZ-int32(radius)
+	maxX, maxZ := centerX+int32(radius), centerZ+int32(radius)
+	var chunks map[[2]int32]*chunkBiomes
+	err := openWorldDB(func(db *ldbDB) error {
+		var err error
+		chunks, err = readAreaBiomes(db, minX, maxX, minZ, maxZ, dim)
+		return err
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	size := (2*radius + 1) * 16
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for pos, chunk := range chunks {
+		ox, oz := int(pos[0]-minX)*16, int(pos[1]-minZ)*16
+		for lz := 0; lz < 16; lz++ {
+			for lx := 0; lx < 16; lx++ {
+				if id, err := chunk.surfaceBiome(lx, lz); err == nil {
+					img.SetNRGBA(ox+lx, oz+lz, biomeColor(id))
+				}
+			}
+		}
+	}
+	return img, int(minX) * 16, int(minZ) * 16, nil
+}
+
+// mapHandler serves GET /map?x=&z=&radius=&dimension=: a PNG map of the
+// surface biomes around a block position (default the world spawn), radius
+// in chunks (default 8, at most 32). X-Map-Origin-X and X-Map-Origin-Z
+// give the block position of the top-left pixel so a page can place
+// markers on it.
+func mapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	q := r.URL.Query()
+	x, z := 0, 0
+	if level, err := readActiveLevelDat(); err == nil {
+		x, _ = levelInt(level, "SpawnX")
+		z, _ = levelInt(level, "SpawnZ")
+	}
+	parse := func(key string, def int) (int, bool) {
+		v := q.Get(key)
+		if v == "" {
+			return def, true
+		}
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	x, okX := parse("x", x)
+	z, okZ := parse("z", z)
+	radius, okR := parse("radius", defaultMapRadius)
+	dimension := q.Get("dimension")
+	if dimension == "" {
+		dimension = "overworld"
+	}
+	dim, ok := biomeDimensions[dimension]
+	if !okX || !okZ || !okR || !ok || radius < 0 || radius > maxMapRadius {
+		writeJSONError(w, http.StatusBadRequest, "Invalid x, z, radius or dimension")
+		return
+	}
+	img, originX, originZ, err := renderBiomeMap(x, z, radius, dim)
+	if err != nil {
+		log.Printf("Error rendering map: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render map")
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render map")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Map-Origin-X", strconv.Itoa(originX))
+	w.Header().Set("X-Map-Origin-Z", strconv.Itoa(originZ))
+	w.Write(buf.Bytes())
+}
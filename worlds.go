@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	worldsDir    = filepath.Join(dataDir, "worlds")
+	templatesDir = filepath.Join(dataDir, "world_templates")
+
+	safeNamePattern = regexp.MustCompile(`^[A-Za-z0-9 _.-]+$`)
+)
+
+// validName reports whether name is safe to use as a single path element.
+func validName(name string) bool {
+	return safeNamePattern.MatchString(name) && name != "." && name != ".." && strings.TrimSpace(name) == name
+}
+
+// currentLevelName returns the active world's folder name.
+func currentLevelName() (string, error) {
+	worldFolder, err := getWorldFolder()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(worldFolder), nil
+}
+
+// replaceWorldFromTemplate replaces the world folder levelName with a fresh
+// copy of the named template. The server must be stopped.
+func replaceWorldFromTemplate(template, levelName string) error {
+	if !validName(template) || !validName(levelName) {
+		return errors.New("invalid template or world name")
+	}
+	src := filepath.Join(templatesDir, template)
+	if info, err := os.Stat(src); err != nil || !info.IsDir() {
+		return fmt.Errorf("template %q not found", template)
+	}
+	dst := filepath.Join(worldsDir, levelName)
+	if err := moveToTrash("world", dst, "replaced from template "+template); err != nil {
+		return fmt.Errorf("failed to remove world %s: %w", levelName, err)
+	}
+	if err := copyDir(src, dst); err != nil {
+		return fmt.Errorf("failed to copy template: %w", err)
+	}
+	log.Printf("World %s replaced from template %s", levelName, template)
+	return nil
+}
+
+// worldTemplatesHandler lists templates (GET) or snapshots the current
+// world as a new template (POST ?name=<template>).
+func worldTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		templates, err := listDirectories(templatesDir)
+		if err != nil && !os.IsNotExist(err) {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to list world templates")
+			return
+		}
+		if templates == nil {
+			templates = []string{}
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"templates": templates})
+	case http.MethodPost:
+		name := r.URL.Query().Get("name")
+		if !validName(name) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid template name")
+			return
+		}
+		worldFolder, err := getWorldFolder()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Error determining world folder")
+			return
+		}
+		dst := filepath.Join(templatesDir, name)
+		if _, err := os.Stat(dst); err == nil {
+			writeJSONError(w, http.StatusConflict, "Template already exists")
+			return
+		}
+		if err := copyWorldConsistent(worldFolder, dst); err != nil {
+			log.Printf("Error creating world template: %v", err)
+			os.RemoveAll(dst)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to create template")
+			return
+		}
+		writeJSONResponse(w, http.StatusCreated, map[string]string{"message": "Template created", "template": name})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// WorldInfo describes a world folder. Generated is false until the server
+// has created level.dat in it.
+type WorldInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Modified  time.Time `json:"modified"`
+	Active    bool      `json:"active"`
+	Generated bool      `json:"generated"`
+}
+
+// CreateWorldRequest creates a world. GameMode is the server-wide gamemode
+// property and is left unchanged when empty.
+type CreateWorldRequest struct {
+	Name     string `json:"name"`
+	Seed     string `json:"seed,omitempty"`
+	GameMode string `json:"gamemode,omitempty"`
+}
+
+// worldInfo reads a world folder's size and the newest modification time
+// of anything in it.
+func worldInfo(name, active string) (WorldInfo, error) {
+	dir := filepath.Join(worldsDir, name)
+	info := WorldInfo{Name: name, Active: name == active}
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			info.Size += fi.Size()
+		}
+		if fi.ModTime().After(info.Modified) {
+			info.Modified = fi.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return info, err
+	}
+	_, err = os.Stat(filepath.Join(dir, "level.dat"))
+	info.Generated = err == nil
+	return info, nil
+}
+
+// applyWhileStopped runs fn, stopping the server around it if it is running.
+func applyWhileStopped(fn func() error) error {
+	if serverUp() {
+		return withServerStopped(fn)
+	}
+	return fn()
+}
+
+// worldsHandler lists the world folders (GET) or creates a world (POST).
+// The server generates a world from level-seed when it starts with it as
+// level-name, so a new world becomes the active one and a running server
+// is restarted.
+func worldsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		names, err := listDirectories(worldsDir)
+		if err != nil && !os.IsNotExist(err) {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to list worlds")
+			return
+		}
+		active, _ := currentLevelName()
+		worlds := []WorldInfo{}
+		for _, name := range names {
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			info, err := worldInfo(name, active)
+			if err != nil {
+				log.Printf("Error reading world %s: %v", name, err)
+				continue
+			}
+			worlds = append(worlds, info)
+		}
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"worlds": worlds, "active": active})
+	case http.MethodPost:
+		requireAdmin(createWorldHandler)(w, r)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// createWorldHandler serves POST /worlds.
+func createWorldHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateWorldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if !validName(req.Name) || strings.HasPrefix(req.Name, ".") {
+		writeJSONError(w, http.StatusBadRequest, "Invalid world name")
+		return
+	}
+	if strings.ContainsAny(req.Seed, "\n\r") {
+		writeJSONError(w, http.StatusBadRequest, "Invalid seed")
+		return
+	}
+	req.GameMode = strings.ToLower(req.GameMode)
+	if req.GameMode != "" && req.GameMode != "survival" && req.GameMode != "creative" && req.GameMode != "adventure" {
+		writeJSONError(w, http.StatusBadRequest, "gamemode must be survival, creative or adventure")
+		return
+	}
+	dir := filepath.Join(worldsDir, req.Name)
+	if _, err := os.Stat(dir); err == nil {
+		writeJSONError(w, http.StatusConflict, "World already exists")
+		return
+	}
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	props := map[string]string{"level-name": req.Name, "level-seed": req.Seed}
+	if req.GameMode != "" {
+		props["gamemode"] = req.GameMode
+	}
+	err := applyWhileStopped(func() error {
+		// Like provisioning, make the folder up front so packs can be
+		// activated before the server generates the world.
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return setServerProperties(props)
+	})
+	if err != nil {
+		log.Printf("Error creating world %s: %v", req.Name, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create world: "+err.Error())
+		return
+	}
+	log.Printf("Created world %s", req.Name)
+	writeJSONResponse(w, http.StatusCreated, map[string]interface{}{"message": "World created", "world": req.Name, "active": true})
+}
+
+// worldHandler serves routes under /worlds/{name}: GET shows a world,
+// DELETE moves it to the trash, POST .../rename and .../clone take
+// {"name": "<new name>"}, POST .../activate switches to it and GET
+// .../export downloads it. The active
+// world cannot be deleted or renamed.
+func worldHandler(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/worlds/"), "/")
+	if !validName(name) || strings.HasPrefix(name, ".") {
+		writeJSONError(w, http.StatusBadRequest, "Invalid world name")
+		return
+	}
+	if info, err := os.Stat(filepath.Join(worldsDir, name)); err != nil || !info.IsDir() {
+		writeJSONError(w, http.StatusNotFound, "World not found")
+		return
+	}
+	switch action {
+	case "":
+		switch r.Method {
+		case http.MethodGet:
+			active, _ := currentLevelName()
+			info, err := worldInfo(name, active)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			writeJSONResponse(w, http.StatusOK, info)
+		case http.MethodDelete:
+			requireAdmin(func(w http.ResponseWriter, r *http.Request) { deleteWorldHandler(w, name) })(w, r)
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+	case "rename", "clone":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { copyWorldHandler(w, r, name, action == "rename") })(w, r)
+	case "activate":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { activateWorldHandler(w, r, name) })(w, r)
+	case "export":
+		worldExportHandler(w, r, name)
+	case "compact":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) { compactWorldHandler(w, r, name) })(w, r)
+	default:
+		writeJSONError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// deleteWorldHandler moves a world to the trash.
+func deleteWorldHandler(w http.ResponseWriter, name string) {
+	if active, _ := currentLevelName(); active == name {
+		writeJSONError(w, http.StatusConflict, "Cannot delete the active world")
+		return
+	}
+	if err := moveToTrash("world", filepath.Join(worldsDir, name), "deleted"); err != nil {
+		log.Printf("Error deleting world %s: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete world")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "World moved to trash", "world": name})
+}
+
+// copyWorldHandler renames or clones a world. Clones of the active world
+// are taken consistently while the server runs. The copy's levelname.txt,
+// the name shown on devices after an export, is updated to the new name.
+func copyWorldHandler(w http.ResponseWriter, r *http.Request, name string, rename bool) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if !validName(req.Name) || strings.HasPrefix(req.Name, ".") {
+		writeJSONError(w, http.StatusBadRequest, "Invalid world name")
+		return
+	}
+	src, dst := filepath.Join(worldsDir, name), filepath.Join(worldsDir, req.Name)
+	if _, err := os.Stat(dst); err == nil {
+		writeJSONError(w, http.StatusConflict, "World already exists")
+		return
+	}
+	var err error
+	if rename {
+		if active, _ := currentLevelName(); active == name {
+			writeJSONError(w, http.StatusConflict, "Cannot rename the active world")
+			return
+		}
+		err = os.Rename(src, dst)
+	} else if err = copyWorldConsistent(src, dst); err != nil {
+		os.RemoveAll(dst)
+	}
+	if err != nil {
+		log.Printf("Error copying world %s to %s: %v", name, req.Name, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to copy world")
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dst, "levelname.txt")); err == nil {
+		os.WriteFile(filepath.Join(dst, "levelname.txt"), []byte(req.Name), 0644)
+	}
+	verb := "cloned"
+	if rename {
+		verb = "renamed"
+	}
+	log.Printf("World %s %s to %s", name, verb, req.Name)
+	writeJSONResponse(w, http.StatusCreated, map[string]string{"message": "World " + verb, "world": req.Name})
+}
+
+// activateWorldHandler makes a world the active one by setting level-name.
+// With ?restart=true a running server is restarted onto it at once;
+// otherwise the switch takes effect on the next start.
+func activateWorldHandler(w http.ResponseWriter, r *http.Request, name string) {
+	worldOpMutex.Lock()
+	defer worldOpMutex.Unlock()
+	if current, _ := currentLevelName(); current == name {
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "World already active", "world": name, "restarted": false})
+		return
+	}
+	apply := func() error { return setServerProperty("level-name", name) }
+	running := serverUp()
+	restart := running && r.URL.Query().Get("restart") == "true"
+	var err error
+	if restart {
+		kickAllPlayers("Switching to world " + name)
+		err = withServerStopped(apply)
+	} else {
+		err = apply()
+	}
+	if err != nil {
+		log.Printf("Error activating world %s: %v", name, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to activate world: "+err.Error())
+		return
+	}
+	log.Printf("Activated world %s", name)
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"message":          "World activated",
+		"world":            name,
+		"restarted":        restart,
+		"restart_required": running && !restart,
+	})
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file holds a small YAML reader and writer covering the subset used by
+// the declarative config file: block mappings and sequences, plain and quoted
+// scalars, literal (|) and folded (>) blocks, comments, and JSON-style flow
+// collections. Anchors, tags and multi-document streams are not supported.
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+	raw   []string
+}
+
+// stripYAMLComment removes a trailing comment that is not inside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return strings.TrimRight(s, " \t")
+}
+
+// parseYAML decodes a document into maps, slices and scalars compatible with
+// encoding/json, so the result can be re-marshalled into typed structs.
+func parseYAML(data []byte) (interface{}, error) {
+	raw := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	p := &yamlParser{raw: raw}
+	for i, l := range raw {
+		if strings.HasPrefix(strings.TrimSpace(l), "---") && len(p.lines) == 0 {
+			continue
+		}
+		if lead := l[:len(l)-len(strings.TrimLeft(l, " \t"))]; strings.Contains(lead, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := stripYAMLComment(l)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: indent, text: strings.TrimSpace(text)})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return v, nil
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	if _, _, ok := splitYAMLKey(p.lines[p.pos].text); ok {
+		return p.parseMap(indent)
+	}
+	l := p.lines[p.pos]
+	p.pos++
+	return parseYAMLScalar(l.text)
+}
+
+func (p *yamlParser) parseSeq(indent int) (interface{}, error) {
+	out := []interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !isSeqItem(l.text) {
+			if l.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+			}
+			break
+		}
+		content := strings.TrimSpace(strings.TrimPrefix(l.text, "-"))
+		if content == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.parseBlock(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			} else {
+				out = append(out, nil)
+			}
+			continue
+		}
+		// Re-read the item content as if it started its own block, so that
+		// "- key: value" begins a mapping indented past the dash.
+		itemIndent := indent + (len(l.text) - len(content))
+		p.lines[p.pos] = yamlLine{num: l.num, indent: itemIndent, text: content}
+		v, err := p.parseValueAt(itemIndent)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// parseValueAt parses the block starting at the current line, which is known
+// to be at indent.
+func (p *yamlParser) parseValueAt(indent int) (interface{}, error) {
+	l := p.lines[p.pos]
+	if isSeqItem(l.text) {
+		return p.parseSeq(indent)
+	}
+	if _, _, ok := splitYAMLKey(l.text); ok {
+		return p.parseMap(indent)
+	}
+	p.pos++
+	return parseYAMLScalar(l.text)
+}
+
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	out := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent {
+			if l.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+			}
+			break
+		}
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", l.num)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+		switch {
+		case rest == "|" || rest == ">" || rest == "|-" || rest == ">-":
+			out[key] = p.blockScalar(l, rest)
+		case rest != "":
+			v, err := parseYAMLScalar(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", l.num, err)
+			}
+			out[key] = v
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text):
+			v, err := p.parseSeq(indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		default:
+			out[key] = nil
+		}
+	}
+	return out, nil
+}
+
+// blockScalar reads a literal or folded block following header line l.
+// Comments are not stripped inside blocks, so it works from the raw lines.
+func (p *yamlParser) blockScalar(l yamlLine, style string) string {
+	var parts []string
+	blockIndent := -1
+	i := l.num // raw index of the line after the header
+	for ; i < len(p.raw); i++ {
+		line := p.raw[i]
+		if strings.TrimSpace(line) == "" {
+			parts = append(parts, "")
+			continue
+		}
+		ind := len(line) - len(strings.TrimLeft(line, " "))
+		if ind <= l.indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = ind
+		}
+		if ind < blockIndent {
+			break
+		}
+		parts = append(parts, line[blockIndent:])
+	}
+	for p.pos < len(p.lines) && p.lines[p.pos].num <= i {
+		p.pos++
+	}
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	sep := "\n"
+	if style[0] == '>' {
+		sep = " "
+	}
+	s := strings.Join(parts, sep)
+	if !strings.HasSuffix(style, "-") {
+		s += "\n"
+	}
+	return s
+}
+
+// splitYAMLKey splits "key: value" outside of quotes and flow collections.
+func splitYAMLKey(text string) (string, string, bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key := strings.TrimSpace(text[:i])
+			if k, err := parseYAMLScalar(key); err == nil {
+				if s, ok := k.(string); ok {
+					key = s
+				}
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// parseYAMLScalar parses a scalar or a JSON-style flow collection.
+func parseYAMLScalar(s string) (interface{}, error) {
+	switch {
+	case s == "" || s == "~" || s == "null" || s == "Null" || s == "NULL":
+		return nil, nil
+	case s == "true" || s == "True" || s == "TRUE":
+		return true, nil
+	case s == "false" || s == "False" || s == "FALSE":
+		return false, nil
+	case s[0] == '"':
+		return strconv.Unquote(s)
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[' || s[0] == '{':
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			return v, nil
+		}
+		if s[0] == '[' && s[len(s)-1] == ']' {
+			out := []interface{}{}
+			inner := strings.TrimSpace(s[1 : len(s)-1])
+			if inner == "" {
+				return out, nil
+			}
+			for _, item := range strings.Split(inner, ",") {
+				v, err := parseYAMLScalar(strings.TrimSpace(item))
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("unsupported flow collection %s", s)
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return json.Number(s), nil
+	}
+	return s, nil
+}
+
+// encodeYAML writes a JSON-compatible value as block YAML with sorted keys.
+func encodeYAML(v interface{}) []byte {
+	var b strings.Builder
+	writeYAML(&b, v, 0, false)
+	return []byte(b.String())
+}
+
+func yamlScalar(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case nil:
+		return "null", true
+	case bool:
+		return strconv.FormatBool(t), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case json.Number:
+		return t.String(), true
+	case string:
+		if plain, err := parseYAMLScalar(t); err == nil && plain == interface{}(t) && t == strings.TrimSpace(t) &&
+			!strings.ContainsAny(t, ":#\n\"'") && !strings.ContainsAny(t[:1], "-*&!|>%@`{[,?") {
+			return t, true
+		}
+		return strconv.Quote(t), true
+	case map[string]interface{}:
+		if len(t) == 0 {
+			return "{}", true
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			return "[]", true
+		}
+	}
+	return "", false
+}
+
+// writeYAML writes v at indent. inline is set when the first line continues
+// a "- " sequence marker.
+func writeYAML(b *strings.Builder, v interface{}, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if !(inline && i == 0) {
+				b.WriteString(pad)
+			}
+			key, _ := yamlScalar(k)
+			b.WriteString(key + ":")
+			if s, ok := yamlScalar(t[k]); ok {
+				b.WriteString(" " + s + "\n")
+				continue
+			}
+			b.WriteString("\n")
+			writeYAML(b, t[k], indent+2, false)
+		}
+	case []interface{}:
+		for i, item := range t {
+			if !(inline && i == 0) {
+				b.WriteString(pad)
+			}
+			b.WriteString("- ")
+			if s, ok := yamlScalar(item); ok {
+				b.WriteString(s + "\n")
+				continue
+			}
+			writeYAML(b, item, indent+2, true)
+		}
+	default:
+		s, _ := yamlScalar(v)
+		b.WriteString(pad + s + "\n")
+	}
+}